@@ -3,13 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path"
 	"strings"
-	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/direct-dev-ru/linux-command-gpt/config"
 	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+	"github.com/direct-dev-ru/linux-command-gpt/validation"
 )
 
 // ExplainDeps инъекция зависимостей для вывода и окружения
@@ -25,26 +25,82 @@ type ExplainDeps struct {
 
 // ShowDetailedExplanation делает дополнительный запрос с подробным описанием и альтернативами
 func ShowDetailedExplanation(command string, gpt3 gpt.Gpt3, system, originalCmd string, timeout int, level int, deps ExplainDeps) {
+	verbosePrompt, ask := explanationPrompts(originalCmd, command, level)
+	processExplanation(verbosePrompt, ask, gpt3, timeout, deps, originalCmd, command, system, level, func() {
+		ShowDetailedExplanation(command, gpt3, system, originalCmd, timeout, level, deps)
+	})
+}
+
+// Explain запрашивает подробное объяснение команды без меню и сохранения
+// (для неинтерактивных режимов --json/--print с --explain)
+func Explain(command string, gpt3 gpt.Gpt3, originalCmd string, timeout int, level int) (string, error) {
+	verbosePrompt, ask := explanationPrompts(originalCmd, command, level)
+	if config.AppConfig.MainFlags.Debug {
+		printVerboseDebugInfo(verbosePrompt, ask, gpt3, timeout, level)
+	}
+	detailed := gpt.NewGpt3(gpt3.ProviderType, config.AppConfig.Host, gpt3.ApiKey, gpt3.Model, verbosePrompt, 0.2, timeout)
+	return detailed.Complete(ask)
+}
+
+// explanationPrompts возвращает промпт подробности по уровню и ask на языке промптов
+func explanationPrompts(originalCmd, command string, level int) (string, string) {
+	verbosePrompt, lang := verbosePromptByLevel(level)
+	return verbosePrompt, getAskByLanguage(lang, originalCmd, command)
+}
+
+// verbosePromptByLevel возвращает промпт подробности по уровню и язык промптов
+func verbosePromptByLevel(level int) (string, string) {
 	// Получаем домашнюю директорию пользователя
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		// Fallback к встроенным промптам
-		detailedSystem := getBuiltinVerbosePrompt(level)
-		ask := getBuiltinAsk(originalCmd, command)
-		processExplanation(detailedSystem, ask, gpt3, timeout, deps, originalCmd, command, system, level)
-		return
+		return getBuiltinVerbosePrompt(level), "ru"
 	}
 
 	// Создаем менеджер промптов
 	pm := gpt.NewPromptManager(homeDir)
+	return getVerbosePromptByLevel(pm.Prompts, level), pm.GetCurrentLanguage()
+}
 
-	// Получаем промпт подробности по уровню
-	verbosePrompt := getVerbosePromptByLevel(pm.Prompts, level)
+// ExplainExisting объясняет уже готовую команду (например, из вики) или shell-скрипт
+// (scriptPath != "") без генерации. Использует промпты подробности 6–8; скрипты
+// разбираются построчно. Результат сохраняется в результаты и историю как обычно.
+func ExplainExisting(command, scriptPath string, gpt3 gpt.Gpt3, timeout int, level int, deps ExplainDeps) error {
+	verbosePrompt, lang := verbosePromptByLevel(level)
+
+	var ask, originalCmd string
+	if scriptPath != "" {
+		data, err := os.ReadFile(scriptPath)
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать скрипт: %v", err)
+		}
+		command = string(data)
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("скрипт %s пуст", scriptPath)
+		}
+		ask = getScriptAskByLanguage(lang, scriptPath, command)
+		originalCmd = "explain --file " + scriptPath
+	} else {
+		ask = getExistingCommandAskByLanguage(lang, command)
+		originalCmd = "explain: " + command
+	}
+	if err := validation.ValidateUserMessage(ask); err != nil {
+		return err
+	}
 
-	// Формируем ask в зависимости от языка
-	ask := getAskByLanguage(pm.GetCurrentLanguage(), originalCmd, command)
+	deps.PrintColored("🔎 Объясняю: ", deps.ColorYellow)
+	if scriptPath != "" {
+		fmt.Printf("%s (%d строк)\n", scriptPath, strings.Count(strings.TrimRight(command, "\n"), "\n")+1)
+	} else {
+		fmt.Println(command)
+	}
 
-	processExplanation(verbosePrompt, ask, gpt3, timeout, deps, originalCmd, command, system, level)
+	var regenerate func()
+	regenerate = func() {
+		processExplanation(verbosePrompt, ask, gpt3, timeout, deps, originalCmd, command, gpt3.Prompt, level, regenerate)
+	}
+	regenerate()
+	return nil
 }
 
 // getVerbosePromptByLevel возвращает промпт подробности по уровню
@@ -94,13 +150,28 @@ func getAskByLanguage(lang, originalCmd, command string) string {
 	return fmt.Sprintf("Explain the command in detail and suggest alternatives. Original command: %s. Original user request: %s", command, originalCmd)
 }
 
-// getBuiltinAsk возвращает встроенный ask
-func getBuiltinAsk(originalCmd, command string) string {
-	return fmt.Sprintf("Объясни подробно команду и предложи альтернативы. Исходная команда: %s. Исходное задание пользователя: %s", command, originalCmd)
+// getExistingCommandAskByLanguage формирует ask для объяснения готовой команды
+func getExistingCommandAskByLanguage(lang, command string) string {
+	if lang == "ru" {
+		return fmt.Sprintf("Объясни подробно команду и предложи альтернативы. Команда: %s", command)
+	}
+	return fmt.Sprintf("Explain the command in detail and suggest alternatives. Command: %s", command)
+}
+
+// getScriptAskByLanguage формирует ask для построчного разбора shell-скрипта
+func getScriptAskByLanguage(lang, scriptPath, script string) string {
+	if lang == "ru" {
+		return fmt.Sprintf("Объясни shell-скрипт %s построчно. Для каждой значимой строки или блока выведи сам код, "+
+			"а под ним комментарий, начинающийся с '# ', о том, что он делает. В конце кратко опиши назначение скрипта "+
+			"и возможные проблемы.\n\n```\n%s\n```", scriptPath, strings.TrimRight(script, "\n"))
+	}
+	return fmt.Sprintf("Explain the shell script %s line by line. For each meaningful line or block print the code itself "+
+		"followed by a comment starting with '# ' describing what it does. Finish with a short summary of the script's "+
+		"purpose and possible pitfalls.\n\n```\n%s\n```", scriptPath, strings.TrimRight(script, "\n"))
 }
 
 // processExplanation обрабатывает объяснение
-func processExplanation(detailedSystem, ask string, gpt3 gpt.Gpt3, timeout int, deps ExplainDeps, originalCmd string, command string, system string, level int) {
+func processExplanation(detailedSystem, ask string, gpt3 gpt.Gpt3, timeout int, deps ExplainDeps, originalCmd string, command string, system string, level int, regenerate func()) {
 	// Выводим debug информацию если включен флаг
 	if config.AppConfig.MainFlags.Debug {
 		printVerboseDebugInfo(detailedSystem, ask, gpt3, timeout, level)
@@ -108,7 +179,11 @@ func processExplanation(detailedSystem, ask string, gpt3 gpt.Gpt3, timeout int,
 	detailed := gpt.NewGpt3(gpt3.ProviderType, config.AppConfig.Host, gpt3.ApiKey, gpt3.Model, detailedSystem, 0.2, timeout)
 
 	deps.PrintColored("\n🧠 Получаю подробное объяснение...\n", deps.ColorPurple)
+	// В истории сохраняется время ответа на сам запрос, а не на объяснение
+	requestElapsed := LastRequest.Elapsed
 	explanation, elapsed := deps.GetCommand(*detailed, ask)
+	LastRequest.Elapsed = requestElapsed
+	LastRequest.Verbosity = strings.Repeat("v", level)
 	if explanation == "" {
 		deps.PrintColored("❌ Не удалось получить подробное объяснение.\n", deps.ColorRed)
 		return
@@ -120,61 +195,47 @@ func processExplanation(detailedSystem, ask string, gpt3 gpt.Gpt3, timeout int,
 	fmt.Println(explanation)
 
 	fmt.Printf("\nДействия: (c)копировать, (s)сохранить, (r)перегенерировать, (n)ничего: ")
-	var choice string
-	fmt.Scanln(&choice)
+	choice := ReadAnswer()
 	switch strings.ToLower(choice) {
 	case "c":
 		clipboard.WriteAll(explanation)
 		fmt.Println("✅ Объяснение скопировано в буфер обмена")
 	case "s":
-		saveExplanation(explanation, gpt3.Model, originalCmd, command, config.AppConfig.ResultFolder)
+		// Сначала история: файл результата ссылается на запись
+		historyID := 0
+		if !deps.DisableHistory {
+			historyID, _ = SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, originalCmd, command, system, explanation)
+		}
+		saveExplanation(explanation, gpt3.Model, originalCmd, command, config.AppConfig.ResultFolder, historyID)
 	case "r":
 		fmt.Println("🔄 Перегенерирую подробное объяснение...")
-		ShowDetailedExplanation(command, gpt3, system, originalCmd, timeout, level, deps)
+		regenerate()
 	default:
 		fmt.Println(" Возврат в основное меню.")
 	}
 
-	if !deps.DisableHistory && (strings.ToLower(choice) == "c" || strings.ToLower(choice) == "s" || strings.ToLower(choice) == "n") {
+	if !deps.DisableHistory && (strings.ToLower(choice) == "c" || strings.ToLower(choice) == "n") {
 		SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, originalCmd, command, system, explanation)
 	}
 }
 
 // saveExplanation сохраняет подробное объяснение и альтернативные способы
-func saveExplanation(explanation string, model string, originalCmd string, commandResponse string, resultFolder string) {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("gpt_explanation_%s_%s.md", model, timestamp)
-	filePath := path.Join(resultFolder, filename)
-	title := truncateTitle(originalCmd)
-	content := fmt.Sprintf(
-		"# %s\n\n## Prompt\n\n%s\n\n## Command\n\n%s\n\n## Explanation and Alternatives (model: %s)\n\n%s\n",
-		title,
-		originalCmd,
-		commandResponse,
-		model,
-		explanation,
-	)
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+func saveExplanation(explanation string, model string, originalCmd string, commandResponse string, resultFolder string, historyID int) {
+	filePath, err := results.Write(resultFolder, results.Result{
+		Meta: NewResultMeta(results.KindExplanation, originalCmd, model, historyID),
+		Sections: []results.Section{
+			{Heading: "Prompt", Body: originalCmd},
+			{Heading: "Command", Body: commandResponse},
+			{Heading: fmt.Sprintf("Explanation and Alternatives (model: %s)", model), Body: explanation},
+		},
+	})
+	if err != nil {
 		fmt.Println("Failed to save explanation:", err)
 	} else {
 		fmt.Printf("Saved to %s\n", filePath)
 	}
 }
 
-// truncateTitle сокращает строку до 120 символов (по рунам), добавляя " ..." при усечении
-func truncateTitle(s string) string {
-	const maxLen = 120
-	if runeCount := len([]rune(s)); runeCount <= maxLen {
-		return s
-	}
-	const head = 116
-	r := []rune(s)
-	if len(r) <= head {
-		return s
-	}
-	return string(r[:head]) + " ..."
-}
-
 // printVerboseDebugInfo выводит отладочную информацию для режимов v/vv/vvv
 func printVerboseDebugInfo(detailedSystem, ask string, gpt3 gpt.Gpt3, timeout int, level int) {
 	fmt.Printf("\n🔍 DEBUG VERBOSE (v%d):\n", level)
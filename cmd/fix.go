@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/reader"
+)
+
+// rerunTimeout ограничивает время повторного запуска упавшей команды
+const rerunTimeout = 30 * time.Second
+
+// FailedCommand описывает команду, которую нужно диагностировать
+type FailedCommand struct {
+	Command string
+	Status  int    // код возврата, -1 если неизвестен
+	Output  string // stdout+stderr повторного запуска (может быть усечен)
+	Omitted int64  // сколько байт вывода пропущено при усечении
+	Source  string // откуда взята команда: аргумент, виджет оболочки, файл истории
+	Shell   string // оболочка, в которой выполнялась команда; пусто — $SHELL
+	Rerun   bool
+}
+
+// LastShellCommand возвращает последнюю команду оболочки. Сначала смотрит переменные
+// LCG_LAST_COMMAND/LCG_LAST_STATUS, которые выставляет скрипт из `lcg shell-init`,
+// затем разбирает файл истории ($HISTFILE или стандартный файл для $SHELL).
+func LastShellCommand() (FailedCommand, error) {
+	if command := strings.TrimSpace(os.Getenv("LCG_LAST_COMMAND")); command != "" && !isFixInvocation(command) {
+		fc := FailedCommand{Command: command, Status: -1, Source: "shell-init"}
+		if status, err := strconv.Atoi(strings.TrimSpace(os.Getenv("LCG_LAST_STATUS"))); err == nil {
+			fc.Status = status
+		}
+		return fc, nil
+	}
+
+	path, shell := historyFileForShell()
+	if path == "" {
+		return FailedCommand{}, errors.New("не удалось определить файл истории оболочки; подключите `lcg shell-init` или передайте команду аргументом")
+	}
+	commands, err := readShellHistory(path, shell)
+	if err != nil {
+		return FailedCommand{}, fmt.Errorf("не удалось прочитать историю %s: %v", path, err)
+	}
+	for i := len(commands) - 1; i >= 0; i-- {
+		if !isFixInvocation(commands[i]) {
+			return FailedCommand{Command: commands[i], Status: -1, Source: path, Shell: shell}, nil
+		}
+	}
+	return FailedCommand{}, fmt.Errorf("в истории %s нет подходящих команд", path)
+}
+
+// isFixInvocation отсекает сам вызов `lcg fix`, чтобы не диагностировать его
+func isFixInvocation(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) < 2 {
+		return false
+	}
+	return filepath.Base(fields[0]) == "lcg" && (fields[1] == "fix" || fields[1] == "fx")
+}
+
+// historyFileForShell возвращает путь к файлу истории и тип оболочки
+func historyFileForShell() (string, string) {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if path := os.Getenv("HISTFILE"); path != "" {
+		if strings.Contains(path, "fish") {
+			shell = "fish"
+		}
+		return path, shell
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", shell
+	}
+	switch shell {
+	case "zsh":
+		return filepath.Join(home, ".zsh_history"), shell
+	case "fish":
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dataHome, "fish", "fish_history"), shell
+	default:
+		return filepath.Join(home, ".bash_history"), "bash"
+	}
+}
+
+// readShellHistory разбирает файл истории bash, zsh (в т.ч. EXTENDED_HISTORY) или fish
+func readShellHistory(path, shell string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// zsh хранит историю в метафицированной форме, но ASCII-команды от этого не страдают
+	data = bytes.ToValidUTF8(data, nil)
+
+	var commands []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if shell == "fish" {
+		for scanner.Scan() {
+			if cmd, ok := strings.CutPrefix(scanner.Text(), "- cmd: "); ok {
+				commands = append(commands, unescapeFish(cmd))
+			}
+		}
+		return commands, scanner.Err()
+	}
+
+	var pending strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pending.Len() == 0 {
+			// bash с HISTTIMEFORMAT пишет строки-метки вида #1690000000
+			if strings.HasPrefix(line, "#") && isDigits(line[1:]) {
+				continue
+			}
+			// zsh EXTENDED_HISTORY: ": 1690000000:0;команда"
+			if strings.HasPrefix(line, ": ") {
+				if i := strings.Index(line, ";"); i > 0 {
+					line = line[i+1:]
+				}
+			}
+		}
+		// В zsh многострочные команды продолжаются обратной косой чертой
+		if shell == "zsh" && strings.HasSuffix(line, `\`) {
+			pending.WriteString(strings.TrimSuffix(line, `\`) + "\n")
+			continue
+		}
+		pending.WriteString(line)
+		if command := strings.TrimSpace(pending.String()); command != "" {
+			commands = append(commands, command)
+		}
+		pending.Reset()
+	}
+	return commands, scanner.Err()
+}
+
+// unescapeFish снимает экранирование, которым fish записывает команды в историю
+func unescapeFish(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(s)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// rerunShell оболочка для повторного запуска команды: та, в которой она
+// выполнялась (zsh, fish; без нее — по $SHELL), иначе bash. Путь берется
+// из $SHELL, если это та же оболочка.
+func rerunShell(shell string) string {
+	login := os.Getenv("SHELL")
+	if shell == "" {
+		shell = filepath.Base(login)
+	}
+	if shell != "zsh" && shell != "fish" {
+		shell = "bash"
+	}
+	if login != "" && filepath.Base(login) == shell {
+		return login
+	}
+	return shell
+}
+
+// RerunCommand повторно запускает команду в ее оболочке, сохраняя код возврата
+// и вывод (не более limit байт, начало и конец). stdin команды отключен.
+func RerunCommand(fc *FailedCommand, limit int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rerunTimeout)
+	defer cancel()
+
+	shell := rerunShell(fc.Shell)
+	if _, err := exec.LookPath(shell); err != nil {
+		shell = "bash"
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, shell, "-c", fc.Command)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		fc.Status = 0
+	case ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("команда не завершилась за %s", rerunTimeout)
+	case errors.As(err, &exitErr):
+		fc.Status = exitErr.ExitCode()
+	default:
+		return err
+	}
+
+	text, omitted, err := reader.ReadHeadTail(&output, limit)
+	if err != nil {
+		return err
+	}
+	fc.Output, fc.Omitted, fc.Rerun = text, omitted, true
+	return nil
+}
+
+// FixSystemPrompt возвращает встроенный системный промпт диагностики по языку
+func FixSystemPrompt(lang string) string {
+	if lang == "ru" {
+		return "Ты опытный Linux-инженер. Пользователь запустил команду в терминале, и она завершилась с ошибкой. " +
+			"Определи причину и предложи исправленную команду. Ответ строго в формате из двух строк:\n" +
+			"REASON: краткое объяснение причины (1-3 предложения, на русском)\n" +
+			"COMMAND: исправленная команда одной строкой, без markdown и пояснений"
+	}
+	return "You are an experienced Linux engineer. The user ran a command in the terminal and it failed. " +
+		"Find the cause and propose a corrected command. Answer strictly in two lines:\n" +
+		"REASON: short explanation of the cause (1-3 sentences)\n" +
+		"COMMAND: the corrected command on a single line, without markdown or comments"
+}
+
+// FixAsk формирует запрос к модели с командой, кодом возврата и выводом
+func FixAsk(fc FailedCommand) string {
+	var sb strings.Builder
+	sb.WriteString("Command: " + fc.Command + "\n")
+	if fc.Status >= 0 {
+		sb.WriteString(fmt.Sprintf("Exit status: %d\n", fc.Status))
+	}
+	if strings.TrimSpace(fc.Output) != "" {
+		sb.WriteString("Output:\n" + strings.TrimRight(fc.Output, "\n") + "\n")
+	} else if !fc.Rerun {
+		sb.WriteString("Output: unavailable (the command was not re-run)\n")
+	}
+	return sb.String()
+}
+
+// ParseFixResponse разбирает ответ модели на причину и исправленную команду.
+// Если формат не соблюден, весь ответ считается командой.
+func ParseFixResponse(response string) (reason, command string) {
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if v, ok := cutPrefixFold(line, "REASON:"); ok {
+			reason = v
+		} else if v, ok := cutPrefixFold(line, "COMMAND:"); ok {
+			command = strings.Trim(v, "`")
+		} else if reason != "" && command == "" && line != "" {
+			reason += " " + line
+		}
+	}
+	if command == "" {
+		return reason, strings.TrimSpace(response)
+	}
+	return reason, strings.TrimSpace(command)
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return strings.TrimSpace(s[len(prefix):]), true
+	}
+	return "", false
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadShellHistory(t *testing.T) {
+	tests := []struct {
+		shell string
+		data  string
+		last  string
+	}{
+		{"bash", "ls\n#1690000000\ngit psuh\n", "git psuh"},
+		{"zsh", ": 1690000000:0;make\n: 1690000001:0;for f in *; do\\\necho $f\\\ndone\n", "for f in *; do\necho $f\ndone"},
+		{"fish", "- cmd: ls\n  when: 1690000000\n- cmd: echo a\\\\nb\\nc\n  when: 1690000001\n", "echo a\\nb\nc"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "history")
+		if err := os.WriteFile(path, []byte(test.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		commands, err := readShellHistory(path, test.shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(commands) != 2 || commands[1] != test.last {
+			t.Errorf("%s: unexpected commands %q", test.shell, commands)
+		}
+	}
+}
+
+func TestParseFixResponse(t *testing.T) {
+	reason, command := ParseFixResponse("REASON: typo in subcommand\nCOMMAND: `git push`")
+	if reason != "typo in subcommand" || command != "git push" {
+		t.Errorf("unexpected parse: %q, %q", reason, command)
+	}
+	if _, command := ParseFixResponse("git push\n"); command != "git push" {
+		t.Errorf("unformatted response must be used as command, got %q", command)
+	}
+	if isFixInvocation("git push") || !isFixInvocation("/usr/local/bin/lcg fix") {
+		t.Error("isFixInvocation misdetects lcg fix")
+	}
+}
+
+func TestRerunShell(t *testing.T) {
+	tests := []struct {
+		env, shell, want string
+	}{
+		{"/usr/bin/zsh", "zsh", "/usr/bin/zsh"},
+		{"/bin/bash", "fish", "fish"},
+		{"/usr/bin/fish", "", "/usr/bin/fish"},
+		{"/bin/bash", "", "/bin/bash"},
+		{"/bin/tcsh", "", "bash"},
+		{"", "bash", "bash"},
+	}
+	for _, test := range tests {
+		t.Setenv("SHELL", test.env)
+		if got := rerunShell(test.shell); got != test.want {
+			t.Errorf("SHELL=%q, history of %q: got %q, want %q", test.env, test.shell, got, test.want)
+		}
+	}
+}
@@ -1,98 +1,149 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
 )
 
-type HistoryEntry struct {
-	Index       int       `json:"index"`
-	Command     string    `json:"command"`
-	Response    string    `json:"response"`
-	Explanation string    `json:"explanation,omitempty"`
-	System      string    `json:"system_prompt"`
-	Timestamp   time.Time `json:"timestamp"`
+// HistoryEntry запись истории (хранение — пакет history)
+type HistoryEntry = history.Entry
+
+// RequestMeta сведения о последнем запросе к модели, которые сохраняются в историю
+type RequestMeta struct {
+	PromptID      int     // ID системного промпта; 0 — передан свой текст
+	PromptVersion int     // версия промпта PromptID
+	Verbosity     string  // v, vv или vvv, если запрошено подробное объяснение
+	Elapsed       float64 // время ответа модели, секунды
 }
 
-func read(historyPath string) ([]HistoryEntry, error) {
-	data, err := os.ReadFile(historyPath)
-	if err != nil {
-		// Если файл не существует, создаем пустой файл истории
-		if os.IsNotExist(err) {
-			emptyHistory := []HistoryEntry{}
-			if writeErr := write(historyPath, emptyHistory); writeErr != nil {
-				return nil, fmt.Errorf("не удалось создать файл истории: %v", writeErr)
-			}
-			return emptyHistory, nil
-		}
-		return nil, err
-	}
-	if len(data) == 0 {
-		return []HistoryEntry{}, nil
-	}
-	var items []HistoryEntry
-	if err := json.Unmarshal(data, &items); err != nil {
-		return nil, err
+// LastRequest сведения о текущем запросе; заполняются по ходу выполнения
+var LastRequest RequestMeta
+
+// NewRequest начинает новый запрос с промптом promptID (0 — свой текст):
+// объяснение и время ответа прошлого запроса не переносятся, версия промпта
+// определяется заново
+func NewRequest(promptID int) {
+	LastRequest = RequestMeta{PromptID: promptID}
+	if promptID > 0 {
+		homeDir, _ := os.UserHomeDir()
+		LastRequest.PromptVersion = gpt.NewPromptManager(homeDir).CurrentVersion(promptID)
 	}
-	return items, nil
 }
 
-func write(historyPath string, entries []HistoryEntry) error {
-	for i := range entries {
-		entries[i].Index = i + 1
-	}
-	out, err := json.MarshalIndent(entries, "", "  ")
-	if err != nil {
-		return err
-	}
-	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
-		return err
+// newHistoryEntry создает запись текущей схемы со сведениями о запросе
+func newHistoryEntry(cmdText, response, system, explanation string) HistoryEntry {
+	host, _ := os.Hostname()
+	return HistoryEntry{
+		Schema:        history.SchemaVersion,
+		Command:       cmdText,
+		Response:      response,
+		Explanation:   explanation,
+		System:        system,
+		Model:         config.AppConfig.Model,
+		Timestamp:     time.Now(),
+		Provider:      config.AppConfig.ProviderType,
+		PromptID:      LastRequest.PromptID,
+		PromptVersion: LastRequest.PromptVersion,
+		Verbosity:     LastRequest.Verbosity,
+		Elapsed:       LastRequest.Elapsed,
+		Host:          host,
+		Cwd:           config.AppConfig.Cwd,
 	}
-	return os.WriteFile(historyPath, out, 0644)
 }
 
-func ShowHistory(historyPath string, printColored func(string, string), colorYellow string) {
-	items, err := read(historyPath)
-	if err != nil || len(items) == 0 {
+// OpenHistory открывает историю по пути в хранилище, выбранном в LCG_HISTORY_BACKEND
+func OpenHistory(historyPath string) (history.Store, error) {
+	return history.Open(config.AppConfig.HistoryBackend, historyPath)
+}
+
+// ShowHistory выводит записи истории, подходящие под фильтр, новые первыми
+func ShowHistory(historyPath string, filter history.Filter, printColored func(string, string), colorYellow string) {
+	store, err := OpenHistory(historyPath)
+	var items []HistoryEntry
+	if err == nil {
+		items, err = store.Find(filter)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		printColored(fmt.Sprintf("📝 История недоступна: %v\n", err), colorYellow)
+		return
+	}
+	if len(items) == 0 {
 		printColored("📝 История пуста\n", colorYellow)
 		return
 	}
-	
+
 	// Сортируем записи по времени в убывающем порядке (новые сначала)
 	sort.Slice(items, func(i, j int) bool {
 		return items[i].Timestamp.After(items[j].Timestamp)
 	})
-	
+
 	printColored("📝 История (из файла):\n", colorYellow)
 	for _, h := range items {
 		ts := h.Timestamp.Format("2006-01-02 15:04:05")
-		fmt.Printf("%d. [%s] %s → %s\n", h.Index, ts, h.Command, h.Response)
+		marks := h.Marks()
+		if marks != "" {
+			marks = " " + marks
+		}
+		if len(h.Versions) > 0 {
+			marks += fmt.Sprintf(" 📚%d", len(h.Versions))
+		}
+		fmt.Printf("%d. [%s]%s %s → %s\n", h.Index, ts, marks, h.Command, h.Response)
+		if details := h.Details(); details != "" {
+			fmt.Printf("   %s\n", details)
+		}
 		fmt.Printf("%s\n", "========================================================================================")
 	}
 }
 
-func ViewHistoryEntry(historyPath string, id int, printColored func(string, string), colorYellow, colorBold, colorGreen string) {
-	items, err := read(historyPath)
-	if err != nil || len(items) == 0 {
+// ViewHistoryEntry выводит запись истории; version — номер версии ответа
+// (0 — предпочтительная или последняя)
+func ViewHistoryEntry(historyPath string, id, version int, printColored func(string, string), colorYellow, colorBold, colorGreen string) {
+	store, err := OpenHistory(historyPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	h, err := store.Get(id)
+	if err == history.ErrNotFound {
+		fmt.Println("Запись не найдена")
+		return
+	}
+	if err != nil {
 		fmt.Println("История пуста или недоступна")
 		return
 	}
-	var h *HistoryEntry
-	for i := range items {
-		if items[i].Index == id {
-			h = &items[i]
-			break
+	if len(h.Versions) > 0 {
+		if version == 0 {
+			version = h.Active()
 		}
-	}
-	if h == nil {
-		fmt.Println("Запись не найдена")
+		shown, err := h.WithVersion(version)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		printColored(fmt.Sprintf("\n📚 Версия %d из %d (%s)\n", version, len(h.Versions), VersionsLine(h)), colorYellow)
+		h = shown
+	} else if version > 1 {
+		fmt.Printf("У записи #%d одна версия ответа\n", id)
 		return
 	}
+	if details := h.Details(); details != "" {
+		fmt.Printf("\n⚙️  %s\n", details)
+	}
+	if marks := h.Marks(); marks != "" {
+		printColored(fmt.Sprintf("\n🏷  %s\n", marks), colorYellow)
+	}
+	if h.RatingNote != "" {
+		fmt.Printf("   Комментарий к оценке: %s\n", h.RatingNote)
+	}
 	printColored("\n📋 Команда:\n", colorYellow)
 	printColored(fmt.Sprintf("   %s\n\n", h.Response), colorBold+colorGreen)
 	if strings.TrimSpace(h.Explanation) != "" {
@@ -102,103 +153,126 @@ func ViewHistoryEntry(historyPath string, id int, printColored func(string, stri
 }
 
 func DeleteHistoryEntry(historyPath string, id int) error {
-	items, err := read(historyPath)
-	if err != nil || len(items) == 0 {
-		return fmt.Errorf("история пуста или недоступна")
-	}
-	pos := -1
-	for i := range items {
-		if items[i].Index == id {
-			pos = i
-			break
-		}
-	}
-	if pos == -1 {
-		return fmt.Errorf("запись не найдена")
+	h, err := OpenHistory(historyPath)
+	if err != nil {
+		return err
 	}
-	items = append(items[:pos], items[pos+1:]...)
-	return write(historyPath, items)
+	return h.Delete(id)
 }
 
-func SaveToHistory(historyPath, resultFolder, cmdText, response, system string, explanationOptional ...string) error {
+// SaveToHistory добавляет запись в историю и возвращает ее номер.
+// Если такой запрос уже есть, новый ответ сохраняется очередной версией записи
+// (прежние ответы остаются доступны в lcg history view --version).
+func SaveToHistory(historyPath, resultFolder, cmdText, response, system string, explanationOptional ...string) (int, error) {
 	var explanation string
 	if len(explanationOptional) > 0 {
 		explanation = explanationOptional[0]
 	}
-	items, _ := read(historyPath)
-	duplicateIndex := -1
-	for i, h := range items {
-		if strings.EqualFold(strings.TrimSpace(h.Command), strings.TrimSpace(cmdText)) {
-			duplicateIndex = i
-			break
+	h, err := OpenHistory(historyPath)
+	if err != nil {
+		return 0, err
+	}
+	entry := newHistoryEntry(cmdText, response, system, explanation)
+	id, replaced, err := h.Upsert(entry)
+	if err != nil || !replaced {
+		return id, err
+	}
+	if e, err := h.Get(id); err == nil {
+		if n := e.AddedVersion(entry); n > 0 {
+			fmt.Printf("📚 Ответ сохранен как версия %d записи #%d\n", n, id)
 		}
 	}
-	entry := HistoryEntry{
-		Index:       len(items) + 1,
-		Command:     cmdText,
-		Response:    response,
-		Explanation: explanation,
-		System:      system,
-		Timestamp:   time.Now(),
-	}
-	if duplicateIndex == -1 {
-		items = append(items, entry)
-		return write(historyPath, items)
-	}
-	fmt.Printf("\nЗапрос уже есть в истории от %s. Перезаписать? (y/N): ", items[duplicateIndex].Timestamp.Format("2006-01-02 15:04:05"))
-	var ans string
-	fmt.Scanln(&ans)
-	if strings.ToLower(ans) == "y" || strings.ToLower(ans) == "yes" {
-		entry.Index = items[duplicateIndex].Index
-		items[duplicateIndex] = entry
-		return write(historyPath, items)
-	}
-	return nil
+	return id, nil
 }
 
-// SaveToHistoryFromHistory сохраняет запись из истории без запроса о перезаписи
-func SaveToHistoryFromHistory(historyPath, resultFolder, cmdText, response, system, explanation string) error {
-	items, _ := read(historyPath)
-	duplicateIndex := -1
-	for i, h := range items {
-		if strings.EqualFold(strings.TrimSpace(h.Command), strings.TrimSpace(cmdText)) {
-			duplicateIndex = i
-			break
-		}
+// FindHistoryID номер записи истории с тем же запросом или 0
+func FindHistoryID(historyPath, cmdText string) int {
+	h, err := OpenHistory(historyPath)
+	if err != nil {
+		return 0
+	}
+	if entries, err := h.Find(history.Filter{Query: cmdText}); err == nil && len(entries) > 0 {
+		return entries[0].Index
 	}
-	entry := HistoryEntry{
-		Index:       len(items) + 1,
-		Command:     cmdText,
-		Response:    response,
-		Explanation: explanation,
-		System:      system,
-		Timestamp:   time.Now(),
+	return 0
+}
+
+// VersionsLine список версий записи с датами; предпочтительная отмечена звездой
+func VersionsLine(h HistoryEntry) string {
+	var parts []string
+	for i, v := range h.Versions {
+		part := fmt.Sprintf("%d: %s", i+1, v.Timestamp.Format("2006-01-02 15:04"))
+		if i+1 == h.Preferred {
+			part += " ★"
+		}
+		parts = append(parts, part)
 	}
-	if duplicateIndex == -1 {
-		items = append(items, entry)
-		return write(historyPath, items)
+	return strings.Join(parts, ", ")
+}
+
+// SaveToHistoryFromHistory сохраняет запись из истории без запроса о перезаписи
+// и возвращает ее номер
+func SaveToHistoryFromHistory(historyPath, resultFolder, cmdText, response, system, explanation string) (int, error) {
+	h, err := OpenHistory(historyPath)
+	if err != nil {
+		return 0, err
 	}
 	// Если дубликат найден, перезаписываем без запроса
-	entry.Index = items[duplicateIndex].Index
-	items[duplicateIndex] = entry
-	return write(historyPath, items)
+	id, _, err := h.Upsert(newHistoryEntry(cmdText, response, system, explanation))
+	return id, err
+}
+
+// MarkHistoryEntry сохраняет ответ в историю без вопроса о перезаписи и применяет
+// к записи fn (избранное, теги, оценка). Возвращает номер записи.
+func MarkHistoryEntry(historyPath, cmdText, response, system, explanation string, fn func(e *HistoryEntry) error) (int, error) {
+	h, err := OpenHistory(historyPath)
+	if err != nil {
+		return 0, err
+	}
+	id, _, err := h.Upsert(newHistoryEntry(cmdText, response, system, explanation))
+	if err != nil {
+		return 0, err
+	}
+	return id, h.Update(id, fn)
+}
+
+// UpdateHistoryEntry изменяет пометки записи истории по номеру
+func UpdateHistoryEntry(historyPath string, id int, fn func(e *HistoryEntry) error) error {
+	h, err := OpenHistory(historyPath)
+	if err != nil {
+		return err
+	}
+	return h.Update(id, fn)
+}
+
+// EditTags добавляет теги к записи; тег с префиксом "-" удаляется
+func EditTags(e *HistoryEntry, tags []string) {
+	var add, remove []string
+	for _, t := range tags {
+		if name, ok := strings.CutPrefix(t, "-"); ok {
+			remove = append(remove, name)
+		} else {
+			add = append(add, t)
+		}
+	}
+	remove = history.NormalizeTags(remove)
+	e.Tags = history.NormalizeTags(append(e.Tags, add...))
+	e.Tags = slices.DeleteFunc(e.Tags, func(t string) bool { return slices.Contains(remove, t) })
 }
 
 func CheckAndSuggestFromHistory(historyPath, cmdText string) (bool, *HistoryEntry) {
-	items, err := read(historyPath)
+	store, err := OpenHistory(historyPath)
+	if err != nil {
+		return false, nil
+	}
+	items, err := store.Find(history.Filter{Query: cmdText})
 	if err != nil || len(items) == 0 {
 		return false, nil
 	}
-	for _, h := range items {
-		if strings.EqualFold(strings.TrimSpace(h.Command), strings.TrimSpace(cmdText)) {
-			fmt.Printf("\nВ истории найден похожий запрос от %s. Показать сохраненный результат? (y/N): ", h.Timestamp.Format("2006-01-02 15:04:05"))
-			var ans string
-			fmt.Scanln(&ans)
-			if strings.ToLower(ans) == "y" || strings.ToLower(ans) == "yes" {
-				return true, &h
-			}
-			break
-		}
+	h := items[0]
+	fmt.Printf("\nВ истории найден похожий запрос от %s. Показать сохраненный результат? (y/N): ", h.Timestamp.Format("2006-01-02 15:04:05"))
+	if Confirm() {
+		return true, &h
 	}
 	return false, nil
 }
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// inputReader источник ответов пользователя для интерактивных вопросов (y/N, меню действий)
+var inputReader = bufio.NewReader(os.Stdin)
+
+// interactive разрешает задавать пользователю вопросы; при false ответы считаются пустыми
+var interactive = true
+
+// assumeYes отвечает "да" на вопросы подтверждения (флаг --yes)
+var assumeYes bool
+
+// StdinIsTerminal сообщает, подключен ли stdin к терминалу (а не к пайпу или файлу)
+func StdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return true
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// UseTTYInput переключает чтение ответов на управляющий терминал.
+// Нужна, когда stdin занят данными из пайпа. Если терминал недоступен
+// (cron, CI), интерактивные вопросы отключаются.
+func UseTTYInput() {
+	tty, err := os.Open(ttyPath())
+	if err != nil {
+		interactive = false
+		return
+	}
+	inputReader = bufio.NewReader(tty)
+}
+
+// IsInteractive возвращает true, если можно задавать пользователю вопросы
+func IsInteractive() bool {
+	return interactive
+}
+
+// SetInteractive включает или отключает интерактивные вопросы
+func SetInteractive(value bool) {
+	interactive = value
+}
+
+// ReadAnswer читает строку ответа пользователя.
+// В неинтерактивном режиме сразу возвращает пустую строку (ответ по умолчанию).
+func ReadAnswer() string {
+	if !interactive {
+		fmt.Println()
+		return ""
+	}
+	line, _ := inputReader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// SetAssumeYes включает автоматическое согласие на вопросы (y/N)
+func SetAssumeYes(value bool) {
+	assumeYes = value
+}
+
+// AssumeYes сообщает, включено ли автоматическое согласие (флаг --yes)
+func AssumeYes() bool {
+	return assumeYes
+}
+
+// Confirm читает ответ на вопрос (y/N). С --yes сразу возвращает true.
+func Confirm() bool {
+	if assumeYes {
+		fmt.Println("y")
+		return true
+	}
+	answer := strings.ToLower(ReadAnswer())
+	return answer == "y" || answer == "yes"
+}
+
+func ttyPath() string {
+	if runtime.GOOS == "windows" {
+		return "CONIN$"
+	}
+	return "/dev/tty"
+}
+
+// ReadSecret запрашивает секрет (парольную фразу) без отображения ввода.
+// Если stdin не терминал, читает с управляющего терминала.
+func ReadSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		tty, err := os.Open(ttyPath())
+		if err != nil {
+			return "", errors.New("нет терминала для ввода парольной фразы")
+		}
+		defer tty.Close()
+		if fd = int(tty.Fd()); !term.IsTerminal(fd) {
+			return "", errors.New("нет терминала для ввода парольной фразы")
+		}
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
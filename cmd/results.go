@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+)
+
+// NewResultMeta сведения о сохраняемом результате текущего запроса. С номером
+// записи истории (historyID > 0) результат связывается с ней и получает ее теги.
+func NewResultMeta(kind, query, model string, historyID int) results.Meta {
+	meta := results.Meta{
+		Kind:      kind,
+		Query:     query,
+		Model:     model,
+		Provider:  config.AppConfig.ProviderType,
+		PromptID:  LastRequest.PromptID,
+		HistoryID: historyID,
+	}
+	if historyID > 0 {
+		if store, err := OpenHistory(config.AppConfig.ResultHistory); err == nil {
+			if e, err := store.Get(historyID); err == nil {
+				meta.Tags = e.Tags
+			}
+		}
+	}
+	return meta
+}
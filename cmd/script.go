@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+)
+
+// ScriptSystemPrompt возвращает встроенный системный промпт генерации скриптов по языку
+func ScriptSystemPrompt(lang string) string {
+	if lang == "ru" {
+		return "Ты опытный Linux-инженер. Напиши законченный bash-скрипт для задачи пользователя. Требования: " +
+			"первая строка #!/usr/bin/env bash, затем set -euo pipefail; функция usage с описанием аргументов; " +
+			"разбор аргументов (getopts или case) с поддержкой -h/--help; понятные комментарии на русском; " +
+			"проверка зависимостей и входных данных; сообщения об ошибках в stderr. " +
+			"Выведи только текст скрипта, без markdown и пояснений вне скрипта."
+	}
+	return "You are an experienced Linux engineer. Write a complete bash script for the user's task. Requirements: " +
+		"first line #!/usr/bin/env bash, then set -euo pipefail; a usage function describing the arguments; " +
+		"argument parsing (getopts or case) supporting -h/--help; clear comments; " +
+		"dependency and input checks; error messages to stderr. " +
+		"Output only the script text, without markdown or explanations outside the script."
+}
+
+// ScriptRefineAsk формирует запрос на доработку готового скрипта целиком
+func ScriptRefineAsk(task, script, instruction string) string {
+	return fmt.Sprintf("Task: %s\n\nCurrent script:\n%s\n\nRevise the whole script: %s\nOutput the complete updated script.",
+		task, strings.TrimRight(script, "\n"), instruction)
+}
+
+// ExtractScript убирает markdown-ограждение ``` вокруг скрипта, если модель его добавила
+func ExtractScript(response string) string {
+	text := strings.TrimSpace(response)
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		// Пропускаем указание языка (```bash)
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		text = strings.TrimSpace(body)
+	}
+	return text + "\n"
+}
+
+// CheckScriptSyntax проверяет синтаксис скрипта через `bash -n`.
+// Если bash не найден, возвращает exec.ErrNotFound.
+func CheckScriptSyntax(script string) error {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		return exec.ErrNotFound
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(bash, "-n")
+	cmd.Stdin = strings.NewReader(script)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// WriteScript сохраняет скрипт и выставляет бит исполнения
+func WriteScript(filePath, script string) error {
+	if err := os.WriteFile(filePath, []byte(script), 0755); err != nil {
+		return err
+	}
+	// WriteFile применяет права только к новому файлу
+	return os.Chmod(filePath, 0755)
+}
+
+// SaveScriptResult записывает скрипт вместе с исходной задачей в папку результатов
+func SaveScriptResult(resultFolder, model, task, scriptPath, script string) (string, error) {
+	return results.Write(resultFolder, results.Result{
+		Meta: NewResultMeta(results.KindScript, task, model, 0),
+		Sections: []results.Section{
+			{Heading: "Prompt", Body: task},
+			{Heading: "Script", Body: fmt.Sprintf("`%s`\n\n```bash\n%s```", scriptPath, script)},
+		},
+	})
+}
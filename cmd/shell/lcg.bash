@@ -0,0 +1,50 @@
+# lcg: интеграция с bash
+# Подключение: eval "$(lcg shell-init bash)"  (например, в ~/.bashrc)
+#
+# Наберите описание задачи в командной строке и нажмите {{.KeyHint}} —
+# строка будет заменена сгенерированной командой для проверки перед запуском.
+
+__lcg_widget() {
+    local query="$READLINE_LINE"
+    [[ -z "${query//[[:space:]]/}" ]] && return
+    local result
+    result="$(command {{.Binary}} --print --no-stdin -- "$query" </dev/null)" || return
+    if [[ -n "$result" ]]; then
+        READLINE_LINE="$result"
+        READLINE_POINT=${#READLINE_LINE}
+    fi
+}
+bind -x '"{{.Key}}": __lcg_widget'
+
+# Последняя команда и ее код возврата для `lcg fix`
+__lcg_precmd() {
+    local last_status=$?
+    export LCG_LAST_STATUS=$last_status
+    export LCG_LAST_COMMAND="$(HISTTIMEFORMAT= builtin history 1 | sed -e 's/^ *[0-9]*[* ] *//')"
+    return $last_status
+}
+if [[ ";${PROMPT_COMMAND[*]};" != *";__lcg_precmd;"* ]]; then
+    PROMPT_COMMAND="__lcg_precmd${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+
+_lcg_complete() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local cmd=""
+    local i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -*) ;;
+            *) cmd="${COMP_WORDS[i]}"; break ;;
+        esac
+    done
+
+    case "$cmd" in
+{{- range .Commands}}
+        {{join .Names "|"}})
+            COMPREPLY=($(compgen -W "{{join .Subcommands " "}} {{flagWords .Flags}}" -- "$cur"))
+            return ;;
+{{- end}}
+    esac
+    COMPREPLY=($(compgen -W "{{commandWords .Commands}} {{flagWords .Flags}}" -- "$cur"))
+}
+complete -o default -F _lcg_complete {{.Binary}}
@@ -0,0 +1,40 @@
+# lcg: интеграция с fish
+# Подключение: lcg shell-init fish | source  (например, в ~/.config/fish/config.fish)
+#
+# Наберите описание задачи в командной строке и нажмите {{.KeyHint}} —
+# строка будет заменена сгенерированной командой для проверки перед запуском.
+
+function __lcg_widget
+    set -l query (commandline)
+    if test -z (string trim -- "$query")
+        return
+    end
+    set -l result (command {{.Binary}} --print --no-stdin -- "$query" </dev/null | string collect)
+    if test -n "$result"
+        commandline -r -- $result
+    end
+    commandline -f repaint
+end
+bind {{.Key}} __lcg_widget
+
+# Последняя команда и ее код возврата для `lcg fix`
+function __lcg_postexec --on-event fish_postexec
+    set -l last_status $status
+    set -gx LCG_LAST_STATUS $last_status
+    set -gx LCG_LAST_COMMAND $argv[1]
+end
+
+complete -c {{.Binary}} -f
+{{- range .Flags}}
+complete -c {{$.Binary}} -n __fish_use_subcommand {{fishFlag .}}
+{{- end}}
+{{- range .Commands}}
+{{- $cmd := .}}
+complete -c {{$.Binary}} -n __fish_use_subcommand -a {{.Name}} -d {{quote .Usage}}
+{{- range .Subcommands}}
+complete -c {{$.Binary}} -n "__fish_seen_subcommand_from {{join $cmd.Names " "}}" -a {{.}}
+{{- end}}
+{{- range .Flags}}
+complete -c {{$.Binary}} -n "__fish_seen_subcommand_from {{join $cmd.Names " "}}" {{fishFlag .}}
+{{- end}}
+{{- end}}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/urfave/cli/v2"
+)
+
+//go:embed shell/lcg.bash
+var bashInitScript string
+
+//go:embed shell/lcg.zsh
+var zshInitScript string
+
+//go:embed shell/lcg.fish
+var fishInitScript string
+
+// shellInit описывает поддерживаемую оболочку: шаблон скрипта и горячую клавишу по умолчанию
+type shellInit struct {
+	script     string
+	defaultKey string
+	keyHint    string
+}
+
+var shellInits = map[string]shellInit{
+	"bash": {script: bashInitScript, defaultKey: `\C-g`, keyHint: "Ctrl+G"},
+	"zsh":  {script: zshInitScript, defaultKey: `^G`, keyHint: "Ctrl+G"},
+	"fish": {script: fishInitScript, defaultKey: `\cg`, keyHint: "Ctrl+G"},
+}
+
+// ShellNames возвращает список поддерживаемых оболочек
+func ShellNames() []string {
+	return []string{"bash", "zsh", "fish"}
+}
+
+// shellFlag флаг CLI в виде, удобном для шаблонов автодополнения
+type shellFlag struct {
+	Long       string   // --name
+	Short      []string // однобуквенные алиасы (-f)
+	Old        []string // многобуквенные алиасы с одним дефисом (-nh)
+	Usage      string
+	TakesValue bool
+}
+
+// shellCommand подкоманда CLI с ее подкомандами и флагами
+type shellCommand struct {
+	Name        string
+	Names       []string
+	Usage       string
+	Subcommands []string
+	Flags       []shellFlag
+}
+
+// ShellInitScript формирует скрипт интеграции для оболочки: виджет, который по горячей
+// клавише заменяет текущую строку сгенерированной командой, и автодополнение
+// подкоманд и флагов. key переопределяет горячую клавишу (в нотации оболочки).
+func ShellInitScript(shell string, app *cli.App, key string) (string, error) {
+	si, ok := shellInits[shell]
+	if !ok {
+		return "", fmt.Errorf("неподдерживаемая оболочка %q (доступны: %s)", shell, strings.Join(ShellNames(), ", "))
+	}
+	keyHint := si.keyHint
+	if key == "" {
+		key = si.defaultKey
+	} else {
+		keyHint = key
+	}
+
+	data := struct {
+		Binary   string
+		Key      string
+		KeyHint  string
+		Commands []shellCommand
+		Flags    []shellFlag
+	}{
+		Binary:  app.Name,
+		Key:     key,
+		KeyHint: keyHint,
+		Flags:   collectShellFlags(app.VisibleFlags()),
+	}
+	for _, c := range app.VisibleCommands() {
+		if c.Name == "help" {
+			continue
+		}
+		sc := shellCommand{
+			Name:  c.Name,
+			Names: c.Names(),
+			Usage: c.Usage,
+			Flags: collectShellFlags(c.VisibleFlags()),
+		}
+		for _, sub := range c.Subcommands {
+			if !sub.Hidden && sub.Name != "help" {
+				sc.Subcommands = append(sc.Subcommands, sub.Names()...)
+				sc.Flags = append(sc.Flags, collectShellFlags(sub.VisibleFlags())...)
+			}
+		}
+		data.Commands = append(data.Commands, sc)
+	}
+
+	funcs := template.FuncMap{
+		"join":         strings.Join,
+		"quote":        fishQuote,
+		"flagWords":    flagWords,
+		"commandWords": commandWords,
+		"fishFlag":     fishFlag,
+	}
+	tmpl, err := template.New(shell).Funcs(funcs).Parse(si.script)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// collectShellFlags переводит флаги urfave/cli в описание для шаблонов (без help)
+func collectShellFlags(flags []cli.Flag) []shellFlag {
+	var result []shellFlag
+	for _, f := range flags {
+		names := f.Names()
+		if len(names) == 0 || names[0] == "help" {
+			continue
+		}
+		sf := shellFlag{Long: names[0]}
+		if df, ok := f.(cli.DocGenerationFlag); ok {
+			sf.Usage = df.GetUsage()
+			sf.TakesValue = df.TakesValue()
+		}
+		for _, alias := range names[1:] {
+			if len(alias) == 1 {
+				sf.Short = append(sf.Short, alias)
+			} else {
+				sf.Old = append(sf.Old, alias)
+			}
+		}
+		result = append(result, sf)
+	}
+	return result
+}
+
+// flagWords возвращает все написания флагов через пробел (--file -f ...)
+func flagWords(flags []shellFlag) string {
+	var words []string
+	for _, f := range flags {
+		words = append(words, "--"+f.Long)
+		for _, s := range f.Short {
+			words = append(words, "-"+s)
+		}
+		for _, o := range f.Old {
+			words = append(words, "-"+o)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// commandWords возвращает имена и алиасы подкоманд через пробел
+func commandWords(commands []shellCommand) string {
+	var words []string
+	for _, c := range commands {
+		words = append(words, c.Names...)
+	}
+	return strings.Join(words, " ")
+}
+
+// fishFlag формирует аргументы complete для флага в fish
+func fishFlag(f shellFlag) string {
+	parts := []string{"-l " + f.Long}
+	for _, s := range f.Short {
+		parts = append(parts, "-s "+s)
+	}
+	for _, o := range f.Old {
+		parts = append(parts, "-o "+o)
+	}
+	if f.TakesValue {
+		parts = append(parts, "-r")
+	}
+	if f.Usage != "" {
+		parts = append(parts, "-d "+fishQuote(f.Usage))
+	}
+	return strings.Join(parts, " ")
+}
+
+// fishQuote заключает строку в одинарные кавычки по правилам fish
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
@@ -16,31 +16,75 @@ type Config struct {
 	Completions    string
 	Model          string
 	Prompt         string
+	PromptVars     string // переменные шаблонов промптов: имя=значение через запятую
 	ApiKeyFile     string
 	ConfigFolder   string
 	ResultFolder   string
 	PromptFolder   string
+	RunbookFolder  string // ранбуки: упорядоченные наборы команд (<имя>.md)
 	ProviderType   string
 	JwtToken       string
 	PromptID       string
 	Timeout        string
 	ResultHistory  string
+	HistoryBackend string // json, jsonl или bolt
 	NoHistoryEnv   string
 	AllowExecution bool
 	Think          bool
 	Query          string
+	StdinMaxBytes  int
+	FileTokens     int // бюджет токенов на содержимое файлов из --file
 	MainFlags      MainFlags
+	Retention      RetentionConfig
+	Encryption     EncryptionConfig
+	Sync           SyncConfig
 	Server         ServerConfig
 	Validation     ValidationConfig
 }
 
 type MainFlags struct {
-	File      string
+	Files     []string
 	NoHistory bool
 	Sys       string
 	PromptID  int
 	Timeout   int
 	Debug     bool
+	NoStdin   bool
+	Vars      []string // переменные шаблонов промптов из --var
+}
+
+// RetentionConfig ограничения хранения истории и файлов результатов
+type RetentionConfig struct {
+	History      RetentionLimits
+	Results      RetentionLimits // gpt_request_* и gpt_script_*
+	Explanations RetentionLimits // gpt_explanation_*
+	GCInterval   string          // период очистки в lcg serve
+}
+
+// RetentionLimits ограничения по возрасту (90d), количеству и размеру (50MB); пусто — без ограничения
+type RetentionLimits struct {
+	MaxAge   string
+	MaxCount string
+	MaxSize  string
+}
+
+// EncryptionConfig источник ключа шифрования истории, результатов и sys_prompts;
+// ничего не задано — данные хранятся открыто
+type EncryptionConfig struct {
+	KeyFile       string // файл с ключом
+	Key           string // ключ в переменной окружения
+	Passphrase    string // парольная фраза
+	AskPassphrase bool   // запросить парольную фразу при запуске
+}
+
+// SyncConfig синхронизация истории, результатов и промптов через git-репозиторий
+type SyncConfig struct {
+	Remote      string // адрес репозитория; пусто — синхронизация не настроена
+	Dir         string // локальная копия репозитория
+	Branch      string
+	ExcludeTags string // записи с этими тегами не покидают машину
+	Results     bool   // синхронизировать файлы результатов
+	Prompts     bool   // синхронизировать пользовательские промпты
 }
 
 type ServerConfig struct {
@@ -133,6 +177,8 @@ func Load() Config {
 	os.MkdirAll(privateConfigDir, 0700)
 	configFolder := getEnv("LCG_CONFIG_FOLDER", privateConfigDir)
 
+	historyBackend := getEnv("LCG_HISTORY_BACKEND", "json")
+
 	return Config{
 		Cwd:            cwd,
 		AppName:        getEnv("LCG_APP_NAME", "Linux Command GPT"),
@@ -141,17 +187,42 @@ func Load() Config {
 		Completions:    getEnv("LCG_COMPLETIONS_PATH", "api/chat"),
 		Model:          getEnv("LCG_MODEL", "hf.co/yandex/YandexGPT-5-Lite-8B-instruct-GGUF:Q4_K_M"),
 		Prompt:         getEnv("LCG_PROMPT", "Reply with linux command and nothing else. Output with plain response - no need formatting. No need explanation. No need code blocks. No need ` symbols."),
+		PromptVars:     getEnv("LCG_PROMPT_VARS", ""),
 		ApiKeyFile:     getEnv("LCG_API_KEY_FILE", ".openai_api_key"),
 		ResultFolder:   resultFolder,
 		PromptFolder:   promptFolder,
+		RunbookFolder:  getEnv("LCG_RUNBOOK_FOLDER", path.Join(homedir, ".config", "lcg", "runbooks")),
 		ConfigFolder:   configFolder,
 		ProviderType:   getEnv("LCG_PROVIDER", "ollama"),
 		JwtToken:       getEnv("LCG_JWT_TOKEN", ""),
 		PromptID:       getEnv("LCG_PROMPT_ID", "1"),
 		Timeout:        getEnv("LCG_TIMEOUT", "300"),
-		ResultHistory:  getEnv("LCG_RESULT_HISTORY", path.Join(resultFolder, "lcg_history.json")),
+		ResultHistory:  getEnv("LCG_RESULT_HISTORY", path.Join(resultFolder, HistoryFileName(historyBackend))),
+		HistoryBackend: historyBackend,
 		NoHistoryEnv:   getEnv("LCG_NO_HISTORY", ""),
 		AllowExecution: isAllowExecutionEnabled(),
+		StdinMaxBytes:  getEnvInt("LCG_STDIN_MAX_BYTES", 3000),
+		FileTokens:     getEnvInt("LCG_FILE_TOKEN_BUDGET", 1000),
+		Retention: RetentionConfig{
+			History:      retentionLimits("HISTORY"),
+			Results:      retentionLimits("RESULTS"),
+			Explanations: retentionLimits("EXPLANATIONS"),
+			GCInterval:   getEnv("LCG_GC_INTERVAL", "1h"),
+		},
+		Encryption: EncryptionConfig{
+			KeyFile:       getEnv("LCG_ENCRYPTION_KEY_FILE", getEnv("LCG_HISTORY_ENCRYPTION_KEY_FILE", "")),
+			Key:           getEnv("LCG_ENCRYPTION_KEY", ""),
+			Passphrase:    getEnv("LCG_ENCRYPTION_PASSPHRASE", ""),
+			AskPassphrase: GetEnvBool("LCG_ENCRYPTION_ASK", false),
+		},
+		Sync: SyncConfig{
+			Remote:      getEnv("LCG_SYNC_REMOTE", ""),
+			Dir:         getEnv("LCG_SYNC_DIR", path.Join(homedir, ".config", "lcg", "sync")),
+			Branch:      getEnv("LCG_SYNC_BRANCH", "main"),
+			ExcludeTags: getEnv("LCG_SYNC_EXCLUDE_TAGS", "private"),
+			Results:     GetEnvBool("LCG_SYNC_RESULTS", true),
+			Prompts:     GetEnvBool("LCG_SYNC_PROMPTS", true),
+		},
 		Server: ServerConfig{
 			Port:           getEnv("LCG_SERVER_PORT", "8080"),
 			Host:           getEnv("LCG_SERVER_HOST", "localhost"),
@@ -181,6 +252,26 @@ func Load() Config {
 	}
 }
 
+// retentionLimits читает LCG_<KIND>_MAX_AGE, LCG_<KIND>_MAX_COUNT и LCG_<KIND>_MAX_SIZE
+func retentionLimits(kind string) RetentionLimits {
+	return RetentionLimits{
+		MaxAge:   getEnv("LCG_"+kind+"_MAX_AGE", ""),
+		MaxCount: getEnv("LCG_"+kind+"_MAX_COUNT", ""),
+		MaxSize:  getEnv("LCG_"+kind+"_MAX_SIZE", ""),
+	}
+}
+
+// HistoryFileName имя файла истории по умолчанию для хранилища
+func HistoryFileName(backend string) string {
+	switch backend {
+	case "jsonl":
+		return "lcg_history.jsonl"
+	case "bolt":
+		return "lcg_history.db"
+	}
+	return "lcg_history.json"
+}
+
 func (c Config) IsNoHistoryEnabled() bool {
 	v := strings.TrimSpace(c.NoHistoryEnv)
 	if v == "" {
@@ -0,0 +1,185 @@
+package diffutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells ограничивает размер таблицы LCS; для больших текстов различающаяся
+// середина показывается целиком как удаленная и добавленная
+const maxCells = 4_000_000
+
+// Op тип изменения строки
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Line строка результата сравнения
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines построчно сравнивает два текста (наибольшая общая подпоследовательность)
+func Lines(a, b string) []Line {
+	al, bl := splitLines(a), splitLines(b)
+
+	// Общие начало и конец не участвуют в LCS
+	prefix := 0
+	for prefix < len(al) && prefix < len(bl) && al[prefix] == bl[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(al)-prefix && suffix < len(bl)-prefix && al[len(al)-1-suffix] == bl[len(bl)-1-suffix] {
+		suffix++
+	}
+
+	var result []Line
+	for _, s := range al[:prefix] {
+		result = append(result, Line{Equal, s})
+	}
+	result = append(result, middle(al[prefix:len(al)-suffix], bl[prefix:len(bl)-suffix])...)
+	for _, s := range al[len(al)-suffix:] {
+		result = append(result, Line{Equal, s})
+	}
+	return result
+}
+
+// HasChanges сообщает, есть ли в результате сравнения отличия
+func HasChanges(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified возвращает различия в формате unified diff с context строками контекста.
+// Для одинаковых текстов возвращает пустую строку.
+func Unified(a, b, fromName, toName string, context int) string {
+	lines := Lines(a, b)
+	if !HasChanges(lines) {
+		return ""
+	}
+
+	// Позиции строк в исходном и новом тексте перед каждой строкой результата
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.Op != Insert {
+			aPos[i+1]++
+		}
+		if l.Op != Delete {
+			bPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+		// Собираем изменения, разделенные не более чем 2*context общими строками
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != Equal {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(len(lines), end+context)
+
+		aStart, aCount := aPos[start], aPos[end]-aPos[start]
+		bStart, bCount := bPos[start], bPos[end]-bPos[start]
+		if aCount > 0 {
+			aStart++
+		}
+		if bCount > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, l := range lines[start:end] {
+			switch l.Op {
+			case Equal:
+				sb.WriteString(" ")
+			case Delete:
+				sb.WriteString("-")
+			case Insert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(l.Text + "\n")
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// middle сравнивает различающуюся часть текстов
+func middle(a, b []string) []Line {
+	var result []Line
+	if len(a)*len(b) > maxCells {
+		for _, s := range a {
+			result = append(result, Line{Delete, s})
+		}
+		for _, s := range b {
+			result = append(result, Line{Insert, s})
+		}
+		return result
+	}
+
+	// lcs[i][j] — длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, Line{Delete, a[i]})
+			i++
+		default:
+			result = append(result, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, Line{Insert, b[j]})
+	}
+	return result
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
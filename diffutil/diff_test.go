@@ -0,0 +1,40 @@
+package diffutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "#!/bin/bash\nset -e\necho one\necho two\nexit 0\n"
+	b := "#!/bin/bash\nset -euo pipefail\necho one\necho two\necho three\nexit 0\n"
+
+	got := Unified(a, b, "v1", "v2", 1)
+	want := "--- v1\n+++ v2\n" +
+		"@@ -1,5 +1,6 @@\n" +
+		" #!/bin/bash\n-set -e\n+set -euo pipefail\n echo one\n echo two\n+echo three\n exit 0\n"
+	if got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+
+	if Unified(a, a, "v1", "v2", 3) != "" {
+		t.Error("identical texts must produce an empty diff")
+	}
+
+	// Далекие изменения попадают в разные блоки
+	long := strings.Repeat("line\n", 20)
+	changed := "first\n" + long + "last\n"
+	if n := strings.Count(Unified(long, changed, "a", "b", 2), "@@ -"); n != 2 {
+		t.Errorf("expected 2 hunks, got %d", n)
+	}
+}
+
+func TestLinesEmpty(t *testing.T) {
+	lines := Lines("", "a\nb\n")
+	if len(lines) != 2 || lines[0].Op != Insert || lines[1].Op != Insert {
+		t.Errorf("unexpected lines: %+v", lines)
+	}
+	if got := Unified("", "a\n", "a", "b", 3); !strings.Contains(got, "@@ -0,0 +1,1 @@") {
+		t.Errorf("unexpected header for new file:\n%s", got)
+	}
+}
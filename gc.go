@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/retention"
+	"github.com/urfave/cli/v2"
+)
+
+// gcKindNames названия видов данных для вывода
+var gcKindNames = map[string]string{
+	retention.KindHistory:      "История",
+	retention.KindResults:      "Результаты",
+	retention.KindExplanations: "Объяснения",
+}
+
+// runRetention применяет ограничения хранения из настроек; со skipHistory
+// (--no-history, LCG_NO_HISTORY) история не очищается
+func runRetention(dryRun, skipHistory bool) ([]retention.Report, error) {
+	policies, err := retention.FromConfig(config.AppConfig.Retention)
+	if err != nil {
+		return nil, err
+	}
+	if !policies.Enabled() {
+		return nil, nil
+	}
+	var store history.Store
+	if !skipHistory {
+		if store, err = cmdPackage.OpenHistory(config.AppConfig.ResultHistory); err != nil {
+			return nil, err
+		}
+	}
+	return retention.Run(store, config.AppConfig.ResultFolder, policies, dryRun)
+}
+
+// executeGC выполняет очистку по ограничениям хранения и выводит отчет
+func executeGC(dryRun, verbose, skipHistory bool) error {
+	reports, err := runRetention(dryRun, skipHistory)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка очистки: %v", err), exitValidation)
+	}
+	if len(reports) == 0 {
+		printColored("Ограничения хранения не заданы (LCG_HISTORY_MAX_*, LCG_RESULTS_MAX_*, LCG_EXPLANATIONS_MAX_*)\n", colorYellow)
+		return nil
+	}
+
+	verb := "Удалено"
+	if dryRun {
+		verb = "Будет удалено"
+		printColored("🔍 Пробный запуск: ничего не удаляется\n", colorYellow)
+	}
+	for _, r := range reports {
+		fmt.Printf("%s (%s): %s %d, освобождается %s\n", gcKindNames[r.Kind], r.Policy, verb, len(r.Removed), retention.FormatSize(r.Freed))
+		if dryRun || verbose {
+			for _, item := range r.Removed {
+				fmt.Printf("  - %s  %s  %s\n", item.Time.Format("2006-01-02 15:04"), retention.FormatSize(item.Size), item.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// autoRetention применяет ограничения при запуске CLI; ошибки и итог выводятся в stderr,
+// чтобы не мешать выводу команды
+func autoRetention(skipHistory bool) {
+	reports, err := runRetention(false, skipHistory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Очистка по ограничениям хранения: %v\n", err)
+		return
+	}
+	removed, freed := 0, int64(0)
+	for _, r := range reports {
+		removed += len(r.Removed)
+		freed += r.Freed
+	}
+	if removed > 0 {
+		fmt.Fprintf(os.Stderr, "🧹 Удалено устаревших записей и файлов: %d (%s)\n", removed, retention.FormatSize(freed))
+	}
+}
+
+// pinResults закрепляет или открепляет файлы результатов, защищая их от очистки
+func pinResults(names []string, pinned bool) error {
+	if len(names) == 0 {
+		return cli.Exit("Укажите файлы результатов", exitValidation)
+	}
+	if err := retention.SetPinned(config.AppConfig.ResultFolder, names, pinned); err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	if pinned {
+		printColored(fmt.Sprintf("📌 Закреплено файлов: %d\n", len(names)), colorGreen)
+	} else {
+		printColored(fmt.Sprintf("Откреплено файлов: %d\n", len(names)), colorGreen)
+	}
+	return nil
+}
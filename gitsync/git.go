@@ -0,0 +1,138 @@
+package gitsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Repo локальная копия репозитория синхронизации
+type Repo struct {
+	Dir    string
+	Remote string
+	Branch string
+}
+
+// git выполняет команду git в каталоге репозитория и возвращает stdout
+func (r Repo) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.Dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Без терминала git не должен спрашивать логин или пароль
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Prepare клонирует репозиторий при первом запуске и приводит рабочую копию к
+// состоянию ветки на сервере. Локальные файлы копии не сохраняются: все данные
+// берутся из истории, результатов и промптов.
+func (r Repo) Prepare() error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("для синхронизации нужен git: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(r.Dir, 0700); err != nil {
+			return err
+		}
+		if _, err := r.git("init", "-q"); err != nil {
+			return err
+		}
+		if _, err := r.git("remote", "add", "origin", r.Remote); err != nil {
+			return err
+		}
+	} else if _, err := r.git("remote", "set-url", "origin", r.Remote); err != nil {
+		return err
+	}
+
+	if _, err := r.git("fetch", "-q", "origin"); err != nil {
+		return err
+	}
+	remoteRef := "refs/remotes/origin/" + r.Branch
+	if _, err := r.git("rev-parse", "-q", "--verify", remoteRef); err == nil {
+		if _, err := r.git("checkout", "-q", "-B", r.Branch, remoteRef); err != nil {
+			return err
+		}
+		if _, err := r.git("reset", "-q", "--hard", remoteRef); err != nil {
+			return err
+		}
+	} else {
+		// Пустой репозиторий: первая синхронизация создаст ветку
+		if _, err := r.git("symbolic-ref", "HEAD", "refs/heads/"+r.Branch); err != nil {
+			return err
+		}
+		if _, err := r.git("rev-parse", "-q", "--verify", "HEAD"); err == nil {
+			if _, err := r.git("reset", "-q", "--hard"); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := r.git("clean", "-q", "-fd")
+	return err
+}
+
+// Commit фиксирует изменения рабочей копии; false — изменений нет
+func (r Repo) Commit(message string) (bool, error) {
+	if _, err := r.git("add", "-A"); err != nil {
+		return false, err
+	}
+	status, err := r.git("status", "--porcelain")
+	if err != nil || status == "" {
+		return false, err
+	}
+	args := []string{"commit", "-q", "-m", message}
+	if name, _ := r.git("config", "user.name"); name == "" {
+		// Автор по умолчанию, если git на машине не настроен
+		host, _ := os.Hostname()
+		args = append([]string{"-c", "user.name=lcg", "-c", "user.email=lcg@" + host}, args...)
+	}
+	_, err = r.git(args...)
+	return err == nil, err
+}
+
+// Push отправляет ветку на сервер
+func (r Repo) Push() error {
+	_, err := r.git("push", "-q", "origin", "HEAD:refs/heads/"+r.Branch)
+	return err
+}
+
+// stateFile состояние прошлой синхронизации: хранится внутри .git, чтобы не
+// попадать в репозиторий
+func (r Repo) stateFile() string {
+	return filepath.Join(r.Dir, ".git", "lcg-sync-state.json")
+}
+
+// LoadState читает хеши элементов на момент прошлой синхронизации
+func (r Repo) LoadState() (map[string]string, error) {
+	state := map[string]string{}
+	data, err := os.ReadFile(r.stateFile())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", r.stateFile(), err)
+	}
+	return state, nil
+}
+
+// SaveState записывает состояние после успешной синхронизации
+func (r Repo) SaveState(state map[string]string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.stateFile(), data, 0600)
+}
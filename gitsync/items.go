@@ -0,0 +1,277 @@
+package gitsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+// source синхронизируемые данные одного вида. Элементы лежат в репозитории в
+// каталоге dir, по файлу на элемент, поэтому изменения разных элементов на
+// разных машинах не конфликтуют.
+type source interface {
+	// dir каталог элементов в репозитории
+	dir() string
+	// local элементы на этой машине (путь в репозитории -> содержимое) и пути
+	// элементов, которые нельзя отправлять в репозиторий
+	local() (map[string][]byte, map[string]bool, error)
+	// merge объединяет версии элемента, измененного на обеих сторонах
+	merge(path string, local, remote []byte) []byte
+	// apply применяет к локальным данным Pull, Merge или DeleteLocal
+	apply(c Change) error
+}
+
+// historySource записи истории: history/<хеш запроса>.json. Запись определяется
+// запросом (после history.NormalizeQuery), как и при сохранении в историю.
+type historySource struct {
+	store   history.Store
+	exclude []string
+	indexes map[string]int // путь -> номер локальной записи
+}
+
+func (s *historySource) dir() string { return "history" }
+
+// entryPath путь записи в репозитории
+func entryPath(command string) string {
+	sum := sha256.Sum256([]byte(history.NormalizeQuery(command)))
+	return "history/" + hex.EncodeToString(sum[:8]) + ".json"
+}
+
+// encodeEntry записывает запись в каноническом виде: без локального номера и
+// со временем в UTC, чтобы одинаковые записи на разных машинах совпадали побайтно
+func encodeEntry(e history.Entry) []byte {
+	e.Index = 0
+	e.Timestamp = e.Timestamp.UTC()
+	e.Versions = append([]history.Version(nil), e.Versions...)
+	for i := range e.Versions {
+		e.Versions[i].Timestamp = e.Versions[i].Timestamp.UTC()
+	}
+	data, _ := json.MarshalIndent(e, "", "  ")
+	return append(data, '\n')
+}
+
+func decodeEntry(path string, data []byte) (history.Entry, error) {
+	var e history.Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, fmt.Errorf("%s: %v", path, err)
+	}
+	return e, nil
+}
+
+func (s *historySource) local() (map[string][]byte, map[string]bool, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("история: %v", err)
+	}
+	items := map[string][]byte{}
+	excluded := map[string]bool{}
+	s.indexes = map[string]int{}
+	for _, e := range entries {
+		path := entryPath(e.Command)
+		if _, ok := s.indexes[path]; ok {
+			continue
+		}
+		s.indexes[path] = e.Index
+		if excludedEntry(s.exclude, e) {
+			excluded[path] = true
+			continue
+		}
+		items[path] = encodeEntry(e)
+	}
+	return items, excluded, nil
+}
+
+// excludedEntry запись помечена тегом, который не синхронизируется
+func excludedEntry(exclude []string, e history.Entry) bool {
+	for _, tag := range exclude {
+		if e.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *historySource) merge(path string, local, remote []byte) []byte {
+	l, lerr := decodeEntry(path, local)
+	r, rerr := decodeEntry(path, remote)
+	if lerr != nil || rerr != nil {
+		return PickByHash(path, local, remote)
+	}
+	return encodeEntry(history.Merge(l, r))
+}
+
+func (s *historySource) apply(c Change) error {
+	index, exists := s.indexes[c.Path]
+	if c.Op == DeleteLocal {
+		if !exists {
+			return nil
+		}
+		return s.store.Delete(index)
+	}
+	e, err := decodeEntry(c.Path, c.Content)
+	if err != nil {
+		return err
+	}
+	if exists {
+		e.Index = index
+		return s.store.Put(e)
+	}
+	index, err = s.store.Add(e)
+	s.indexes[c.Path] = index
+	return err
+}
+
+// resultSource файлы результатов (*.md): results/<имя файла>. Файл в папке
+// результатов читается и записывается с учетом шифрования. Файл не
+// отправляется, если несинхронизируемый тег стоит в его front-matter или у
+// связанной записи истории.
+type resultSource struct {
+	folder  string
+	store   history.Store // nil — история не синхронизируется
+	exclude []string
+}
+
+func (s *resultSource) dir() string { return "results" }
+
+func (s *resultSource) local() (map[string][]byte, map[string]bool, error) {
+	items := map[string][]byte{}
+	files, err := os.ReadDir(s.folder)
+	if os.IsNotExist(err) {
+		return items, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	excluded := map[string]bool{}
+	for _, file := range files {
+		if !file.Type().IsRegular() || !strings.HasSuffix(file.Name(), ".md") {
+			continue
+		}
+		data, err := storage.ReadFile(filepath.Join(s.folder, file.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		path := "results/" + file.Name()
+		if s.excluded(data) {
+			excluded[path] = true
+			continue
+		}
+		items[path] = data
+	}
+	return items, excluded, nil
+}
+
+// excluded файл результата помечен тегом, который не синхронизируется, сам
+// или через связанную запись истории
+func (s *resultSource) excluded(data []byte) bool {
+	if len(s.exclude) == 0 {
+		return false
+	}
+	meta, _, ok := results.Parse(data)
+	if !ok {
+		return false
+	}
+	if excludedEntry(s.exclude, history.Entry{Tags: meta.Tags}) {
+		return true
+	}
+	if meta.HistoryID == 0 || s.store == nil {
+		return false
+	}
+	e, err := s.store.Get(meta.HistoryID)
+	return err == nil && excludedEntry(s.exclude, e)
+}
+
+func (s *resultSource) merge(path string, local, remote []byte) []byte {
+	return PickByHash(path, local, remote)
+}
+
+func (s *resultSource) apply(c Change) error {
+	path := filepath.Join(s.folder, strings.TrimPrefix(c.Path, "results/"))
+	if c.Op == DeleteLocal {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(s.folder, 0755); err != nil {
+		return err
+	}
+	return storage.WriteFile(path, c.Content, 0644)
+}
+
+// promptSource пользовательские промпты: prompts/<имя>.json. Встроенные
+// промпты есть на каждой машине и не синхронизируются.
+type promptSource struct {
+	pm  *gpt.PromptManager
+	ids map[string]int // путь -> ID локального промпта
+}
+
+// promptFile промпт в репозитории; ID у каждой машины свой
+type promptFile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Content     string `json:"content"`
+}
+
+func (s *promptSource) dir() string { return "prompts" }
+
+// promptPath путь промпта в репозитории по имени
+func promptPath(name string) string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, strings.TrimSpace(name))
+	return "prompts/" + slug + ".json"
+}
+
+func (s *promptSource) local() (map[string][]byte, map[string]bool, error) {
+	items := map[string][]byte{}
+	s.ids = map[string]int{}
+	for _, p := range s.pm.Prompts {
+		if s.pm.IsDefaultPromptByID(p) {
+			continue
+		}
+		path := promptPath(p.Name)
+		if _, ok := s.ids[path]; ok {
+			continue
+		}
+		s.ids[path] = p.ID
+		data, _ := json.MarshalIndent(promptFile{Name: p.Name, Description: p.Description, Content: p.Content}, "", "  ")
+		items[path] = append(data, '\n')
+	}
+	return items, nil, nil
+}
+
+func (s *promptSource) merge(path string, local, remote []byte) []byte {
+	return PickByHash(path, local, remote)
+}
+
+func (s *promptSource) apply(c Change) error {
+	id, exists := s.ids[c.Path]
+	if c.Op == DeleteLocal {
+		if !exists {
+			return nil
+		}
+		return s.pm.DeletePrompt(id)
+	}
+	var p promptFile
+	if err := json.Unmarshal(c.Content, &p); err != nil {
+		return fmt.Errorf("%s: %v", c.Path, err)
+	}
+	if exists {
+		return s.pm.UpdatePrompt(id, p.Name, p.Description, p.Content)
+	}
+	return s.pm.AddPrompt(p.Name, p.Description, p.Content)
+}
@@ -0,0 +1,102 @@
+package gitsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// Op действие над элементом после сравнения с прошлой синхронизацией
+type Op int
+
+const (
+	Keep         Op = iota // одинаково на обеих сторонах
+	Pull                   // взять из репозитория
+	Push                   // отправить в репозиторий
+	Merge                  // изменено на обеих сторонах: объединить
+	DeleteLocal            // удалено в репозитории
+	DeleteRemote           // удалено локально
+)
+
+// Change действие над элементом: путь в репозитории и итоговое содержимое
+// (для Merge — объединенное, для удаления — nil)
+type Change struct {
+	Path    string
+	Op      Op
+	Content []byte
+}
+
+// MergeFunc объединяет локальную и удаленную версии элемента, измененного на
+// обеих сторонах. Результат не должен зависеть от порядка аргументов, иначе
+// машины будут бесконечно перезаписывать друг друга.
+type MergeFunc func(path string, local, remote []byte) []byte
+
+// Hash отпечаток содержимого для состояния синхронизации
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// PickByHash детерминированно выбирает одну из версий: с большим хешем содержимого.
+// Используется для файлов, которые нельзя объединить по смыслу.
+func PickByHash(_ string, local, remote []byte) []byte {
+	if Hash(local) >= Hash(remote) {
+		return local
+	}
+	return remote
+}
+
+// Plan сравнивает локальные элементы и элементы репозитория с состоянием прошлой
+// синхронизации (base: путь -> хеш) и возвращает действия и новое состояние.
+// Удаление на одной стороне применяется, только если другая сторона не менялась;
+// иначе измененная версия побеждает удаление.
+func Plan(base map[string]string, local, remote map[string][]byte, merge MergeFunc) ([]Change, map[string]string) {
+	paths := map[string]bool{}
+	for p := range base {
+		paths[p] = true
+	}
+	for p := range local {
+		paths[p] = true
+	}
+	for p := range remote {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	next := map[string]string{}
+	for _, p := range sorted {
+		l, lok := local[p]
+		r, rok := remote[p]
+		b, bok := base[p]
+		lUnchanged := lok && bok && Hash(l) == b
+		rUnchanged := rok && bok && Hash(r) == b
+
+		switch {
+		case lok && rok && bytes.Equal(l, r):
+			next[p] = Hash(l)
+		case !lok && !rok:
+			// Удалено везде
+		case lUnchanged && !rok:
+			changes = append(changes, Change{Path: p, Op: DeleteLocal})
+		case rUnchanged && !lok:
+			changes = append(changes, Change{Path: p, Op: DeleteRemote})
+		case lUnchanged || !lok:
+			changes = append(changes, Change{Path: p, Op: Pull, Content: r})
+			next[p] = Hash(r)
+		case rUnchanged || !rok:
+			changes = append(changes, Change{Path: p, Op: Push, Content: l})
+			next[p] = Hash(l)
+		default:
+			merged := merge(p, l, r)
+			changes = append(changes, Change{Path: p, Op: Merge, Content: merged})
+			next[p] = Hash(merged)
+		}
+	}
+	return changes, next
+}
@@ -0,0 +1,257 @@
+// Package gitsync синхронизирует историю, файлы результатов и пользовательские
+// промпты между машинами через git-репозиторий.
+//
+// Каждый элемент хранится в репозитории отдельным файлом, поэтому правки разных
+// записей на разных машинах не конфликтуют. Состояние прошлой синхронизации
+// (хеши элементов) позволяет отличить удаление на одной стороне от добавления на
+// другой. Если элемент изменен на обеих сторонах, записи истории объединяются
+// (history.Merge), а для остальных файлов детерминированно выбирается одна
+// версия, так что все машины приходят к одному результату.
+package gitsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+// maxAttempts попытки синхронизации, если другая машина успела отправить
+// изменения раньше
+const maxAttempts = 3
+
+// Options что и куда синхронизировать
+type Options struct {
+	Config       config.SyncConfig
+	Store        history.Store      // nil — история не синхронизируется
+	ResultFolder string             // пусто — результаты не синхронизируются
+	Prompts      *gpt.PromptManager // nil — промпты не синхронизируются
+	DryRun       bool               // только показать изменения
+}
+
+// Counts изменения одного вида данных
+type Counts struct {
+	Pulled        int // получено из репозитория
+	Pushed        int // отправлено в репозиторий
+	Merged        int // объединено
+	DeletedLocal  int // удалено локально вслед за репозиторием
+	DeletedRemote int // удалено из репозитория вслед за локальным удалением
+	Excluded      int // не отправлено из-за исключающих тегов
+}
+
+// Total число изменений
+func (c Counts) Total() int {
+	return c.Pulled + c.Pushed + c.Merged + c.DeletedLocal + c.DeletedRemote
+}
+
+// KindReport итог по одному виду данных
+type KindReport struct {
+	Name string // history, results или prompts
+	Counts
+}
+
+// Report итог синхронизации
+type Report struct {
+	Kinds     []KindReport
+	Committed bool // в репозиторий отправлен коммит
+}
+
+// Run выполняет синхронизацию: получает ветку из репозитория, сравнивает ее и
+// локальные данные с прошлой синхронизацией, отправляет коммит с локальными
+// изменениями и применяет изменения из репозитория
+func Run(opts Options) (Report, error) {
+	if opts.Config.Remote == "" {
+		return Report{}, fmt.Errorf("репозиторий синхронизации не задан (LCG_SYNC_REMOTE)")
+	}
+	repo := Repo{Dir: opts.Config.Dir, Remote: opts.Config.Remote, Branch: opts.Config.Branch}
+	sources := opts.sources()
+
+	for attempt := 1; ; attempt++ {
+		report, err := run(repo, sources, opts.DryRun)
+		if err != nil && attempt < maxAttempts && strings.Contains(err.Error(), "rejected") {
+			continue
+		}
+		return report, err
+	}
+}
+
+// sources включенные виды данных
+func (opts Options) sources() []source {
+	var sources []source
+	exclude := history.ParseTags(opts.Config.ExcludeTags)
+	if opts.Store != nil {
+		sources = append(sources, &historySource{store: opts.Store, exclude: exclude})
+	}
+	if opts.ResultFolder != "" {
+		sources = append(sources, &resultSource{folder: opts.ResultFolder, store: opts.Store, exclude: exclude})
+	}
+	if opts.Prompts != nil {
+		sources = append(sources, &promptSource{pm: opts.Prompts})
+	}
+	return sources
+}
+
+// run одна попытка синхронизации
+func run(repo Repo, sources []source, dryRun bool) (Report, error) {
+	var report Report
+	if err := repo.Prepare(); err != nil {
+		return report, err
+	}
+	base, err := repo.LoadState()
+	if err != nil {
+		return report, err
+	}
+
+	// Состояние выключенных видов данных сохраняется до их включения
+	state := map[string]string{}
+	for path, hash := range base {
+		state[path] = hash
+	}
+	plans := make([][]Change, len(sources))
+	for i, src := range sources {
+		local, excluded, err := src.local()
+		if err != nil {
+			return report, err
+		}
+		remote, err := readItems(repo.Dir, src.dir())
+		if err != nil {
+			return report, err
+		}
+		kindBase := map[string]string{}
+		for path, hash := range base {
+			if strings.HasPrefix(path, src.dir()+"/") {
+				kindBase[path] = hash
+				delete(state, path)
+			}
+		}
+
+		kind := KindReport{Name: src.dir()}
+		// Исключенные элементы не должны оставаться в репозитории, даже если
+		// попали туда до пометки тегом
+		var changes []Change
+		for path := range excluded {
+			kind.Excluded++
+			if _, ok := remote[path]; ok {
+				changes = append(changes, Change{Path: path, Op: DeleteRemote})
+				kind.DeletedRemote++
+				delete(remote, path)
+			}
+			delete(kindBase, path)
+		}
+		planned, next := Plan(kindBase, local, remote, src.merge)
+		changes = append(changes, planned...)
+		for path, hash := range next {
+			state[path] = hash
+		}
+		for _, c := range planned {
+			switch c.Op {
+			case Pull:
+				kind.Pulled++
+			case Push:
+				kind.Pushed++
+			case Merge:
+				kind.Merged++
+			case DeleteLocal:
+				kind.DeletedLocal++
+			case DeleteRemote:
+				kind.DeletedRemote++
+			}
+		}
+		plans[i] = changes
+		report.Kinds = append(report.Kinds, kind)
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	// Сначала репозиторий: если отправка не удалась, локальные данные не меняются
+	for _, changes := range plans {
+		for _, c := range changes {
+			if err := writeRemote(repo.Dir, c); err != nil {
+				return report, err
+			}
+		}
+	}
+	committed, err := repo.Commit(commitMessage(report))
+	if err != nil {
+		return report, err
+	}
+	if committed {
+		if err := repo.Push(); err != nil {
+			return report, err
+		}
+		report.Committed = true
+	}
+
+	for i, src := range sources {
+		for _, c := range plans[i] {
+			if c.Op != Pull && c.Op != Merge && c.Op != DeleteLocal {
+				continue
+			}
+			if err := src.apply(c); err != nil {
+				return report, fmt.Errorf("%s: %v", c.Path, err)
+			}
+		}
+	}
+	return report, repo.SaveState(state)
+}
+
+// readItems читает элементы каталога репозитория (путь -> содержимое)
+func readItems(repoDir, dir string) (map[string][]byte, error) {
+	items := map[string][]byte{}
+	files, err := os.ReadDir(filepath.Join(repoDir, dir))
+	if os.IsNotExist(err) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !file.Type().IsRegular() {
+			continue
+		}
+		data, err := storage.ReadFile(filepath.Join(repoDir, dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		items[dir+"/"+file.Name()] = data
+	}
+	return items, nil
+}
+
+// writeRemote применяет к рабочей копии репозитория Push, Merge или DeleteRemote.
+// При включенном шифровании файлы в репозитории тоже зашифрованы.
+func writeRemote(repoDir string, c Change) error {
+	path := filepath.Join(repoDir, filepath.FromSlash(c.Path))
+	switch c.Op {
+	case Push, Merge:
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		return storage.WriteFile(path, c.Content, 0644)
+	case DeleteRemote:
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitMessage описание коммита: машина и число изменений по видам данных
+func commitMessage(report Report) string {
+	host, _ := os.Hostname()
+	var parts []string
+	for _, kind := range report.Kinds {
+		if n := kind.Pushed + kind.Merged + kind.DeletedRemote; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", kind.Name, n))
+		}
+	}
+	sort.Strings(parts)
+	return fmt.Sprintf("lcg sync from %s (%s)", host, strings.Join(parts, ", "))
+}
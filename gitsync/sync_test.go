@@ -0,0 +1,146 @@
+package gitsync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+)
+
+func TestPlan(t *testing.T) {
+	a, b := []byte("a"), []byte("b")
+	base := map[string]string{"same": Hash(a), "pulled": Hash(a), "deleted-remote": Hash(a), "deleted-local": Hash(a), "edited-deleted": Hash(a), "both": Hash(a)}
+	local := map[string][]byte{"same": a, "pulled": a, "deleted-local": a, "edited-deleted": b, "both": b, "new-local": a}
+	remote := map[string][]byte{"same": a, "pulled": b, "deleted-remote": a, "both": []byte("c"), "new-remote": a}
+	changes, next := Plan(base, local, remote, func(_ string, l, r []byte) []byte { return append(l, r...) })
+
+	want := map[string]Op{
+		"both":           Merge,
+		"deleted-local":  DeleteLocal,
+		"deleted-remote": DeleteRemote,
+		"edited-deleted": Push,
+		"new-local":      Push,
+		"new-remote":     Pull,
+		"pulled":         Pull,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for _, c := range changes {
+		if want[c.Path] != c.Op {
+			t.Errorf("%s: expected op %d, got %d", c.Path, want[c.Path], c.Op)
+		}
+	}
+	if string(changes[0].Content) != "bc" || next["both"] != Hash([]byte("bc")) {
+		t.Errorf("merged content is wrong: %q", changes[0].Content)
+	}
+	if _, ok := next["deleted-local"]; ok {
+		t.Error("deleted items must leave the state")
+	}
+	if next["same"] != Hash(a) {
+		t.Error("unchanged items must stay in the state")
+	}
+}
+
+func TestSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+
+	type machine struct {
+		store   history.Store
+		results string
+		opts    Options
+	}
+	newMachine := func(name string) machine {
+		store := history.NewJSON(filepath.Join(dir, name, "history.json"))
+		results := filepath.Join(dir, name, "results")
+		cfg := config.SyncConfig{Remote: remote, Dir: filepath.Join(dir, name, "sync"), Branch: "main", ExcludeTags: "private"}
+		return machine{store, results, Options{Config: cfg, Store: store, ResultFolder: results}}
+	}
+	a, b := newMachine("a"), newMachine("b")
+	sync := func(m machine) Report {
+		t.Helper()
+		report, err := Run(m.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.store.Add(history.Entry{Command: "list files", Response: "ls", Timestamp: base})
+	a.store.Add(history.Entry{Command: "secret", Response: "cat key", Tags: []string{"private"}, Timestamp: base})
+	os.MkdirAll(a.results, 0755)
+	os.WriteFile(filepath.Join(a.results, "gpt_request_1.md"), []byte("# ls"), 0644)
+	// Результаты с исключающим тегом и связанные с исключенной записью истории
+	os.WriteFile(filepath.Join(a.results, "gpt_request_2.md"), []byte("---\nid: x\ntags: [private]\n---\n# key\n"), 0644)
+	os.WriteFile(filepath.Join(a.results, "gpt_request_3.md"), []byte("---\nid: y\nhistory_id: 2\n---\n# cat key\n"), 0644)
+	b.store.Add(history.Entry{Command: "disk usage", Response: "df -h", Timestamp: base})
+
+	if r := sync(a); r.Kinds[0].Pushed != 1 || r.Kinds[0].Excluded != 1 || r.Kinds[1].Pushed != 1 || r.Kinds[1].Excluded != 2 {
+		t.Errorf("unexpected first sync report: %+v", r)
+	}
+	if r := sync(b); r.Kinds[0].Pulled != 1 || r.Kinds[0].Pushed != 1 || r.Kinds[1].Pulled != 1 {
+		t.Errorf("unexpected second sync report: %+v", r)
+	}
+	sync(a)
+	for _, m := range []machine{a, b} {
+		entries, _ := m.store.Find(history.Filter{Query: "disk usage"})
+		if len(entries) != 1 {
+			t.Errorf("both machines must have the entry, got %+v", entries)
+		}
+		if _, err := os.Stat(filepath.Join(m.results, "gpt_request_1.md")); err != nil {
+			t.Error(err)
+		}
+	}
+	if entries, _ := b.store.Find(history.Filter{Query: "secret"}); len(entries) != 0 {
+		t.Error("private entries must not be synchronized")
+	}
+	for _, name := range []string{"gpt_request_2.md", "gpt_request_3.md"} {
+		if _, err := os.Stat(filepath.Join(b.results, name)); err == nil {
+			t.Errorf("private result %s must not be synchronized", name)
+		}
+	}
+
+	// Один запрос изменен на обеих машинах: ответы объединяются версиями
+	a.store.Update(1, func(e *history.Entry) error {
+		e.Response, e.Timestamp = "ls -la", base.Add(time.Hour)
+		return nil
+	})
+	entries, _ := b.store.Find(history.Filter{Query: "list files"})
+	b.store.Update(entries[0].Index, func(e *history.Entry) error {
+		e.Starred, e.Timestamp = true, base.Add(time.Minute)
+		return nil
+	})
+	sync(a)
+	if r := sync(b); r.Kinds[0].Merged != 1 {
+		t.Errorf("expected a merge, got %+v", r)
+	}
+	sync(a)
+	for _, m := range []machine{a, b} {
+		entries, _ := m.store.Find(history.Filter{Query: "list files"})
+		if len(entries) != 1 || entries[0].Response != "ls -la" || !entries[0].Starred || len(entries[0].Versions) != 2 {
+			t.Errorf("merged entry is wrong: %+v", entries)
+		}
+	}
+
+	// Удаление на одной машине применяется на другой
+	os.Remove(filepath.Join(b.results, "gpt_request_1.md"))
+	sync(b)
+	if r := sync(a); r.Kinds[1].DeletedLocal != 1 {
+		t.Errorf("expected a local deletion, got %+v", r)
+	}
+	if _, err := os.Stat(filepath.Join(a.results, "gpt_request_1.md")); !os.IsNotExist(err) {
+		t.Error("deleted result must be removed")
+	}
+}
@@ -10,6 +10,14 @@ require gopkg.in/yaml.v3 v3.0.1
 
 require github.com/golang-jwt/jwt/v5 v5.3.0
 
+require golang.org/x/term v0.29.0
+
+require golang.org/x/sys v0.30.0
+
+require go.etcd.io/bbolt v1.3.10
+
+require golang.org/x/crypto v0.33.0
+
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.5 //indirect
 	github.com/russross/blackfriday/v2 v2.1.0
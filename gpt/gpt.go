@@ -199,20 +199,29 @@ func NewGpt3(providerType, host, apiKey, model, prompt string, temperature float
 
 // Completions обновленный метод с поддержкой разных провайдеров
 func (gpt3 *Gpt3) Completions(ask string) string {
-	messages := []Chat{
-		{"system", gpt3.Prompt},
-		{"user", ask + ". " + gpt3.Prompt},
-	}
-
-	response, err := gpt3.Provider.Chat(messages)
+	response, err := gpt3.Complete(ask)
 	if err != nil {
-		fmt.Printf("Ошибка при выполнении запроса: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Ошибка при выполнении запроса: %v\n", err)
 		return ""
 	}
 
 	return response
 }
 
+// Complete выполняет запрос к провайдеру и возвращает ошибку вместо ее вывода
+func (gpt3 *Gpt3) Complete(ask string) (string, error) {
+	return gpt3.CompleteChat(nil, ask)
+}
+
+// CompleteChat выполняет запрос с предыдущими репликами диалога (history — пары user/assistant)
+func (gpt3 *Gpt3) CompleteChat(history []Chat, ask string) (string, error) {
+	messages := make([]Chat, 0, len(history)+2)
+	messages = append(messages, Chat{"system", gpt3.Prompt})
+	messages = append(messages, history...)
+	messages = append(messages, Chat{"user", ask + ". " + gpt3.Prompt})
+	return gpt3.Provider.Chat(messages)
+}
+
 // Health проверяет состояние провайдера
 func (gpt3 *Gpt3) Health() error {
 	return gpt3.Provider.Health()
@@ -0,0 +1,141 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+)
+
+// varNamePattern имя переменной шаблона: доступна в промпте как {{.Имя}}
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// missingVarPattern имя отсутствующей переменной в ошибке text/template
+var missingVarPattern = regexp.MustCompile(`no entry for key "([^"]+)"`)
+
+// MissingVarError в промпте используется переменная, которой нет среди заданных
+type MissingVarError struct {
+	Name string
+}
+
+func (e *MissingVarError) Error() string {
+	return fmt.Sprintf("в промпте используется переменная {{.%s}}, но она не задана", e.Name)
+}
+
+// ParsePromptVars разбирает переменные вида имя=значение
+func ParsePromptVars(pairs []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, pair := range pairs {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !varNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("неверная переменная %q: ожидается имя=значение, имя из латинских букв, цифр и _", pair)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// commandPlaceholders значение {{.command}} по языку: встроенный промпт #5
+// ссылается на команду, которая приходит в сообщении пользователя
+var commandPlaceholders = map[string]string{
+	"ru": "команда из сообщения пользователя",
+	"en": "the command from the user's message",
+}
+
+// PromptVars встроенные переменные запроса (OS, Shell, Cwd, User, Lang, Date,
+// Hostname, command), дополненные пользовательскими; пользовательские перекрывают встроенные
+func PromptVars(lang string, custom map[string]string) map[string]string {
+	vars := map[string]string{
+		"OS":      runtime.GOOS,
+		"Shell":   "",
+		"User":    "",
+		"Lang":    lang,
+		"Date":    time.Now().Format("2006-01-02"),
+		"command": commandPlaceholders["en"],
+	}
+	if placeholder, ok := commandPlaceholders[lang]; ok {
+		vars["command"] = placeholder
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" && runtime.GOOS == "windows" {
+		shell = os.Getenv("ComSpec")
+	}
+	if shell != "" {
+		vars["Shell"] = filepath.Base(shell)
+	}
+	vars["Cwd"], _ = os.Getwd()
+	if currentUser, err := user.Current(); err == nil {
+		vars["User"] = currentUser.Username
+	}
+	vars["Hostname"], _ = os.Hostname()
+	for name, value := range custom {
+		vars[name] = value
+	}
+	return vars
+}
+
+// RenderPrompt подставляет переменные в текст промпта ({{.OS}}, {{.team}}).
+// Промпт без {{ возвращается как есть; отсутствующая переменная — *MissingVarError.
+func RenderPrompt(content string, vars map[string]string) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
+	t, err := template.New("prompt").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("ошибка шаблона промпта: %v", err)
+	}
+	var out strings.Builder
+	if err := t.Execute(&out, vars); err != nil {
+		if m := missingVarPattern.FindStringSubmatch(err.Error()); m != nil {
+			return "", &MissingVarError{Name: m[1]}
+		}
+		return "", fmt.Errorf("ошибка шаблона промпта: %v", err)
+	}
+	return out.String(), nil
+}
+
+// RenderSystemPrompt подставляет в системный промпт встроенные переменные (Lang —
+// язык промптов), переменные из LCG_PROMPT_VARS и extra (--var), которые
+// перекрывают остальные
+func (pm *PromptManager) RenderSystemPrompt(content string, extra map[string]string) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
+	custom, err := ParsePromptVars(strings.Split(config.AppConfig.PromptVars, ","))
+	if err != nil {
+		return "", fmt.Errorf("LCG_PROMPT_VARS: %v", err)
+	}
+	maps.Copy(custom, extra)
+	return RenderPrompt(content, PromptVars(pm.GetCurrentLanguage(), custom))
+}
+
+// RenderConfiguredPrompt подставляет в системный промпт переменные из настроек:
+// встроенные, LCG_PROMPT_VARS и --var. Отсутствующая переменная — ошибка с
+// подсказкой, где ее задать.
+func RenderConfiguredPrompt(content string) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
+	// --var уже проверены при разборе флагов
+	vars, _ := ParsePromptVars(config.AppConfig.MainFlags.Vars)
+	homeDir, _ := os.UserHomeDir()
+	rendered, err := NewPromptManager(homeDir).RenderSystemPrompt(content, vars)
+	var missing *MissingVarError
+	if errors.As(err, &missing) {
+		return "", fmt.Errorf("%v: задайте --var %s=<значение> или LCG_PROMPT_VARS", err, missing.Name)
+	}
+	return rendered, err
+}
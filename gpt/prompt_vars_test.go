@@ -0,0 +1,67 @@
+package gpt
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	vars := PromptVars("en", map[string]string{"team": "infra", "OS": "plan9"})
+	if vars["Lang"] != "en" || vars["team"] != "infra" || vars["OS"] != "plan9" {
+		t.Errorf("unexpected vars: %v", vars)
+	}
+	if vars := PromptVars("ru", nil); vars["OS"] != runtime.GOOS {
+		t.Errorf("OS = %q, want %q", vars["OS"], runtime.GOOS)
+	}
+
+	got, err := RenderPrompt("Commands for {{.OS}} ({{.Lang}}), team {{.team}}", vars)
+	if err != nil || got != "Commands for plan9 (en), team infra" {
+		t.Errorf("RenderPrompt = %q (%v)", got, err)
+	}
+	// Текст без шаблона не разбирается
+	if got, err := RenderPrompt("no {template} here", nil); err != nil || got != "no {template} here" {
+		t.Errorf("plain prompt changed: %q (%v)", got, err)
+	}
+
+	_, err = RenderPrompt("Region {{.region}}", vars)
+	var missing *MissingVarError
+	if !errors.As(err, &missing) || missing.Name != "region" {
+		t.Errorf("want MissingVarError for region, got %v", err)
+	}
+	if _, err := RenderPrompt("broken {{.OS", vars); err == nil {
+		t.Error("broken template must fail")
+	}
+}
+
+func TestParsePromptVars(t *testing.T) {
+	vars, err := ParsePromptVars([]string{"team=infra", "url=http://x/?a=b", ""})
+	if err != nil || vars["team"] != "infra" || vars["url"] != "http://x/?a=b" || len(vars) != 2 {
+		t.Errorf("ParsePromptVars = %v (%v)", vars, err)
+	}
+	for _, bad := range []string{"novalue", "=x", "bad-name=x"} {
+		if _, err := ParsePromptVars([]string{bad}); err == nil {
+			t.Errorf("%q must be rejected", bad)
+		}
+	}
+}
+
+func TestRenderBuiltinPrompts(t *testing.T) {
+	for name, data := range map[string]string{"unix": builtinPromptsYAML, "windows": builtinPromptsWindowsYAML} {
+		saved := builtinPrompts
+		builtinPrompts = data
+		for _, lang := range []string{"ru", "en"} {
+			vars := PromptVars(lang, nil)
+			prompts := GetBuiltinPromptsByLanguage(lang)
+			if len(prompts) == 0 {
+				t.Fatalf("%s: no builtin prompts", name)
+			}
+			for _, prompt := range prompts {
+				if _, err := RenderPrompt(prompt.Content, vars); err != nil {
+					t.Errorf("%s/%s prompt #%d %s: %v", name, lang, prompt.ID, prompt.Name, err)
+				}
+			}
+		}
+		builtinPrompts = saved
+	}
+}
@@ -0,0 +1,270 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/diffutil"
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+// promptVersionsFileName файл версий промптов рядом с sys_prompts
+const promptVersionsFileName = "sys_prompts_versions"
+
+// PromptVersion сохраненная версия промпта. Версии нумеруются с 1 для каждого
+// ID; откат и удаление тоже записываются новой версией.
+type PromptVersion struct {
+	Version     int       `json:"version"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Content     string    `json:"content,omitempty"`
+	Deleted     bool      `json:"deleted,omitempty"`
+	Note        string    `json:"note,omitempty"` // например, "откат к версии 2"
+	Timestamp   time.Time `json:"timestamp"`
+	Author      string    `json:"author,omitempty"` // пользователь@машина
+}
+
+// promptVersionsFile внутренний формат файла версий
+type promptVersionsFile struct {
+	Prompts map[int][]PromptVersion `json:"prompts"`
+}
+
+// Prompt промпт этой версии
+func (v PromptVersion) Prompt(id int) SystemPrompt {
+	return SystemPrompt{ID: id, Name: v.Name, Description: v.Description, Content: v.Content}
+}
+
+// matches совпадает ли версия с промптом
+func (v PromptVersion) matches(p SystemPrompt) bool {
+	return !v.Deleted && v.Name == p.Name && v.Description == p.Description && v.Content == p.Content
+}
+
+// promptAuthor автор изменений: пользователь@машина
+func promptAuthor() string {
+	name := ""
+	if currentUser, err := user.Current(); err == nil {
+		name = currentUser.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += "@" + host
+	}
+	return name
+}
+
+// versionsFile путь к файлу версий
+func (pm *PromptManager) versionsFile() string {
+	return filepath.Join(filepath.Dir(pm.ConfigFile), promptVersionsFileName)
+}
+
+// loadVersions читает версии всех промптов; нет файла — версий нет
+func (pm *PromptManager) loadVersions() (map[int][]PromptVersion, error) {
+	data, err := storage.ReadFile(pm.versionsFile())
+	if os.IsNotExist(err) {
+		return map[int][]PromptVersion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vf promptVersionsFile
+	if err := json.Unmarshal(data, &vf); err != nil {
+		return nil, fmt.Errorf("ошибка чтения версий промптов: %v", err)
+	}
+	if vf.Prompts == nil {
+		vf.Prompts = map[int][]PromptVersion{}
+	}
+	return vf.Prompts, nil
+}
+
+// saveVersions записывает версии всех промптов
+func (pm *PromptManager) saveVersions(versions map[int][]PromptVersion) error {
+	data, err := json.MarshalIndent(promptVersionsFile{Prompts: versions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(pm.versionsFile(), data, 0644)
+}
+
+// snapshot запоминает промпты в том виде, в каком они записаны в sys_prompts
+func (pm *PromptManager) snapshot() {
+	pm.saved = make(map[int]SystemPrompt, len(pm.Prompts))
+	for _, prompt := range pm.Prompts {
+		pm.saved[prompt.ID] = prompt
+	}
+}
+
+// recordVersions добавляет версию каждому промпту, который изменился, появился
+// или был удален с прошлого сохранения. Промпт, созданный до появления версий,
+// получает первой версией свое прежнее содержимое.
+func (pm *PromptManager) recordVersions() error {
+	versions, err := pm.loadVersions()
+	if err != nil {
+		return err
+	}
+	now, author := time.Now(), promptAuthor()
+	baseline := func(old SystemPrompt) PromptVersion {
+		return PromptVersion{Version: 1, Name: old.Name, Description: old.Description, Content: old.Content, Timestamp: pm.savedAt}
+	}
+	changed := false
+	current := map[int]bool{}
+	for _, prompt := range pm.Prompts {
+		current[prompt.ID] = true
+		list := versions[prompt.ID]
+		if n := len(list); n > 0 && list[n-1].matches(prompt) {
+			continue
+		}
+		if old, ok := pm.saved[prompt.ID]; ok && len(list) == 0 {
+			if old == prompt {
+				continue
+			}
+			list = append(list, baseline(old))
+		}
+		list = append(list, PromptVersion{
+			Version:     len(list) + 1,
+			Name:        prompt.Name,
+			Description: prompt.Description,
+			Content:     prompt.Content,
+			Note:        pm.versionNote,
+			Timestamp:   now,
+			Author:      author,
+		})
+		versions[prompt.ID] = list
+		changed = true
+	}
+	for id, old := range pm.saved {
+		if current[id] {
+			continue
+		}
+		list := versions[id]
+		if len(list) == 0 {
+			list = append(list, baseline(old))
+		}
+		if list[len(list)-1].Deleted {
+			continue
+		}
+		versions[id] = append(list, PromptVersion{Version: len(list) + 1, Name: old.Name, Deleted: true, Timestamp: now, Author: author})
+		changed = true
+	}
+	pm.versionNote = ""
+	pm.snapshot()
+	pm.savedAt = now
+	if !changed {
+		return nil
+	}
+	return pm.saveVersions(versions)
+}
+
+// PromptVersions версии промпта по порядку. У промпта, который не менялся с
+// появления версий, одна версия — текущее содержимое.
+func (pm *PromptManager) PromptVersions(id int) ([]PromptVersion, error) {
+	versions, err := pm.loadVersions()
+	if err != nil {
+		return nil, err
+	}
+	if list := versions[id]; len(list) > 0 {
+		return list, nil
+	}
+	prompt, err := pm.GetPromptByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return []PromptVersion{{Version: 1, Name: prompt.Name, Description: prompt.Description, Content: prompt.Content, Timestamp: pm.savedAt}}, nil
+}
+
+// PromptVersion возвращает версию n (с 1) промпта
+func (pm *PromptManager) PromptVersion(id, n int) (PromptVersion, error) {
+	list, err := pm.PromptVersions(id)
+	if err != nil {
+		return PromptVersion{}, err
+	}
+	if n < 1 || n > len(list) {
+		return PromptVersion{}, fmt.Errorf("у промпта #%d нет версии %d (всего версий: %d)", id, n, len(list))
+	}
+	return list[n-1], nil
+}
+
+// CurrentVersion номер текущей версии промпта; 0 — промпта нет
+func (pm *PromptManager) CurrentVersion(id int) int {
+	if _, err := pm.GetPromptByID(id); err != nil {
+		return 0
+	}
+	list, err := pm.PromptVersions(id)
+	if err != nil {
+		return 0
+	}
+	return len(list)
+}
+
+// RollbackPrompt возвращает промпту содержимое версии n; удаленный промпт
+// восстанавливается. Откат записывается новой версией.
+func (pm *PromptManager) RollbackPrompt(id, n int) error {
+	v, err := pm.PromptVersion(id, n)
+	if err != nil {
+		return err
+	}
+	if v.Deleted {
+		return fmt.Errorf("версия %d промпта #%d — удаление, откатить к ней нельзя", n, id)
+	}
+	prompt := v.Prompt(id)
+	found := false
+	for i := range pm.Prompts {
+		if pm.Prompts[i].ID == id {
+			if pm.Prompts[i] == prompt {
+				return fmt.Errorf("промпт #%d уже совпадает с версией %d", id, n)
+			}
+			pm.Prompts[i] = prompt
+			found = true
+		}
+	}
+	if !found {
+		pm.Prompts = append(pm.Prompts, prompt)
+	}
+	pm.versionNote = fmt.Sprintf("откат к версии %d", n)
+	return pm.saveAllPrompts()
+}
+
+// VersionChange что изменилось в версии i списка по сравнению с предыдущей
+func VersionChange(list []PromptVersion, i int) string {
+	v := list[i]
+	switch {
+	case v.Deleted:
+		return "удален"
+	case i == 0:
+		return "первая версия"
+	case list[i-1].Deleted:
+		return "восстановлен"
+	}
+	prev := list[i-1]
+	var changes []string
+	if prev.Name != v.Name {
+		changes = append(changes, "название")
+	}
+	if prev.Description != v.Description {
+		changes = append(changes, "описание")
+	}
+	if prev.Content != v.Content {
+		changes = append(changes, "текст")
+	}
+	if len(changes) == 0 {
+		return "без изменений"
+	}
+	return strings.Join(changes, ", ")
+}
+
+// DiffPromptVersions различия двух версий промпта в формате unified diff:
+// название и описание, затем текст; пусто — версии совпадают
+func DiffPromptVersions(a, b PromptVersion) string {
+	from, to := fmt.Sprintf("версия %d", a.Version), fmt.Sprintf("версия %d", b.Version)
+	header := func(v PromptVersion) string {
+		return "название: " + v.Name + "\nописание: " + v.Description + "\n"
+	}
+	diff := ""
+	if a.Name != b.Name || a.Description != b.Description {
+		diff = diffutil.Unified(header(a), header(b), from+" (название и описание)", to+" (название и описание)", 0)
+	}
+	return diff + diffutil.Unified(a.Content, b.Content, from, to, 3)
+}
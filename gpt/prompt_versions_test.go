@@ -0,0 +1,64 @@
+package gpt
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPromptVersions(t *testing.T) {
+	dir := t.TempDir()
+	pm := &PromptManager{
+		ConfigFile: filepath.Join(dir, "sys_prompts"),
+		Prompts:    []SystemPrompt{{ID: 9, Name: "k8s", Content: "kubectl only"}},
+	}
+	// Промпт, записанный до появления версий: его содержимое станет версией 1
+	pm.snapshot()
+	if n := pm.CurrentVersion(9); n != 1 {
+		t.Fatalf("unversioned prompt must have version 1, got %d", n)
+	}
+
+	if err := pm.UpdatePrompt(9, "k8s", "", "kubectl and helm"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.UpdatePrompt(9, "k8s", "", "kubectl and helm"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := pm.PromptVersions(9)
+	if err != nil || len(list) != 2 || list[0].Content != "kubectl only" || list[1].Content != "kubectl and helm" || list[1].Author == "" {
+		t.Fatalf("unexpected versions after update: %+v (%v)", list, err)
+	}
+
+	if err := pm.RollbackPrompt(9, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.RollbackPrompt(9, 1); err == nil {
+		t.Error("rollback to the current content must fail")
+	}
+	if err := pm.DeletePrompt(9); err != nil {
+		t.Fatal(err)
+	}
+	// Версии читаются новым менеджером из файла
+	reloaded := &PromptManager{ConfigFile: pm.ConfigFile}
+	reloaded.loadAllPrompts()
+	list, _ = reloaded.PromptVersions(9)
+	if len(list) != 4 || list[2].Note != "откат к версии 1" || list[2].Content != "kubectl only" || !list[3].Deleted {
+		t.Fatalf("unexpected versions after rollback and delete: %+v", list)
+	}
+	if reloaded.nextID() != 10 {
+		t.Errorf("deleted prompt id must not be reused, next id %d", reloaded.nextID())
+	}
+	if reloaded.CurrentVersion(9) != 0 {
+		t.Error("deleted prompt has no current version")
+	}
+
+	// Удаленный промпт восстанавливается откатом
+	if err := reloaded.RollbackPrompt(9, 4); err == nil {
+		t.Error("rollback to a deletion must fail")
+	}
+	if err := reloaded.RollbackPrompt(9, 2); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := reloaded.GetPromptByID(9); err != nil || p.Content != "kubectl and helm" || reloaded.CurrentVersion(9) != 5 {
+		t.Errorf("prompt is not restored: %+v (%v)", p, err)
+	}
+}
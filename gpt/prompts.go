@@ -6,16 +6,18 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
 )
 
 // SystemPrompt представляет системный промпт
 type SystemPrompt struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Content     string `json:"content"`
+	ID          int    `json:"id" yaml:"id"`
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Content     string `json:"content" yaml:"content"`
 }
 
 // PromptManager управляет системными промптами
@@ -24,6 +26,10 @@ type PromptManager struct {
 	ConfigFile string
 	HomeDir    string
 	Language   string // Текущий язык для файла sys_prompts (en/ru)
+
+	saved       map[int]SystemPrompt // промпты в том виде, в каком записаны в файл
+	savedAt     time.Time            // время последней записи файла
+	versionNote string               // пометка к версиям при следующем сохранении
 }
 
 // NewPromptManager создает новый менеджер промптов
@@ -76,10 +82,14 @@ func (pm *PromptManager) loadAllPrompts() {
 		return
 	}
 
-	data, err := os.ReadFile(pm.ConfigFile)
+	data, err := storage.ReadFile(pm.ConfigFile)
 	if err != nil {
 		return
 	}
+	if info, err := os.Stat(pm.ConfigFile); err == nil {
+		pm.savedAt = info.ModTime()
+	}
+	defer pm.snapshot()
 
 	// Новый формат: объект с полями language и prompts
 	var pf promptsFile
@@ -114,7 +124,10 @@ func (pm *PromptManager) saveAllPrompts() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(pm.ConfigFile, data, 0644)
+	if err := storage.WriteFile(pm.ConfigFile, data, 0644); err != nil {
+		return err
+	}
+	return pm.recordVersions()
 }
 
 // SaveAllPrompts экспортированная версия saveAllPrompts
@@ -135,24 +148,6 @@ func (pm *PromptManager) SetLanguage(lang string) {
 	pm.Language = lang
 }
 
-// saveCustomPrompts сохраняет пользовательские промпты
-func (pm *PromptManager) saveCustomPrompts() error {
-	// Находим пользовательские промпты (ID > 5)
-	var customPrompts []SystemPrompt
-	for _, prompt := range pm.Prompts {
-		if prompt.ID > 5 {
-			customPrompts = append(customPrompts, prompt)
-		}
-	}
-
-	data, err := json.MarshalIndent(customPrompts, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(pm.ConfigFile, data, 0644)
-}
-
 // GetPromptByID возвращает промпт по ID
 func (pm *PromptManager) GetPromptByID(id int) (*SystemPrompt, error) {
 	for _, prompt := range pm.Prompts {
@@ -173,18 +168,39 @@ func (pm *PromptManager) GetPromptByName(name string) (*SystemPrompt, error) {
 	return nil, fmt.Errorf("промпт с именем '%s' не найден", name)
 }
 
-// AddPrompt добавляет новый промпт
-func (pm *PromptManager) AddPrompt(name, description, content string) error {
-	// Находим максимальный ID
+// GetPromptByContent возвращает промпт с таким же текстом (без учета пробелов по краям)
+func (pm *PromptManager) GetPromptByContent(content string) (*SystemPrompt, error) {
+	content = strings.TrimSpace(content)
+	for _, prompt := range pm.Prompts {
+		if content != "" && strings.TrimSpace(prompt.Content) == content {
+			return &prompt, nil
+		}
+	}
+	return nil, fmt.Errorf("промпт с таким текстом не найден")
+}
+
+// nextID возвращает ID для нового промпта: больше всех имеющихся, встроенных и
+// удаленных, чтобы новый промпт не занял ID с чужой историей версий
+func (pm *PromptManager) nextID() int {
 	maxID := 0
 	for _, prompt := range pm.Prompts {
-		if prompt.ID > maxID {
-			maxID = prompt.ID
+		maxID = max(maxID, prompt.ID)
+	}
+	for _, prompt := range GetBuiltinPrompts() {
+		maxID = max(maxID, prompt.ID)
+	}
+	if versions, err := pm.loadVersions(); err == nil {
+		for id := range versions {
+			maxID = max(maxID, id)
 		}
 	}
+	return maxID + 1
+}
 
+// AddPrompt добавляет новый промпт
+func (pm *PromptManager) AddPrompt(name, description, content string) error {
 	newPrompt := SystemPrompt{
-		ID:          maxID + 1,
+		ID:          pm.nextID(),
 		Name:        name,
 		Description: description,
 		Content:     content,
@@ -313,14 +329,14 @@ func (pm *PromptManager) AddCustomPrompt(name, description, content string) erro
 	}
 
 	newPrompt := SystemPrompt{
-		ID:          len(pm.Prompts) + 1,
+		ID:          pm.nextID(),
 		Name:        name,
 		Description: description,
 		Content:     content,
 	}
 
 	pm.Prompts = append(pm.Prompts, newPrompt)
-	return pm.saveCustomPrompts()
+	return pm.saveAllPrompts()
 }
 
 // DeleteCustomPrompt удаляет пользовательский промпт
@@ -332,7 +348,7 @@ func (pm *PromptManager) DeleteCustomPrompt(id int) error {
 	for i, prompt := range pm.Prompts {
 		if prompt.ID == id {
 			pm.Prompts = append(pm.Prompts[:i], pm.Prompts[i+1:]...)
-			return pm.saveCustomPrompts()
+			return pm.saveAllPrompts()
 		}
 	}
 
@@ -0,0 +1,199 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы экспорта промптов
+const (
+	PromptFormatJSON = "json"
+	PromptFormatYAML = "yaml"
+)
+
+// PromptFormats поддерживаемые форматы экспорта промптов
+var PromptFormats = []string{PromptFormatYAML, PromptFormatJSON}
+
+// promptLibraryVersion версия формата файла экспорта
+const promptLibraryVersion = 1
+
+// PromptLibrary файл экспорта промптов
+type PromptLibrary struct {
+	Version int            `json:"version" yaml:"version"`
+	Prompts []SystemPrompt `json:"prompts" yaml:"prompts"`
+}
+
+// ExportPrompts записывает промпты в формате yaml или json
+func ExportPrompts(w io.Writer, prompts []SystemPrompt, format string) error {
+	if prompts == nil {
+		prompts = []SystemPrompt{}
+	}
+	library := PromptLibrary{Version: promptLibraryVersion, Prompts: prompts}
+	switch format {
+	case PromptFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(library)
+	case PromptFormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(library); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("неизвестный формат %q (доступны: %s)", format, strings.Join(PromptFormats, ", "))
+}
+
+// ParsePrompts разбирает файл экспорта в yaml или json. Принимается и список
+// промптов без обертки, и файл sys_prompts.
+func ParsePrompts(data []byte) ([]SystemPrompt, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("пустой файл")
+	}
+	unmarshal := yaml.Unmarshal
+	if data[0] == '{' || data[0] == '[' {
+		unmarshal = json.Unmarshal
+	}
+	var library PromptLibrary
+	if err := unmarshal(data, &library); err != nil {
+		var prompts []SystemPrompt
+		if err := unmarshal(data, &prompts); err != nil {
+			return nil, err
+		}
+		return prompts, nil
+	}
+	if library.Version > promptLibraryVersion {
+		return nil, fmt.Errorf("версия формата %d не поддерживается (поддерживается до %d)", library.Version, promptLibraryVersion)
+	}
+	return library.Prompts, nil
+}
+
+// SelectPrompts возвращает промпты с указанными ID в порядке списка; без ID — все
+func (pm *PromptManager) SelectPrompts(ids []int) ([]SystemPrompt, error) {
+	if len(ids) == 0 {
+		return slices.Clone(pm.Prompts), nil
+	}
+	var prompts []SystemPrompt
+	for _, id := range ids {
+		prompt, err := pm.GetPromptByID(id)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, *prompt)
+	}
+	return prompts, nil
+}
+
+// Стратегии импорта для промптов с уже занятым именем
+const (
+	ConflictRename    = "rename"    // добавить под новым именем: "имя (2)"
+	ConflictOverwrite = "overwrite" // заменить существующий промпт
+	ConflictSkip      = "skip"      // оставить существующий
+)
+
+// ConflictStrategies поддерживаемые стратегии импорта
+var ConflictStrategies = []string{ConflictRename, ConflictOverwrite, ConflictSkip}
+
+// PromptImportPlan результат сопоставления импортируемых промптов с имеющимися
+type PromptImportPlan struct {
+	Add    []SystemPrompt // новые промпты с выданными ID
+	Update []SystemPrompt // замены имеющихся промптов (с их ID)
+	Skip   []SystemPrompt // пропущенные промпты (как в файле)
+	IDs    map[int]int    // ID в файле -> ID после импорта
+}
+
+// PlanImport сопоставляет импортируемые промпты с имеющимися по имени (без учета
+// регистра). ID из файла не сохраняются: новые промпты получают свободные ID
+// менеджера, а соответствие записывается в IDs. Промпт, совпадающий с имеющимся
+// по описанию и тексту, пропускается при любой стратегии.
+func (pm *PromptManager) PlanImport(incoming []SystemPrompt, strategy string) (PromptImportPlan, error) {
+	plan := PromptImportPlan{IDs: map[int]int{}}
+	if !slices.Contains(ConflictStrategies, strategy) {
+		return plan, fmt.Errorf("неизвестная стратегия %q (доступны: %s)", strategy, strings.Join(ConflictStrategies, ", "))
+	}
+
+	// Имена имеющихся и уже добавленных планом промптов
+	byName := map[string]SystemPrompt{}
+	for _, prompt := range pm.Prompts {
+		byName[strings.ToLower(prompt.Name)] = prompt
+	}
+	nextID := pm.nextID()
+	planned := map[int]int{} // ID нового промпта -> позиция в plan.Add
+	updated := map[int]int{} // ID имеющегося промпта -> позиция в plan.Update
+
+	for _, prompt := range incoming {
+		sourceID := prompt.ID
+		prompt.Name = strings.TrimSpace(prompt.Name)
+		if prompt.Name == "" || strings.TrimSpace(prompt.Content) == "" {
+			plan.Skip = append(plan.Skip, prompt)
+			continue
+		}
+		existing, taken := byName[strings.ToLower(prompt.Name)]
+		if taken && existing.Description == prompt.Description && existing.Content == prompt.Content {
+			plan.Skip = append(plan.Skip, prompt)
+			plan.IDs[sourceID] = existing.ID
+			continue
+		}
+		if taken {
+			switch strategy {
+			case ConflictSkip:
+				plan.Skip = append(plan.Skip, prompt)
+				continue
+			case ConflictOverwrite:
+				prompt.ID = existing.ID
+				prompt.Name = existing.Name
+				if pos, ok := planned[existing.ID]; ok {
+					plan.Add[pos] = prompt
+				} else if pos, ok := updated[existing.ID]; ok {
+					plan.Update[pos] = prompt
+				} else {
+					updated[existing.ID] = len(plan.Update)
+					plan.Update = append(plan.Update, prompt)
+				}
+				byName[strings.ToLower(prompt.Name)] = prompt
+				plan.IDs[sourceID] = prompt.ID
+				continue
+			case ConflictRename:
+				prompt.Name = uniquePromptName(prompt.Name, byName)
+			}
+		}
+		prompt.ID = nextID
+		nextID++
+		planned[prompt.ID] = len(plan.Add)
+		plan.Add = append(plan.Add, prompt)
+		byName[strings.ToLower(prompt.Name)] = prompt
+		plan.IDs[sourceID] = prompt.ID
+	}
+	return plan, nil
+}
+
+// uniquePromptName подбирает свободное имя вида "имя (2)"
+func uniquePromptName(name string, taken map[string]SystemPrompt) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if _, ok := taken[strings.ToLower(candidate)]; !ok {
+			return candidate
+		}
+	}
+}
+
+// ApplyImport применяет план импорта и сохраняет sys_prompts
+func (pm *PromptManager) ApplyImport(plan PromptImportPlan) error {
+	for _, prompt := range plan.Update {
+		for i := range pm.Prompts {
+			if pm.Prompts[i].ID == prompt.ID {
+				pm.Prompts[i] = prompt
+			}
+		}
+	}
+	pm.Prompts = append(pm.Prompts, plan.Add...)
+	return pm.saveAllPrompts()
+}
@@ -0,0 +1,94 @@
+package gpt
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportParsePrompts(t *testing.T) {
+	prompts := []SystemPrompt{
+		{ID: 1, Name: "linux-command", Description: "Команды", Content: "Reply with a command.\nNo markdown."},
+		{ID: 9, Name: "k8s", Description: "", Content: "kubectl only"},
+	}
+	for _, format := range PromptFormats {
+		var buf bytes.Buffer
+		if err := ExportPrompts(&buf, prompts, format); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParsePrompts(buf.Bytes())
+		if err != nil || !reflect.DeepEqual(parsed, prompts) {
+			t.Errorf("%s: prompts are not parsed back: %+v (%v)", format, parsed, err)
+		}
+	}
+	// Список без обертки и файл sys_prompts
+	for _, data := range []string{
+		`[{"id": 9, "name": "k8s", "content": "kubectl only"}]`,
+		`{"language": "ru", "prompts": [{"id": 9, "name": "k8s", "content": "kubectl only"}]}`,
+		"- id: 9\n  name: k8s\n  content: kubectl only\n",
+	} {
+		parsed, err := ParsePrompts([]byte(data))
+		if err != nil || len(parsed) != 1 || parsed[0].Name != "k8s" {
+			t.Errorf("unexpected parse of %s: %+v (%v)", data, parsed, err)
+		}
+	}
+	if _, err := ParsePrompts([]byte("version: 99\nprompts: []\n")); err == nil {
+		t.Error("newer format version must be rejected")
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	newManager := func() *PromptManager {
+		return &PromptManager{
+			ConfigFile: filepath.Join(t.TempDir(), "sys_prompts"),
+			Prompts: []SystemPrompt{
+				{ID: 1, Name: "linux-command", Content: "builtin"},
+				{ID: 12, Name: "k8s", Content: "kubectl only"},
+			},
+		}
+	}
+	incoming := []SystemPrompt{
+		{ID: 3, Name: "K8S", Content: "helm too"},          // конфликт по имени
+		{ID: 4, Name: "k8s", Content: "kubectl only"},      // совпадает с имеющимся
+		{ID: 5, Name: "terraform", Content: "hcl"},         // новый
+		{ID: 6, Name: "linux-command", Content: "builtin"}, // без изменений
+		{ID: 7, Name: " ", Content: "no name"},
+	}
+
+	pm := newManager()
+	plan, err := pm.PlanImport(incoming, ConflictRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Add) != 2 || plan.Add[0].Name != "K8S (2)" || plan.Add[0].ID != 13 || plan.Add[1].Name != "terraform" || plan.Add[1].ID != 14 {
+		t.Errorf("unexpected rename plan: %+v", plan.Add)
+	}
+	if plan.IDs[3] != 13 || plan.IDs[4] != 12 || plan.IDs[6] != 1 || len(plan.Skip) != 3 {
+		t.Errorf("unexpected id mapping %v, skipped %+v", plan.IDs, plan.Skip)
+	}
+
+	plan, _ = pm.PlanImport(incoming, ConflictOverwrite)
+	if len(plan.Update) != 1 || plan.Update[0].ID != 12 || plan.Update[0].Content != "kubectl only" || len(plan.Add) != 1 {
+		t.Errorf("later prompt with the same name must win: update %+v, add %+v", plan.Update, plan.Add)
+	}
+	if err := pm.ApplyImport(plan); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := pm.GetPromptByName("terraform"); p == nil || p.ID != 13 {
+		t.Errorf("imported prompt must get a free id: %+v", p)
+	}
+	reloaded := &PromptManager{ConfigFile: pm.ConfigFile}
+	reloaded.loadAllPrompts()
+	if len(reloaded.Prompts) != 3 {
+		t.Errorf("import must keep existing prompts in sys_prompts: %+v", reloaded.Prompts)
+	}
+
+	plan, _ = newManager().PlanImport(incoming, ConflictSkip)
+	if len(plan.Add) != 1 || len(plan.Update) != 0 || len(plan.Skip) != 4 {
+		t.Errorf("unexpected skip plan: %+v", plan)
+	}
+	if _, err := pm.PlanImport(incoming, "merge"); err == nil {
+		t.Error("unknown strategy must be rejected")
+	}
+}
@@ -0,0 +1,490 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltTimeout сколько ждать, пока базу освободит другой процесс
+const boltTimeout = 10 * time.Second
+
+// Бакеты базы: записи по номеру, индексы и служебные данные
+var (
+	bucketEntries = []byte("entries")
+	bucketQuery   = []byte("idx_query") // нормализованный запрос + 0 + номер
+	bucketTime    = []byte("idx_time")  // время (нс) + номер
+	bucketModel   = []byte("idx_model") // модель + 0 + номер
+	bucketMeta    = []byte("meta")
+	keyNextID     = []byte("next_id")
+	keyCipher     = []byte("cipher") // отпечаток ключа, которым построен индекс запросов
+)
+
+// BoltFile история во встроенной базе bbolt с индексами по нормализованному
+// запросу, времени и модели. База открывается только на время операции,
+// чтобы CLI и веб-сервер могли работать с ней по очереди.
+//
+// При шифровании записи хранятся зашифрованными, а в индексе запросов вместо
+// текста — его HMAC. При смене ключа индекс перестраивается при первом изменении.
+type BoltFile struct {
+	path string
+}
+
+// NewBolt возвращает историю, хранящуюся в базе bbolt
+func NewBolt(path string) *BoltFile {
+	return &BoltFile{path: path}
+}
+
+func (f *BoltFile) Path() string {
+	return f.path
+}
+
+func (f *BoltFile) Init() error {
+	return f.update(func(tx *bolt.Tx) error { return nil })
+}
+
+func (f *BoltFile) List() ([]Entry, error) {
+	var entries []Entry
+	err := f.view(func(tx *bolt.Tx) error {
+		var err error
+		entries, err = allEntries(tx)
+		return err
+	})
+	return entries, err
+}
+
+func (f *BoltFile) Find(filt Filter) ([]Entry, error) {
+	var entries []Entry
+	err := f.view(func(tx *bolt.Tx) error {
+		ids := indexLookup(tx, filt)
+		if ids == nil {
+			all, err := allEntries(tx)
+			entries = filter(all, filt)
+			return err
+		}
+		for _, id := range ids {
+			e, err := getEntry(tx, id)
+			if err != nil {
+				return err
+			}
+			if filt.Match(e) {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (f *BoltFile) Get(id int) (Entry, error) {
+	var entry Entry
+	err := f.view(func(tx *bolt.Tx) error {
+		var err error
+		entry, err = getEntry(tx, id)
+		return err
+	})
+	return entry, err
+}
+
+func (f *BoltFile) Add(e Entry) (int, error) {
+	err := f.update(func(tx *bolt.Tx) error {
+		var err error
+		if e.Index, err = takeNextID(tx); err != nil {
+			return err
+		}
+		return putEntry(tx, e)
+	})
+	return e.Index, err
+}
+
+func (f *BoltFile) Put(e Entry) error {
+	return f.update(func(tx *bolt.Tx) error {
+		if _, err := getEntry(tx, e.Index); err != nil {
+			return err
+		}
+		return putEntry(tx, e)
+	})
+}
+
+func (f *BoltFile) Upsert(e Entry) (int, bool, error) {
+	replaced := false
+	err := f.update(func(tx *bolt.Tx) error {
+		if ids := prefixIDs(tx.Bucket(bucketQuery), queryPrefix(e.Command)); len(ids) > 0 {
+			old, err := getEntry(tx, ids[0])
+			if err != nil {
+				return err
+			}
+			e.Index = ids[0]
+			e.Inherit(old)
+			replaced = true
+		} else {
+			var err error
+			if e.Index, err = takeNextID(tx); err != nil {
+				return err
+			}
+		}
+		return putEntry(tx, e)
+	})
+	return e.Index, replaced, err
+}
+
+func (f *BoltFile) Update(id int, fn func(e *Entry) error) error {
+	return f.update(func(tx *bolt.Tx) error {
+		e, err := getEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+		e.Index = id
+		return putEntry(tx, e)
+	})
+}
+
+func (f *BoltFile) Delete(id int) error {
+	return f.update(func(tx *bolt.Tx) error {
+		e, err := getEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		return deleteEntry(tx, e)
+	})
+}
+
+func (f *BoltFile) DeleteMany(ids []int) (int, error) {
+	removed := 0
+	err := f.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			e, err := getEntry(tx, id)
+			if err == ErrNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := deleteEntry(tx, e); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (f *BoltFile) Clear() error {
+	return f.update(clearEntries)
+}
+
+func (f *BoltFile) Replace(entries []Entry) error {
+	entries = append([]Entry(nil), entries...)
+	return f.ReplaceFunc(func([]Entry) ([]Entry, bool, error) { return entries, true, nil })
+}
+
+func (f *BoltFile) ReplaceFunc(fn func(entries []Entry) ([]Entry, bool, error)) error {
+	return ignoreUnchanged(f.update(func(tx *bolt.Tx) error {
+		current, err := allEntries(tx)
+		if err != nil {
+			return err
+		}
+		entries, next, err := replaceFunc(fn, current, readNextID(tx))
+		if err != nil {
+			return err
+		}
+		if err := clearEntries(tx); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := putEntry(tx, e); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketMeta).Put(keyNextID, itob(next))
+	}))
+}
+
+// Rewrite перешифровывает записи и копирует базу в новый файл: bbolt не
+// затирает освобожденные страницы, и в них остались бы старые данные
+func (f *BoltFile) Rewrite() error {
+	if err := f.update(reindex); err != nil {
+		return err
+	}
+	db, err := bolt.Open(f.path, storage.FileMode(0644), &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tmp := f.path + ".tmp"
+	os.Remove(tmp)
+	dst, err := bolt.Open(tmp, storage.FileMode(0644), &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		return err
+	}
+	if err := bolt.Compact(dst, db, 0); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// view выполняет чтение; отсутствующая база — пустая история
+func (f *BoltFile) view(fn func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(f.path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := bolt.Open(f.path, 0644, &bolt.Options{Timeout: boltTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketEntries) == nil {
+			return nil
+		}
+		return fn(tx)
+	})
+}
+
+// update выполняет изменение в транзакции, создавая базу и бакеты при необходимости
+func (f *BoltFile) update(fn func(tx *bolt.Tx) error) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	db, err := bolt.Open(f.path, storage.FileMode(0644), &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := storage.Protect(f.path); err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketEntries, bucketQuery, bucketTime, bucketModel, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if !indexed(tx) {
+			if err := reindex(tx); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// indexLookup возвращает номера записей по наиболее избирательному индексу
+// или nil, если фильтр пуст
+func indexLookup(tx *bolt.Tx, filt Filter) []int {
+	switch {
+	case filt.Query != "" && indexed(tx):
+		return nonNil(prefixIDs(tx.Bucket(bucketQuery), queryPrefix(filt.Query)))
+	case filt.Model != "":
+		return nonNil(prefixIDs(tx.Bucket(bucketModel), indexPrefix(filt.Model)))
+	case !filt.From.IsZero() || !filt.To.IsZero():
+		ids := []int{}
+		c := tx.Bucket(bucketTime).Cursor()
+		var to []byte
+		if !filt.To.IsZero() {
+			to = timeKey(filt.To)
+		}
+		for k, _ := c.Seek(timeKey(filt.From)); k != nil; k, _ = c.Next() {
+			if to != nil && bytes.Compare(k[:8], to) >= 0 {
+				break
+			}
+			ids = append(ids, int(binary.BigEndian.Uint64(k[8:])))
+		}
+		sort.Ints(ids)
+		return ids
+	}
+	return nil
+}
+
+// prefixIDs возвращает номера из ключей индекса вида <значение>0<номер>
+func prefixIDs(b *bolt.Bucket, prefix []byte) []int {
+	var ids []int
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids = append(ids, int(binary.BigEndian.Uint64(k[len(prefix):])))
+	}
+	return ids
+}
+
+func nonNil(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
+
+func allEntries(tx *bolt.Tx) ([]Entry, error) {
+	var entries []Entry
+	err := tx.Bucket(bucketEntries).ForEach(func(k, v []byte) error {
+		var e Entry
+		data, err := storage.Decode(v)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+func getEntry(tx *bolt.Tx, id int) (Entry, error) {
+	var e Entry
+	data := tx.Bucket(bucketEntries).Get(itob(id))
+	if data == nil {
+		return e, ErrNotFound
+	}
+	data, err := storage.Decode(data)
+	if err != nil {
+		return e, err
+	}
+	return e, json.Unmarshal(data, &e)
+}
+
+// putEntry сохраняет запись и обновляет индексы
+func putEntry(tx *bolt.Tx, e Entry) error {
+	if old, err := getEntry(tx, e.Index); err == nil {
+		if err := deleteEntry(tx, old); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if data, err = storage.Encode(data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketEntries).Put(itob(e.Index), data); err != nil {
+		return err
+	}
+	for bucket, key := range indexKeys(e) {
+		if err := tx.Bucket([]byte(bucket)).Put(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteEntry удаляет запись вместе с ключами индексов
+func deleteEntry(tx *bolt.Tx, e Entry) error {
+	for bucket, key := range indexKeys(e) {
+		if err := tx.Bucket([]byte(bucket)).Delete(key); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(bucketEntries).Delete(itob(e.Index))
+}
+
+// clearEntries удаляет все записи и индексы, сохраняя счетчик номеров
+func clearEntries(tx *bolt.Tx) error {
+	for _, name := range [][]byte{bucketEntries, bucketQuery, bucketTime, bucketModel} {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexKeys ключи индексов записи по имени бакета
+func indexKeys(e Entry) map[string][]byte {
+	id := itob(e.Index)
+	keys := map[string][]byte{
+		string(bucketQuery): append(queryPrefix(e.Command), id...),
+		string(bucketTime):  append(timeKey(e.Timestamp), id...),
+	}
+	if e.Model != "" {
+		keys[string(bucketModel)] = append(indexPrefix(e.Model), id...)
+	}
+	return keys
+}
+
+func indexPrefix(value string) []byte {
+	return append([]byte(value), 0)
+}
+
+// queryPrefix префикс ключа индекса запросов; при шифровании — HMAC запроса
+func queryPrefix(command string) []byte {
+	return indexPrefix(storage.IndexValue(NormalizeQuery(command)))
+}
+
+// indexed проверяет, что индекс запросов построен текущим ключом
+func indexed(tx *bolt.Tx) bool {
+	return string(tx.Bucket(bucketMeta).Get(keyCipher)) == storage.Fingerprint()
+}
+
+// reindex перешифровывает записи текущим ключом и перестраивает индексы
+func reindex(tx *bolt.Tx) error {
+	entries, err := allEntries(tx)
+	if err != nil {
+		return err
+	}
+	if err := clearEntries(tx); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := putEntry(tx, e); err != nil {
+			return err
+		}
+	}
+	if fp := storage.Fingerprint(); fp != "" {
+		return tx.Bucket(bucketMeta).Put(keyCipher, []byte(fp))
+	}
+	return tx.Bucket(bucketMeta).Delete(keyCipher)
+}
+
+// timeKey время в наносекундах; моменты до 1970 года сводятся к нулю
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return key
+}
+
+func readNextID(tx *bolt.Tx) int {
+	next := 1
+	if v := tx.Bucket(bucketMeta).Get(keyNextID); v != nil {
+		next = int(binary.BigEndian.Uint64(v))
+	}
+	// Номер не может совпасть с существующим, даже если счетчик потерян
+	if k, _ := tx.Bucket(bucketEntries).Cursor().Last(); k != nil {
+		if last := int(binary.BigEndian.Uint64(k)); last >= next {
+			next = last + 1
+		}
+	}
+	return next
+}
+
+// takeNextID выдает очередной номер записи
+func takeNextID(tx *bolt.Tx) (int, error) {
+	id := readNextID(tx)
+	return id, tx.Bucket(bucketMeta).Put(keyNextID, itob(id+1))
+}
+
+func itob(v int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
@@ -0,0 +1,392 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы экспорта истории
+const (
+	FormatJSON     = "json"
+	FormatJSONL    = "jsonl"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatShell    = "sh"
+)
+
+// ExportFormats поддерживаемые форматы экспорта
+var ExportFormats = []string{FormatJSON, FormatJSONL, FormatCSV, FormatMarkdown, FormatShell}
+
+// csvHeader колонки CSV-экспорта; по ним же разбирается импорт
+var csvHeader = []string{"index", "timestamp", "model", "command", "response", "explanation", "system_prompt", "starred", "tags", "rating", "rating_note",
+	"schema", "provider", "prompt_id", "verbosity", "elapsed", "host", "cwd", "prompt_version"}
+
+// Export записывает записи в указанном формате
+func Export(w io.Writer, entries []Entry, format string) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, e := range entries {
+			if err := encoder.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		return exportCSV(w, entries)
+	case FormatMarkdown:
+		return exportMarkdown(w, entries)
+	case FormatShell:
+		return exportShell(w, entries)
+	}
+	return fmt.Errorf("неизвестный формат %q (доступны: %s)", format, strings.Join(ExportFormats, ", "))
+}
+
+// ContentType MIME-тип файла экспорта
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv"
+	case FormatMarkdown:
+		return "text/markdown"
+	case FormatShell:
+		return "text/x-shellscript"
+	}
+	return "application/octet-stream"
+}
+
+// FileExtension расширение файла экспорта
+func FileExtension(format string) string {
+	if format == FormatMarkdown {
+		return "md"
+	}
+	return format
+}
+
+func exportCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.Itoa(e.Index),
+			e.Timestamp.Format(time.RFC3339),
+			e.Model,
+			e.Command,
+			e.Response,
+			e.Explanation,
+			e.System,
+			strconv.FormatBool(e.Starred),
+			strings.Join(e.Tags, ","),
+			e.Rating,
+			e.RatingNote,
+			strconv.Itoa(e.Schema),
+			e.Provider,
+			strconv.Itoa(e.PromptID),
+			e.Verbosity,
+			strconv.FormatFloat(e.Elapsed, 'f', -1, 64),
+			e.Host,
+			e.Cwd,
+			strconv.Itoa(e.PromptVersion),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportMarkdown(w io.Writer, entries []Entry) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# История запросов lcg\n\nЭкспортировано: %s, записей: %d\n", time.Now().Format("2006-01-02 15:04:05"), len(entries))
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "\n## #%d %s\n\n", e.Index, strings.ReplaceAll(e.Command, "\n", " "))
+		meta := []string{e.Timestamp.Format("2006-01-02 15:04:05")}
+		if details := e.Details(); details != "" {
+			meta = append(meta, details)
+		}
+		if marks := e.Marks(); marks != "" {
+			meta = append(meta, marks)
+		}
+		fmt.Fprintf(&sb, "_%s_\n\n```bash\n%s\n```\n", strings.Join(meta, " · "), strings.TrimSpace(e.Response))
+		if e.RatingNote != "" {
+			fmt.Fprintf(&sb, "\n> Комментарий к оценке: %s\n", e.RatingNote)
+		}
+		if strings.TrimSpace(e.Explanation) != "" {
+			fmt.Fprintf(&sb, "\n### Объяснение\n\n%s\n", strings.TrimSpace(e.Explanation))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func exportShell(w io.Writer, entries []Entry) error {
+	var sb strings.Builder
+	sb.WriteString("#!/usr/bin/env bash\n")
+	fmt.Fprintf(&sb, "# История команд lcg, экспортировано %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	sb.WriteString("# Команды сгенерированы ИИ: просмотрите скрипт перед запуском.\n")
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "\n# #%d [%s] %s\n", e.Index, e.Timestamp.Format("2006-01-02 15:04"), commentLines(e.Command))
+		if marks := e.Marks(); marks != "" {
+			fmt.Fprintf(&sb, "# %s\n", marks)
+		}
+		sb.WriteString(strings.TrimSpace(e.Response) + "\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// commentLines делает многострочный текст продолжением комментария
+func commentLines(text string) string {
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n# ")
+}
+
+// ParseExport разбирает файл экспорта в форматах json, jsonl или csv, а также
+// файл истории lcg (массив записей или формат версии 2). Пустой format —
+// определить по содержимому.
+func ParseExport(data []byte, format string) ([]Entry, error) {
+	data = bytes.TrimSpace(data)
+	if format == "" {
+		format = detectFormat(data)
+	}
+	switch format {
+	case FormatJSON:
+		if len(data) > 0 && data[0] == '{' {
+			var d jsonData
+			if err := json.Unmarshal(data, &d); err != nil {
+				return nil, err
+			}
+			return d.Entries, nil
+		}
+		var entries []Entry
+		err := json.Unmarshal(data, &entries)
+		return entries, err
+	case FormatJSONL:
+		var entries []Entry
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+			var e Entry
+			if err := json.Unmarshal(line, &e); err != nil {
+				return nil, fmt.Errorf("строка %d: %v", i+1, err)
+			}
+			entries = append(entries, e)
+		}
+		return entries, nil
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatMarkdown, FormatShell:
+		return nil, fmt.Errorf("формат %s предназначен только для чтения, импортируйте json, jsonl или csv", format)
+	}
+	return nil, fmt.Errorf("неизвестный формат %q", format)
+}
+
+// detectFormat определяет формат по первым символам
+func detectFormat(data []byte) string {
+	switch {
+	case len(data) == 0 || data[0] == '[':
+		return FormatJSON
+	case data[0] == '{':
+		// Объект на всю первую строку — JSONL, иначе JSON с отступами
+		line, _, _ := bytes.Cut(data, []byte("\n"))
+		if json.Valid(bytes.TrimSpace(line)) && !bytes.Contains(line, []byte(`"entries"`)) {
+			return FormatJSONL
+		}
+		return FormatJSON
+	case bytes.HasPrefix(data, []byte("#!")):
+		return FormatShell
+	case data[0] == '#':
+		return FormatMarkdown
+	}
+	return FormatCSV
+}
+
+func parseCSV(data []byte) ([]Entry, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[strings.TrimSpace(name)] = i
+	}
+	if _, ok := col["command"]; !ok {
+		return nil, fmt.Errorf("в CSV нет колонки command")
+	}
+	get := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	var entries []Entry
+	for _, record := range records[1:] {
+		e := Entry{
+			Command:     get(record, "command"),
+			Response:    get(record, "response"),
+			Explanation: get(record, "explanation"),
+			System:      get(record, "system_prompt"),
+			Model:       get(record, "model"),
+			Rating:      get(record, "rating"),
+			RatingNote:  get(record, "rating_note"),
+			Provider:    get(record, "provider"),
+			Verbosity:   get(record, "verbosity"),
+			Host:        get(record, "host"),
+			Cwd:         get(record, "cwd"),
+		}
+		e.Schema, _ = strconv.Atoi(get(record, "schema"))
+		e.PromptID, _ = strconv.Atoi(get(record, "prompt_id"))
+		e.PromptVersion, _ = strconv.Atoi(get(record, "prompt_version"))
+		e.Elapsed, _ = strconv.ParseFloat(get(record, "elapsed"), 64)
+		e.Index, _ = strconv.Atoi(get(record, "index"))
+		e.Timestamp, _ = time.Parse(time.RFC3339, get(record, "timestamp"))
+		e.Starred, _ = strconv.ParseBool(get(record, "starred"))
+		e.Tags = NormalizeTags(ParseTags(get(record, "tags")))
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Стратегии слияния при импорте для записей с тем же запросом
+const (
+	MergeNewer = "newer" // оставить более новую запись
+	MergeBoth  = "both"  // сохранить обе
+	MergeSkip  = "skip"  // оставить существующую
+)
+
+// MergeStrategies поддерживаемые стратегии слияния
+var MergeStrategies = []string{MergeNewer, MergeBoth, MergeSkip}
+
+// ImportPlan результат сопоставления импортируемых записей с историей
+type ImportPlan struct {
+	Add    []Entry // новые записи (номера будут выданы при добавлении)
+	Update []Entry // замены существующих записей (с их номерами)
+	Skip   []Entry // пропущенные записи
+}
+
+// PlanImport сопоставляет импортируемые записи с существующими по нормализованному
+// запросу. Повторы внутри импортируемого файла сводятся к самой новой записи
+// (кроме стратегии both).
+func PlanImport(existing, incoming []Entry, strategy string) (ImportPlan, error) {
+	var plan ImportPlan
+	if !slices.Contains(MergeStrategies, strategy) {
+		return plan, fmt.Errorf("неизвестная стратегия %q (доступны: %s)", strategy, strings.Join(MergeStrategies, ", "))
+	}
+
+	// Старые записи первыми, чтобы в истории сохранился порядок добавления
+	incoming = append([]Entry(nil), incoming...)
+	sort.SliceStable(incoming, func(i, j int) bool {
+		return incoming[i].Timestamp.Before(incoming[j].Timestamp)
+	})
+
+	byQuery := map[string]int{} // нормализованный запрос -> позиция в existing
+	for i, e := range existing {
+		if _, ok := byQuery[NormalizeQuery(e.Command)]; !ok {
+			byQuery[NormalizeQuery(e.Command)] = i
+		}
+	}
+	planned := map[string]int{} // нормализованный запрос -> позиция в plan.Add
+
+	for _, e := range incoming {
+		if strings.TrimSpace(e.Command) == "" {
+			plan.Skip = append(plan.Skip, e)
+			continue
+		}
+		if e.Timestamp.IsZero() {
+			e.Timestamp = time.Now()
+		}
+		query := NormalizeQuery(e.Command)
+
+		if strategy == MergeBoth {
+			plan.Add = append(plan.Add, e)
+			continue
+		}
+		if pos, ok := planned[query]; ok {
+			// Повтор внутри файла: записи отсортированы, более поздняя новее
+			plan.Skip = append(plan.Skip, plan.Add[pos])
+			e.Index = 0
+			e.Inherit(plan.Add[pos])
+			plan.Add[pos] = e
+			continue
+		}
+		pos, ok := byQuery[query]
+		if !ok {
+			planned[query] = len(plan.Add)
+			plan.Add = append(plan.Add, e)
+			continue
+		}
+		old := existing[pos]
+		if strategy == MergeSkip || !e.Timestamp.After(old.Timestamp) {
+			plan.Skip = append(plan.Skip, e)
+			continue
+		}
+		e.Index = old.Index
+		e.Inherit(old)
+		// Повторная замена той же записи заменяет предыдущую в плане
+		replaced := false
+		for i := range plan.Update {
+			if plan.Update[i].Index == e.Index {
+				plan.Skip = append(plan.Skip, plan.Update[i])
+				plan.Update[i] = e
+				replaced = true
+			}
+		}
+		if !replaced {
+			plan.Update = append(plan.Update, e)
+		}
+	}
+	// Замена повтора из файла могла нарушить порядок по времени
+	sort.SliceStable(plan.Add, func(i, j int) bool {
+		return plan.Add[i].Timestamp.Before(plan.Add[j].Timestamp)
+	})
+	return plan, nil
+}
+
+// Import сопоставляет записи с историей (см. PlanImport) и применяет план
+// одним изменением хранилища под блокировкой: одновременные записи не
+// теряются, а при ошибке история не меняется. С dryRun только возвращает план.
+func Import(s Store, incoming []Entry, strategy string, dryRun bool) (ImportPlan, error) {
+	var plan ImportPlan
+	err := s.ReplaceFunc(func(entries []Entry) ([]Entry, bool, error) {
+		var err error
+		if plan, err = PlanImport(entries, incoming, strategy); err != nil {
+			return nil, false, err
+		}
+		if dryRun || len(plan.Add)+len(plan.Update) == 0 {
+			return nil, false, nil
+		}
+		for _, e := range plan.Update {
+			for i := range entries {
+				if entries[i].Index == e.Index {
+					entries[i] = e
+				}
+			}
+		}
+		for _, e := range plan.Add {
+			e.Index = 0
+			entries = append(entries, e)
+		}
+		return entries, true, nil
+	})
+	return plan, err
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+// withLock выполняет fn под блокировкой файла <path>.lock. Блокируется отдельный
+// файл, так как сам файл истории может заменяться при записи.
+func withLock(path string, exclusive bool, fn func() error) error {
+	if exclusive {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	lockFile, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		if !exclusive && os.IsNotExist(err) {
+			// Каталога истории еще нет — читать нечего
+			return fn()
+		}
+		return fmt.Errorf("не удалось открыть файл блокировки истории: %v", err)
+	}
+	defer lockFile.Close()
+	if err := lock(lockFile, exclusive); err != nil {
+		return fmt.Errorf("не удалось заблокировать файл истории: %v", err)
+	}
+	defer unlock(lockFile)
+	return fn()
+}
+
+// writeAtomic записывает данные во временный файл рядом с path и переименовывает его
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), storage.FileMode(0644)); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,432 @@
+// Package history хранит историю запросов lcg, общую для CLI и веб-сервера.
+//
+// Хранилище выбирается через LCG_HISTORY_BACKEND: JSON-файл (по умолчанию),
+// журнал JSONL только с дозаписью или встроенная база bbolt с индексами.
+// Все изменения выполняются под блокировкой, поэтому одновременные сохранения
+// из CLI и сервера не теряют записи. Номер записи (Index) выдается один раз
+// и не меняется при удалении других записей.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Поддерживаемые хранилища истории
+const (
+	BackendJSON  = "json"
+	BackendJSONL = "jsonl"
+	BackendBolt  = "bolt"
+)
+
+// Backends список поддерживаемых хранилищ
+var Backends = []string{BackendJSON, BackendJSONL, BackendBolt}
+
+// ErrNotFound возвращается, если запись с указанным номером отсутствует
+var ErrNotFound = errors.New("запись не найдена")
+
+// SchemaVersion текущая версия схемы записи. Записи без поля schema (версия 1)
+// не содержат сведений о запросе, кроме модели; lcg history migrate переводит их
+// в текущую версию.
+const SchemaVersion = 2
+
+// Entry запись истории
+type Entry struct {
+	Index       int       `json:"index"` // стабильный номер записи
+	Schema      int       `json:"schema,omitempty"`
+	Command     string    `json:"command"`
+	Response    string    `json:"response"`
+	Explanation string    `json:"explanation,omitempty"`
+	System      string    `json:"system_prompt"`
+	Model       string    `json:"model,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Starred     bool      `json:"starred,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Rating      string    `json:"rating,omitempty"` // good или bad
+	RatingNote  string    `json:"rating_note,omitempty"`
+
+	// Сведения о запросе (схема 2)
+	Provider      string  `json:"provider,omitempty"`       // ollama, proxy
+	PromptID      int     `json:"prompt_id,omitempty"`      // ID системного промпта; 0 — свой текст
+	PromptVersion int     `json:"prompt_version,omitempty"` // версия промпта PromptID
+	Verbosity     string  `json:"verbosity,omitempty"`      // v, vv или vvv для подробного объяснения
+	Elapsed       float64 `json:"elapsed,omitempty"`        // время ответа модели, секунды
+	Host          string  `json:"host,omitempty"`           // машина, на которой сделан запрос
+	Cwd           string  `json:"cwd,omitempty"`            // рабочий каталог запроса
+
+	// Все ответы на запрос по порядку (пусто, если ответ один). Поля ответа
+	// выше совпадают с предпочтительной версией, а без нее — с последней.
+	Versions  []Version `json:"versions,omitempty"`
+	Preferred int       `json:"preferred,omitempty"` // номер предпочтительной версии (с 1)
+}
+
+// Upgrade переводит запись старой схемы в текущую. ID промпта восстанавливается
+// по сохраненному системному промпту через promptID (0 — не найден).
+// Возвращает true, если запись изменилась.
+func (e *Entry) Upgrade(promptID func(system string) int) bool {
+	if e.Schema >= SchemaVersion {
+		return false
+	}
+	if e.PromptID == 0 && promptID != nil {
+		e.PromptID = promptID(e.System)
+	}
+	e.Schema = SchemaVersion
+	return true
+}
+
+// Details сведения о запросе одной строкой: провайдер, модель, промпт,
+// подробность, время ответа, хост и каталог
+func (e Entry) Details() string {
+	var details []string
+	model := e.Model
+	if e.Provider != "" {
+		model = strings.TrimSuffix(e.Provider+" / "+e.Model, " / ")
+	}
+	if model != "" {
+		details = append(details, model)
+	}
+	if e.PromptID > 0 {
+		prompt := fmt.Sprintf("промпт #%d", e.PromptID)
+		if e.PromptVersion > 0 {
+			prompt += fmt.Sprintf(" v%d", e.PromptVersion)
+		}
+		details = append(details, prompt)
+	}
+	if e.Verbosity != "" {
+		details = append(details, "объяснение "+e.Verbosity)
+	}
+	if e.Elapsed > 0 {
+		details = append(details, fmt.Sprintf("%.2f сек", e.Elapsed))
+	}
+	switch {
+	case e.Host != "" && e.Cwd != "":
+		details = append(details, e.Host+":"+e.Cwd)
+	case e.Host != "":
+		details = append(details, e.Host)
+	case e.Cwd != "":
+		details = append(details, e.Cwd)
+	}
+	return strings.Join(details, " · ")
+}
+
+// Оценки ответа
+const (
+	RatingGood = "good"
+	RatingBad  = "bad"
+)
+
+// Inherit переносит пометки пользователя из заменяемой записи: избранное и теги
+// сохраняются, оценка — только если ответ не изменился. Прежние ответы остаются
+// версиями записи, новый ответ добавляется последней версией.
+func (e *Entry) Inherit(old Entry) {
+	e.Starred = e.Starred || old.Starred
+	e.Tags = NormalizeTags(append(append([]string(nil), old.Tags...), e.Tags...))
+	e.mergeVersions(old)
+	if e.Rating == "" && strings.TrimSpace(e.Response) == strings.TrimSpace(old.Response) {
+		e.Rating, e.RatingNote = old.Rating, old.RatingNote
+	}
+}
+
+// Merge объединяет две версии одной записи с разных машин: более новая запись
+// наследует пометки и ответы более старой (см. Inherit). Результат не зависит от
+// порядка аргументов; номер записи берется из a.
+func Merge(a, b Entry) Entry {
+	newer, older := a, b
+	if b.Timestamp.After(a.Timestamp) || b.Timestamp.Equal(a.Timestamp) && newerByContent(b, a) {
+		newer, older = b, a
+	}
+	index := a.Index
+	newer.Inherit(older)
+	newer.Index = index
+	return newer
+}
+
+// newerByContent порядок записей с одинаковым временем: по содержимому
+func newerByContent(a, b Entry) bool {
+	a.Index, b.Index = 0, 0
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) > string(jb)
+}
+
+// HasTag проверяет наличие тега (без учета регистра)
+func (e Entry) HasTag(tag string) bool {
+	tag = normalizeTag(tag)
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags приводит теги к нижнему регистру, убирает пустые и повторы и сортирует
+func NormalizeTags(tags []string) []string {
+	var result []string
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" && !slices.Contains(result, t) {
+			result = append(result, t)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ParseTags разбивает строку с тегами, разделенными пробелами или запятыми
+func ParseTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// normalizeTag убирает пробелы, запятые и ведущий # из тега
+func normalizeTag(tag string) string {
+	tag = strings.ToLower(strings.Trim(strings.TrimSpace(tag), ",#"))
+	return strings.Join(strings.Fields(tag), "-")
+}
+
+// Marks краткое обозначение пометок записи для списков: ★, 👍/👎 и теги
+func (e Entry) Marks() string {
+	var marks []string
+	if e.Starred {
+		marks = append(marks, "★")
+	}
+	switch e.Rating {
+	case RatingGood:
+		marks = append(marks, "👍")
+	case RatingBad:
+		marks = append(marks, "👎")
+	}
+	for _, t := range e.Tags {
+		marks = append(marks, "#"+t)
+	}
+	return strings.Join(marks, " ")
+}
+
+// ParseRating разбирает оценку: good/bad и их синонимы (+, -, 👍, 👎)
+func ParseRating(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "good", "+", "up", "👍":
+		return RatingGood, nil
+	case "bad", "-", "down", "👎":
+		return RatingBad, nil
+	}
+	return "", fmt.Errorf("неверная оценка %q: ожидается good или bad", value)
+}
+
+// Filter условия выборки записей; пустые поля не ограничивают выборку
+type Filter struct {
+	Query string    // запрос пользователя, сравнивается после NormalizeQuery
+	Model string    // точное имя модели
+	From  time.Time // не раньше (включительно)
+	To    time.Time // раньше (не включительно)
+
+	Provider string // провайдер (ollama, proxy)
+	PromptID int    // ID системного промпта
+	Host     string // машина, на которой сделан запрос
+
+	Tag     string // запись помечена тегом
+	Starred bool   // только избранные
+	Rating  string // good, bad или any — любая оценка
+}
+
+// Match проверяет, подходит ли запись под условия
+func (f Filter) Match(e Entry) bool {
+	if f.Query != "" && NormalizeQuery(e.Command) != NormalizeQuery(f.Query) {
+		return false
+	}
+	if f.Model != "" && e.Model != f.Model {
+		return false
+	}
+	if f.Provider != "" && !strings.EqualFold(e.Provider, f.Provider) {
+		return false
+	}
+	if f.PromptID != 0 && e.PromptID != f.PromptID {
+		return false
+	}
+	if f.Host != "" && !strings.EqualFold(e.Host, f.Host) {
+		return false
+	}
+	if !f.From.IsZero() && e.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Timestamp.Before(f.To) {
+		return false
+	}
+	if f.Tag != "" && !e.HasTag(f.Tag) {
+		return false
+	}
+	if f.Starred && !e.Starred {
+		return false
+	}
+	switch {
+	case f.Rating == "any" && e.Rating == "":
+		return false
+	case f.Rating != "" && f.Rating != "any" && e.Rating != f.Rating:
+		return false
+	}
+	return true
+}
+
+// Store хранилище истории
+type Store interface {
+	// Path возвращает путь к файлу хранилища
+	Path() string
+	// Init создает пустое хранилище, если его еще нет
+	Init() error
+	// List возвращает все записи в порядке добавления
+	List() ([]Entry, error)
+	// Find возвращает записи, подходящие под фильтр, в порядке добавления
+	Find(f Filter) ([]Entry, error)
+	// Get возвращает запись по номеру
+	Get(id int) (Entry, error)
+	// Add добавляет запись и возвращает присвоенный ей номер
+	Add(e Entry) (int, error)
+	// Put заменяет запись с номером e.Index
+	Put(e Entry) error
+	// Upsert заменяет запись с тем же запросом или добавляет новую.
+	// Возвращает номер записи и признак того, что запись была заменена.
+	Upsert(e Entry) (int, bool, error)
+	// Update изменяет запись по номеру под блокировкой (fn может вернуть ошибку,
+	// тогда запись не меняется)
+	Update(id int, fn func(e *Entry) error) error
+	// Delete удаляет запись по номеру; номера остальных записей не меняются
+	Delete(id int) error
+	// DeleteMany удаляет записи с указанными номерами за одно изменение;
+	// отсутствующие номера пропускаются. Возвращает число удаленных записей.
+	DeleteMany(ids []int) (int, error)
+	// Clear удаляет все записи; нумерация продолжается с прежнего места
+	Clear() error
+	// Replace заменяет все содержимое записями с сохранением их номеров
+	Replace(entries []Entry) error
+	// ReplaceFunc заменяет все содержимое записями, которые вернула fn по
+	// текущим, под одной блокировкой; записи с нулевым номером получают
+	// очередные номера. Если fn вернула false, хранилище не меняется.
+	ReplaceFunc(fn func(entries []Entry) ([]Entry, bool, error)) error
+	// Rewrite переписывает хранилище целиком текущим ключом шифрования
+	// (или открытым текстом, если шифрование выключено)
+	Rewrite() error
+}
+
+// Open возвращает хранилище истории указанного типа
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendJSON:
+		return NewJSON(path), nil
+	case BackendJSONL:
+		return NewJSONL(path), nil
+	case BackendBolt:
+		return NewBolt(path), nil
+	}
+	return nil, fmt.Errorf("неизвестное хранилище истории %q (доступны: %s)", backend, strings.Join(Backends, ", "))
+}
+
+// Migrate копирует все записи из src в dst с сохранением номеров.
+// Имеющиеся в dst записи заменяются.
+func Migrate(src, dst Store) (int, error) {
+	entries, err := src.List()
+	if err != nil {
+		return 0, err
+	}
+	if err := dst.Replace(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Upgrade переводит записи старой схемы в текущую (см. Entry.Upgrade) и
+// сохраняет их одним изменением. Возвращает число обновленных записей.
+func Upgrade(store Store, promptID func(system string) int) (int, error) {
+	n := 0
+	err := store.ReplaceFunc(func(entries []Entry) ([]Entry, bool, error) {
+		n = 0
+		for i := range entries {
+			if entries[i].Upgrade(promptID) {
+				n++
+			}
+		}
+		return entries, n > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// NormalizeQuery приводит запрос к виду для сравнения:
+// нижний регистр, без пробелов по краям и с одиночными пробелами внутри
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// FindCommand возвращает позицию записи с тем же запросом (после NormalizeQuery) или -1
+func FindCommand(entries []Entry, command string) int {
+	command = NormalizeQuery(command)
+	for i, e := range entries {
+		if NormalizeQuery(e.Command) == command {
+			return i
+		}
+	}
+	return -1
+}
+
+// filter отбирает записи, подходящие под фильтр
+func filter(entries []Entry, f Filter) []Entry {
+	var result []Entry
+	for _, e := range entries {
+		if f.Match(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// errUnchanged ReplaceFunc: fn не изменила записи, сохранять нечего
+var errUnchanged = errors.New("history unchanged")
+
+// replaceFunc вызывает fn для ReplaceFunc, нумерует новые записи начиная с next
+// и возвращает записи и следующий свободный номер; errUnchanged — записи не изменились
+func replaceFunc(fn func(entries []Entry) ([]Entry, bool, error), entries []Entry, next int) ([]Entry, int, error) {
+	result, changed, err := fn(entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !changed {
+		return nil, 0, errUnchanged
+	}
+	next = nextID(result, next)
+	for i := range result {
+		if result[i].Index == 0 {
+			result[i].Index = next
+			next++
+		}
+	}
+	return result, next, nil
+}
+
+// ignoreUnchanged ошибка ReplaceFunc без errUnchanged
+func ignoreUnchanged(err error) error {
+	if errors.Is(err, errUnchanged) {
+		return nil
+	}
+	return err
+}
+
+// nextID возвращает номер, следующий за наибольшим из записей и next
+func nextID(entries []Entry, next int) int {
+	if next < 1 {
+		next = 1
+	}
+	for _, e := range entries {
+		if e.Index >= next {
+			next = e.Index + 1
+		}
+	}
+	return next
+}
@@ -0,0 +1,497 @@
+package history
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+func TestJSONMigrateLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lcg_history.json")
+	legacy := `[{"index":1,"command":"a","response":"ls"},{"index":2,"command":"b","response":"pwd"},{"index":3,"command":"c","response":"id"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewJSON(path)
+	if err := h.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := h.Add(Entry{Command: "d"}); err != nil || id != 4 {
+		t.Errorf("expected new id 4, got %d (%v)", id, err)
+	}
+	if e, err := h.Get(3); err != nil || e.Command != "c" {
+		t.Errorf("entry 3 must keep its id after migration: %+v, %v", e, err)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			h, err := Open(backend, filepath.Join(t.TempDir(), "history"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, cmd := range []string{"list files", "show disk", "who am i"} {
+				model := "m1"
+				if i == 1 {
+					model = "m2"
+				}
+				if _, err := h.Add(Entry{Command: cmd, Model: model, Timestamp: base.Add(time.Duration(i) * time.Hour)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := h.Delete(2); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := h.Get(2); err != ErrNotFound {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+			if e, err := h.Get(3); err != nil || e.Command != "who am i" {
+				t.Errorf("entry 3 must keep its id after delete: %+v, %v", e, err)
+			}
+
+			if id, replaced, err := h.Upsert(Entry{Command: "  LIST   files ", Response: "ls", Model: "m2", Timestamp: base}); err != nil || !replaced || id != 1 {
+				t.Errorf("upsert must replace entry 1: id=%d replaced=%v err=%v", id, replaced, err)
+			}
+
+			found, err := h.Find(Filter{Query: "list files"})
+			if err != nil || len(found) != 1 || found[0].Response != "ls" {
+				t.Errorf("find by query: %+v, %v", found, err)
+			}
+			if found, _ := h.Find(Filter{Model: "m2"}); len(found) != 1 || found[0].Index != 1 {
+				t.Errorf("find by model: %+v", found)
+			}
+			if found, _ := h.Find(Filter{From: base.Add(time.Hour), To: base.Add(3 * time.Hour)}); len(found) != 1 || found[0].Index != 3 {
+				t.Errorf("find by time: %+v", found)
+			}
+
+			if n, err := h.DeleteMany([]int{3, 2, 42}); err != nil || n != 1 {
+				t.Errorf("delete many must skip missing ids: %d, %v", n, err)
+			}
+			if entries, _ := h.List(); len(entries) != 1 || entries[0].Index != 1 {
+				t.Errorf("after delete many: %+v", entries)
+			}
+
+			// После очистки номера не переиспользуются
+			if err := h.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if id, _ := h.Add(Entry{Command: "e"}); id != 4 {
+				t.Errorf("expected id 4 after clear, got %d", id)
+			}
+
+			dst, _ := Open(BackendJSON, filepath.Join(t.TempDir(), "copy.json"))
+			if n, err := Migrate(h, dst); err != nil || n != 1 {
+				t.Fatalf("migrate: %d, %v", n, err)
+			}
+			if e, err := dst.Get(4); err != nil || e.Command != "e" {
+				t.Errorf("migrated entry must keep its id: %+v, %v", e, err)
+			}
+		})
+	}
+}
+
+func TestEncryptedBackends(t *testing.T) {
+	defer storage.Use(nil)
+	oldKey, _ := storage.NewKey([]byte("old"))
+	newKey, _ := storage.NewKey([]byte("new"))
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history")
+			storage.Use(nil)
+			h, _ := Open(backend, path)
+			if _, err := h.Add(Entry{Command: "ssh db-01", Timestamp: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Открытая история шифруется целиком, запись по-прежнему находится по запросу
+			storage.Use(oldKey)
+			if err := h.Rewrite(); err != nil {
+				t.Fatal(err)
+			}
+			if raw, _ := os.ReadFile(path); bytes.Contains(raw, []byte("db-01")) {
+				t.Error("history must not contain plaintext after Rewrite")
+			}
+			if _, replaced, err := h.Upsert(Entry{Command: "SSH  db-01", Timestamp: time.Now()}); err != nil || !replaced {
+				t.Errorf("upsert over encrypted history: replaced=%v, %v", replaced, err)
+			}
+
+			storage.Use(newKey, oldKey)
+			if err := h.Rewrite(); err != nil {
+				t.Fatal(err)
+			}
+			storage.Use(oldKey)
+			if _, err := h.List(); err == nil {
+				t.Error("old key must not read rekeyed history")
+			}
+			storage.Use(newKey)
+			if found, err := h.Find(Filter{Query: "ssh db-01"}); err != nil || len(found) != 1 {
+				t.Errorf("find after rekey: %v, %v", found, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	for _, backend := range Backends {
+		path := filepath.Join(t.TempDir(), "sub", "history")
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h, _ := Open(backend, path)
+				if _, err := h.Add(Entry{Command: "q"}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		h, _ := Open(backend, path)
+		entries, err := h.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := map[int]bool{}
+		for _, e := range entries {
+			seen[e.Index] = true
+		}
+		if len(entries) != 20 || len(seen) != 20 {
+			t.Errorf("%s: expected 20 entries with unique ids, got %d (%d unique)", backend, len(entries), len(seen))
+		}
+	}
+}
+
+func TestConcurrentReplaceFunc(t *testing.T) {
+	for _, backend := range Backends {
+		path := filepath.Join(t.TempDir(), "history")
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				h, _ := Open(backend, path)
+				if _, err := h.Add(Entry{Command: "q"}); err != nil {
+					t.Error(err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				h, _ := Open(backend, path)
+				err := h.ReplaceFunc(func(entries []Entry) ([]Entry, bool, error) {
+					for i := range entries {
+						entries[i].Starred = true
+					}
+					return entries, len(entries) > 0, nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		h, _ := Open(backend, path)
+		if entries, _ := h.List(); len(entries) != 10 {
+			t.Errorf("%s: entries added during ReplaceFunc were lost: %d of 10", backend, len(entries))
+		}
+		// Без изменений хранилище не переписывается
+		info, _ := os.Stat(h.Path())
+		time.Sleep(10 * time.Millisecond)
+		h.ReplaceFunc(func(entries []Entry) ([]Entry, bool, error) { return nil, false, nil })
+		if after, _ := os.Stat(h.Path()); !after.ModTime().Equal(info.ModTime()) {
+			t.Errorf("%s: unchanged ReplaceFunc must not rewrite the history", backend)
+		}
+	}
+}
+
+func TestJSONLCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewJSONL(path)
+	for i := 0; i < 2*jsonlCompactMin; i++ {
+		if _, _, err := h.Upsert(Entry{Command: "same"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines >= jsonlCompactMin {
+		t.Errorf("journal must be compacted, got %d lines", lines)
+	}
+	if entries, _ := h.List(); len(entries) != 1 || entries[0].Index != 1 {
+		t.Errorf("unexpected entries after compaction: %+v", entries)
+	}
+}
+
+func TestJSONLTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewJSONL(path)
+	if _, err := h.Add(Entry{Command: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	// Сбой во время записи оставил недописанную строку
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"op":"put","entry":{"command":"to`)
+	file.Close()
+
+	if _, err := h.Add(Entry{Command: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Command != "first" || entries[1].Command != "second" {
+		t.Errorf("unexpected entries after torn line: %+v", entries)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Index: 1, Command: "copy folder to server", Response: "rsync -av ./dir user@host:/backup", Timestamp: base},
+		{Index: 2, Command: "sync backup with rsync", Response: "rsync -a --delete src/ dst/", Timestamp: base.Add(time.Hour)},
+		{Index: 3, Command: "list files", Response: "ls -la", Explanation: "rsync is not needed here", Timestamp: base.Add(2 * time.Hour)},
+		{Index: 4, Command: "disk usage", Response: "du -sh *", Timestamp: base.Add(3 * time.Hour)},
+	}
+
+	results := Search(entries, "rsync")
+	if len(results) != 3 || results[0].Index != 2 || results[2].Index != 3 {
+		t.Errorf("unexpected ranking: %+v", results)
+	}
+	// Опечатка и начало слова
+	if results := Search(entries, "rsycn backu"); len(results) != 2 || results[0].Index != 2 {
+		t.Errorf("unexpected fuzzy results: %+v", results)
+	}
+	if results := Search(entries, "rsync usage"); len(results) != 0 {
+		t.Errorf("all terms must match: %+v", results)
+	}
+	if results := Search(entries, ""); len(results) != 4 || results[0].Index != 4 {
+		t.Errorf("empty query must return all entries newest first: %+v", results)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"7d":  now.AddDate(0, 0, -7),
+		"2w":  now.AddDate(0, 0, -14),
+		"1m":  now.AddDate(0, -1, 0),
+		"36h": now.Add(-36 * time.Hour),
+	}
+	for value, want := range tests {
+		if got, err := ParseSince(value, now); err != nil || !got.Equal(want) {
+			t.Errorf("%s: got %v, %v", value, got, err)
+		}
+	}
+	if got, err := ParseSince("2025-01-02", now); err != nil || got.Day() != 2 {
+		t.Errorf("date: got %v, %v", got, err)
+	}
+	if _, err := ParseSince("last month", now); err == nil {
+		t.Error("expected error for invalid period")
+	}
+}
+
+func TestMarks(t *testing.T) {
+	h := NewJSON(filepath.Join(t.TempDir(), "history.json"))
+	id, _ := h.Add(Entry{Command: "list files", Response: "ls"})
+	err := h.Update(id, func(e *Entry) error {
+		e.Starred = true
+		e.Tags = NormalizeTags([]string{"#Files", "files", "Disk Usage"})
+		e.Rating = RatingGood
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := h.Get(id); !e.HasTag("FILES") || len(e.Tags) != 2 || e.Tags[0] != "disk-usage" {
+		t.Errorf("unexpected tags: %q", e.Tags)
+	}
+
+	// Перезапись тем же запросом сохраняет избранное и теги, оценку — только для того же ответа
+	h.Upsert(Entry{Command: "List files", Response: "ls"})
+	if e, _ := h.Get(id); !e.Starred || e.Rating != RatingGood || len(e.Tags) != 2 {
+		t.Errorf("marks must survive overwrite: %+v", e)
+	}
+	h.Upsert(Entry{Command: "list files", Response: "ls -la"})
+	if e, _ := h.Get(id); !e.Starred || e.Rating != "" {
+		t.Errorf("rating must be reset for a new answer: %+v", e)
+	}
+
+	if found, _ := h.Find(Filter{Starred: true, Tag: "files"}); len(found) != 1 {
+		t.Errorf("filter by marks: %+v", found)
+	}
+	if found, _ := h.Find(Filter{Rating: "any"}); len(found) != 0 {
+		t.Errorf("filter by rating: %+v", found)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	entries := []Entry{
+		{Index: 1, Command: "list files", Response: "ls", Timestamp: now.Add(-time.Hour), Tags: []string{"files"}},
+		{Index: 2, Command: "disk usage", Response: "du -sh, \"quoted\"\nsecond line", Timestamp: now, Starred: true},
+	}
+	for _, format := range []string{FormatJSON, FormatJSONL, FormatCSV} {
+		var buf bytes.Buffer
+		if err := Export(&buf, entries, format); err != nil {
+			t.Fatal(err)
+		}
+		// Формат определяется по содержимому
+		got, err := ParseExport(buf.Bytes(), "")
+		if err != nil || len(got) != 2 || got[1].Response != entries[1].Response || !got[1].Starred || !got[0].Timestamp.Equal(entries[0].Timestamp) {
+			t.Errorf("%s round trip: %+v, %v", format, got, err)
+		}
+	}
+	var buf bytes.Buffer
+	Export(&buf, entries, FormatShell)
+	if _, err := ParseExport(buf.Bytes(), ""); err == nil || !strings.Contains(buf.String(), "# #2 ") {
+		t.Errorf("shell export must be commented and not importable: %s", buf.String())
+	}
+
+	existing := []Entry{{Index: 7, Command: "List  Files", Response: "ls -a", Timestamp: now.Add(-2 * time.Hour), Starred: true}}
+	incoming := append(entries, Entry{Command: "disk usage", Response: "df", Timestamp: now.Add(-time.Minute)})
+	plan, _ := PlanImport(existing, incoming, MergeNewer)
+	if len(plan.Update) != 1 || plan.Update[0].Index != 7 || !plan.Update[0].Starred || len(plan.Add) != 1 || plan.Add[0].Response != entries[1].Response || len(plan.Skip) != 1 {
+		t.Errorf("newer: %+v", plan)
+	}
+	if plan, _ = PlanImport(existing, incoming, MergeSkip); len(plan.Update) != 0 || len(plan.Add) != 1 {
+		t.Errorf("skip: %+v", plan)
+	}
+	if plan, _ = PlanImport(existing, incoming, MergeBoth); len(plan.Add) != 3 {
+		t.Errorf("both: %+v", plan)
+	}
+	if _, err := PlanImport(existing, incoming, "merge"); err == nil {
+		t.Error("unknown strategy must fail")
+	}
+}
+
+func TestImport(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	incoming := []Entry{
+		{Index: 1, Command: "list files", Response: "ls -la", Timestamp: now},
+		{Index: 2, Command: "disk usage", Response: "du -sh", Timestamp: now},
+	}
+	for _, backend := range Backends {
+		h, err := Open(backend, filepath.Join(t.TempDir(), "history"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Add(Entry{Command: "list files", Response: "ls", Timestamp: now.Add(-time.Hour)})
+		h.Add(Entry{Command: "deleted", Response: "rm"})
+		h.Delete(2)
+
+		if plan, err := Import(h, incoming, MergeNewer, true); err != nil || len(plan.Add) != 1 || len(plan.Update) != 1 {
+			t.Errorf("%s: dry run plan: %+v, %v", backend, plan, err)
+		}
+		if entries, _ := h.List(); len(entries) != 1 || entries[0].Response != "ls" {
+			t.Errorf("%s: dry run must not change the history: %+v", backend, entries)
+		}
+
+		if _, err := Import(h, incoming, MergeNewer, false); err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := h.List()
+		if len(entries) != 2 || entries[0].Index != 1 || entries[0].Response != "ls -la" || entries[1].Index != 3 || entries[1].Command != "disk usage" {
+			t.Errorf("%s: import must update in place and not reuse deleted ids: %+v", backend, entries)
+		}
+		if _, err := Import(h, incoming, "merge", false); err == nil {
+			t.Errorf("%s: unknown strategy must fail", backend)
+		}
+	}
+}
+
+func TestSchemaUpgrade(t *testing.T) {
+	h := NewJSON(filepath.Join(t.TempDir(), "lcg_history.json"))
+	h.Add(Entry{Command: "old", Response: "ls", System: "prompt text"})
+	h.Add(Entry{Command: "new", Response: "pwd", Schema: SchemaVersion, Provider: "proxy", PromptID: 3, Host: "box", Elapsed: 1.5})
+
+	resolve := func(system string) int {
+		if system == "prompt text" {
+			return 2
+		}
+		return 0
+	}
+	if n, err := Upgrade(h, resolve); err != nil || n != 1 {
+		t.Fatalf("expected 1 upgraded entry, got %d (%v)", n, err)
+	}
+	if n, _ := Upgrade(h, resolve); n != 0 {
+		t.Errorf("second upgrade must be a no-op, got %d", n)
+	}
+	if e, _ := h.Get(1); e.Schema != SchemaVersion || e.PromptID != 2 {
+		t.Errorf("legacy entry not upgraded: %+v", e)
+	}
+
+	if got, _ := h.Find(Filter{Provider: "PROXY", Host: "box"}); len(got) != 1 || got[0].Index != 2 {
+		t.Errorf("provider/host filter: %+v", got)
+	}
+	if got, _ := h.Find(Filter{PromptID: 2}); len(got) != 1 || got[0].Index != 1 {
+		t.Errorf("prompt filter: %+v", got)
+	}
+
+	entries, _ := h.List()
+	var buf bytes.Buffer
+	Export(&buf, entries, FormatCSV)
+	got, err := ParseExport(buf.Bytes(), FormatCSV)
+	if err != nil || len(got) != 2 || got[1].Provider != "proxy" || got[1].PromptID != 3 || got[1].Elapsed != 1.5 || got[1].Schema != SchemaVersion {
+		t.Errorf("csv round trip lost metadata: %+v, %v", got, err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	for _, backend := range Backends {
+		t.Run(backend, func(t *testing.T) {
+			h, err := Open(backend, filepath.Join(t.TempDir(), "history"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, _, _ := h.Upsert(Entry{Command: "list files", Response: "ls"})
+			h.Upsert(Entry{Command: "List Files", Response: "ls -la"})
+			// Повтор того же ответа с объяснением дополняет версию, а не добавляет новую
+			h.Upsert(Entry{Command: "list files", Response: "ls", Explanation: "why", Verbosity: "v"})
+
+			e, _ := h.Get(id)
+			if len(e.Versions) != 2 || e.Versions[0].Explanation != "why" || e.Response != "ls -la" || e.Active() != 2 {
+				t.Fatalf("expected two versions with the latest shown: %+v", e)
+			}
+			if e.AddedVersion(Entry{Response: "ls -la", Timestamp: e.Versions[1].Timestamp}) != 2 || e.AddedVersion(Entry{Response: "ls", Timestamp: time.Now()}) != 0 {
+				t.Error("AddedVersion must find only the version saved at that time")
+			}
+			if n, err := e.VersionNumber("latest"); err != nil || n != 2 {
+				t.Errorf("latest must resolve to version 2, got %d (%v)", n, err)
+			}
+			if _, err := e.VersionNumber("0"); err == nil {
+				t.Error("version 0 must be rejected")
+			}
+
+			if err := h.Update(id, func(e *Entry) error { return e.Prefer(1) }); err != nil {
+				t.Fatal(err)
+			}
+			h.Upsert(Entry{Command: "list files", Response: "find . -maxdepth 1"})
+			e, _ = h.Get(id)
+			if len(e.Versions) != 3 || e.Preferred != 1 || e.Response != "ls" || e.Explanation != "why" {
+				t.Errorf("preferred version must stay shown after a new version: %+v", e)
+			}
+			if err := e.Prefer(4); err == nil {
+				t.Error("prefer of a missing version must fail")
+			}
+			if v, err := e.WithVersion(3); err != nil || v.Response != "find . -maxdepth 1" {
+				t.Errorf("version 3: %+v, %v", v, err)
+			}
+		})
+	}
+}
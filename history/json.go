@@ -0,0 +1,248 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+// formatVersion текущая версия формата JSON-файла истории
+const formatVersion = 2
+
+// jsonData содержимое JSON-файла истории.
+// Старый формат (версия 1) — просто массив записей; он читается прозрачно
+// и переписывается в новый формат при первом изменении.
+type jsonData struct {
+	Version int     `json:"version"`
+	NextID  int     `json:"next_id"`
+	Entries []Entry `json:"entries"`
+}
+
+// JSONFile история в одном JSON-файле, переписываемом целиком при каждом изменении
+type JSONFile struct {
+	path string
+}
+
+// NewJSON возвращает историю, хранящуюся в JSON-файле
+func NewJSON(path string) *JSONFile {
+	return &JSONFile{path: path}
+}
+
+func (f *JSONFile) Path() string {
+	return f.path
+}
+
+func (f *JSONFile) Init() error {
+	return f.update(func(d *jsonData) error { return nil })
+}
+
+func (f *JSONFile) List() ([]Entry, error) {
+	var entries []Entry
+	err := withLock(f.path, false, func() error {
+		d, err := f.load()
+		entries = d.Entries
+		return err
+	})
+	return entries, err
+}
+
+func (f *JSONFile) Find(filt Filter) ([]Entry, error) {
+	entries, err := f.List()
+	if err != nil {
+		return nil, err
+	}
+	return filter(entries, filt), nil
+}
+
+func (f *JSONFile) Get(id int) (Entry, error) {
+	entries, err := f.List()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, e := range entries {
+		if e.Index == id {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+func (f *JSONFile) Add(e Entry) (int, error) {
+	err := f.update(func(d *jsonData) error {
+		e.Index = d.add(e)
+		return nil
+	})
+	return e.Index, err
+}
+
+func (f *JSONFile) Put(e Entry) error {
+	return f.update(func(d *jsonData) error {
+		for i := range d.Entries {
+			if d.Entries[i].Index == e.Index {
+				d.Entries[i] = e
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *JSONFile) Upsert(e Entry) (int, bool, error) {
+	replaced := false
+	err := f.update(func(d *jsonData) error {
+		if pos := FindCommand(d.Entries, e.Command); pos >= 0 {
+			e.Index = d.Entries[pos].Index
+			e.Inherit(d.Entries[pos])
+			d.Entries[pos] = e
+			replaced = true
+			return nil
+		}
+		e.Index = d.add(e)
+		return nil
+	})
+	return e.Index, replaced, err
+}
+
+func (f *JSONFile) Update(id int, fn func(e *Entry) error) error {
+	return f.update(func(d *jsonData) error {
+		for i := range d.Entries {
+			if d.Entries[i].Index == id {
+				e := d.Entries[i]
+				if err := fn(&e); err != nil {
+					return err
+				}
+				e.Index = id
+				d.Entries[i] = e
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *JSONFile) Delete(id int) error {
+	return f.update(func(d *jsonData) error {
+		for i := range d.Entries {
+			if d.Entries[i].Index == id {
+				d.Entries = append(d.Entries[:i], d.Entries[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *JSONFile) DeleteMany(ids []int) (int, error) {
+	removed := 0
+	err := f.update(func(d *jsonData) error {
+		kept := d.Entries[:0]
+		for _, e := range d.Entries {
+			if slices.Contains(ids, e.Index) {
+				removed++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		d.Entries = kept
+		return nil
+	})
+	return removed, err
+}
+
+func (f *JSONFile) Clear() error {
+	return f.update(func(d *jsonData) error {
+		d.Entries = nil
+		return nil
+	})
+}
+
+func (f *JSONFile) Replace(entries []Entry) error {
+	entries = append([]Entry(nil), entries...)
+	return f.ReplaceFunc(func([]Entry) ([]Entry, bool, error) { return entries, true, nil })
+}
+
+func (f *JSONFile) ReplaceFunc(fn func(entries []Entry) ([]Entry, bool, error)) error {
+	return ignoreUnchanged(f.update(func(d *jsonData) error {
+		entries, next, err := replaceFunc(fn, append([]Entry(nil), d.Entries...), d.NextID)
+		if err != nil {
+			return err
+		}
+		d.Entries, d.NextID = entries, next
+		return nil
+	}))
+}
+
+func (f *JSONFile) Rewrite() error {
+	return f.update(func(d *jsonData) error { return nil })
+}
+
+// add добавляет запись с очередным номером
+func (d *jsonData) add(e Entry) int {
+	e.Index = d.NextID
+	d.NextID++
+	d.Entries = append(d.Entries, e)
+	return e.Index
+}
+
+// update выполняет изменение истории под исключительной блокировкой
+func (f *JSONFile) update(fn func(d *jsonData) error) error {
+	return withLock(f.path, true, func() error {
+		d, err := f.load()
+		if err != nil {
+			return err
+		}
+		if err := fn(&d); err != nil {
+			return err
+		}
+		if d.Entries == nil {
+			d.Entries = []Entry{}
+		}
+		out, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		if out, err = storage.Encode(out); err != nil {
+			return err
+		}
+		return writeAtomic(f.path, out)
+	})
+}
+
+// load читает файл истории; отсутствующий или пустой файл — пустая история
+func (f *JSONFile) load() (jsonData, error) {
+	d := jsonData{Version: formatVersion, NextID: 1}
+	data, err := storage.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, nil
+		}
+		return d, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return d, nil
+	}
+
+	if data[0] == '[' {
+		// Версия 1: номера были порядковыми, их и сохраняем как стабильные
+		if err := json.Unmarshal(data, &d.Entries); err != nil {
+			return d, fmt.Errorf("ошибка чтения истории %s: %v", f.path, err)
+		}
+		for i := range d.Entries {
+			if d.Entries[i].Index <= 0 {
+				d.Entries[i].Index = i + 1
+			}
+		}
+	} else if err := json.Unmarshal(data, &d); err != nil {
+		return d, fmt.Errorf("ошибка чтения истории %s: %v", f.path, err)
+	}
+
+	// Номер следующей записи не может совпасть с существующим
+	d.NextID = nextID(d.Entries, d.NextID)
+	d.Version = formatVersion
+	return d, nil
+}
@@ -0,0 +1,366 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+)
+
+// jsonlCompactMin число записей журнала, начиная с которого он может сжиматься
+const jsonlCompactMin = 100
+
+// jsonlRecord строка журнала истории
+type jsonlRecord struct {
+	Op     string `json:"op"` // put, delete, clear, meta
+	ID     int    `json:"id,omitempty"`
+	NextID int    `json:"next_id,omitempty"`
+	Entry  *Entry `json:"entry,omitempty"`
+}
+
+// jsonlState состояние истории после воспроизведения журнала
+type jsonlState struct {
+	entries []Entry
+	pos     map[int]int // номер записи -> позиция в entries
+	nextID  int
+	records int // число строк журнала
+
+	end        int64 // длина прочитанной части журнала без недописанной строки
+	unfinished bool  // последняя прочитанная строка не завершена переводом строки
+}
+
+// JSONLFile история в журнале JSONL: каждое изменение дописывается в конец файла
+// отдельной строкой, а текущее состояние получается воспроизведением журнала.
+// Когда устаревших строк становится больше, чем актуальных, журнал сжимается.
+type JSONLFile struct {
+	path string
+}
+
+// NewJSONL возвращает историю, хранящуюся в журнале JSONL
+func NewJSONL(path string) *JSONLFile {
+	return &JSONLFile{path: path}
+}
+
+func (f *JSONLFile) Path() string {
+	return f.path
+}
+
+func (f *JSONLFile) Init() error {
+	return f.update(func(s *jsonlState) ([]jsonlRecord, error) { return nil, nil })
+}
+
+func (f *JSONLFile) List() ([]Entry, error) {
+	var entries []Entry
+	err := withLock(f.path, false, func() error {
+		s, err := f.load()
+		if err != nil {
+			return err
+		}
+		entries = s.entries
+		return nil
+	})
+	return entries, err
+}
+
+func (f *JSONLFile) Find(filt Filter) ([]Entry, error) {
+	entries, err := f.List()
+	if err != nil {
+		return nil, err
+	}
+	return filter(entries, filt), nil
+}
+
+func (f *JSONLFile) Get(id int) (Entry, error) {
+	var entry Entry
+	err := withLock(f.path, false, func() error {
+		s, err := f.load()
+		if err != nil {
+			return err
+		}
+		i, ok := s.pos[id]
+		if !ok {
+			return ErrNotFound
+		}
+		entry = s.entries[i]
+		return nil
+	})
+	return entry, err
+}
+
+func (f *JSONLFile) Add(e Entry) (int, error) {
+	err := f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		e.Index = s.nextID
+		return []jsonlRecord{{Op: "put", Entry: &e}}, nil
+	})
+	return e.Index, err
+}
+
+func (f *JSONLFile) Put(e Entry) error {
+	return f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		if _, ok := s.pos[e.Index]; !ok {
+			return nil, ErrNotFound
+		}
+		return []jsonlRecord{{Op: "put", Entry: &e}}, nil
+	})
+}
+
+func (f *JSONLFile) Upsert(e Entry) (int, bool, error) {
+	replaced := false
+	err := f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		if pos := FindCommand(s.entries, e.Command); pos >= 0 {
+			e.Index = s.entries[pos].Index
+			e.Inherit(s.entries[pos])
+			replaced = true
+		} else {
+			e.Index = s.nextID
+		}
+		return []jsonlRecord{{Op: "put", Entry: &e}}, nil
+	})
+	return e.Index, replaced, err
+}
+
+func (f *JSONLFile) Update(id int, fn func(e *Entry) error) error {
+	return f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		i, ok := s.pos[id]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		e := s.entries[i]
+		if err := fn(&e); err != nil {
+			return nil, err
+		}
+		e.Index = id
+		return []jsonlRecord{{Op: "put", Entry: &e}}, nil
+	})
+}
+
+func (f *JSONLFile) Delete(id int) error {
+	return f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		if _, ok := s.pos[id]; !ok {
+			return nil, ErrNotFound
+		}
+		return []jsonlRecord{{Op: "delete", ID: id}}, nil
+	})
+}
+
+func (f *JSONLFile) DeleteMany(ids []int) (int, error) {
+	removed := 0
+	err := f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		var records []jsonlRecord
+		for _, id := range ids {
+			if _, ok := s.pos[id]; ok {
+				records = append(records, jsonlRecord{Op: "delete", ID: id})
+			}
+		}
+		removed = len(records)
+		return records, nil
+	})
+	return removed, err
+}
+
+func (f *JSONLFile) Clear() error {
+	return f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		return []jsonlRecord{{Op: "clear"}}, nil
+	})
+}
+
+func (f *JSONLFile) Replace(entries []Entry) error {
+	entries = append([]Entry(nil), entries...)
+	return f.ReplaceFunc(func([]Entry) ([]Entry, bool, error) { return entries, true, nil })
+}
+
+func (f *JSONLFile) ReplaceFunc(fn func(entries []Entry) ([]Entry, bool, error)) error {
+	return ignoreUnchanged(f.update(func(s *jsonlState) ([]jsonlRecord, error) {
+		entries, next, err := replaceFunc(fn, append([]Entry(nil), s.entries...), s.nextID)
+		if err != nil {
+			return nil, err
+		}
+		records := []jsonlRecord{{Op: "clear"}, {Op: "meta", NextID: next}}
+		for i := range entries {
+			records = append(records, jsonlRecord{Op: "put", Entry: &entries[i]})
+		}
+		return records, nil
+	}))
+}
+
+func (f *JSONLFile) Rewrite() error {
+	return withLock(f.path, true, func() error {
+		s, err := f.load()
+		if err != nil {
+			return err
+		}
+		return f.compact(s)
+	})
+}
+
+// apply применяет строку журнала к состоянию
+func (s *jsonlState) apply(r jsonlRecord) {
+	s.records++
+	switch r.Op {
+	case "put":
+		if r.Entry == nil {
+			return
+		}
+		if i, ok := s.pos[r.Entry.Index]; ok {
+			s.entries[i] = *r.Entry
+		} else {
+			s.pos[r.Entry.Index] = len(s.entries)
+			s.entries = append(s.entries, *r.Entry)
+		}
+		if r.Entry.Index >= s.nextID {
+			s.nextID = r.Entry.Index + 1
+		}
+	case "delete":
+		i, ok := s.pos[r.ID]
+		if !ok {
+			return
+		}
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		delete(s.pos, r.ID)
+		for j := i; j < len(s.entries); j++ {
+			s.pos[s.entries[j].Index] = j
+		}
+	case "clear":
+		s.entries = nil
+		s.pos = map[int]int{}
+	case "meta":
+		if r.NextID > s.nextID {
+			s.nextID = r.NextID
+		}
+	}
+}
+
+// load воспроизводит журнал. Недописанная последняя строка (сбой во время
+// записи) пропускается.
+func (f *JSONLFile) load() (*jsonlState, error) {
+	s := &jsonlState{pos: map[int]int{}, nextID: 1}
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		complete := err == nil
+		offset += int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			// Строки, записанные с шифрованием, расшифровываются по одной
+			decoded, decodeErr := storage.DecodeLine(line)
+			if decodeErr != nil {
+				if !complete {
+					break
+				}
+				return nil, fmt.Errorf("ошибка чтения истории %s, строка %d: %w", f.path, lineNo, decodeErr)
+			}
+			var r jsonlRecord
+			if jsonErr := json.Unmarshal(decoded, &r); jsonErr != nil {
+				if !complete {
+					break
+				}
+				return nil, fmt.Errorf("ошибка чтения истории %s, строка %d: %v", f.path, lineNo, jsonErr)
+			}
+			s.apply(r)
+		}
+		s.end, s.unfinished = offset, !complete && len(line) > 0
+		if !complete {
+			break
+		}
+	}
+	return s, nil
+}
+
+// update дописывает в журнал строки, сформированные fn, под исключительной блокировкой
+func (f *JSONLFile) update(fn func(s *jsonlState) ([]jsonlRecord, error)) error {
+	return withLock(f.path, true, func() error {
+		s, err := f.load()
+		if err != nil {
+			return err
+		}
+		records, err := fn(s)
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			s.apply(r)
+		}
+		if s.records >= jsonlCompactMin && s.records > 2*len(s.entries) {
+			return f.compact(s)
+		}
+
+		data, err := encodeRecords(records)
+		if err != nil {
+			return err
+		}
+		// Недописанная строка отбрасывается, иначе новые строки склеятся с ней
+		// и журнал перестанет читаться
+		if info, err := os.Stat(f.path); err == nil && info.Size() > s.end {
+			if err := os.Truncate(f.path, s.end); err != nil {
+				return err
+			}
+		}
+		if s.unfinished {
+			data = append([]byte("\n"), data...)
+		}
+		file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, storage.FileMode(0644))
+		if err != nil {
+			return err
+		}
+		if err := storage.Protect(f.path); err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	})
+}
+
+// compact переписывает журнал, оставляя только актуальные записи
+func (f *JSONLFile) compact(s *jsonlState) error {
+	records := []jsonlRecord{{Op: "meta", NextID: s.nextID}}
+	for i := range s.entries {
+		records = append(records, jsonlRecord{Op: "put", Entry: &s.entries[i]})
+	}
+	data, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(f.path, data)
+}
+
+// encodeRecords строки журнала; при включенном шифровании каждая строка
+// шифруется отдельно, чтобы журнал оставался только с дозаписью
+func encodeRecords(records []jsonlRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		if line, err = storage.EncodeLine(line); err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
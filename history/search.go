@@ -0,0 +1,185 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Веса полей при ранжировании результатов поиска
+const (
+	weightCommand     = 3.0
+	weightResponse    = 2.0
+	weightExplanation = 1.0
+)
+
+// Result запись истории с оценкой релевантности
+type Result struct {
+	Entry
+	Score float64 `json:"score"`
+}
+
+// Search ищет записи по словам запроса в запросе пользователя, ответе и объяснении.
+// Каждое слово должно найтись хотя бы в одном поле: точно, по началу слова или
+// нечетко (с опечаткой). Результаты упорядочены по убыванию релевантности, при
+// равенстве — новые первыми. Пустой запрос возвращает все записи, новые первыми.
+func Search(entries []Entry, query string) []Result {
+	terms := tokenize(query)
+	var results []Result
+	for _, e := range entries {
+		score, ok := scoreEntry(e, terms)
+		if !ok {
+			continue
+		}
+		// Фраза целиком в запросе пользователя поднимает запись выше
+		if len(terms) > 1 && strings.Contains(NormalizeQuery(e.Command), strings.Join(terms, " ")) {
+			score += weightCommand
+		}
+		results = append(results, Result{Entry: e, Score: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Timestamp.After(results[j].Timestamp)
+	})
+	return results
+}
+
+// scoreEntry суммирует лучшие совпадения каждого слова по всем полям
+func scoreEntry(e Entry, terms []string) (float64, bool) {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{tokenize(e.Command), weightCommand},
+		{tokenize(e.Response), weightResponse},
+		{tokenize(e.Explanation), weightExplanation},
+	}
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, f := range fields {
+			if s := matchWords(term, f.words) * f.weight; s > best {
+				best = s
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		total += best
+	}
+	return total, true
+}
+
+// matchWords оценивает совпадение слова со словами поля:
+// 1 — точное, 0.8 — начало слова или подстрока, 0.5 — нечеткое
+func matchWords(term string, words []string) float64 {
+	best := 0.0
+	for _, w := range words {
+		switch {
+		case w == term:
+			return 1
+		case strings.HasPrefix(w, term) || (len([]rune(term)) >= 3 && strings.Contains(w, term)):
+			best = max(best, 0.8)
+		case best < 0.5 && fuzzyMatch(term, w):
+			best = 0.5
+		}
+	}
+	return best
+}
+
+// fuzzyMatch допускает одну опечатку в словах от 4 символов и две — от 8
+func fuzzyMatch(term, word string) bool {
+	a, b := []rune(term), []rune(word)
+	limit := 0
+	switch {
+	case len(a) >= 8:
+		limit = 2
+	case len(a) >= 4:
+		limit = 1
+	}
+	if limit == 0 || abs(len(a)-len(b)) > limit {
+		return false
+	}
+	return levenshtein(a, b, limit) <= limit
+}
+
+// levenshtein расстояние редактирования (с перестановкой соседних символов);
+// вычисление прекращается, как только расстояние превысило limit
+func levenshtein(a, b []rune, limit int) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return rowMin
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// tokenize разбивает текст на слова в нижнем регистре; дефисы, точки и
+// подчеркивания остаются внутри слов (rsync, --delete, file.tar.gz)
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.'
+	})
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// ParseSince разбирает начало периода: дату (2006-01-02), время RFC3339 или
+// длительность назад от now — 36h, 7d, 2w, 1m (месяц), 1y
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if len(value) > 1 {
+		if n, err := strconv.Atoi(value[:len(value)-1]); err == nil && n >= 0 {
+			switch value[len(value)-1] {
+			case 'd':
+				return now.AddDate(0, 0, -n), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*n), nil
+			case 'm':
+				return now.AddDate(0, -n, 0), nil
+			case 'y':
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("неверный период %q: ожидается дата 2006-01-02 или длительность вида 36h, 7d, 2w, 1m, 1y", value)
+}
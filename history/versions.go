@@ -0,0 +1,183 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version один из ответов модели на запрос записи. Перегенерированные ответы
+// сохраняются как новые версии той же записи, а не заменяют прежний ответ.
+type Version struct {
+	Response      string    `json:"response"`
+	Explanation   string    `json:"explanation,omitempty"`
+	System        string    `json:"system_prompt,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	Provider      string    `json:"provider,omitempty"`
+	PromptID      int       `json:"prompt_id,omitempty"`
+	PromptVersion int       `json:"prompt_version,omitempty"`
+	Verbosity     string    `json:"verbosity,omitempty"`
+	Elapsed       float64   `json:"elapsed,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// current ответ записи как версия
+func (e Entry) current() Version {
+	return Version{
+		Response:      e.Response,
+		Explanation:   e.Explanation,
+		System:        e.System,
+		Model:         e.Model,
+		Provider:      e.Provider,
+		PromptID:      e.PromptID,
+		PromptVersion: e.PromptVersion,
+		Verbosity:     e.Verbosity,
+		Elapsed:       e.Elapsed,
+		Timestamp:     e.Timestamp,
+	}
+}
+
+// AllVersions версии ответа по порядку; у записи с одним ответом — он сам
+func (e Entry) AllVersions() []Version {
+	if len(e.Versions) > 0 {
+		return e.Versions
+	}
+	return []Version{e.current()}
+}
+
+// Active номер (с 1) версии, которая показывается как ответ записи:
+// предпочтительная или последняя
+func (e Entry) Active() int {
+	n := len(e.AllVersions())
+	if e.Preferred > 0 && e.Preferred <= n {
+		return e.Preferred
+	}
+	return n
+}
+
+// Version возвращает версию по номеру (с 1)
+func (e Entry) Version(n int) (Version, error) {
+	versions := e.AllVersions()
+	if n < 1 || n > len(versions) {
+		return Version{}, fmt.Errorf("у записи #%d нет версии %d (всего версий: %d)", e.Index, n, len(versions))
+	}
+	return versions[n-1], nil
+}
+
+// VersionNumber номер версии по аргументу команды: число с 1 или latest — последняя
+func (e Entry) VersionNumber(arg string) (int, error) {
+	if arg == "latest" {
+		return len(e.AllVersions()), nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("неверный номер версии %q", arg)
+	}
+	return n, nil
+}
+
+// AddedVersion номер (с 1) версии, которую добавило сохранение saved: ответ
+// тот же, а время версии — время сохранения. 0 — новой версии не появилось
+// (такой ответ уже был или у записи одна версия).
+func (e Entry) AddedVersion(saved Entry) int {
+	if len(e.Versions) == 0 {
+		return 0
+	}
+	i := findVersion(e.Versions, saved.Response)
+	if i < 0 || !e.Versions[i].Timestamp.Equal(saved.Timestamp) {
+		return 0
+	}
+	return i + 1
+}
+
+// WithVersion возвращает запись, в которой ответом показана версия n (с 1)
+func (e Entry) WithVersion(n int) (Entry, error) {
+	v, err := e.Version(n)
+	if err != nil {
+		return e, err
+	}
+	e.apply(v)
+	return e, nil
+}
+
+// Prefer отмечает версию n предпочтительной и делает ее ответом записи;
+// 0 снимает отметку (ответом становится последняя версия)
+func (e *Entry) Prefer(n int) error {
+	if n != 0 {
+		if _, err := e.Version(n); err != nil {
+			return err
+		}
+	}
+	if len(e.Versions) == 0 {
+		// Единственный ответ уже показывается, отмечать нечего
+		return nil
+	}
+	e.Preferred = n
+	e.apply(e.Versions[e.Active()-1])
+	return nil
+}
+
+// apply показывает версию как ответ записи
+func (e *Entry) apply(v Version) {
+	e.Response = v.Response
+	e.Explanation = v.Explanation
+	e.System = v.System
+	e.Model = v.Model
+	e.Provider = v.Provider
+	e.PromptID = v.PromptID
+	e.PromptVersion = v.PromptVersion
+	e.Verbosity = v.Verbosity
+	e.Elapsed = v.Elapsed
+}
+
+// mergeVersions добавляет ответы записи к версиям заменяемой записи. Совпадающий
+// ответ не создает новую версию, а дополняет существующую (например, объяснением).
+func (e *Entry) mergeVersions(old Entry) {
+	versions := append([]Version(nil), old.AllVersions()...)
+	for _, v := range e.AllVersions() {
+		if i := findVersion(versions, v.Response); i >= 0 {
+			versions[i] = versions[i].update(v)
+		} else {
+			versions = append(versions, v)
+		}
+	}
+	if e.Preferred == 0 {
+		e.Preferred = old.Preferred
+	}
+	if len(versions) == 1 {
+		e.Versions, e.Preferred = nil, 0
+		e.apply(versions[0])
+		return
+	}
+	e.Versions = versions
+	e.apply(versions[e.Active()-1])
+}
+
+// update дополняет версию сведениями из повторного сохранения того же ответа
+func (v Version) update(next Version) Version {
+	if strings.TrimSpace(next.Explanation) != "" {
+		v.Explanation, v.Verbosity = next.Explanation, next.Verbosity
+	}
+	if v.Provider == "" {
+		v.Provider = next.Provider
+	}
+	if v.PromptID == 0 {
+		v.PromptID, v.PromptVersion = next.PromptID, next.PromptVersion
+	}
+	if v.Elapsed == 0 {
+		v.Elapsed = next.Elapsed
+	}
+	return v
+}
+
+// findVersion позиция версии с тем же ответом (без учета пробелов по краям) или -1
+func findVersion(versions []Version, response string) int {
+	response = strings.TrimSpace(response)
+	for i, v := range versions {
+		if strings.TrimSpace(v.Response) == response {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/urfave/cli/v2"
+)
+
+// exportHistory выгружает записи истории, подходящие под фильтр, в указанном формате
+func exportHistory(filter history.Filter, since, format, output string) error {
+	if !slices.Contains(history.ExportFormats, format) {
+		return cli.Exit(fmt.Sprintf("Неизвестный формат %q (доступны: %s)", format, strings.Join(history.ExportFormats, ", ")), exitValidation)
+	}
+	if since != "" {
+		from, err := history.ParseSince(since, time.Now())
+		if err != nil {
+			return cli.Exit(err.Error(), exitValidation)
+		}
+		filter.From = from
+	}
+
+	store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	entries, err := store.Find(filter)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка чтения истории: %v", err), exitGeneral)
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Ошибка создания файла: %v", err), exitGeneral)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := history.Export(out, entries, format); err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка экспорта: %v", err), exitGeneral)
+	}
+	if output != "" {
+		if format == history.FormatShell {
+			os.Chmod(output, 0755)
+		}
+		fmt.Fprintf(os.Stderr, "✅ Выгружено записей: %d → %s\n", len(entries), output)
+	}
+	return nil
+}
+
+// importFormat определяет формат файла импорта по расширению; пустая строка —
+// определить по содержимому
+func importFormat(path, format string) string {
+	if format != "" && format != "auto" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return history.FormatJSONL
+	case ".csv":
+		return history.FormatCSV
+	case ".md", ".markdown":
+		return history.FormatMarkdown
+	case ".sh":
+		return history.FormatShell
+	}
+	return ""
+}
+
+// importHistory загружает записи из файла экспорта в историю. Записи с тем же
+// запросом (после нормализации) сливаются по стратегии; с dryRun только
+// показывается, что будет сделано.
+func importHistory(path, format, strategy string, dryRun bool) error {
+	if disableHistory {
+		fmt.Println("История отключена")
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка чтения файла: %v", err), exitValidation)
+	}
+	incoming, err := history.ParseExport(data, importFormat(path, format))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка разбора %s: %v", path, err), exitValidation)
+	}
+
+	store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	if !slices.Contains(history.MergeStrategies, strategy) {
+		return cli.Exit(fmt.Sprintf("Неизвестная стратегия %q (доступны: %s)", strategy, strings.Join(history.MergeStrategies, ", ")), exitValidation)
+	}
+	plan, err := history.Import(store, incoming, strategy, dryRun)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка импорта: %v", err), exitGeneral)
+	}
+
+	if dryRun {
+		printColored(fmt.Sprintf("🔍 Пробный импорт %s (стратегия %s), история не изменена\n", path, strategy), colorYellow)
+		for _, e := range plan.Add {
+			fmt.Printf("  + %s\n", firstLine(e.Command))
+		}
+		for _, e := range plan.Update {
+			fmt.Printf("  ~ #%d %s\n", e.Index, firstLine(e.Command))
+		}
+	}
+	printColored(fmt.Sprintf("✅ Записей в файле: %d, добавлено: %d, обновлено: %d, пропущено: %d\n",
+		len(incoming), len(plan.Add), len(plan.Update), len(plan.Skip)), colorGreen)
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/urfave/cli/v2"
+)
+
+// markFilterFlags флаги отбора записей по пометкам, провайдеру и хосту для list,
+// search и export
+func markFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "tag",
+			Usage: "Only entries with this tag",
+		},
+		&cli.BoolFlag{
+			Name:  "starred",
+			Usage: "Only starred entries",
+		},
+		&cli.StringFlag{
+			Name:  "rating",
+			Usage: "Only entries rated good, bad or any",
+		},
+		&cli.StringFlag{
+			Name:  "provider",
+			Usage: "Only entries answered via this provider (ollama, proxy)",
+		},
+		&cli.StringFlag{
+			Name:  "host",
+			Usage: "Only entries made on this machine",
+		},
+	}
+}
+
+// metaFilterFlags флаги отбора по модели и промпту для list и export
+// (у search они свои: там промпт сравнивается и по тексту у старых записей)
+func metaFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "model",
+			Usage: "Only entries answered by this model",
+		},
+		&cli.IntFlag{
+			Name:  "prompt-id",
+			Usage: "Only entries generated with this system prompt",
+		},
+	}
+}
+
+// markFilter собирает фильтр по пометкам из флагов
+func markFilter(c *cli.Context) (history.Filter, error) {
+	filter := history.Filter{
+		Tag:      c.String("tag"),
+		Starred:  c.Bool("starred"),
+		Provider: c.String("provider"),
+		Host:     c.String("host"),
+	}
+	if rating := c.String("rating"); rating != "" {
+		if rating == "any" {
+			filter.Rating = rating
+		} else {
+			r, err := history.ParseRating(rating)
+			if err != nil {
+				return filter, cli.Exit(err.Error(), exitValidation)
+			}
+			filter.Rating = r
+		}
+	}
+	return filter, nil
+}
+
+// parseHistoryID разбирает номер записи истории из аргумента
+func parseHistoryID(arg string) (int, error) {
+	var id int
+	if _, err := fmt.Sscanf(arg, "%d", &id); err != nil || id <= 0 {
+		return 0, cli.Exit("Неверный ID", exitValidation)
+	}
+	return id, nil
+}
+
+// updateHistoryMarks изменяет пометки записи и показывает результат
+func updateHistoryMarks(id int, fn func(e *history.Entry) error) error {
+	if disableHistory {
+		fmt.Println("История отключена")
+		return nil
+	}
+	var marks string
+	err := cmdPackage.UpdateHistoryEntry(config.AppConfig.ResultHistory, id, func(e *history.Entry) error {
+		if err := fn(e); err != nil {
+			return err
+		}
+		marks = e.Marks()
+		return nil
+	})
+	if err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	if marks == "" {
+		marks = "без пометок"
+	}
+	printColored(fmt.Sprintf("✅ #%d: %s\n", id, marks), colorGreen)
+	return nil
+}
+
+// setRating выставляет оценку записи; value "clear" снимает оценку
+func setRating(e *history.Entry, value, note string) error {
+	if value == "clear" {
+		e.Rating, e.RatingNote = "", ""
+		return nil
+	}
+	rating, err := history.ParseRating(value)
+	if err != nil {
+		return err
+	}
+	e.Rating, e.RatingNote = rating, strings.TrimSpace(note)
+	return nil
+}
+
+// markFromMenu обрабатывает пометки из меню действий: ответ сохраняется в историю,
+// к записи применяется пометка, после чего меню показывается снова
+func markFromMenu(choice, response string, gpt3 gpt.Gpt3, system, cmd string, timeout int, explanation string) {
+	if disableHistory {
+		printColored("📝 История отключена (--no-history / LCG_NO_HISTORY)\n", colorYellow)
+		return
+	}
+	var mark func(e *history.Entry) error
+	switch choice {
+	case "*":
+		mark = func(e *history.Entry) error {
+			e.Starred = !e.Starred
+			return nil
+		}
+	case "t":
+		fmt.Print("Теги через пробел или запятую (-тег — удалить): ")
+		tags := history.ParseTags(cmdPackage.ReadAnswer())
+		mark = func(e *history.Entry) error {
+			cmdPackage.EditTags(e, tags)
+			return nil
+		}
+	case "+", "-":
+		fmt.Print("Комментарий к оценке (Enter — без комментария): ")
+		note := cmdPackage.ReadAnswer()
+		mark = func(e *history.Entry) error {
+			return setRating(e, choice, note)
+		}
+	}
+
+	var marks string
+	id, err := cmdPackage.MarkHistoryEntry(config.AppConfig.ResultHistory, cmd, response, gpt3.Prompt, explanation, func(e *history.Entry) error {
+		if err := mark(e); err != nil {
+			return err
+		}
+		marks = e.Marks()
+		return nil
+	})
+	if err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка сохранения в историю: %v\n", err), colorRed)
+		return
+	}
+	if marks == "" {
+		marks = "без пометок"
+	}
+	printColored(fmt.Sprintf("✅ #%d: %s\n", id, marks), colorGreen)
+
+	// Запись уже в истории — дальнейшие действия сохраняют ее без вопросов
+	fromHistory = true
+	handlePostResponse(response, gpt3, system, cmd, timeout, explanation, nil)
+}
+
+// ratingRecord оцененный ответ для анализа и настройки промптов
+type ratingRecord struct {
+	ID          int    `json:"id"`
+	Timestamp   string `json:"timestamp"`
+	Model       string `json:"model,omitempty"`
+	System      string `json:"system_prompt"`
+	Query       string `json:"query"`
+	Response    string `json:"response"`
+	Explanation string `json:"explanation,omitempty"`
+	Rating      string `json:"rating"`
+	Note        string `json:"note,omitempty"`
+	Tags        string `json:"tags,omitempty"`
+}
+
+// exportRatings выгружает оцененные записи в JSONL или CSV
+func exportRatings(rating, format, output string) error {
+	filter := history.Filter{Rating: "any"}
+	if rating != "" && rating != "any" {
+		r, err := history.ParseRating(rating)
+		if err != nil {
+			return cli.Exit(err.Error(), exitValidation)
+		}
+		filter.Rating = r
+	}
+	if format != "jsonl" && format != "csv" {
+		return cli.Exit("Поддерживаются форматы jsonl и csv", exitValidation)
+	}
+
+	store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	entries, err := store.Find(filter)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка чтения истории: %v", err), exitGeneral)
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Ошибка создания файла: %v", err), exitGeneral)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	records := make([]ratingRecord, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, ratingRecord{
+			ID:          e.Index,
+			Timestamp:   e.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+			Model:       e.Model,
+			System:      e.System,
+			Query:       e.Command,
+			Response:    e.Response,
+			Explanation: e.Explanation,
+			Rating:      e.Rating,
+			Note:        e.RatingNote,
+			Tags:        strings.Join(e.Tags, ","),
+		})
+	}
+
+	if format == "csv" {
+		w := csv.NewWriter(out)
+		w.Write([]string{"id", "timestamp", "model", "system_prompt", "query", "response", "explanation", "rating", "note", "tags"})
+		for _, r := range records {
+			w.Write([]string{fmt.Sprint(r.ID), r.Timestamp, r.Model, r.System, r.Query, r.Response, r.Explanation, r.Rating, r.Note, r.Tags})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return cli.Exit(err.Error(), exitGeneral)
+		}
+	} else {
+		encoder := json.NewEncoder(out)
+		for _, r := range records {
+			if err := encoder.Encode(r); err != nil {
+				return cli.Exit(err.Error(), exitGeneral)
+			}
+		}
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "✅ Выгружено оценок: %d → %s\n", len(records), output)
+	}
+	return nil
+}
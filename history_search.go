@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/urfave/cli/v2"
+)
+
+// historySearchOptions фильтры поиска по истории
+type historySearchOptions struct {
+	Marks          history.Filter // отбор по тегу, избранному и оценке
+	Since          string
+	Model          string
+	PromptID       int
+	HasExplanation bool
+	Limit          int
+	JSON           bool
+}
+
+// searchHistory ищет записи истории с учетом фильтров; результаты упорядочены по релевантности
+func searchHistory(terms string, opts historySearchOptions) ([]history.Result, error) {
+	store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return nil, cli.Exit(err.Error(), exitValidation)
+	}
+
+	// Время, модель и пометки отбираются хранилищем (в bolt время и модель — по индексам)
+	filter := opts.Marks
+	filter.Model = opts.Model
+	if opts.Since != "" {
+		if filter.From, err = history.ParseSince(opts.Since, time.Now()); err != nil {
+			return nil, cli.Exit(err.Error(), exitValidation)
+		}
+	}
+	entries, err := store.Find(filter)
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Ошибка чтения истории: %v", err), exitGeneral)
+	}
+
+	// Записи схемы 2 хранят ID промпта; у старых записей сравниваем текст
+	promptContent := ""
+	if opts.PromptID > 0 {
+		currentUser, _ := user.Current()
+		if prompt, err := gpt.NewPromptManager(currentUser.HomeDir).GetPromptByID(opts.PromptID); err == nil {
+			promptContent = strings.TrimSpace(prompt.Content)
+		}
+	}
+	var matched []history.Entry
+	for _, e := range entries {
+		if opts.HasExplanation && strings.TrimSpace(e.Explanation) == "" {
+			continue
+		}
+		if opts.PromptID > 0 && e.PromptID != opts.PromptID &&
+			(e.PromptID != 0 || promptContent == "" || strings.TrimSpace(e.System) != promptContent) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	results := history.Search(matched, terms)
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// executeHistorySearch выводит найденные записи и в интерактивном режиме
+// предлагает выбрать одну для стандартного меню действий
+func executeHistorySearch(terms string, opts historySearchOptions, timeout int) error {
+	results, err := searchHistory(terms, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		if results == nil {
+			results = []history.Result{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	if len(results) == 0 {
+		printColored("🔍 Ничего не найдено\n", colorYellow)
+		return cli.Exit("", exitEmpty)
+	}
+
+	printColored(fmt.Sprintf("🔍 Найдено: %d\n", len(results)), colorYellow)
+	for i, r := range results {
+		marker := ""
+		if strings.TrimSpace(r.Explanation) != "" {
+			marker = " 📖"
+		}
+		if marks := r.Marks(); marks != "" {
+			marker += " " + marks
+		}
+		fmt.Printf("%2d. #%d [%s]%s %s\n", i+1, r.Index, r.Timestamp.Format("2006-01-02 15:04"), marker, r.Command)
+		printColored(fmt.Sprintf("      %s\n", firstLine(r.Response)), colorGreen)
+	}
+
+	if !cmdPackage.IsInteractive() {
+		return nil
+	}
+	fmt.Print("\nВыберите запись (номер в списке, Enter — выход): ")
+	choice := cmdPackage.ReadAnswer()
+	if choice == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(results) {
+		fmt.Println("Неверный номер")
+		return nil
+	}
+
+	entry := results[n-1].Entry
+	system := entry.System
+	if strings.TrimSpace(system) == "" {
+		system = config.AppConfig.Prompt
+	}
+	showFromHistory(entry, system, timeout)
+	return nil
+}
+
+// firstLine возвращает первую строку текста, отмечая усечение многострочного
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if line, _, found := strings.Cut(text, "\n"); found {
+		return line + " …"
+	}
+	return text
+}
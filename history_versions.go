@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/diffutil"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/urfave/cli/v2"
+)
+
+// parseVersion разбирает номер версии ответа; "latest" — последняя (0)
+func parseVersion(arg string) (int, error) {
+	if arg == "latest" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, cli.Exit(fmt.Sprintf("Неверный номер версии %q", arg), exitValidation)
+	}
+	return n, nil
+}
+
+// diffHistoryVersions выводит различия ответов и объяснений двух версий записи.
+// Без номеров сравниваются две последние версии.
+func diffHistoryVersions(id int, from, to string) error {
+	if disableHistory {
+		fmt.Println("История отключена")
+		return nil
+	}
+	store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	e, err := store.Get(id)
+	if err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	if len(e.Versions) < 2 {
+		printColored(fmt.Sprintf("У записи #%d одна версия ответа\n", id), colorYellow)
+		return nil
+	}
+
+	a, b := len(e.Versions)-1, len(e.Versions)
+	if from != "" {
+		if a, err = e.VersionNumber(from); err != nil {
+			return cli.Exit(err.Error(), exitValidation)
+		}
+	}
+	if to != "" {
+		if b, err = e.VersionNumber(to); err != nil {
+			return cli.Exit(err.Error(), exitValidation)
+		}
+	}
+	va, err := e.Version(a)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	vb, err := e.Version(b)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+
+	fromName, toName := fmt.Sprintf("версия %d", a), fmt.Sprintf("версия %d", b)
+	changed := false
+	if diff := diffutil.Unified(va.Response, vb.Response, fromName, toName, 3); diff != "" {
+		printColored("\n🔀 Ответ:\n", colorYellow)
+		printDiff(diff)
+		changed = true
+	}
+	if diff := diffutil.Unified(va.Explanation, vb.Explanation, fromName, toName, 3); diff != "" {
+		printColored("\n🔀 Объяснение:\n", colorYellow)
+		printDiff(diff)
+		changed = true
+	}
+	if !changed {
+		printColored("Изменений нет.\n", colorYellow)
+	}
+	return nil
+}
+
+// preferHistoryVersion отмечает версию ответа предпочтительной (0 — снять отметку)
+func preferHistoryVersion(id, version int) error {
+	if disableHistory {
+		fmt.Println("История отключена")
+		return nil
+	}
+	var versions int
+	err := cmdPackage.UpdateHistoryEntry(config.AppConfig.ResultHistory, id, func(e *history.Entry) error {
+		versions = len(e.AllVersions())
+		return e.Prefer(version)
+	})
+	if err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	switch {
+	case versions < 2:
+		printColored(fmt.Sprintf("У записи #%d одна версия ответа\n", id), colorYellow)
+	case version == 0:
+		printColored(fmt.Sprintf("✅ #%d: показывается последняя версия\n", id), colorGreen)
+	default:
+		printColored(fmt.Sprintf("✅ #%d: версия %d отмечена предпочтительной\n", id, version), colorGreen)
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package main
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/user"
@@ -17,7 +19,9 @@ import (
 	"github.com/atotto/clipboard"
 	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/diffutil"
 	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
 	"github.com/direct-dev-ru/linux-command-gpt/reader"
 	"github.com/direct-dev-ru/linux-command-gpt/serve"
 	"github.com/direct-dev-ru/linux-command-gpt/validation"
@@ -43,6 +47,10 @@ var disableHistory bool
 // fromHistory указывает, что текущий ответ взят из истории
 var fromHistory bool
 
+// regenerating указывает, что запрос повторяется по (r): предложение открыть
+// ответ из истории пропускается, новый ответ сохраняется версией той же записи
+var regenerating bool
+
 const (
 	colorRed    = "\033[31m"
 	colorGreen  = "\033[32m"
@@ -54,6 +62,14 @@ const (
 	colorBold   = "\033[1m"
 )
 
+// Коды возврата неинтерактивных режимов (--print, --json, --no-interactive)
+const (
+	exitGeneral    = 1 // прочие ошибки (например, сохранение результата)
+	exitValidation = 2 // некорректный запрос, файлы или флаги
+	exitProvider   = 3 // ошибка провайдера (сеть, авторизация, таймаут)
+	exitEmpty      = 4 // провайдер вернул пустой ответ
+)
+
 func main() {
 
 	if err := yaml.Unmarshal([]byte(BuildConditionsFromYaml), &CompileConditions); err != nil {
@@ -67,8 +83,11 @@ func main() {
 
 	gpt.InitBuiltinPrompts("")
 
+	// Ключ шифрования нужен до первого чтения sys_prompts и истории
+	storageErr = configureStorage()
+
 	// Авто-инициализация sys_prompts при старте CLI (создаст файл при отсутствии)
-	if currentUser, err := user.Current(); err == nil {
+	if currentUser, err := user.Current(); err == nil && storageErr == nil {
 		_ = gpt.NewPromptManager(currentUser.HomeDir)
 	}
 
@@ -81,6 +100,17 @@ func main() {
 			// Применяем флаги приложения к конфигурации перед выполнением любой команды
 			// Это гарантирует, что флаги будут применены даже для команд, которые не используют основной Action
 			applyAppFlagsToConfig(c)
+			if _, err := gpt.ParsePromptVars(config.AppConfig.MainFlags.Vars); err != nil {
+				return cli.Exit(fmt.Sprintf("--var: %v", err), exitValidation)
+			}
+			if storageErr != nil && !(c.Args().First() == "storage" && c.Args().Get(1) == "keygen") {
+				return cli.Exit(fmt.Sprintf("Ошибка ключа шифрования: %v", storageErr), exitValidation)
+			}
+			// Ограничения хранения применяются при каждом запуске; gc выводит свой отчет,
+			// а serve очищает периодически сам
+			if command := c.Args().First(); command != "gc" && command != "serve" {
+				autoRetention(c.Bool("no-history") || config.AppConfig.IsNoHistoryEnabled())
+			}
 			return nil
 		},
 		UsageText: `
@@ -89,6 +119,8 @@ lcg [опции] <описание команды>
 Примеры:
   lcg "хочу извлечь файл linux-command-gpt.tar.gz"
   lcg --file /path/to/file.txt "хочу вывести все директории с помощью ls"
+  lcg -f nginx.conf -f 'conf.d/*.conf' "почему не работает редирект?"
+  journalctl -u nginx -n 200 | lcg "что здесь не так?"
 `,
 		Description: `
 {{.AppName}} - инструмент для генерации Linux команд из описаний на естественном языке.
@@ -104,11 +136,14 @@ lcg [опции] <описание команды>
   LCG_PROVIDER            Тип провайдера: "ollama" или "proxy" (по умолчанию: ollama)
   LCG_JWT_TOKEN           JWT токен для proxy провайдера
   LCG_PROMPT_ID           ID промпта по умолчанию (по умолчанию: 1)
+  LCG_PROMPT_VARS         Переменные шаблонов промптов: имя=значение через запятую (перекрываются --var)
   LCG_TIMEOUT             Таймаут запроса в секундах (по умолчанию: 300)
   LCG_COMPLETIONS_PATH    Путь к API для завершений (по умолчанию: api/chat)
   LCG_PROXY_URL           URL прокси для proxy провайдера (по умолчанию: /api/v1/protected/sberchat/chat)
   LCG_API_KEY_FILE        Файл с API ключом (по умолчанию: .openai_api_key)
   LCG_APP_NAME            Название приложения (по умолчанию: Linux Command GPT)
+  LCG_STDIN_MAX_BYTES     Максимальный объем данных из stdin, добавляемых к запросу (по умолчанию: 3000)
+  LCG_FILE_TOKEN_BUDGET   Бюджет токенов на содержимое файлов из --file (по умолчанию: 1000, ~4 символа на токен)
   LCG_ALLOW_THINK         только для ollama: разрешить модели отправлять свои размышления ("1" или "true" = разрешено, пусто = запрещено). Имеет смысл для моделей, которые поддерживают эти действия: qwen3, deepseek.  
 
 Настройки истории и выполнения:
@@ -119,6 +154,18 @@ lcg [опции] <описание команды>
   LCG_PROMPT_FOLDER       Папка для системных промптов (по умолчанию: ~/.config/lcg/gpt_sys_prompts)
   LCG_CONFIG_FOLDER       Папка для конфигурации (по умолчанию: ~/.config/lcg/config)
 
+Ограничения хранения (пусто = без ограничения, lcg gc --dry-run покажет, что будет удалено):
+  LCG_HISTORY_MAX_AGE     Максимальный возраст записей истории (90d, 2w, 6m, 1y); также _MAX_COUNT и _MAX_SIZE (50MB)
+  LCG_RESULTS_MAX_AGE     То же для файлов результатов (LCG_RESULTS_MAX_COUNT, LCG_RESULTS_MAX_SIZE)
+  LCG_EXPLANATIONS_MAX_AGE То же для файлов объяснений (LCG_EXPLANATIONS_MAX_COUNT, LCG_EXPLANATIONS_MAX_SIZE)
+  LCG_GC_INTERVAL         Период очистки в lcg serve (по умолчанию: 1h, 0 = только при запуске)
+
+Шифрование истории, результатов и sys_prompts (AES-256-GCM, lcg storage):
+  LCG_ENCRYPTION_KEY_FILE Файл с ключом (создать: lcg storage keygen <файл>)
+  LCG_ENCRYPTION_KEY      Ключ в переменной окружения
+  LCG_ENCRYPTION_PASSPHRASE Парольная фраза
+  LCG_ENCRYPTION_ASK      Запросить парольную фразу при запуске ("true")
+
 Настройки сервера (команда serve):
   LCG_SERVER_PORT         Порт сервера (по умолчанию: 8080)
   LCG_SERVER_HOST         Хост сервера (по умолчанию: localhost)
@@ -147,10 +194,10 @@ lcg [опции] <описание команды>
   LCG_BROWSER_PATH        Путь к браузеру для автоматического открытия (команда serve --browser)
 `,
 		Flags: []cli.Flag{
-			&cli.StringFlag{
+			&cli.StringSliceFlag{
 				Name:    "file",
 				Aliases: []string{"f"},
-				Usage:   "Read part of the command from a file",
+				Usage:   "Add file contents to the request (repeatable; accepts globs and directories)",
 			},
 			&cli.StringFlag{
 				Name:        "model",
@@ -165,10 +212,10 @@ lcg [опции] <описание команды>
 				Value:   false,
 			},
 			&cli.BoolFlag{
-				Name:        "think",
-				Aliases:     []string{"T"},
-				Usage:       "Разрешить модели отправлять свои размышления",
-				Value:       false,				
+				Name:    "think",
+				Aliases: []string{"T"},
+				Usage:   "Разрешить модели отправлять свои размышления",
+				Value:   false,
 			},
 			&cli.StringFlag{
 				Name:        "query",
@@ -191,6 +238,10 @@ lcg [опции] <описание команды>
 				DefaultText: "1",
 				Value:       1,
 			},
+			&cli.StringSliceFlag{
+				Name:  "var",
+				Usage: "Prompt template variable name=value, overrides LCG_PROMPT_VARS (repeatable)",
+			},
 			&cli.IntFlag{
 				Name:        "timeout",
 				Aliases:     []string{"t"},
@@ -204,9 +255,44 @@ lcg [опции] <описание команды>
 				Usage:   "Show debug information (request parameters and prompts)",
 				Value:   false,
 			},
+			&cli.BoolFlag{
+				Name:  "no-stdin",
+				Usage: "Do not read piped stdin as request context",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "print",
+				Usage: "Print only the generated command to stdout, without menu (for shell widgets and scripts)",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print only a JSON object (command, explanation, model, elapsed, history_id), without menu",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "no-interactive",
+				Usage: "Never ask questions: print the command and exit (default answers are used)",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Answer yes to confirmation questions (overwrite history, execute, ...)",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:  "save",
+				Usage: "Save the result to the results folder (with --print, --json, --no-interactive)",
+				Value: false,
+			},
+			&cli.StringFlag{
+				Name:  "explain",
+				Usage: "Add an explanation of level v, vv or vvv (with --print, --json, --no-interactive)",
+			},
 		},
 		Action: func(c *cli.Context) error {
-			file := c.String("file")
+			files := c.StringSlice("file")
 			system := c.String("sys")
 			model := c.String("model")
 			query := c.String("query")
@@ -231,12 +317,14 @@ lcg [опции] <описание команды>
 			timeout := c.Int("timeout")
 
 			config.AppConfig.MainFlags = config.MainFlags{
-				File:      file,
+				Files:     files,
 				NoHistory: c.Bool("no-history"),
 				Sys:       system,
 				PromptID:  promptID,
 				Timeout:   timeout,
 				Debug:     c.Bool("debug"),
+				NoStdin:   c.Bool("no-stdin"),
+				Vars:      c.StringSlice("var"),
 			}
 			disableHistory = config.AppConfig.MainFlags.NoHistory || config.AppConfig.IsNoHistoryEnabled()
 
@@ -251,15 +339,28 @@ lcg [опции] <описание команды>
 			}
 
 			// Если указан prompt-id, загружаем соответствующий промпт
+			requestPrompt := 0
 			if system == "" && promptID > 0 {
 				currentUser, _ := user.Current()
 				pm := gpt.NewPromptManager(currentUser.HomeDir)
 				if prompt, err := pm.GetPromptByID(promptID); err == nil {
 					system = prompt.Content
+					requestPrompt = promptID
 				} else {
 					fmt.Printf("Warning: Prompt ID %d not found, using default prompt\n", promptID)
 				}
 			}
+			if requestPrompt == 0 {
+				effective := system
+				if effective == "" {
+					effective = config.AppConfig.Prompt
+				}
+				if resolve := promptResolver(); resolve != nil {
+					requestPrompt = resolve(effective)
+				}
+			}
+			// ID и версия промпта записываются в историю вместе с ответом
+			cmdPackage.NewRequest(requestPrompt)
 
 			if CompileConditions.NoServe {
 				if len(args) > 1 && args[0] == "serve" {
@@ -268,12 +369,48 @@ lcg [опции] <описание команды>
 				}
 			}
 
-			if config.AppConfig.Query != "" {
-				executeMain(file, system, config.AppConfig.Query, timeout)
-				return nil
+			// Аргументы имеют приоритет над значением --query по умолчанию
+			commandInput := strings.Join(args, " ")
+			if commandInput == "" || c.IsSet("query") {
+				commandInput = config.AppConfig.Query
+			}
+
+			batch := c.Bool("print") || c.Bool("json") || c.Bool("no-interactive")
+			opts := batchOptions{Format: "text", Save: c.Bool("save")}
+			switch {
+			case c.Bool("json"):
+				opts.Format = "json"
+			case c.Bool("print"):
+				opts.Format = "print"
+			}
+
+			// Данные из пайпа (some-cmd | lcg "вопрос") добавляем к запросу как контекст
+			if !config.AppConfig.MainFlags.NoStdin && !cmdPackage.StdinIsTerminal() {
+				if err := attachStdin(&commandInput); err != nil {
+					if batch {
+						return batchFail(opts, exitValidation, "ошибка чтения stdin: %v", err)
+					}
+					printColored(fmt.Sprintf("❌ Ошибка чтения stdin: %v\n", err), colorRed)
+					return nil
+				}
+				cmdPackage.UseTTYInput()
 			}
 
-			executeMain(file, system, strings.Join(args, " "), timeout)
+			if batch {
+				if explain := c.String("explain"); explain != "" {
+					if explain != "v" && explain != "vv" && explain != "vvv" {
+						return batchFail(opts, exitValidation, "неверный уровень --explain=%s (допустимо v, vv, vvv)", explain)
+					}
+					opts.Explain = len(explain)
+					cmdPackage.LastRequest.Verbosity = explain
+				}
+				return executeBatch(files, system, commandInput, timeout, opts)
+			}
+			if c.Bool("save") || c.IsSet("explain") {
+				printColored("⚠️  --save и --explain действуют только с --print, --json или --no-interactive; используйте меню действий\n", colorYellow)
+			}
+
+			executeMain(files, system, commandInput, timeout)
 			return nil
 		},
 	}
@@ -307,6 +444,9 @@ func applyAppFlagsToConfig(c *cli.Context) {
 		config.AppConfig.Prompt = sys
 	}
 
+	// Переменные шаблонов промптов (--var) нужны и подкомандам: repl, runbook add --ask
+	config.AppConfig.MainFlags.Vars = c.StringSlice("var")
+
 	// Применяем флаг timeout (только если явно установлен)
 	if c.IsSet("timeout") {
 		config.AppConfig.Timeout = fmt.Sprintf("%d", c.Int("timeout"))
@@ -316,6 +456,14 @@ func applyAppFlagsToConfig(c *cli.Context) {
 	if query := c.String("query"); query != "" && query != "Hello? what day is it today?" {
 		config.AppConfig.Query = query
 	}
+
+	// Неинтерактивный режим и автоподтверждение действуют и для подкоманд
+	if c.Bool("no-interactive") || c.Bool("json") || c.Bool("print") {
+		cmdPackage.SetInteractive(false)
+	}
+	if c.Bool("yes") {
+		cmdPackage.SetAssumeYes(true)
+	}
 }
 
 func getCommands() []*cli.Command {
@@ -480,19 +628,133 @@ func getCommands() []*cli.Command {
 					Name:    "list",
 					Aliases: []string{"l"},
 					Usage:   "List history entries",
+					Flags:   append(metaFilterFlags(), markFilterFlags()...),
 					Action: func(c *cli.Context) error {
+						filter, err := markFilter(c)
+						if err != nil {
+							return err
+						}
+						filter.Model, filter.PromptID = c.String("model"), c.Int("prompt-id")
 						if disableHistory {
 							printColored("📝 История отключена (--no-history / LCG_NO_HISTORY)\n", colorYellow)
 						} else {
-							cmdPackage.ShowHistory(config.AppConfig.ResultHistory, printColored, colorYellow)
+							cmdPackage.ShowHistory(config.AppConfig.ResultHistory, filter, printColored, colorYellow)
 						}
 						return nil
 					},
 				},
 				{
-					Name:    "view",
-					Aliases: []string{"v"},
-					Usage:   "View history entry by ID",
+					Name:      "search",
+					Aliases:   []string{"s"},
+					Usage:     "Search history by query, response and explanation (ranked, typo-tolerant)",
+					ArgsUsage: "<terms> [--since 30d] [--model name] [--provider P] [--prompt-id N] [--host H] [--has-explanation] [--tag T] [--starred] [--rating good|bad|any] [--limit N]",
+					Flags: append([]cli.Flag{
+						&cli.StringFlag{
+							Name:  "since",
+							Usage: "Only entries newer than a date (2006-01-02) or age (36h, 7d, 2w, 1m, 1y)",
+						},
+						&cli.StringFlag{
+							Name:  "model",
+							Usage: "Only entries answered by this model",
+						},
+						&cli.IntFlag{
+							Name:  "prompt-id",
+							Usage: "Only entries generated with this system prompt",
+						},
+						&cli.BoolFlag{
+							Name:  "has-explanation",
+							Usage: "Only entries with a saved explanation",
+						},
+						&cli.IntFlag{
+							Name:  "limit",
+							Usage: "Maximum number of results",
+							Value: 20,
+						},
+					}, markFilterFlags()...),
+					Action: func(c *cli.Context) error {
+						if disableHistory {
+							printColored("📝 История отключена (--no-history / LCG_NO_HISTORY)\n", colorYellow)
+							return nil
+						}
+						marks, err := markFilter(c)
+						if err != nil {
+							return err
+						}
+						opts := historySearchOptions{
+							Marks:          marks,
+							Since:          c.String("since"),
+							Model:          c.String("model"),
+							PromptID:       c.Int("prompt-id"),
+							HasExplanation: c.Bool("has-explanation"),
+							Limit:          c.Int("limit"),
+							JSON:           c.Bool("json"),
+						}
+						// urfave/cli не разбирает флаги после аргументов: `lcg history search rsync --since 30d`
+						args := c.Args().Slice()
+						var value string
+						if args, value = trailingFlag(args, "--since"); value != "" {
+							opts.Since = value
+						}
+						if args, value = trailingFlag(args, "--model"); value != "" {
+							opts.Model = value
+						}
+						if args, value = trailingFlag(args, "--prompt-id"); value != "" {
+							id, err := strconv.Atoi(value)
+							if err != nil {
+								return cli.Exit("Неверный ID промпта", exitValidation)
+							}
+							opts.PromptID = id
+						}
+						if args, value = trailingFlag(args, "--limit"); value != "" {
+							limit, err := strconv.Atoi(value)
+							if err != nil {
+								return cli.Exit("Неверное значение --limit", exitValidation)
+							}
+							opts.Limit = limit
+						}
+						if args, value = trailingFlag(args, "--tag"); value != "" {
+							opts.Marks.Tag = value
+						}
+						if args, value = trailingFlag(args, "--provider"); value != "" {
+							opts.Marks.Provider = value
+						}
+						if args, value = trailingFlag(args, "--host"); value != "" {
+							opts.Marks.Host = value
+						}
+						if args, value = trailingFlag(args, "--rating"); value != "" {
+							if opts.Marks.Rating = value; value != "any" {
+								rating, err := history.ParseRating(value)
+								if err != nil {
+									return cli.Exit(err.Error(), exitValidation)
+								}
+								opts.Marks.Rating = rating
+							}
+						}
+						for _, flag := range []string{"--has-explanation", "--starred"} {
+							if slices.Contains(args, flag) {
+								opts.HasExplanation = opts.HasExplanation || flag == "--has-explanation"
+								opts.Marks.Starred = opts.Marks.Starred || flag == "--starred"
+								args = slices.DeleteFunc(args, func(a string) bool { return a == flag })
+							}
+						}
+						timeout := 120 // default timeout
+						if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
+							timeout = t
+						}
+						return executeHistorySearch(strings.Join(args, " "), opts, timeout)
+					},
+				},
+				{
+					Name:      "view",
+					Aliases:   []string{"v"},
+					Usage:     "View history entry by ID",
+					ArgsUsage: "<id> [--version N]",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "version",
+							Usage: "Show this response version (default: preferred or latest)",
+						},
+					},
 					Action: func(c *cli.Context) error {
 						if c.NArg() == 0 {
 							fmt.Println("Укажите ID записи истории")
@@ -503,14 +765,56 @@ func getCommands() []*cli.Command {
 							fmt.Println("Неверный ID")
 							return nil
 						}
+						value := c.String("version")
+						if _, trailing := trailingFlag(c.Args().Slice(), "--version"); trailing != "" {
+							value = trailing
+						}
+						version := 0
+						if value != "" {
+							var err error
+							if version, err = parseVersion(value); err != nil {
+								return err
+							}
+						}
 						if disableHistory {
 							fmt.Println("История отключена")
 						} else {
-							cmdPackage.ViewHistoryEntry(config.AppConfig.ResultHistory, id, printColored, colorYellow, colorBold, colorGreen)
+							cmdPackage.ViewHistoryEntry(config.AppConfig.ResultHistory, id, version, printColored, colorYellow, colorBold, colorGreen)
 						}
 						return nil
 					},
 				},
+				{
+					Name:      "diff",
+					Usage:     "Show differences between response versions of a history entry",
+					ArgsUsage: "<id> [from] [to]",
+					Action: func(c *cli.Context) error {
+						id, err := parseHistoryID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						return diffHistoryVersions(id, c.Args().Get(1), c.Args().Get(2))
+					},
+				},
+				{
+					Name:      "prefer",
+					Usage:     "Mark a response version of a history entry as preferred (latest — show the newest one)",
+					ArgsUsage: "<id> <version|latest>",
+					Action: func(c *cli.Context) error {
+						id, err := parseHistoryID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						if c.NArg() < 2 {
+							return cli.Exit("Укажите номер версии или latest", exitValidation)
+						}
+						version, err := parseVersion(c.Args().Get(1))
+						if err != nil {
+							return err
+						}
+						return preferHistoryVersion(id, version)
+					},
+				},
 				{
 					Name:    "delete",
 					Aliases: []string{"d"},
@@ -533,6 +837,192 @@ func getCommands() []*cli.Command {
 						return nil
 					},
 				},
+				{
+					Name:      "star",
+					Usage:     "Mark history entry as favorite",
+					ArgsUsage: "<id>",
+					Action: func(c *cli.Context) error {
+						id, err := parseHistoryID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						return updateHistoryMarks(id, func(e *history.Entry) error {
+							e.Starred = true
+							return nil
+						})
+					},
+				},
+				{
+					Name:      "unstar",
+					Usage:     "Remove history entry from favorites",
+					ArgsUsage: "<id>",
+					Action: func(c *cli.Context) error {
+						id, err := parseHistoryID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						return updateHistoryMarks(id, func(e *history.Entry) error {
+							e.Starred = false
+							return nil
+						})
+					},
+				},
+				{
+					Name:      "tag",
+					Usage:     "Add tags to history entry (prefix a tag with - to remove it)",
+					ArgsUsage: "<id> <tag>... (-<tag> removes a tag)",
+					Action: func(c *cli.Context) error {
+						id, err := parseHistoryID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						tags := history.ParseTags(strings.Join(c.Args().Tail(), " "))
+						if len(tags) == 0 {
+							return cli.Exit("Укажите теги", exitValidation)
+						}
+						return updateHistoryMarks(id, func(e *history.Entry) error {
+							cmdPackage.EditTags(e, tags)
+							return nil
+						})
+					},
+				},
+				{
+					Name:      "rate",
+					Usage:     "Rate history entry answer as good or bad with an optional note",
+					ArgsUsage: "<id> good|bad|clear [note]",
+					Action: func(c *cli.Context) error {
+						id, err := parseHistoryID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						if c.NArg() < 2 {
+							return cli.Exit("Укажите оценку: good, bad или clear", exitValidation)
+						}
+						note := strings.Join(c.Args().Slice()[2:], " ")
+						if _, err := history.ParseRating(c.Args().Get(1)); err != nil && c.Args().Get(1) != "clear" {
+							return cli.Exit(err.Error(), exitValidation)
+						}
+						return updateHistoryMarks(id, func(e *history.Entry) error {
+							return setRating(e, c.Args().Get(1), note)
+						})
+					},
+				},
+				{
+					Name:  "ratings",
+					Usage: "Export rated entries for prompt tuning (JSONL or CSV)",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "rating",
+							Usage: "Only good or bad ratings",
+						},
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "Output format: jsonl or csv",
+							Value: "jsonl",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "Output file (default: stdout)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return exportRatings(c.String("rating"), c.String("format"), c.String("output"))
+					},
+				},
+				{
+					Name:  "export",
+					Usage: "Export history as json, jsonl, csv, markdown or sh (commented shell script)",
+					Flags: append([]cli.Flag{
+						&cli.StringFlag{
+							Name:    "format",
+							Aliases: []string{"f"},
+							Usage:   "Output format: json, jsonl, csv, markdown or sh",
+							Value:   "json",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "Output file (default: stdout)",
+						},
+						&cli.StringFlag{
+							Name:  "since",
+							Usage: "Only entries newer than a date (2006-01-02) or age (36h, 7d, 2w, 1m, 1y)",
+						},
+					}, append(metaFilterFlags(), markFilterFlags()...)...),
+					Action: func(c *cli.Context) error {
+						if disableHistory {
+							fmt.Println("История отключена")
+							return nil
+						}
+						filter, err := markFilter(c)
+						if err != nil {
+							return err
+						}
+						filter.Model, filter.PromptID = c.String("model"), c.Int("prompt-id")
+						return exportHistory(filter, c.String("since"), c.String("format"), c.String("output"))
+					},
+				},
+				{
+					Name:      "import",
+					Usage:     "Import history from a json, jsonl or csv export, merging entries with the same query",
+					ArgsUsage: "<file> [--strategy newer|both|skip] [--dry-run]",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "Input format: json, jsonl or csv (default: by extension or content)",
+						},
+						&cli.StringFlag{
+							Name:  "strategy",
+							Usage: "For entries with the same query: newer (keep the newer one), both (keep both) or skip (keep existing)",
+							Value: history.MergeNewer,
+						},
+						&cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Only show what would be imported",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						// urfave/cli не разбирает флаги после аргументов: `lcg history import file.json --dry-run`
+						args := c.Args().Slice()
+						strategy, format := c.String("strategy"), c.String("format")
+						var value string
+						if args, value = trailingFlag(args, "--strategy"); value != "" {
+							strategy = value
+						}
+						if args, value = trailingFlag(args, "--format"); value != "" {
+							format = value
+						}
+						dryRun := c.Bool("dry-run") || slices.Contains(args, "--dry-run")
+						args = slices.DeleteFunc(args, func(a string) bool { return a == "--dry-run" })
+						if len(args) != 1 {
+							return cli.Exit("Укажите файл для импорта", exitValidation)
+						}
+						return importHistory(args[0], format, strategy, dryRun)
+					},
+				},
+				{
+					Name:      "migrate",
+					Usage:     "Upgrade history entries to the current schema or copy history to another storage backend (json, jsonl, bolt)",
+					ArgsUsage: "[--to <backend> [--output <path>]]",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "to",
+							Usage: "Target backend: json, jsonl or bolt (without it entries are upgraded in place)",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "Target file (default: next to the current history file)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.String("to") == "" {
+							return upgradeHistory()
+						}
+						return migrateHistory(c.String("to"), c.String("output"))
+					},
+				},
 			},
 		},
 		{
@@ -615,6 +1105,97 @@ func getCommands() []*cli.Command {
 						return nil
 					},
 				},
+				{
+					Name:  "export",
+					Usage: "Export prompts as yaml or json",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "ids",
+							Usage: "Comma-separated prompt IDs to export (default: all)",
+						},
+						&cli.StringFlag{
+							Name:    "format",
+							Aliases: []string{"f"},
+							Usage:   "Output format: yaml or json",
+							Value:   gpt.PromptFormatYAML,
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "Output file (default: stdout)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return exportPrompts(c.String("ids"), c.String("format"), c.String("output"))
+					},
+				},
+				{
+					Name:      "import",
+					Usage:     "Import prompts from a yaml or json export, assigning new IDs",
+					ArgsUsage: "<file> [--strategy rename|overwrite|skip] [--dry-run]",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "strategy",
+							Usage: "For prompts with an existing name: rename (add as \"name (2)\"), overwrite (replace existing) or skip (keep existing)",
+							Value: gpt.ConflictRename,
+						},
+						&cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Only show what would be imported",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						// urfave/cli не разбирает флаги после аргументов: `lcg prompts import file.yaml --dry-run`
+						args := c.Args().Slice()
+						strategy := c.String("strategy")
+						var value string
+						if args, value = trailingFlag(args, "--strategy"); value != "" {
+							strategy = value
+						}
+						dryRun := c.Bool("dry-run") || slices.Contains(args, "--dry-run")
+						args = slices.DeleteFunc(args, func(a string) bool { return a == "--dry-run" })
+						if len(args) != 1 {
+							return cli.Exit("Укажите файл для импорта", exitValidation)
+						}
+						return importPrompts(args[0], strategy, dryRun)
+					},
+				},
+				{
+					Name:      "log",
+					Usage:     "Show the version history of a prompt",
+					ArgsUsage: "<id>",
+					Action: func(c *cli.Context) error {
+						id, err := parsePromptID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						return logPromptVersions(id)
+					},
+				},
+				{
+					Name:      "diff",
+					Usage:     "Show differences between two versions of a prompt (default: the last two)",
+					ArgsUsage: "<id> [v1] [v2]",
+					Action: func(c *cli.Context) error {
+						id, err := parsePromptID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						return diffPromptVersions(id, c.Args().Get(1), c.Args().Get(2))
+					},
+				},
+				{
+					Name:      "rollback",
+					Usage:     "Restore a prompt to an earlier version (default: the previous one)",
+					ArgsUsage: "<id> [version]",
+					Action: func(c *cli.Context) error {
+						id, err := parsePromptID(c.Args().First())
+						if err != nil {
+							return err
+						}
+						return rollbackPrompt(id, c.Args().Get(1))
+					},
+				},
 			},
 		},
 		{
@@ -653,39 +1234,454 @@ func getCommands() []*cli.Command {
 					if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
 						timeout = t
 					}
-					executeMain("", prompt.Content, command, timeout)
+					executeMain(nil, prompt.Content, command, timeout)
 				}
 
 				return nil
 			},
 		},
 		{
-			Name:  "serve",
-			Usage: "Start HTTP server to browse saved results",
+			Name:      "explain",
+			Aliases:   []string{"ex"},
+			Usage:     "Explain an existing command or a shell script line by line",
+			ArgsUsage: "[-v|-vv|-vvv] \"<command>\" | --file script.sh",
 			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "v", Usage: "Short explanation with alternatives"},
+				&cli.BoolFlag{Name: "vv", Usage: "Detailed explanation with alternatives"},
+				&cli.BoolFlag{Name: "vvv", Usage: "Maximum detailed explanation"},
 				&cli.StringFlag{
-					Name:    "port",
-					Aliases: []string{"p"},
-					Usage:   "Server port",
-					Value:   config.AppConfig.Server.Port,
+					Name:    "file",
+					Aliases: []string{"f"},
+					Usage:   "Shell script to annotate line by line",
 				},
+			},
+			Action: func(c *cli.Context) error {
+				command := strings.TrimSpace(strings.Join(c.Args().Slice(), " "))
+				scriptPath := c.String("file")
+				if command == "" && scriptPath == "" {
+					return cli.Exit("Usage: lcg explain [-v|-vv|-vvv] \"<command>\" | --file script.sh", 1)
+				}
+				level := 1
+				switch {
+				case c.Bool("vvv"):
+					level = 3
+				case c.Bool("vv"):
+					level = 2
+				}
+
+				timeout := 120 // default timeout
+				if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
+					timeout = t
+				}
+				gpt3 := initGPT(config.AppConfig.Prompt, timeout)
+				deps := cmdPackage.ExplainDeps{
+					DisableHistory: disableHistory || config.AppConfig.IsNoHistoryEnabled(),
+					PrintColored:   printColored,
+					ColorPurple:    colorPurple,
+					ColorGreen:     colorGreen,
+					ColorRed:       colorRed,
+					ColorYellow:    colorYellow,
+					GetCommand:     getCommand,
+				}
+				if err := cmdPackage.ExplainExisting(command, scriptPath, gpt3, timeout, level, deps); err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "fix",
+			Aliases:   []string{"fx"},
+			Usage:     "Diagnose the last failed shell command and propose a fix",
+			ArgsUsage: "[command]",
+			Action: func(c *cli.Context) error {
+				timeout := 120 // default timeout
+				if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
+					timeout = t
+				}
+				disableHistory = disableHistory || config.AppConfig.IsNoHistoryEnabled()
+				return executeFix(strings.TrimSpace(strings.Join(c.Args().Slice(), " ")), timeout)
+			},
+		},
+		{
+			Name:      "script",
+			Aliases:   []string{"sc"},
+			Usage:     "Generate a complete bash script for a multi-step task",
+			ArgsUsage: "\"<task>\" -o file.sh",
+			Flags: []cli.Flag{
 				&cli.StringFlag{
-					Name:    "host",
-					Aliases: []string{"H"},
-					Usage:   "Server host",
-					Value:   config.AppConfig.Server.Host,
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "Path to save the script",
 				},
 				&cli.BoolFlag{
-					Name:    "browser",
-					Aliases: []string{"b"},
-					Usage:   "Open browser automatically after starting server",
-					Value:   false,
+					Name:  "force",
+					Usage: "Overwrite an existing file without asking",
 				},
 			},
 			Action: func(c *cli.Context) error {
-				port := c.String("port")
-				host := c.String("host")
-				openBrowser := c.Bool("browser")
+				// urfave/cli не разбирает флаги после аргументов: `lcg script "задача" -o file.sh`
+				args, output := trailingFlag(c.Args().Slice(), "-o", "--output")
+				if output == "" {
+					output = c.String("output")
+				}
+				force := c.Bool("force") || slices.Contains(args, "--force")
+				args = slices.DeleteFunc(args, func(a string) bool { return a == "--force" })
+				task := strings.TrimSpace(strings.Join(args, " "))
+				if task == "" || output == "" {
+					return cli.Exit("Usage: lcg script \"<task>\" -o file.sh", 1)
+				}
+				timeout := 120 // default timeout
+				if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
+					timeout = t
+				}
+				return executeScript(task, output, force, timeout)
+			},
+		},
+		{
+			Name:  "repl",
+			Usage: "Interactive session with conversation context and slash-commands",
+			Action: func(c *cli.Context) error {
+				timeout := 120 // default timeout
+				if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
+					timeout = t
+				}
+				disableHistory = disableHistory || config.AppConfig.IsNoHistoryEnabled()
+				config.AppConfig.MainFlags.Debug = c.Bool("debug") || config.GetEnvBool("LCG_DEBUG", false)
+				return runREPL(timeout)
+			},
+		},
+		{
+			Name:      "shell-init",
+			Usage:     "Print shell integration script (hotkey widget and completions)",
+			ArgsUsage: "bash|zsh|fish",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "Widget hotkey in shell notation (default: Ctrl+G)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					return cli.Exit(fmt.Sprintf("Укажите оболочку: %s", strings.Join(cmdPackage.ShellNames(), "|")), 1)
+				}
+				script, err := cmdPackage.ShellInitScript(c.Args().First(), c.App, c.String("key"))
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				fmt.Print(script)
+				return nil
+			},
+		},
+		{
+			Name:  "gc",
+			Usage: "Apply retention limits to history, results and explanations (LCG_*_MAX_AGE/COUNT/SIZE)",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only show what would be deleted",
+				},
+				&cli.BoolFlag{
+					Name:    "verbose",
+					Aliases: []string{"v"},
+					Usage:   "List deleted entries and files",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				skipHistory := c.Bool("no-history") || config.AppConfig.IsNoHistoryEnabled()
+				return executeGC(c.Bool("dry-run"), c.Bool("verbose"), skipHistory)
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:      "pin",
+					Usage:     "Protect result files from retention cleanup",
+					ArgsUsage: "<file>...",
+					Action: func(c *cli.Context) error {
+						return pinResults(c.Args().Slice(), true)
+					},
+				},
+				{
+					Name:      "unpin",
+					Usage:     "Remove protection from result files",
+					ArgsUsage: "<file>...",
+					Action: func(c *cli.Context) error {
+						return pinResults(c.Args().Slice(), false)
+					},
+				},
+			},
+		},
+		{
+			Name:  "export",
+			Usage: "Export saved data",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "site",
+					Usage:     "Render results and history into a static HTML site",
+					ArgsUsage: "<dir>",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:        "base-path",
+							Usage:       "Path the site is published under, e.g. /kb",
+							Value:       config.AppConfig.Server.BasePath,
+							DefaultText: "LCG_BASE_URL",
+						},
+						&cli.StringFlag{
+							Name:        "exclude-tags",
+							Usage:       "Do not export entries and results with these tags (comma separated)",
+							Value:       config.AppConfig.Sync.ExcludeTags,
+							DefaultText: "LCG_SYNC_EXCLUDE_TAGS",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						args := c.Args().Slice()
+						basePath, excludeTags := c.String("base-path"), c.String("exclude-tags")
+						var value string
+						if args, value = trailingFlag(args, "--base-path"); value != "" {
+							basePath = value
+						}
+						if args, value = trailingFlag(args, "--exclude-tags"); value != "" {
+							excludeTags = value
+						}
+						if len(args) != 1 {
+							return cli.Exit("Укажите каталог сайта: lcg export site <каталог>", exitValidation)
+						}
+						skipHistory := c.Bool("no-history") || config.AppConfig.IsNoHistoryEnabled()
+						return executeExportSite(args[0], basePath, excludeTags, skipHistory)
+					},
+				},
+			},
+		},
+		{
+			Name:  "sync",
+			Usage: "Synchronize history, results and custom prompts through a git repository (LCG_SYNC_REMOTE)",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "remote",
+					Usage: "Git repository URL or path (overrides LCG_SYNC_REMOTE)",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only show what would be synchronized",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				skipHistory := c.Bool("no-history") || config.AppConfig.IsNoHistoryEnabled()
+				return executeSync(c.String("remote"), c.Bool("dry-run"), skipHistory)
+			},
+		},
+		{
+			Name:  "runbook",
+			Usage: "Ordered collections of commands executed step by step (LCG_RUNBOOK_FOLDER)",
+			Action: func(c *cli.Context) error {
+				return listRunbooks()
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:  "list",
+					Usage: "List runbooks",
+					Action: func(c *cli.Context) error {
+						return listRunbooks()
+					},
+				},
+				{
+					Name:      "show",
+					Usage:     "Show runbook steps",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 1 {
+							return cli.Exit("Usage: lcg runbook show <name>", exitValidation)
+						}
+						return showRunbook(c.Args().First())
+					},
+				},
+				{
+					Name:      "new",
+					Usage:     "Create an empty runbook",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "title", Usage: "Runbook title"},
+						&cli.StringFlag{Name: "description", Usage: "Runbook description"},
+					},
+					Action: func(c *cli.Context) error {
+						args := c.Args().Slice()
+						title, description := c.String("title"), c.String("description")
+						var value string
+						if args, value = trailingFlag(args, "--title"); value != "" {
+							title = value
+						}
+						if args, value = trailingFlag(args, "--description"); value != "" {
+							description = value
+						}
+						if len(args) != 1 {
+							return cli.Exit("Usage: lcg runbook new <name> [--title \"...\"] [--description \"...\"]", exitValidation)
+						}
+						return newRunbook(args[0], title, description)
+					},
+				},
+				{
+					Name:      "add",
+					Usage:     "Append a step from history, a new generation or an explicit command",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "history", Usage: "Take the command from a history entry"},
+						&cli.StringFlag{Name: "ask", Usage: "Generate the command from a query"},
+						&cli.StringFlag{Name: "command", Usage: "Command to add as is"},
+						&cli.StringFlag{Name: "description", Usage: "Step description (default: the query)"},
+					},
+					Action: func(c *cli.Context) error {
+						args := c.Args().Slice()
+						historyID := c.Int("history")
+						ask, command, description := c.String("ask"), c.String("command"), c.String("description")
+						var value string
+						if args, value = trailingFlag(args, "--history"); value != "" {
+							id, err := strconv.Atoi(value)
+							if err != nil || id <= 0 {
+								return cli.Exit(fmt.Sprintf("Неверный номер записи %q", value), exitValidation)
+							}
+							historyID = id
+						}
+						if args, value = trailingFlag(args, "--ask"); value != "" {
+							ask = value
+						}
+						if args, value = trailingFlag(args, "--command"); value != "" {
+							command = value
+						}
+						if args, value = trailingFlag(args, "--description"); value != "" {
+							description = value
+						}
+						if len(args) != 1 {
+							return cli.Exit("Usage: lcg runbook add <name> --history <id> | --ask \"<query>\" | --command \"<cmd>\" [--description \"...\"]", exitValidation)
+						}
+						timeout := 120 // default timeout
+						if t, err := strconv.Atoi(config.AppConfig.Timeout); err == nil {
+							timeout = t
+						}
+						return addRunbookStep(args[0], historyID, ask, command, description, timeout)
+					},
+				},
+				{
+					Name:      "run",
+					Usage:     "Run runbook steps one by one with confirmation",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						&cli.IntFlag{Name: "from", Value: 1, Usage: "Start from this step"},
+					},
+					Action: func(c *cli.Context) error {
+						args := c.Args().Slice()
+						from := c.Int("from")
+						var value string
+						if args, value = trailingFlag(args, "--from"); value != "" {
+							n, err := strconv.Atoi(value)
+							if err != nil {
+								return cli.Exit(fmt.Sprintf("Неверный номер шага %q", value), exitValidation)
+							}
+							from = n
+						}
+						if slices.Contains(args, "--yes") || slices.Contains(args, "-y") {
+							cmdPackage.SetAssumeYes(true)
+							args = slices.DeleteFunc(args, func(a string) bool { return a == "--yes" || a == "-y" })
+						}
+						if len(args) != 1 {
+							return cli.Exit("Usage: lcg runbook run <name> [--from N]", exitValidation)
+						}
+						return runRunbook(args[0], from)
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "Delete a runbook",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 1 {
+							return cli.Exit("Usage: lcg runbook delete <name>", exitValidation)
+						}
+						return deleteRunbook(c.Args().First())
+					},
+				},
+			},
+		},
+		{
+			Name:  "storage",
+			Usage: "Encryption at rest for history, results and sys_prompts",
+			Action: func(c *cli.Context) error {
+				return storageStatus()
+			},
+			Subcommands: []*cli.Command{
+				{
+					Name:  "status",
+					Usage: "Show encryption state",
+					Action: func(c *cli.Context) error {
+						return storageStatus()
+					},
+				},
+				{
+					Name:      "keygen",
+					Usage:     "Generate a random key file (0600)",
+					ArgsUsage: "[file]",
+					Action: func(c *cli.Context) error {
+						return keygenStorage(c.Args().First())
+					},
+				},
+				{
+					Name:  "encrypt",
+					Usage: "Encrypt existing plaintext data with the current key",
+					Action: func(c *cli.Context) error {
+						return encryptStorage()
+					},
+				},
+				{
+					Name:  "decrypt",
+					Usage: "Decrypt data back to plaintext",
+					Action: func(c *cli.Context) error {
+						return decryptStorage()
+					},
+				},
+				{
+					Name:  "rekey",
+					Usage: "Re-encrypt data with a new key (by default generates a new key into LCG_ENCRYPTION_KEY_FILE)",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "new-key-file",
+							Usage: "File with the new key",
+						},
+						&cli.BoolFlag{
+							Name:  "new-passphrase",
+							Usage: "Ask for a new passphrase",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return rekeyStorage(c.String("new-key-file"), c.Bool("new-passphrase"))
+					},
+				},
+			},
+		},
+		{
+			Name:  "serve",
+			Usage: "Start HTTP server to browse saved results",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "port",
+					Aliases: []string{"p"},
+					Usage:   "Server port",
+					Value:   config.AppConfig.Server.Port,
+				},
+				&cli.StringFlag{
+					Name:    "host",
+					Aliases: []string{"H"},
+					Usage:   "Server host",
+					Value:   config.AppConfig.Server.Host,
+				},
+				&cli.BoolFlag{
+					Name:    "browser",
+					Aliases: []string{"b"},
+					Usage:   "Open browser automatically after starting server",
+					Value:   false,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				port := c.String("port")
+				host := c.String("host")
+				openBrowser := c.Bool("browser")
 
 				// Пробрасываем debug: флаг или переменная окружения LCG_DEBUG
 				// Позволяет запускать: LCG_DEBUG=1 lcg serve ... или lcg -d serve ...
@@ -760,13 +1756,32 @@ func getCommands() []*cli.Command {
 
 }
 
-func executeMain(file, system, commandInput string, timeout int) {
-	// Валидация длины пользовательского сообщения
+func executeMain(files []string, system, commandInput string, timeout int) {
+	if len(files) > 0 {
+		if err := attachFiles(&commandInput, files); err != nil {
+			printColored(fmt.Sprintf("❌ Ошибка чтения файла: %v\n", err), colorRed)
+			return
+		}
+	}
+
+	// Валидация длины пользовательского сообщения (вместе с добавленными файлами)
 	if err := validation.ValidateUserMessage(commandInput); err != nil {
 		printColored(fmt.Sprintf("❌ Ошибка: %s\n", err.Error()), colorRed)
 		return
 	}
 
+	// Если system пустой, используем дефолтный промпт
+	if system == "" {
+		system = config.AppConfig.Prompt
+	}
+
+	// Подставляем переменные шаблона: длину проверяем по готовому тексту
+	system, err := gpt.RenderConfiguredPrompt(system)
+	if err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка: %s\n", err.Error()), colorRed)
+		return
+	}
+
 	// Валидация длины системного промпта
 	if err := validation.ValidateSystemPrompt(system); err != nil {
 		printColored(fmt.Sprintf("❌ Ошибка: %s\n", err.Error()), colorRed)
@@ -775,18 +1790,7 @@ func executeMain(file, system, commandInput string, timeout int) {
 
 	// Выводим debug информацию если включен флаг
 	if config.AppConfig.MainFlags.Debug {
-		printDebugInfo(file, system, commandInput, timeout)
-	}
-	if file != "" {
-		if err := reader.FileToPrompt(&commandInput, file); err != nil {
-			printColored(fmt.Sprintf("❌ Ошибка чтения файла: %v\n", err), colorRed)
-			return
-		}
-	}
-
-	// Если system пустой, используем дефолтный промпт
-	if system == "" {
-		system = config.AppConfig.Prompt
+		printDebugInfo(files, system, commandInput, timeout)
 	}
 
 	// Обеспечим папку результатов заранее (может понадобиться при действиях)
@@ -798,19 +1802,9 @@ func executeMain(file, system, commandInput string, timeout int) {
 	}
 
 	// Проверка истории: если такой запрос уже встречался — предложить открыть из истории
-	if !disableHistory {
+	if !disableHistory && !regenerating {
 		if found, hist := cmdPackage.CheckAndSuggestFromHistory(config.AppConfig.ResultHistory, commandInput); found && hist != nil {
-			fromHistory = true // Устанавливаем флаг, что ответ из истории
-			gpt3 := initGPT(system, timeout)
-			printColored("\nВНИМАНИЕ: ОТВЕТ СФОРМИРОВАН ИИ. ТРЕБУЕТСЯ ПРОВЕРКА И КРИТИЧЕСКИЙ АНАЛИЗ. ВОЗМОЖНЫ ОШИБКИ И ГАЛЛЮЦИНАЦИИ.\n", colorRed)
-			printColored("\n📋 Команда (из истории):\n", colorYellow)
-			printColored(fmt.Sprintf("   %s\n\n", hist.Response), colorBold+colorGreen)
-			if strings.TrimSpace(hist.Explanation) != "" {
-				printColored("\n📖 Подробное объяснение (из истории):\n\n", colorYellow)
-				fmt.Println(hist.Explanation)
-			}
-			// Показали из истории — не выполняем запрос к API, сразу меню действий
-			handlePostResponse(hist.Response, gpt3, system, commandInput, timeout, hist.Explanation)
+			showFromHistory(*hist, system, timeout)
 			return
 		}
 	}
@@ -837,7 +1831,548 @@ func executeMain(file, system, commandInput string, timeout int) {
 	// Сохраняем в историю (после завершения работы – т.е. позже, в зависимости от выбора действия)
 	// Здесь не сохраняем, чтобы учесть правило: сохранять после действия, отличного от v/vv/vvv
 	fromHistory = false // Сбрасываем флаг для новых запросов
-	handlePostResponse(response, gpt3, system, commandInput, timeout, "")
+	handlePostResponse(response, gpt3, system, commandInput, timeout, "", nil)
+}
+
+// attachFiles дописывает к запросу содержимое файлов в пределах бюджета токенов
+// и лимита длины сообщения, сообщая, что было сокращено или пропущено
+func attachFiles(commandInput *string, files []string) error {
+	budget := config.AppConfig.FileTokens * reader.CharsPerToken
+	available := config.AppConfig.Validation.MaxUserMessageLength - len(*commandInput)
+	if budget <= 0 || available < budget {
+		budget = available
+	}
+	if budget <= 0 {
+		return fmt.Errorf("запрос уже занимает весь лимит длины сообщения (%d символов)", config.AppConfig.Validation.MaxUserMessageLength)
+	}
+
+	reports, err := reader.FilesToPrompt(commandInput, files, budget)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return fmt.Errorf("по шаблонам %s не найдено ни одного файла", strings.Join(files, ", "))
+	}
+
+	included := 0
+	for _, r := range reports {
+		switch {
+		case r.Skipped != "":
+			printColored(fmt.Sprintf("⏭️  %s: пропущен (%s)\n", r.Path, r.Skipped), colorYellow)
+		case r.Omitted > 0:
+			included++
+			printColored(fmt.Sprintf("✂️  %s: включено %d из %d байт (бюджет %d символов)\n", r.Path, r.Included, r.Size, budget), colorYellow)
+		default:
+			included++
+			printColored(fmt.Sprintf("📎 %s: %d байт\n", r.Path, r.Size), colorCyan)
+		}
+	}
+	if included == 0 {
+		return fmt.Errorf("ни один файл не добавлен в запрос")
+	}
+	return nil
+}
+
+// attachStdin дописывает к запросу данные из stdin, укладываясь в лимит длины сообщения
+func attachStdin(commandInput *string) error {
+	limit := config.AppConfig.StdinMaxBytes
+	available := config.AppConfig.Validation.MaxUserMessageLength - len(*commandInput) - reader.StdinOverhead()
+	if available < limit {
+		limit = available
+	}
+	if limit <= 0 {
+		return fmt.Errorf("запрос уже занимает весь лимит длины сообщения (%d символов)", config.AppConfig.Validation.MaxUserMessageLength)
+	}
+	omitted, err := reader.StdinToPrompt(commandInput, limit)
+	if err != nil {
+		return err
+	}
+	if omitted > 0 {
+		printColored(fmt.Sprintf("✂️  Данные из stdin сокращены: пропущено %d байт (лимит %d)\n", omitted, limit), colorYellow)
+	}
+	return nil
+}
+
+// batchOptions параметры неинтерактивного запуска
+type batchOptions struct {
+	Format  string // "print" — только команда, "json" — JSON-объект, "text" — команда и объяснение
+	Save    bool   // сохранить результат в папку результатов
+	Explain int    // уровень объяснения 1–3, 0 — без объяснения
+}
+
+// batchResult результат неинтерактивного запуска для --json
+type batchResult struct {
+	Command     string  `json:"command"`
+	Explanation string  `json:"explanation,omitempty"`
+	Model       string  `json:"model"`
+	Elapsed     float64 `json:"elapsed"`
+	HistoryID   int     `json:"history_id,omitempty"`
+	SavedTo     string  `json:"saved_to,omitempty"`
+}
+
+// batchError описание ошибки для --json
+type batchError struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// batchFail завершает неинтерактивный запуск с кодом возврата; для --json
+// описание ошибки выводится в stdout JSON-объектом, иначе — в stderr
+func batchFail(opts batchOptions, code int, format string, a ...any) error {
+	msg := fmt.Sprintf(format, a...)
+	if opts.Format == "json" {
+		data, _ := json.Marshal(batchError{Error: msg, ExitCode: code})
+		fmt.Fprintln(os.Stdout, string(data))
+		return cli.Exit("", code)
+	}
+	return cli.Exit("❌ "+msg, code)
+}
+
+// executeBatch генерирует команду без меню и вопросов: в stdout попадает только
+// результат (команда или JSON), а спиннер и служебные сообщения — в stderr.
+// Используется виджетами оболочки, скриптами и CI.
+func executeBatch(files []string, system, commandInput string, timeout int, opts batchOptions) error {
+	cmdPackage.SetInteractive(false)
+
+	// Весь попутный вывод (отчеты о файлах, debug, ошибки провайдера) уводим в stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	fail := func(code int, format string, a ...any) error {
+		os.Stdout = stdout
+		return batchFail(opts, code, format, a...)
+	}
+
+	if len(files) > 0 {
+		if err := attachFiles(&commandInput, files); err != nil {
+			return fail(exitValidation, "ошибка чтения файла: %v", err)
+		}
+	}
+	if err := validation.ValidateUserMessage(commandInput); err != nil {
+		return fail(exitValidation, "%s", err.Error())
+	}
+	if system == "" {
+		system = config.AppConfig.Prompt
+	}
+	system, err := gpt.RenderConfiguredPrompt(system)
+	if err != nil {
+		return fail(exitValidation, "%s", err.Error())
+	}
+	if err := validation.ValidateSystemPrompt(system); err != nil {
+		return fail(exitValidation, "%s", err.Error())
+	}
+	if config.AppConfig.MainFlags.Debug {
+		printDebugInfo(files, system, commandInput, timeout)
+	}
+
+	gpt3 := initGPT(system, timeout)
+	response, elapsed, err := requestCommand(gpt3, commandInput)
+	if err != nil {
+		return fail(exitProvider, "ошибка провайдера: %v", err)
+	}
+	if strings.TrimSpace(response) == "" {
+		return fail(exitEmpty, "провайдер вернул пустой ответ")
+	}
+
+	result := batchResult{Command: response, Model: gpt3.Model, Elapsed: elapsed}
+	if opts.Explain > 0 {
+		explanation, err := cmdPackage.Explain(response, gpt3, commandInput, timeout, opts.Explain)
+		if err != nil {
+			return fail(exitProvider, "ошибка провайдера при получении объяснения: %v", err)
+		}
+		if strings.TrimSpace(explanation) == "" {
+			return fail(exitEmpty, "провайдер вернул пустое объяснение")
+		}
+		result.Explanation = explanation
+	}
+
+	if !disableHistory {
+		id, err := cmdPackage.SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, commandInput, response, gpt3.Prompt, result.Explanation)
+		if err != nil {
+			printColored(fmt.Sprintf("⚠️  Не удалось сохранить историю: %v\n", err), colorYellow)
+		} else {
+			result.HistoryID = id
+		}
+	}
+	if opts.Save {
+		if err := os.MkdirAll(config.AppConfig.ResultFolder, 0755); err != nil {
+			return fail(exitGeneral, "ошибка создания папки результатов: %v", err)
+		}
+		if result.SavedTo = saveResponse(response, gpt3.Model, gpt3.Prompt, commandInput, result.Explanation, result.HistoryID); result.SavedTo == "" {
+			return fail(exitGeneral, "не удалось сохранить результат")
+		}
+	}
+
+	switch opts.Format {
+	case "json":
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fail(exitGeneral, "%v", err)
+		}
+		fmt.Fprintln(stdout, string(data))
+	case "print":
+		// Объяснение — в stderr, чтобы stdout оставался пригодным для подстановки
+		if result.Explanation != "" {
+			fmt.Println(result.Explanation)
+		}
+		fmt.Fprintln(stdout, response)
+	default:
+		fmt.Fprintln(stdout, response)
+		if result.Explanation != "" {
+			fmt.Fprintf(stdout, "\n%s\n", result.Explanation)
+		}
+	}
+	return nil
+}
+
+// upgradeHistory переводит записи текущей истории в последнюю схему на месте
+func upgradeHistory() error {
+	store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	n, err := history.Upgrade(store, promptResolver())
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка обновления схемы истории: %v", err), exitGeneral)
+	}
+	if n == 0 {
+		printColored(fmt.Sprintf("✅ Все записи уже в схеме %d\n", history.SchemaVersion), colorGreen)
+		return nil
+	}
+	printColored(fmt.Sprintf("✅ Записей переведено в схему %d: %d\n", history.SchemaVersion, n), colorGreen)
+	fmt.Println("ID промпта восстановлен по тексту системного промпта; провайдер, хост и время ответа для старых записей неизвестны")
+	return nil
+}
+
+// promptResolver находит ID промпта по тексту (или имени) системного промпта записи
+func promptResolver() func(system string) int {
+	currentUser, err := user.Current()
+	if err != nil {
+		return nil
+	}
+	pm := gpt.NewPromptManager(currentUser.HomeDir)
+	return func(system string) int {
+		if prompt, err := pm.GetPromptByContent(system); err == nil {
+			return prompt.ID
+		}
+		// Веб-интерфейс сохранял в запись имя промпта вместо текста
+		if prompt, err := pm.GetPromptByName(strings.TrimSpace(system)); err == nil && system != "" {
+			return prompt.ID
+		}
+		return 0
+	}
+}
+
+// migrateHistory копирует историю из текущего хранилища в новое с сохранением номеров
+// записей. Исходный файл не изменяется.
+func migrateHistory(backend, output string) error {
+	src, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	if !slices.Contains(history.Backends, backend) {
+		return cli.Exit(fmt.Sprintf("Неизвестное хранилище %q (доступны: %s)", backend, strings.Join(history.Backends, ", ")), exitValidation)
+	}
+	if output == "" {
+		output = filepath.Join(filepath.Dir(config.AppConfig.ResultHistory), config.HistoryFileName(backend))
+	}
+	if filepath.Clean(output) == filepath.Clean(src.Path()) {
+		return cli.Exit("Файл назначения совпадает с текущим файлом истории, укажите --output", exitValidation)
+	}
+	dst, err := history.Open(backend, output)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	if entries, err := dst.List(); err == nil && len(entries) > 0 {
+		fmt.Printf("В %s уже есть записи (%d), они будут заменены. Продолжить? (y/N): ", output, len(entries))
+		if !cmdPackage.Confirm() {
+			return nil
+		}
+	}
+	n, err := history.Migrate(src, dst)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка миграции истории: %v", err), exitGeneral)
+	}
+	upgraded, err := history.Upgrade(dst, promptResolver())
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка обновления схемы истории: %v", err), exitGeneral)
+	}
+	printColored(fmt.Sprintf("✅ Перенесено записей: %d → %s\n", n, output), colorGreen)
+	if upgraded > 0 {
+		fmt.Printf("Записей переведено в схему %d: %d\n", history.SchemaVersion, upgraded)
+	}
+	fmt.Printf("Чтобы использовать новое хранилище:\n  export LCG_HISTORY_BACKEND=%s\n", backend)
+	// Путь по умолчанию зависит от хранилища, иначе его нужно указать явно
+	if filepath.Clean(output) != filepath.Join(config.AppConfig.ResultFolder, config.HistoryFileName(backend)) {
+		fmt.Printf("  export LCG_RESULT_HISTORY=%s\n", output)
+	}
+	return nil
+}
+
+// executeFix диагностирует упавшую команду: берет ее из аргумента или истории оболочки,
+// по согласию пользователя перезапускает для получения вывода и предлагает исправление
+func executeFix(command string, timeout int) error {
+	fc := cmdPackage.FailedCommand{Command: command, Status: -1, Source: "аргумент"}
+	if command == "" {
+		var err error
+		if fc, err = cmdPackage.LastShellCommand(); err != nil {
+			return cli.Exit(fmt.Sprintf("❌ %v", err), 1)
+		}
+	}
+
+	printColored("🩺 Команда: ", colorCyan)
+	fmt.Printf("%s\n", fc.Command)
+	if fc.Status >= 0 {
+		fmt.Printf("   Код возврата: %d (источник: %s)\n", fc.Status, fc.Source)
+	} else {
+		fmt.Printf("   Источник: %s\n", fc.Source)
+	}
+
+	if cmdPackage.IsInteractive() {
+		printColored("⚠️  Повторный запуск выполнит команду еще раз со всеми ее побочными эффектами.\n", colorYellow)
+		fmt.Print("Перезапустить, чтобы получить вывод ошибки? (y/N): ")
+		if cmdPackage.Confirm() {
+			if err := cmdPackage.RerunCommand(&fc, config.AppConfig.StdinMaxBytes); err != nil {
+				printColored(fmt.Sprintf("❌ Не удалось перезапустить: %v\n", err), colorRed)
+			} else {
+				fmt.Printf("   Код возврата: %d\n", fc.Status)
+				if fc.Omitted > 0 {
+					printColored(fmt.Sprintf("✂️  Вывод сокращен: пропущено %d байт\n", fc.Omitted), colorYellow)
+				}
+				if fc.Status == 0 {
+					printColored("ℹ️  Команда завершилась успешно; модель все равно проверит ее.\n", colorYellow)
+				}
+			}
+		}
+	}
+
+	lang := "ru"
+	if currentUser, err := user.Current(); err == nil {
+		lang = gpt.NewPromptManager(currentUser.HomeDir).GetCurrentLanguage()
+	}
+	system := cmdPackage.FixSystemPrompt(lang)
+	ask := cmdPackage.FixAsk(fc)
+	if err := validation.ValidateUserMessage(ask); err != nil {
+		return cli.Exit(fmt.Sprintf("❌ Ошибка: %s", err.Error()), 1)
+	}
+	if config.AppConfig.MainFlags.Debug {
+		printDebugInfo(nil, system, ask, timeout)
+	}
+	return requestFix(fc.Command, system, ask, timeout)
+}
+
+// requestFix запрашивает у модели причину ошибки и исправление и показывает меню действий
+func requestFix(command, system, ask string, timeout int) error {
+	gpt3 := initGPT(system, timeout)
+	response, elapsed := getCommand(gpt3, ask)
+	if response == "" {
+		return cli.Exit("❌ Ответ не получен. Проверьте подключение к API.", 1)
+	}
+	reason, fixed := cmdPackage.ParseFixResponse(response)
+
+	printColored(fmt.Sprintf("✅ Выполнено за %.2f сек\n", elapsed), colorGreen)
+	printColored("\nВНИМАНИЕ: ОТВЕТ СФОРМИРОВАН ИИ. ТРЕБУЕТСЯ ПРОВЕРКА И КРИТИЧЕСКИЙ АНАЛИЗ. ВОЗМОЖНЫ ОШИБКИ И ГАЛЛЮЦИНАЦИИ.\n", colorRed)
+	if reason != "" {
+		printColored("\n🔍 Причина:\n", colorYellow)
+		fmt.Printf("   %s\n", reason)
+	}
+	printColored("\n📋 Исправленная команда:\n", colorYellow)
+	printColored(fmt.Sprintf("   %s\n\n", fixed), colorBold+colorGreen)
+
+	fromHistory = false
+	handlePostResponse(fixed, gpt3, system, "fix: "+command, timeout, "", func() {
+		if err := requestFix(command, system, ask, timeout); err != nil {
+			printColored(err.Error()+"\n", colorRed)
+		}
+	})
+	return nil
+}
+
+// trailingFlag извлекает из аргументов флаг со значением, указанный после позиционных аргументов
+func trailingFlag(args []string, names ...string) ([]string, string) {
+	var rest []string
+	value := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		matched := false
+		for _, name := range names {
+			if arg == name && i+1 < len(args) {
+				value = args[i+1]
+				i++
+				matched = true
+				break
+			}
+			if v, ok := strings.CutPrefix(arg, name+"="); ok {
+				value = v
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, value
+}
+
+// executeScript генерирует bash-скрипт для задачи, проверяет синтаксис и после
+// подтверждения сохраняет его с битом исполнения. Перегенерация и доработка
+// работают со всем файлом и показывают diff между версиями.
+func executeScript(task, output string, force bool, timeout int) error {
+	if _, err := os.Stat(output); err == nil && !force {
+		fmt.Printf("Файл %s уже существует. Перезаписать? (y/N): ", output)
+		if !cmdPackage.Confirm() {
+			if !cmdPackage.IsInteractive() {
+				return cli.Exit(fmt.Sprintf("❌ Файл %s уже существует (используйте --force или --yes)", output), 1)
+			}
+			fmt.Println("❌ Отменено")
+			return nil
+		}
+	}
+	if err := validation.ValidateUserMessage(task); err != nil {
+		return cli.Exit(fmt.Sprintf("❌ Ошибка: %s", err.Error()), 1)
+	}
+
+	lang := "ru"
+	if currentUser, err := user.Current(); err == nil {
+		lang = gpt.NewPromptManager(currentUser.HomeDir).GetCurrentLanguage()
+	}
+	gpt3 := initGPT(cmdPackage.ScriptSystemPrompt(lang), timeout)
+	if config.AppConfig.MainFlags.Debug {
+		printDebugInfo(nil, gpt3.Prompt, task, timeout)
+	}
+
+	printColored("📝 Задача: ", colorCyan)
+	fmt.Printf("%s\n", task)
+
+	var script string
+	version := 0
+	ask := task
+	for {
+		response, elapsed := getCommand(gpt3, ask)
+		if response == "" {
+			return cli.Exit("❌ Ответ не получен. Проверьте подключение к API.", 1)
+		}
+		previous := script
+		script = cmdPackage.ExtractScript(response)
+		version++
+		printColored(fmt.Sprintf("✅ Версия %d готова за %.2f сек\n", version, elapsed), colorGreen)
+
+		if version > 1 {
+			diff := diffutil.Unified(previous, script, fmt.Sprintf("версия %d", version-1), fmt.Sprintf("версия %d", version), 3)
+			if diff == "" {
+				printColored("\nИзменений нет.\n", colorYellow)
+			} else {
+				printColored("\n🔀 Изменения:\n", colorYellow)
+				printDiff(diff)
+			}
+		} else {
+			printColored("\nВНИМАНИЕ: ОТВЕТ СФОРМИРОВАН ИИ. ТРЕБУЕТСЯ ПРОВЕРКА И КРИТИЧЕСКИЙ АНАЛИЗ. ВОЗМОЖНЫ ОШИБКИ И ГАЛЛЮЦИНАЦИИ.\n", colorRed)
+			printColored("\n📋 Скрипт:\n\n", colorYellow)
+			fmt.Print(script)
+		}
+
+		syntaxOK := true
+		switch err := cmdPackage.CheckScriptSyntax(script); {
+		case err == nil:
+			printColored("\n✅ Синтаксис проверен (bash -n)\n", colorGreen)
+		case errors.Is(err, exec.ErrNotFound):
+			printColored("\n⚠️  bash не найден, проверка синтаксиса пропущена\n", colorYellow)
+		default:
+			syntaxOK = false
+			printColored(fmt.Sprintf("\n❌ Ошибка синтаксиса:\n%s\n", err), colorRed)
+		}
+
+		if !cmdPackage.IsInteractive() {
+			if !syntaxOK {
+				return cli.Exit("❌ Скрипт не сохранен: ошибка синтаксиса", 1)
+			}
+			return saveScript(task, output, script, gpt3.Model)
+		}
+
+		next, done, err := scriptAction(task, output, script, syntaxOK, gpt3.Model)
+		if done {
+			return err
+		}
+		ask = next
+	}
+}
+
+// scriptAction показывает меню действий над скриптом. Возвращает запрос для следующей
+// версии либо done=true, если работа завершена (скрипт сохранен или пользователь вышел).
+func scriptAction(task, output, script string, syntaxOK bool, model string) (string, bool, error) {
+	for {
+		fmt.Printf("\nДействия: (s)сохранить в %s, (r)перегенерировать, (f)доработать, (p)показать целиком, (n)ничего: ", output)
+		switch strings.ToLower(cmdPackage.ReadAnswer()) {
+		case "s":
+			if !syntaxOK {
+				fmt.Print("Скрипт содержит ошибки синтаксиса. Все равно сохранить? (y/N): ")
+				if !cmdPackage.Confirm() {
+					continue
+				}
+			}
+			return "", true, saveScript(task, output, script, model)
+		case "r":
+			fmt.Println("🔄 Перегенерирую...")
+			return task, false, nil
+		case "f":
+			fmt.Print("Что изменить? ")
+			instruction := cmdPackage.ReadAnswer()
+			if instruction == "" {
+				continue
+			}
+			ask := cmdPackage.ScriptRefineAsk(task, script, instruction)
+			if err := validation.ValidateUserMessage(ask); err != nil {
+				printColored(fmt.Sprintf("❌ Ошибка: %s\n", err.Error()), colorRed)
+				continue
+			}
+			fmt.Println("🛠️  Дорабатываю...")
+			return ask, false, nil
+		case "p":
+			fmt.Println()
+			fmt.Print(script)
+		default:
+			fmt.Println(" До свидания!")
+			return "", true, nil
+		}
+	}
+}
+
+// saveScript записывает скрипт на диск и фиксирует его в результатах вместе с задачей
+func saveScript(task, output, script, model string) error {
+	if err := cmdPackage.WriteScript(output, script); err != nil {
+		return cli.Exit(fmt.Sprintf("❌ Ошибка сохранения скрипта: %v", err), 1)
+	}
+	printColored(fmt.Sprintf("💾 Скрипт сохранен: %s (chmod 755)\n", output), colorGreen)
+
+	if err := os.MkdirAll(config.AppConfig.ResultFolder, 0755); err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка создания папки результатов: %v\n", err), colorRed)
+		return nil
+	}
+	if resultPath, err := cmdPackage.SaveScriptResult(config.AppConfig.ResultFolder, model, task, output, script); err != nil {
+		fmt.Println("Failed to save response:", err)
+	} else {
+		fmt.Printf("Saved to %s\n", resultPath)
+	}
+	return nil
+}
+
+// printDiff выводит unified diff с подсветкой добавленных и удаленных строк
+func printDiff(diff string) {
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			printColored(line, colorBold)
+		case strings.HasPrefix(line, "@@"):
+			printColored(line, colorCyan)
+		case strings.HasPrefix(line, "+"):
+			printColored(line, colorGreen)
+		case strings.HasPrefix(line, "-"):
+			printColored(line, colorRed)
+		default:
+			fmt.Print(line)
+		}
+	}
 }
 
 // checkAndSuggestFromHistory проверяет файл истории и при совпадении запроса предлагает показать сохраненный результат
@@ -863,7 +2398,25 @@ func initGPT(system string, timeout int) gpt.Gpt3 {
 }
 
 func getCommand(gpt3 gpt.Gpt3, cmd string) (string, float64) {
+	response, elapsed, err := requestCommand(gpt3, cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка при выполнении запроса: %v\n", err)
+	}
+	return response, elapsed
+}
+
+// requestCommand выполняет запрос к модели со спиннером в stderr и возвращает ошибку провайдера
+func requestCommand(gpt3 gpt.Gpt3, cmd string) (string, float64, error) {
 	gpt3.InitKey()
+	response, elapsed, err := withSpinner(func() (string, error) {
+		return gpt3.Complete(cmd)
+	})
+	cmdPackage.LastRequest.Elapsed = elapsed
+	return response, elapsed, err
+}
+
+// withSpinner выполняет запрос, показывая спиннер в stderr, и возвращает время выполнения
+func withSpinner(request func() (string, error)) (string, float64, error) {
 	start := time.Now()
 	done := make(chan bool)
 
@@ -873,35 +2426,55 @@ func getCommand(gpt3 gpt.Gpt3, cmd string) (string, float64) {
 		for {
 			select {
 			case <-done:
-				fmt.Printf("\r%s", strings.Repeat(" ", 50))
-				fmt.Print("\r")
+				fmt.Fprintf(os.Stderr, "\r%s", strings.Repeat(" ", 50))
+				fmt.Fprint(os.Stderr, "\r")
 				return
 			default:
-				fmt.Printf("\r%s Обрабатываю запрос...", loadingChars[i])
+				fmt.Fprintf(os.Stderr, "\r%s Обрабатываю запрос...", loadingChars[i])
 				i = (i + 1) % len(loadingChars)
 				time.Sleep(100 * time.Millisecond)
 			}
 		}
 	}()
 
-	response := gpt3.Completions(cmd)
+	response, err := request()
 	done <- true
 	elapsed := math.Round(time.Since(start).Seconds()*100) / 100
 
-	return response, elapsed
+	return response, elapsed, err
+}
+
+// showFromHistory показывает сохраненный ответ из истории и сразу открывает меню
+// действий — запрос к API не выполняется
+func showFromHistory(hist cmdPackage.HistoryEntry, system string, timeout int) {
+	fromHistory = true // Устанавливаем флаг, что ответ из истории
+	gpt3 := initGPT(system, timeout)
+	printColored("\nВНИМАНИЕ: ОТВЕТ СФОРМИРОВАН ИИ. ТРЕБУЕТСЯ ПРОВЕРКА И КРИТИЧЕСКИЙ АНАЛИЗ. ВОЗМОЖНЫ ОШИБКИ И ГАЛЛЮЦИНАЦИИ.\n", colorRed)
+	printColored("\n📋 Команда (из истории):\n", colorYellow)
+	printColored(fmt.Sprintf("   %s\n\n", hist.Response), colorBold+colorGreen)
+	if strings.TrimSpace(hist.Explanation) != "" {
+		printColored("\n📖 Подробное объяснение (из истории):\n\n", colorYellow)
+		fmt.Println(hist.Explanation)
+	}
+	handlePostResponse(hist.Response, gpt3, system, hist.Command, timeout, hist.Explanation, nil)
 }
 
-func handlePostResponse(response string, gpt3 gpt.Gpt3, system, cmd string, timeout int, explanation string) {
+// handlePostResponse показывает меню действий над ответом. regenerate задает, как
+// перегенерировать ответ; nil — повторить обычный запрос cmd с тем же системным промптом.
+func handlePostResponse(response string, gpt3 gpt.Gpt3, system, cmd string, timeout int, explanation string, regenerate func()) {
 	// Формируем меню действий
 	menu := "Действия: (c)копировать, (s)сохранить, (r)перегенерировать"
 	if config.AppConfig.AllowExecution {
 		menu += ", (e)выполнить"
 	}
-	menu += ", (v|vv|vvv)подробно, (n)ничего: "
+	menu += ", (v|vv|vvv)подробно"
+	if !disableHistory {
+		menu += ", (*)избранное, (t)теги, (+|-)оценка"
+	}
+	menu += ", (n)ничего: "
 
 	fmt.Print(menu)
-	var choice string
-	fmt.Scanln(&choice)
+	choice := cmdPackage.ReadAnswer()
 
 	switch strings.ToLower(choice) {
 	case "c":
@@ -915,21 +2488,38 @@ func handlePostResponse(response string, gpt3 gpt.Gpt3, system, cmd string, time
 			}
 		}
 	case "s":
-		if fromHistory && strings.TrimSpace(explanation) != "" {
-			saveResponse(response, gpt3.Model, gpt3.Prompt, cmd, explanation)
-		} else {
-			saveResponse(response, gpt3.Model, gpt3.Prompt, cmd)
-		}
+		// Сначала история: файл результата ссылается на запись
+		historyID := 0
 		if !disableHistory {
 			if fromHistory {
-				cmdPackage.SaveToHistoryFromHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, cmd, response, gpt3.Prompt, explanation)
+				historyID, _ = cmdPackage.SaveToHistoryFromHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, cmd, response, gpt3.Prompt, explanation)
 			} else {
-				cmdPackage.SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, cmd, response, gpt3.Prompt)
+				historyID, _ = cmdPackage.SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, cmd, response, gpt3.Prompt)
 			}
 		}
+		if fromHistory {
+			saveResponse(response, gpt3.Model, gpt3.Prompt, cmd, explanation, historyID)
+		} else {
+			saveResponse(response, gpt3.Model, gpt3.Prompt, cmd, "", historyID)
+		}
 	case "r":
+		// Текущий ответ остается версией записи истории, новый добавится следующей
+		if !disableHistory {
+			cmdPackage.SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, cmd, response, gpt3.Prompt, explanation)
+		}
+		// У нового ответа еще нет объяснения
+		cmdPackage.NewRequest(cmdPackage.LastRequest.PromptID)
 		fmt.Println("🔄 Перегенерирую...")
-		executeMain("", system, cmd, timeout)
+		func() {
+			// Флаг действует только на этот повтор, а не на следующие запросы процесса
+			regenerating = true
+			defer func() { regenerating = false }()
+			if regenerate != nil {
+				regenerate()
+			} else {
+				executeMain(nil, system, cmd, timeout)
+			}
+		}()
 	case "e":
 		if config.AppConfig.AllowExecution {
 			executeCommand(response)
@@ -943,6 +2533,8 @@ func handlePostResponse(response string, gpt3 gpt.Gpt3, system, cmd string, time
 		} else {
 			fmt.Println("⚠️  Выполнение команд отключено. Установите LCG_ALLOW_EXECUTION=1 для включения этой функции.")
 		}
+	case "*", "t", "+", "-":
+		markFromMenu(strings.ToLower(choice), response, gpt3, system, cmd, timeout, explanation)
 	case "v", "vv", "vvv":
 		level := len(choice) // 1, 2, 3
 		deps := cmdPackage.ExplainDeps{
@@ -980,10 +2572,8 @@ func handlePostResponse(response string, gpt3 gpt.Gpt3, system, cmd string, time
 func executeCommand(command string) {
 	fmt.Printf("🚀 Выполняю: %s\n", command)
 	fmt.Print("Продолжить? (y/N): ")
-	var confirm string
-	fmt.Scanln(&confirm)
 
-	if strings.ToLower(confirm) == "y" || strings.ToLower(confirm) == "yes" {
+	if cmdPackage.Confirm() {
 		cmd := exec.Command("bash", "-c", command)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -1008,7 +2598,8 @@ func printColored(text, color string) {
 
 func showTips() {
 	printColored("💡 Подсказки:\n", colorCyan)
-	fmt.Println("   • Используйте --file для чтения из файла")
+	fmt.Println("   • Используйте --file для чтения из файлов (можно несколько раз, поддерживаются шаблоны и каталоги)")
+	fmt.Println("   • Передайте вывод команды через пайп: some-cmd | lcg \"что здесь не так?\"")
 	fmt.Println("   • Используйте --sys для изменения системного промпта")
 	fmt.Println("   • Используйте --prompt-id для выбора предустановленного промпта")
 	fmt.Println("   • Используйте --timeout для установки таймаута запроса")
@@ -1018,14 +2609,15 @@ func showTips() {
 	fmt.Println("   • Команда 'config' покажет текущие настройки")
 	fmt.Println("   • Команда 'health' проверит доступность API")
 	fmt.Println("   • Команда 'serve' запустит HTTP сервер для просмотра результатов")
+	fmt.Println("   • Команда 'shell-init bash|zsh|fish' подключит виджет: описание в строке + Ctrl+G → команда")
 	fmt.Println("   • Используйте --browser для автоматического открытия браузера")
 	fmt.Println("   • Установите LCG_BROWSER_PATH для указания конкретного браузера")
 }
 
 // printDebugInfo выводит отладочную информацию о параметрах запроса
-func printDebugInfo(file, system, commandInput string, timeout int) {
+func printDebugInfo(files []string, system, commandInput string, timeout int) {
 	printColored("\n🔍 DEBUG ИНФОРМАЦИЯ:\n", colorCyan)
-	fmt.Printf("📁 Файл: %s\n", file)
+	fmt.Printf("📁 Файлы: %s\n", strings.Join(files, ", "))
 	fmt.Printf("🤖 Системный промпт: %s\n", system)
 	fmt.Printf("💬 Запрос: %s\n", commandInput)
 	fmt.Printf("⏱️ Таймаут: %d сек\n", timeout)
@@ -1135,29 +2727,34 @@ func showFullConfig() {
 		ApiKeyFile     string                  `json:"api_key_file"`
 		ResultFolder   string                  `json:"result_folder"`
 		PromptFolder   string                  `json:"prompt_folder"`
+		RunbookFolder  string                  `json:"runbook_folder"`
 		ProviderType   string                  `json:"provider_type"`
 		JwtToken       string                  `json:"jwt_token"` // Показываем статус, не сам токен
 		PromptID       string                  `json:"prompt_id"`
 		Timeout        string                  `json:"timeout"`
 		ResultHistory  string                  `json:"result_history"`
+		HistoryBackend string                  `json:"history_backend"`
 		NoHistoryEnv   string                  `json:"no_history_env"`
 		AllowExecution bool                    `json:"allow_execution"`
 		MainFlags      config.MainFlags        `json:"main_flags"`
+		Retention      config.RetentionConfig  `json:"retention"`
+		Sync           config.SyncConfig       `json:"sync"`
 		Server         config.ServerConfig     `json:"server"`
 		Validation     config.ValidationConfig `json:"validation"`
 	}
 
 	// Создаем безопасную копию конфигурации
 	safeConfig := SafeConfig{
-		Cwd:          config.AppConfig.Cwd,
-		Host:         config.AppConfig.Host,
-		Completions:  config.AppConfig.Completions,
-		Model:        config.AppConfig.Model,
-		Prompt:       config.AppConfig.Prompt,
-		ApiKeyFile:   config.AppConfig.ApiKeyFile,
-		ResultFolder: config.AppConfig.ResultFolder,
-		PromptFolder: config.AppConfig.PromptFolder,
-		ProviderType: config.AppConfig.ProviderType,
+		Cwd:           config.AppConfig.Cwd,
+		Host:          config.AppConfig.Host,
+		Completions:   config.AppConfig.Completions,
+		Model:         config.AppConfig.Model,
+		Prompt:        config.AppConfig.Prompt,
+		ApiKeyFile:    config.AppConfig.ApiKeyFile,
+		ResultFolder:  config.AppConfig.ResultFolder,
+		PromptFolder:  config.AppConfig.PromptFolder,
+		RunbookFolder: config.AppConfig.RunbookFolder,
+		ProviderType:  config.AppConfig.ProviderType,
 		JwtToken: func() string {
 			if config.AppConfig.JwtToken != "" {
 				return "***set***"
@@ -1172,14 +2769,20 @@ func showFullConfig() {
 		PromptID:       config.AppConfig.PromptID,
 		Timeout:        config.AppConfig.Timeout,
 		ResultHistory:  config.AppConfig.ResultHistory,
+		HistoryBackend: config.AppConfig.HistoryBackend,
 		NoHistoryEnv:   config.AppConfig.NoHistoryEnv,
 		AllowExecution: config.AppConfig.AllowExecution,
 		MainFlags:      config.AppConfig.MainFlags,
+		Retention:      config.AppConfig.Retention,
+		Sync:           config.AppConfig.Sync,
 		Server:         config.AppConfig.Server,
 		Validation:     config.AppConfig.Validation,
 	}
 
 	safeConfig.Server.Password = "***"
+	if u, err := url.Parse(safeConfig.Sync.Remote); err == nil && u.User != nil {
+		safeConfig.Sync.Remote = u.Redacted()
+	}
 
 	// Выводим JSON с отступами
 	jsonData, err := json.MarshalIndent(safeConfig, "", "  ")
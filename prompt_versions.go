@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/urfave/cli/v2"
+)
+
+// parsePromptID разбирает ID промпта из аргумента команды
+func parsePromptID(arg string) (int, error) {
+	if arg == "" {
+		return 0, cli.Exit("Укажите ID промпта", exitValidation)
+	}
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, cli.Exit(fmt.Sprintf("Неверный ID промпта %q", arg), exitValidation)
+	}
+	return id, nil
+}
+
+// promptVersions версии промпта; ошибка — промпта нет ни среди текущих, ни в версиях
+func promptVersions(id int) (*gpt.PromptManager, []gpt.PromptVersion, error) {
+	currentUser, _ := user.Current()
+	pm := gpt.NewPromptManager(currentUser.HomeDir)
+	list, err := pm.PromptVersions(id)
+	if err != nil {
+		return nil, nil, cli.Exit(err.Error(), exitValidation)
+	}
+	return pm, list, nil
+}
+
+// logPromptVersions выводит версии промпта от новых к старым
+func logPromptVersions(id int) error {
+	pm, list, err := promptVersions(id)
+	if err != nil {
+		return err
+	}
+	current := pm.CurrentVersion(id)
+	for i := len(list) - 1; i >= 0; i-- {
+		v := list[i]
+		timestamp := "—"
+		if !v.Timestamp.IsZero() {
+			timestamp = v.Timestamp.Format("2006-01-02 15:04")
+		}
+		line := fmt.Sprintf("v%-3d %s  %s  %s — %s", v.Version, timestamp, v.Author, v.Name, gpt.VersionChange(list, i))
+		if v.Note != "" {
+			line += " (" + v.Note + ")"
+		}
+		switch {
+		case v.Version == current:
+			printColored(line+"  ← текущая\n", colorGreen)
+		case v.Deleted:
+			printColored(line+"\n", colorRed)
+		default:
+			fmt.Println(line)
+		}
+	}
+	if current == 0 {
+		printColored(fmt.Sprintf("Промпт #%d удален; вернуть его можно командой lcg prompts rollback %d <версия>\n", id, id), colorYellow)
+	}
+	return nil
+}
+
+// diffPromptVersions выводит различия двух версий промпта. Без номеров
+// сравниваются две последние версии.
+func diffPromptVersions(id int, from, to string) error {
+	_, list, err := promptVersions(id)
+	if err != nil {
+		return err
+	}
+	if len(list) < 2 && from == "" && to == "" {
+		printColored(fmt.Sprintf("У промпта #%d одна версия\n", id), colorYellow)
+		return nil
+	}
+
+	a, b := len(list)-1, len(list)
+	if from != "" {
+		if a, err = parseVersion(from); err != nil {
+			return err
+		}
+	}
+	if to != "" {
+		if b, err = parseVersion(to); err != nil {
+			return err
+		}
+	}
+	// latest — последняя версия
+	if a == 0 {
+		a = len(list)
+	}
+	if b == 0 {
+		b = len(list)
+	}
+	for _, n := range []int{a, b} {
+		if n > len(list) {
+			return cli.Exit(fmt.Sprintf("У промпта #%d нет версии %d (всего версий: %d)", id, n, len(list)), exitValidation)
+		}
+	}
+
+	if diff := gpt.DiffPromptVersions(list[a-1], list[b-1]); diff != "" {
+		printDiff(diff)
+	} else {
+		printColored("Изменений нет.\n", colorYellow)
+	}
+	return nil
+}
+
+// rollbackPrompt возвращает промпту содержимое версии (без номера — предыдущей).
+// Откат записывается новой версией, так что его тоже можно отменить.
+func rollbackPrompt(id int, version string) error {
+	pm, list, err := promptVersions(id)
+	if err != nil {
+		return err
+	}
+	n := len(list) - 1
+	if version != "" {
+		if n, err = parseVersion(version); err != nil {
+			return err
+		}
+		if n == 0 {
+			n = len(list)
+		}
+	}
+	if n < 1 {
+		printColored(fmt.Sprintf("У промпта #%d одна версия, откатывать некуда\n", id), colorYellow)
+		return nil
+	}
+	if err := pm.RollbackPrompt(id, n); err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	printColored(fmt.Sprintf("✅ Промпт #%d: откат к версии %d (версия %d)\n", id, n, pm.CurrentVersion(id)), colorGreen)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/urfave/cli/v2"
+)
+
+// parsePromptIDs разбирает список ID промптов через запятую
+func parsePromptIDs(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, cli.Exit(fmt.Sprintf("Неверный ID промпта %q", part), exitValidation)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// exportPrompts выгружает промпты (все или с указанными ID) в yaml или json
+func exportPrompts(ids, format, output string) error {
+	if !slices.Contains(gpt.PromptFormats, format) {
+		return cli.Exit(fmt.Sprintf("Неизвестный формат %q (доступны: %s)", format, strings.Join(gpt.PromptFormats, ", ")), exitValidation)
+	}
+	selected, err := parsePromptIDs(ids)
+	if err != nil {
+		return err
+	}
+	currentUser, _ := user.Current()
+	pm := gpt.NewPromptManager(currentUser.HomeDir)
+	prompts, err := pm.SelectPrompts(selected)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Ошибка создания файла: %v", err), exitGeneral)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := gpt.ExportPrompts(out, prompts, format); err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка экспорта: %v", err), exitGeneral)
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "✅ Выгружено промптов: %d → %s\n", len(prompts), output)
+	}
+	return nil
+}
+
+// importPrompts загружает промпты из файла экспорта. Промпты с уже занятым
+// именем переименовываются, заменяют имеющиеся или пропускаются по стратегии;
+// с dryRun только показывается, что будет сделано.
+func importPrompts(path, strategy string, dryRun bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка чтения файла: %v", err), exitValidation)
+	}
+	incoming, err := gpt.ParsePrompts(data)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка разбора %s: %v", path, err), exitValidation)
+	}
+
+	currentUser, _ := user.Current()
+	pm := gpt.NewPromptManager(currentUser.HomeDir)
+	plan, err := pm.PlanImport(incoming, strategy)
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+
+	if dryRun {
+		printColored(fmt.Sprintf("🔍 Пробный импорт %s (стратегия %s), промпты не изменены\n", path, strategy), colorYellow)
+	}
+	for _, source := range incoming {
+		id, ok := plan.IDs[source.ID]
+		if !ok {
+			continue
+		}
+		for _, p := range plan.Add {
+			if p.ID == id {
+				fmt.Printf("  + #%d %s (в файле #%d)\n", p.ID, p.Name, source.ID)
+			}
+		}
+		for _, p := range plan.Update {
+			if p.ID == id {
+				fmt.Printf("  ~ #%d %s (в файле #%d)\n", p.ID, p.Name, source.ID)
+			}
+		}
+	}
+	if !dryRun && len(plan.Add)+len(plan.Update) > 0 {
+		if err := pm.ApplyImport(plan); err != nil {
+			return cli.Exit(fmt.Sprintf("Ошибка импорта: %v", err), exitGeneral)
+		}
+	}
+	printColored(fmt.Sprintf("✅ Промптов в файле: %d, добавлено: %d, обновлено: %d, пропущено: %d\n",
+		len(incoming), len(plan.Add), len(plan.Update), len(plan.Skip)), colorGreen)
+	return nil
+}
@@ -1,24 +1,217 @@
 package reader
 
 import (
-	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
+const (
+	// maxContextFiles ограничивает число файлов, раскрываемых из каталогов и шаблонов
+	maxContextFiles = 100
+	// binarySniffSize сколько байт из начала файла проверяется на "бинарность"
+	binarySniffSize = 8000
+	// CharsPerToken грубая оценка числа символов на один токен
+	CharsPerToken = 4
+)
+
+// FileReport описывает, что попало в запрос из одного файла
+type FileReport struct {
+	Path     string
+	Size     int64
+	Included int64  // сколько байт включено в запрос
+	Omitted  int64  // сколько байт вырезано при усечении
+	Skipped  string // причина пропуска файла целиком (пусто, если файл включен)
+}
+
+// FileToPrompt добавляет к запросу содержимое одного файла без ограничения объема
 func FileToPrompt(cmd *string, filePath string) error {
-	f, err := os.Open(filePath)
+	reports, err := FilesToPrompt(cmd, []string{filePath}, 0)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	reader := bufio.NewReader(f)
-	*cmd = *cmd + "\nFile path: " + filePath + "\n"
-	for {
-		line, err := reader.ReadString('\n')
+	if len(reports) == 1 && reports[0].Skipped != "" {
+		return fmt.Errorf("%s: %s", filePath, reports[0].Skipped)
+	}
+	return nil
+}
+
+// FilesToPrompt раскрывает пути (файлы, каталоги, glob-шаблоны) и добавляет содержимое
+// файлов к запросу, каждый в отдельном блоке с путем. budget ограничивает суммарный
+// объем добавляемого текста в символах (0 — без ограничений): бюджет делится между
+// файлами поровну, неиспользованный остаток переходит к следующим, длинные файлы
+// усекаются с сохранением начала и конца. Бинарные файлы пропускаются.
+func FilesToPrompt(cmd *string, patterns []string, budget int) ([]FileReport, error) {
+	paths, err := ExpandPaths(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]FileReport, 0, len(paths))
+	var candidates []*FileReport
+	for _, p := range paths {
+		report := FileReport{Path: p}
+		info, err := os.Stat(p)
 		if err != nil {
-			break
+			report.Skipped = err.Error()
+		} else {
+			report.Size = info.Size()
+			if binary, err := isBinaryFile(p); err != nil {
+				report.Skipped = err.Error()
+			} else if binary {
+				report.Skipped = "бинарный файл"
+			}
 		}
-		*cmd = *cmd + "\n" + line
+		reports = append(reports, report)
 	}
-	return nil
+	for i := range reports {
+		if reports[i].Skipped == "" {
+			candidates = append(candidates, &reports[i])
+		}
+	}
+
+	// Меньшие файлы получают бюджет первыми, чтобы остаток достался крупным
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Size < candidates[j].Size
+	})
+
+	blocks := make(map[string]string, len(candidates))
+	remaining := budget
+	for i, report := range candidates {
+		limit := 0
+		if budget > 0 {
+			share := remaining / (len(candidates) - i)
+			limit = share - len(fileBlock(report.Path, "")) - truncationMarkerReserve
+			if limit <= 0 {
+				report.Skipped = "не помещается в бюджет"
+				report.Omitted = report.Size
+				continue
+			}
+		}
+
+		data, omitted, err := readFileLimited(report.Path, limit)
+		if err != nil {
+			report.Skipped = err.Error()
+			continue
+		}
+		report.Omitted = omitted
+		report.Included = report.Size - omitted
+		block := fileBlock(report.Path, data)
+		blocks[report.Path] = block
+		remaining -= len(block)
+	}
+
+	// Блоки добавляем в исходном порядке путей
+	for _, report := range reports {
+		if block, ok := blocks[report.Path]; ok {
+			*cmd += block
+		}
+	}
+	return reports, nil
+}
+
+// ExpandPaths раскрывает glob-шаблоны и каталоги (рекурсивно, без скрытых) в список файлов
+func ExpandPaths(patterns []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("неверный шаблон %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			// Не шаблон или ничего не найдено — пусть ошибка проявится при чтении
+			matches = []string{pattern}
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || !info.IsDir() {
+				add(m)
+				continue
+			}
+			err = filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				if p != m && strings.HasPrefix(d.Name(), ".") {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.Type().IsRegular() {
+					add(p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(result) > maxContextFiles {
+			return nil, fmt.Errorf("слишком много файлов: больше %d", maxContextFiles)
+		}
+	}
+	return result, nil
+}
+
+// readFileLimited читает файл целиком (limit <= 0) или с усечением до limit байт
+func readFileLimited(path string, limit int) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	if limit <= 0 {
+		data, err := io.ReadAll(f)
+		return string(data), 0, err
+	}
+	return ReadHeadTail(f, limit)
+}
+
+// isBinaryFile проверяет начало файла на нулевые байты и некорректный UTF-8
+func isBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	sample := buf[:n]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true, nil
+	}
+	// Последний символ мог быть разрезан границей выборки
+	if n == binarySniffSize {
+		for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return !utf8.Valid(sample), nil
+}
+
+// fileBlock оборачивает содержимое файла в разделители с путем
+func fileBlock(path, data string) string {
+	return contextBlock("----- BEGIN FILE: "+path+" -----", "----- END FILE: "+path+" -----", data)
 }
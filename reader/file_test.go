@@ -0,0 +1,58 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilesToPrompt(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	noNewline := write("a.txt", []byte("first\nlast line without newline"))
+	binary := write("b.bin", []byte{0x7f, 'E', 'L', 'F', 0, 0, 1})
+	big := write("c.log", []byte(strings.Repeat("line of log output\n", 500)))
+
+	cmd := "query"
+	reports, err := FilesToPrompt(&cmd, []string{filepath.Join(dir, "*")}, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 reports, got %d", len(reports))
+	}
+
+	if !strings.Contains(cmd, "last line without newline") {
+		t.Error("last line without trailing newline was dropped")
+	}
+	if !strings.Contains(cmd, "----- BEGIN FILE: "+noNewline+" -----") {
+		t.Error("file block is not delimited with its path")
+	}
+	if strings.Contains(cmd, binary) {
+		t.Error("binary file must not be included")
+	}
+	if len(cmd) > len("query")+2000 {
+		t.Errorf("budget exceeded: %d characters", len(cmd))
+	}
+
+	for _, r := range reports {
+		switch r.Path {
+		case binary:
+			if r.Skipped == "" {
+				t.Error("binary file is not reported as skipped")
+			}
+		case big:
+			if r.Omitted == 0 || r.Included+r.Omitted != r.Size {
+				t.Errorf("unexpected truncation report for big file: %+v", r)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package reader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	stdinBlockBegin = "----- BEGIN STDIN -----"
+	stdinBlockEnd   = "----- END STDIN -----"
+	// truncationMarkerReserve запас под строку-маркер об усечении
+	truncationMarkerReserve = 64
+)
+
+// StdinOverhead возвращает количество символов, которое добавляет обрамление блока stdin
+func StdinOverhead() int {
+	return len(contextBlock(stdinBlockBegin, stdinBlockEnd, "")) + truncationMarkerReserve
+}
+
+// StdinToPrompt читает stdin (не более limit байт) и добавляет его к запросу
+// отдельным блоком контекста. При превышении лимита сохраняются начало и конец
+// потока. Возвращает количество пропущенных байт.
+func StdinToPrompt(cmd *string, limit int) (int64, error) {
+	data, omitted, err := ReadHeadTail(os.Stdin, limit)
+	if err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(data) == "" {
+		return 0, nil
+	}
+	*cmd = *cmd + contextBlock(stdinBlockBegin, stdinBlockEnd, data)
+	return omitted, nil
+}
+
+// ReadHeadTail читает поток целиком, но хранит не более limit байт:
+// если данных больше, возвращаются начало и конец с маркером пропуска посередине.
+func ReadHeadTail(r io.Reader, limit int) (string, int64, error) {
+	if limit <= 0 {
+		_, err := io.Copy(io.Discard, r)
+		return "", 0, err
+	}
+
+	var head, tail []byte
+	var total int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			total += int64(n)
+			if len(head) < limit {
+				take := min(limit-len(head), len(chunk))
+				head = append(head, chunk[:take]...)
+			}
+			tail = append(tail, chunk...)
+			if len(tail) > 2*limit {
+				tail = append(tail[:0], tail[len(tail)-limit:]...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	if total <= int64(limit) {
+		return string(head), 0, nil
+	}
+	if len(tail) > limit {
+		tail = tail[len(tail)-limit:]
+	}
+	text, omitted := cutHeadTail(head, tail, total, limit)
+	return text, omitted, nil
+}
+
+// cutHeadTail собирает начало и конец данных общей длиной не более limit,
+// стараясь резать по границам строк. Возвращает текст и число пропущенных байт.
+func cutHeadTail(head, tail []byte, total int64, limit int) (string, int64) {
+	headSize := limit / 2
+	tailSize := limit - headSize
+
+	h := head[:min(headSize, len(head))]
+	if i := bytes.LastIndexByte(h, '\n'); i >= len(h)/2 {
+		h = h[:i+1]
+	}
+	t := tail[max(0, len(tail)-tailSize):]
+	if i := bytes.IndexByte(t, '\n'); i >= 0 && i < len(t)/2 {
+		t = t[i+1:]
+	}
+
+	omitted := total - int64(len(h)) - int64(len(t))
+	h = bytes.ToValidUTF8(h, nil)
+	t = bytes.ToValidUTF8(t, nil)
+
+	var sb strings.Builder
+	sb.Write(h)
+	if len(h) > 0 && h[len(h)-1] != '\n' {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("[... пропущено %d байт ...]\n", omitted))
+	sb.Write(t)
+	return sb.String(), omitted
+}
+
+// contextBlock оборачивает данные в явные разделители, чтобы модель отличала их от вопроса
+func contextBlock(begin, end, data string) string {
+	data = strings.TrimRight(data, "\n")
+	return "\n\n" + begin + "\n" + data + "\n" + end + "\n"
+}
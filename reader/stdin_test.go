@@ -0,0 +1,74 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadHeadTail(t *testing.T) {
+	var lines strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&lines, "line%02d\n", i)
+	}
+	log := strings.Repeat("0123456789\n", 10000) // больше буфера чтения
+
+	tests := []struct {
+		name    string
+		input   string
+		limit   int
+		want    string
+		omitted int64
+	}{
+		{"short", "short", 100, "short", 0},
+		{"exactly limit", strings.Repeat("x", 10), 10, strings.Repeat("x", 10), 0},
+		{"one byte over limit", strings.Repeat("x", 11), 10, "xxxxx\n[... пропущено 1 байт ...]\nxxxxx", 1},
+		{"no newlines", strings.Repeat("x", 100), 10, "xxxxx\n[... пропущено 90 байт ...]\nxxxxx", 90},
+		{"cut by lines", lines.String(), 70,
+			"line00\nline01\nline02\nline03\nline04\n[... пропущено 637 байт ...]\nline96\nline97\nline98\nline99\n", 637},
+		{"zero limit", "data", 0, "", 0},
+	}
+	for _, test := range tests {
+		got, omitted, err := ReadHeadTail(strings.NewReader(test.input), test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want || omitted != test.omitted {
+			t.Errorf("%s: got %q, %d; want %q, %d", test.name, got, omitted, test.want, test.omitted)
+		}
+	}
+
+	got, omitted, err := ReadHeadTail(strings.NewReader(log), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "0123456789\n") || !strings.HasSuffix(got, "0123456789\n") {
+		t.Errorf("head or tail is not cut by lines: %q", got)
+	}
+	if kept := int64(len(log)) - omitted; kept > 1000 || !strings.Contains(got, fmt.Sprintf("[... пропущено %d байт ...]", omitted)) {
+		t.Errorf("kept %d bytes of %d, omitted %d", kept, len(log), omitted)
+	}
+}
+
+func TestCutHeadTail(t *testing.T) {
+	tests := []struct {
+		head, tail string
+		total      int64
+		limit      int
+		want       string
+		omitted    int64
+	}{
+		// Перевод строки во второй половине головы — режем по нему
+		{"ab\ncd\nef", "xx\nyy\nzz", 100, 8, "ab\n[... пропущено 95 байт ...]\nzz", 95},
+		// Перевод строки в первой половине хвоста — хвост начинается со следующей строки
+		{"abcdefgh", "yyyyyyz\nyy", 100, 8, "abcd\n[... пропущено 94 байт ...]\nyy", 94},
+		// Без переводов строк режем ровно по половинам лимита
+		{"abcdefgh", "stuvwxyz", 100, 7, "abc\n[... пропущено 93 байт ...]\nwxyz", 93},
+	}
+	for _, test := range tests {
+		got, omitted := cutHeadTail([]byte(test.head), []byte(test.tail), test.total, test.limit)
+		if got != test.want || omitted != test.omitted {
+			t.Errorf("cutHeadTail(%q, %q, %d): got %q, %d; want %q, %d", test.head, test.tail, test.limit, got, omitted, test.want, test.omitted)
+		}
+	}
+}
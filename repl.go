@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/validation"
+	"golang.org/x/term"
+)
+
+// replMaxTurns сколько последних пар вопрос/ответ передается модели как контекст
+const replMaxTurns = 10
+
+// replCommands slash-команды сессии (для справки и автодополнения по Tab)
+var replCommands = []struct{ name, usage string }{
+	{"/help", "список команд"},
+	{"/model", "[имя] — показать доступные модели или переключить модель"},
+	{"/provider", "[ollama|proxy] [host] — показать или переключить провайдера"},
+	{"/prompt", "[id] — показать промпты или выбрать системный промпт"},
+	{"/explain", "[v|vv|vvv] — объяснить последнюю команду"},
+	{"/save", "сохранить последний ответ в папку результатов"},
+	{"/exec", "выполнить последнюю команду"},
+	{"/copy", "скопировать последнюю команду в буфер обмена"},
+	{"/history", "показать историю запросов"},
+	{"/reset", "очистить контекст диалога"},
+	{"/quit", "выйти (также /exit, Ctrl+D)"},
+}
+
+// replSession состояние интерактивной сессии
+type replSession struct {
+	gpt3        gpt.Gpt3
+	system      string
+	promptID    int // ID промпта system для истории; 0 — свой текст
+	timeout     int
+	context     []gpt.Chat // предыдущие реплики user/assistant
+	lastAsk     string
+	lastCommand string
+	explanation string
+}
+
+// replReader читает строки ввода: в терминале — с редактированием и историей
+// (стрелки вверх/вниз), иначе — построчно из stdin
+type replReader struct {
+	terminal *term.Terminal
+	fd       int
+	plain    *bufio.Reader
+}
+
+func newReplReader() *replReader {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// Без терминала вопросы подтверждения читали бы тот же поток — отключаем их
+		cmdPackage.SetInteractive(false)
+		return &replReader{plain: bufio.NewReader(os.Stdin)}
+	}
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "lcg> ")
+	terminal.AutoCompleteCallback = completeReplCommand
+	return &replReader{terminal: terminal, fd: fd}
+}
+
+// ReadLine читает очередную строку; io.EOF означает конец ввода (Ctrl+D)
+func (r *replReader) ReadLine() (string, error) {
+	if r.terminal == nil {
+		fmt.Print("lcg> ")
+		line, err := r.plain.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return line, err
+	}
+	// Raw-режим только на время ввода, чтобы остальной вывод работал как обычно
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(r.fd, state)
+	return r.terminal.ReadLine()
+}
+
+// completeReplCommand дополняет slash-команду по Tab
+func completeReplCommand(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || !strings.HasPrefix(line, "/") || strings.Contains(line, " ") {
+		return "", 0, false
+	}
+	var match string
+	for _, c := range replCommands {
+		if strings.HasPrefix(c.name, line) {
+			if match != "" {
+				return "", 0, false
+			}
+			match = c.name
+		}
+	}
+	if match == "" {
+		return "", 0, false
+	}
+	return match + " ", len(match) + 1, true
+}
+
+// runREPL запускает интерактивную сессию: вопросы с сохранением контекста диалога,
+// slash-команды и переключение провайдера и модели на лету
+func runREPL(timeout int) error {
+	system, err := gpt.RenderConfiguredPrompt(config.AppConfig.Prompt)
+	if err == nil {
+		err = validation.ValidateSystemPrompt(system)
+	}
+	if err != nil {
+		return err
+	}
+	if config.AppConfig.MainFlags.Debug {
+		printDebugInfo(nil, system, "", timeout)
+	}
+	s := &replSession{system: system, timeout: timeout}
+	if resolve := promptResolver(); resolve != nil {
+		s.promptID = resolve(config.AppConfig.Prompt)
+	}
+	s.reinit()
+	reader := newReplReader()
+
+	printColored(fmt.Sprintf("lcg repl — провайдер %s, модель %s. /help — команды, /quit — выход\n", config.AppConfig.ProviderType, s.gpt3.Model), colorCyan)
+	printColored("ВНИМАНИЕ: ОТВЕТЫ ФОРМИРУЕТ ИИ. ТРЕБУЕТСЯ ПРОВЕРКА И КРИТИЧЕСКИЙ АНАЛИЗ. ВОЗМОЖНЫ ОШИБКИ И ГАЛЛЮЦИНАЦИИ.\n", colorRed)
+
+	for {
+		line, err := reader.ReadLine()
+		if err == io.EOF {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "/"):
+			if s.command(line) {
+				return nil
+			}
+		default:
+			s.ask(line)
+		}
+	}
+}
+
+// reinit пересоздает клиента после смены провайдера, модели или промпта
+func (s *replSession) reinit() {
+	s.gpt3 = initGPT(s.system, s.timeout)
+	s.gpt3.InitKey()
+}
+
+// ask отправляет вопрос вместе с контекстом диалога и сохраняет ответ в историю
+func (s *replSession) ask(question string) {
+	if err := validation.ValidateUserMessage(question); err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка: %s\n", err.Error()), colorRed)
+		return
+	}
+	response, elapsed, err := withSpinner(func() (string, error) {
+		return s.gpt3.CompleteChat(s.context, question)
+	})
+	if err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка провайдера: %v\n", err), colorRed)
+		return
+	}
+	if strings.TrimSpace(response) == "" {
+		printColored("❌ Ответ не получен.\n", colorRed)
+		return
+	}
+
+	s.context = append(s.context, gpt.Chat{Role: "user", Content: question}, gpt.Chat{Role: "assistant", Content: response})
+	if len(s.context) > 2*replMaxTurns {
+		s.context = s.context[len(s.context)-2*replMaxTurns:]
+	}
+	s.lastAsk, s.lastCommand, s.explanation = question, response, ""
+
+	printColored(fmt.Sprintf("✅ %.2f сек\n", elapsed), colorGreen)
+	printColored(fmt.Sprintf("   %s\n", response), colorBold+colorGreen)
+	cmdPackage.NewRequest(s.promptID)
+	cmdPackage.LastRequest.Elapsed = elapsed
+	if !disableHistory {
+		cmdPackage.SaveToHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, question, response, s.gpt3.Prompt)
+	}
+}
+
+// command выполняет slash-команду; возвращает true, если сессию нужно завершить
+func (s *replSession) command(line string) bool {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "/quit", "/exit", "/q":
+		return true
+	case "/help", "/?":
+		for _, c := range replCommands {
+			fmt.Printf("  %-10s %s\n", c.name, c.usage)
+		}
+	case "/model":
+		s.switchModel(args)
+	case "/provider":
+		s.switchProvider(args)
+	case "/prompt":
+		s.switchPrompt(args)
+	case "/explain":
+		s.explain(args)
+	case "/save":
+		if s.requireCommand() {
+			if err := os.MkdirAll(config.AppConfig.ResultFolder, 0755); err != nil {
+				printColored(fmt.Sprintf("❌ Ошибка создания папки результатов: %v\n", err), colorRed)
+				break
+			}
+			// Ответ уже в истории; объяснение добавляется к записи перед сохранением файла
+			historyID := 0
+			if !disableHistory {
+				if s.explanation != "" {
+					historyID, _ = cmdPackage.SaveToHistoryFromHistory(config.AppConfig.ResultHistory, config.AppConfig.ResultFolder, s.lastAsk, s.lastCommand, s.gpt3.Prompt, s.explanation)
+				} else {
+					historyID = cmdPackage.FindHistoryID(config.AppConfig.ResultHistory, s.lastAsk)
+				}
+			}
+			saveResponse(s.lastCommand, s.gpt3.Model, s.gpt3.Prompt, s.lastAsk, s.explanation, historyID)
+		}
+	case "/exec":
+		if s.requireCommand() {
+			if config.AppConfig.AllowExecution {
+				executeCommand(s.lastCommand)
+			} else {
+				fmt.Println("⚠️  Выполнение команд отключено. Установите LCG_ALLOW_EXECUTION=1 для включения этой функции.")
+			}
+		}
+	case "/copy":
+		if s.requireCommand() {
+			clipboard.WriteAll(s.lastCommand)
+			fmt.Println("✅ Команда скопирована в буфер обмена")
+		}
+	case "/history":
+		if disableHistory {
+			printColored("📝 История отключена (--no-history / LCG_NO_HISTORY)\n", colorYellow)
+		} else {
+			cmdPackage.ShowHistory(config.AppConfig.ResultHistory, history.Filter{}, printColored, colorYellow)
+		}
+	case "/reset":
+		s.context = nil
+		fmt.Println("🧹 Контекст диалога очищен")
+	default:
+		printColored(fmt.Sprintf("Неизвестная команда %s. /help — список команд\n", name), colorYellow)
+	}
+	return false
+}
+
+// requireCommand проверяет, что в сессии уже есть ответ модели
+func (s *replSession) requireCommand() bool {
+	if s.lastCommand == "" {
+		printColored("Сначала задайте вопрос.\n", colorYellow)
+		return false
+	}
+	return true
+}
+
+func (s *replSession) switchModel(args []string) {
+	if len(args) == 0 {
+		fmt.Printf("Текущая модель: %s\n", s.gpt3.Model)
+		models, err := s.gpt3.GetAvailableModels()
+		if err != nil {
+			printColored(fmt.Sprintf("Ошибка получения моделей: %v\n", err), colorRed)
+			return
+		}
+		for i, model := range models {
+			fmt.Printf("  %d. %s\n", i+1, model)
+		}
+		return
+	}
+	config.AppConfig.Model = args[0]
+	s.reinit()
+	printColored(fmt.Sprintf("🧠 Модель: %s\n", s.gpt3.Model), colorGreen)
+}
+
+func (s *replSession) switchProvider(args []string) {
+	if len(args) == 0 {
+		fmt.Printf("Провайдер: %s, хост: %s\n", config.AppConfig.ProviderType, config.AppConfig.Host)
+		return
+	}
+	if args[0] != "ollama" && args[0] != "proxy" {
+		printColored("Поддерживаются провайдеры ollama и proxy\n", colorYellow)
+		return
+	}
+	config.AppConfig.ProviderType = args[0]
+	if len(args) > 1 {
+		config.AppConfig.Host = args[1]
+	}
+	s.reinit()
+	printColored(fmt.Sprintf("🌐 Провайдер: %s, хост: %s\n", config.AppConfig.ProviderType, config.AppConfig.Host), colorGreen)
+}
+
+func (s *replSession) switchPrompt(args []string) {
+	currentUser, _ := user.Current()
+	pm := gpt.NewPromptManager(currentUser.HomeDir)
+	if len(args) == 0 {
+		for _, p := range pm.Prompts {
+			fmt.Printf("  %d. %s — %s\n", p.ID, p.Name, p.Description)
+		}
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		printColored("Неверный ID промпта\n", colorYellow)
+		return
+	}
+	prompt, err := pm.GetPromptByID(id)
+	if err != nil {
+		printColored(fmt.Sprintf("Prompt ID %d not found\n", id), colorYellow)
+		return
+	}
+	system, err := gpt.RenderConfiguredPrompt(prompt.Content)
+	if err == nil {
+		err = validation.ValidateSystemPrompt(system)
+	}
+	if err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка: %s\n", err.Error()), colorRed)
+		return
+	}
+	s.system, s.promptID = system, prompt.ID
+	s.reinit()
+	printColored(fmt.Sprintf("📝 Промпт %d: %s\n", prompt.ID, prompt.Name), colorGreen)
+}
+
+func (s *replSession) explain(args []string) {
+	if !s.requireCommand() {
+		return
+	}
+	level := 1
+	if len(args) > 0 {
+		switch args[0] {
+		case "v", "vv", "vvv":
+			level = len(args[0])
+		default:
+			printColored("Уровень объяснения: v, vv или vvv\n", colorYellow)
+			return
+		}
+	}
+	explanation, elapsed, err := withSpinner(func() (string, error) {
+		return cmdPackage.Explain(s.lastCommand, s.gpt3, s.lastAsk, s.timeout, level)
+	})
+	if err != nil {
+		printColored(fmt.Sprintf("❌ Ошибка провайдера: %v\n", err), colorRed)
+		return
+	}
+	s.explanation = explanation
+	cmdPackage.LastRequest.Verbosity = strings.Repeat("v", level)
+	printColored(fmt.Sprintf("✅ %.2f сек\n", elapsed), colorGreen)
+	printColored("\n📖 Подробное объяснение и альтернативы:\n\n", colorYellow)
+	fmt.Println(explanation)
+}
@@ -2,58 +2,31 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"path"
 	"strings"
-	"time"
 
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
 )
 
-func nowTimestamp() string {
-	return time.Now().Format("2006-01-02_15-04-05")
-}
-
-func pathJoin(base, name string) string {
-	return path.Join(base, name)
-}
-
-func writeFile(filePath, content string) {
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		fmt.Println("Failed to save response:", err)
-	} else {
-		fmt.Printf("Saved to %s\n", filePath)
-	}
-}
-
-func saveResponse(response string, gpt3Model string, prompt string, cmd string, explanation ...string) {
-	timestamp := nowTimestamp()
-	filename := fmt.Sprintf("gpt_request_%s_%s.md", gpt3Model, timestamp)
-	filePath := pathJoin(config.AppConfig.ResultFolder, filename)
-	title := truncateTitle(cmd)
-
-	var content string
-	if len(explanation) > 0 && strings.TrimSpace(explanation[0]) != "" {
-		// Если есть объяснение, сохраняем полную структуру
-		content = fmt.Sprintf("# %s\n\n## Prompt\n\n%s\n\n## Response\n\n%s\n\n## Explanation\n\n%s\n",
-			title, cmd+". "+prompt, response, explanation[0])
-	} else {
-		// Если объяснения нет, сохраняем базовую структуру
-		content = fmt.Sprintf("# %s\n\n## Prompt\n\n%s\n\n## Response\n\n%s\n",
-			title, cmd+". "+prompt, response)
+// saveResponse сохраняет ответ в папку результатов и возвращает путь к файлу ("" при ошибке).
+// historyID — номер связанной записи истории (0 — без связи).
+func saveResponse(response string, gpt3Model string, prompt string, cmd string, explanation string, historyID int) string {
+	sections := []results.Section{
+		{Heading: "Prompt", Body: cmd + ". " + prompt},
+		{Heading: "Response", Body: response},
 	}
-	writeFile(filePath, content)
-}
-
-func truncateTitle(s string) string {
-	const maxLen = 120
-	if runeCount := len([]rune(s)); runeCount <= maxLen {
-		return s
+	if strings.TrimSpace(explanation) != "" {
+		sections = append(sections, results.Section{Heading: "Explanation", Body: explanation})
 	}
-	const head = 116
-	r := []rune(s)
-	if len(r) <= head {
-		return s
+	filePath, err := results.Write(config.AppConfig.ResultFolder, results.Result{
+		Meta:     cmdPackage.NewResultMeta(results.KindRequest, cmd, gpt3Model, historyID),
+		Sections: sections,
+	})
+	if err != nil {
+		fmt.Println("Failed to save response:", err)
+		return ""
 	}
-	return string(r[:head]) + " ..."
+	fmt.Printf("Saved to %s\n", filePath)
+	return filePath
 }
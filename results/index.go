@@ -0,0 +1,520 @@
+package results
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+	bolt "go.etcd.io/bbolt"
+)
+
+// IndexFile индекс результатов в папке результатов (база bbolt). Это кеш:
+// индекс обновляется при сохранении через Write, а файлы, добавленные, измененные
+// или удаленные иначе (lcg gc, lcg sync, вручную), учитываются при пересканировании
+// папки. Удаленный индекс строится заново.
+const IndexFile = ".lcg_results.db"
+
+// RescanInterval как часто поиск пересканирует папку, даже если список файлов
+// в ней не менялся (файл мог быть перезаписан на месте)
+var RescanInterval = 30 * time.Second
+
+// boltTimeout сколько ждать, пока индекс освободит другой процесс
+const boltTimeout = 10 * time.Second
+
+// maxTerms ограничение числа слов одного файла в полнотекстовом индексе
+const maxTerms = 5000
+
+// Бакеты индекса
+var (
+	bucketFiles = []byte("files") // имя -> сведения о файле (JSON, при шифровании зашифрован)
+	bucketStat  = []byte("stat")  // имя -> размер и время изменения
+	bucketOrder = []byte("order") // время создания (нс) + имя -> вид + 0 + модель
+	bucketTerms = []byte("terms") // слово + 0 + имя; при шифровании вместо слова HMAC
+	bucketWords = []byte("words") // имя -> слова файла (для удаления из индекса)
+	bucketMeta  = []byte("meta")
+	keyCipher   = []byte("cipher")  // отпечаток ключа, которым построен индекс
+	keyScanned  = []byte("scanned") // время последнего сканирования и папки
+)
+
+// File результат в папке
+type File struct {
+	Meta
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Legacy  bool      `json:"legacy,omitempty"` // без front-matter: сведения восстановлены по имени и заголовку
+	Preview string    `json:"preview,omitempty"`
+}
+
+// Query условия поиска результатов; пустые поля не ограничивают выборку
+type Query struct {
+	Text   string // слова (все должны встретиться в файле) или 'фраза' в одинарных кавычках
+	Model  string
+	Kind   string
+	From   time.Time // не раньше (включительно)
+	To     time.Time // раньше (не включительно)
+	Offset int
+	Limit  int // 0 — без ограничения
+}
+
+// Page страница результатов поиска, новые сверху
+type Page struct {
+	Files []File
+	Total int // всего подходящих файлов
+}
+
+// List возвращает все результаты папки, новые сверху
+func List(folder string) ([]File, error) {
+	page, err := Search(folder, Query{})
+	return page.Files, err
+}
+
+// Search ищет результаты по индексу. Слова запроса ищутся по началу слов файла
+// (при шифровании — целиком, так как индекс хранит только HMAC слов).
+func Search(folder string, q Query) (Page, error) {
+	var page Page
+	if _, err := os.Stat(folder); err != nil {
+		return page, err
+	}
+	if err := refresh(folder, false); err != nil {
+		return page, err
+	}
+	phrase := ""
+	if text := strings.TrimSpace(q.Text); len(text) > 1 && strings.HasPrefix(text, "'") && strings.HasSuffix(text, "'") {
+		phrase = strings.ToLower(strings.Trim(text, "'"))
+	}
+
+	var names []string
+	err := view(folder, func(tx *bolt.Tx) error {
+		var candidates map[string]bool
+		if terms := Terms(q.Text); len(terms) > 0 {
+			candidates = lookup(tx, terms)
+		}
+		c := tx.Bucket(bucketOrder).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			created := time.Unix(0, int64(binary.BigEndian.Uint64(k[:8])))
+			name := string(k[8:])
+			kind, model, _ := strings.Cut(string(v), "\x00")
+			switch {
+			case candidates != nil && !candidates[name],
+				q.Model != "" && model != q.Model,
+				q.Kind != "" && kind != q.Kind,
+				!q.From.IsZero() && created.Before(q.From),
+				!q.To.IsZero() && !created.Before(q.To):
+				continue
+			}
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return page, err
+	}
+	if phrase != "" {
+		// Фраза проверяется по тексту файлов, отобранных по ее словам
+		var matched []string
+		for _, name := range names {
+			if _, body, err := Read(folder, name); err == nil && strings.Contains(strings.ToLower(string(body)), phrase) {
+				matched = append(matched, name)
+			}
+		}
+		names = matched
+	}
+
+	page.Total = len(names)
+	if q.Offset > 0 {
+		names = names[min(q.Offset, len(names)):]
+	}
+	if q.Limit > 0 && len(names) > q.Limit {
+		names = names[:q.Limit]
+	}
+	err = view(folder, func(tx *bolt.Tx) error {
+		for _, name := range names {
+			file, err := getFile(tx, name)
+			if err != nil {
+				return err
+			}
+			page.Files = append(page.Files, file)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// Models модели, для которых есть результаты, по алфавиту
+func Models(folder string) ([]string, error) {
+	if err := refresh(folder, false); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var models []string
+	err := view(folder, func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketOrder).ForEach(func(_, v []byte) error {
+			if _, model, _ := strings.Cut(string(v), "\x00"); model != "" && !seen[model] {
+				seen[model] = true
+				models = append(models, model)
+			}
+			return nil
+		})
+	})
+	sort.Strings(models)
+	return models, err
+}
+
+// Rescan сверяет индекс с папкой: индексирует новые и измененные файлы и
+// убирает удаленные
+func Rescan(folder string) error {
+	return refresh(folder, true)
+}
+
+// Read читает результат: сведения и markdown без front-matter
+func Read(folder, name string) (File, []byte, error) {
+	path := filepath.Join(folder, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, nil, err
+	}
+	content, err := storage.ReadFile(path)
+	if err != nil {
+		return File{}, nil, err
+	}
+	file, body := describe(name, info, content)
+	return file, body, nil
+}
+
+// Terms слова текста для полнотекстового индекса: в нижнем регистре, без повторов
+func Terms(text string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// indexWritten добавляет в индекс только что записанный файл
+func indexWritten(folder, name string) error {
+	return update(folder, func(tx *bolt.Tx) error {
+		info, err := os.Stat(filepath.Join(folder, name))
+		if err != nil {
+			return err
+		}
+		return indexFile(tx, folder, name, info)
+	})
+}
+
+// refresh пересканирует папку, если изменился список файлов в ней или с прошлого
+// сканирования прошло больше RescanInterval; force — сканировать в любом случае
+func refresh(folder string, force bool) error {
+	dir, err := os.Stat(folder)
+	if err != nil {
+		return err
+	}
+	if !force {
+		fresh := false
+		err := view(folder, func(tx *bolt.Tx) error {
+			scanned := tx.Bucket(bucketMeta).Get(keyScanned)
+			fresh = len(scanned) == 16 && indexed(tx) &&
+				time.Since(time.Unix(0, int64(binary.BigEndian.Uint64(scanned[:8])))) < RescanInterval &&
+				int64(binary.BigEndian.Uint64(scanned[8:])) == dir.ModTime().UnixNano()
+			return nil
+		})
+		if err != nil || fresh {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	return update(folder, func(tx *bolt.Tx) error {
+		seen := map[string]bool{}
+		stats := tx.Bucket(bucketStat)
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Type().IsRegular() || !strings.HasSuffix(name, ".md") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			seen[name] = true
+			if bytes.Equal(stats.Get([]byte(name)), statValue(info)) {
+				continue
+			}
+			if err := indexFile(tx, folder, name, info); err != nil {
+				return err
+			}
+		}
+		var removed []string
+		stats.ForEach(func(k, _ []byte) error {
+			if !seen[string(k)] {
+				removed = append(removed, string(k))
+			}
+			return nil
+		})
+		for _, name := range removed {
+			if err := removeFile(tx, name); err != nil {
+				return err
+			}
+		}
+		scanned := make([]byte, 16)
+		binary.BigEndian.PutUint64(scanned[:8], uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(scanned[8:], uint64(dir.ModTime().UnixNano()))
+		return tx.Bucket(bucketMeta).Put(keyScanned, scanned)
+	})
+}
+
+// indexFile читает файл и записывает его сведения и слова в индекс
+func indexFile(tx *bolt.Tx, folder, name string, info os.FileInfo) error {
+	if err := removeFile(tx, name); err != nil {
+		return err
+	}
+	content, err := storage.ReadFile(filepath.Join(folder, name))
+	if err != nil {
+		// Нечитаемый файл (например, зашифрован другим ключом) не попадает в
+		// индекс и перечитывается при следующем сканировании
+		return nil
+	}
+	file, body := describe(name, info, content)
+	file.Preview = preview(body)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if data, err = storage.Encode(data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketFiles).Put([]byte(name), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketStat).Put([]byte(name), statValue(info)); err != nil {
+		return err
+	}
+	order := append(timeKey(file.Created), name...)
+	if err := tx.Bucket(bucketOrder).Put(order, []byte(file.Kind+"\x00"+file.Model)); err != nil {
+		return err
+	}
+
+	terms := Terms(strings.Join(append([]string{file.Query, file.Model, string(body)}, file.Tags...), " "))
+	words, err := json.Marshal(terms)
+	if err != nil {
+		return err
+	}
+	if words, err = storage.Encode(words); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketWords).Put([]byte(name), words); err != nil {
+		return err
+	}
+	for _, term := range terms {
+		if err := tx.Bucket(bucketTerms).Put(termKey(term, name), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFile удаляет файл из индекса вместе с его словами
+func removeFile(tx *bolt.Tx, name string) error {
+	if data := tx.Bucket(bucketWords).Get([]byte(name)); data != nil {
+		var terms []string
+		if plain, err := storage.Decode(data); err == nil && json.Unmarshal(plain, &terms) == nil {
+			for _, term := range terms {
+				if err := tx.Bucket(bucketTerms).Delete(termKey(term, name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if file, err := getFile(tx, name); err == nil {
+		if err := tx.Bucket(bucketOrder).Delete(append(timeKey(file.Created), name...)); err != nil {
+			return err
+		}
+	}
+	for _, bucket := range [][]byte{bucketFiles, bucketStat, bucketWords} {
+		if err := tx.Bucket(bucket).Delete([]byte(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup имена файлов, в которых есть все слова
+func lookup(tx *bolt.Tx, terms []string) map[string]bool {
+	var result map[string]bool
+	c := tx.Bucket(bucketTerms).Cursor()
+	for _, term := range terms {
+		// Без шифрования слово ищется по началу, иначе — целиком
+		prefix := []byte(storage.IndexValue(term))
+		if storage.Enabled() {
+			prefix = append(prefix, 0)
+		}
+		found := map[string]bool{}
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if i := bytes.IndexByte(k, 0); i >= 0 && (result == nil || result[string(k[i+1:])]) {
+				found[string(k[i+1:])] = true
+			}
+		}
+		result = found
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+func getFile(tx *bolt.Tx, name string) (File, error) {
+	var file File
+	data := tx.Bucket(bucketFiles).Get([]byte(name))
+	if data == nil {
+		return file, os.ErrNotExist
+	}
+	data, err := storage.Decode(data)
+	if err != nil {
+		return file, err
+	}
+	return file, json.Unmarshal(data, &file)
+}
+
+func termKey(term, name string) []byte {
+	return append(append([]byte(storage.IndexValue(term)), 0), name...)
+}
+
+// statValue размер и время изменения файла: по ним видно, что файл изменился
+func statValue(info os.FileInfo) []byte {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v[:8], uint64(info.Size()))
+	binary.BigEndian.PutUint64(v[8:], uint64(info.ModTime().UnixNano()))
+	return v
+}
+
+// timeKey время в наносекундах; моменты до 1970 года сводятся к нулю
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return key
+}
+
+// indexed проверяет, что индекс построен текущим ключом шифрования
+func indexed(tx *bolt.Tx) bool {
+	return string(tx.Bucket(bucketMeta).Get(keyCipher)) == storage.Fingerprint()
+}
+
+// view читает индекс; отсутствующий индекс — пустой
+func view(folder string, fn func(tx *bolt.Tx) error) error {
+	path := filepath.Join(folder, IndexFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: boltTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketMeta) == nil {
+			return nil
+		}
+		return fn(tx)
+	})
+}
+
+// update изменяет индекс, создавая его при необходимости. Индекс, построенный
+// другим ключом шифрования, очищается и строится заново при сканировании.
+func update(folder string, fn func(tx *bolt.Tx) error) error {
+	path := filepath.Join(folder, IndexFile)
+	db, err := bolt.Open(path, storage.FileMode(0644), &bolt.Options{Timeout: boltTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := storage.Protect(path); err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(bucketMeta); meta != nil && !indexed(tx) {
+			for _, name := range [][]byte{bucketFiles, bucketStat, bucketOrder, bucketTerms, bucketWords, bucketMeta} {
+				if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+					return err
+				}
+			}
+		}
+		for _, name := range [][]byte{bucketFiles, bucketStat, bucketOrder, bucketTerms, bucketWords, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if fp := storage.Fingerprint(); fp != "" {
+			if err := tx.Bucket(bucketMeta).Put(keyCipher, []byte(fp)); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// describe разбирает содержимое файла; у файлов старого формата сведения
+// восстанавливаются по имени (вид, модель, время) и заголовку (запрос)
+func describe(name string, info os.FileInfo, content []byte) (File, []byte) {
+	meta, body, ok := Parse(content)
+	file := File{Meta: meta, Name: name, Size: info.Size(), ModTime: info.ModTime(), Legacy: !ok}
+	if !ok {
+		file.Meta = legacyMeta(name, body, info.ModTime())
+	}
+	return file, body
+}
+
+// legacyMeta сведения о файле без front-matter:
+// gpt_request_<модель>_2025-10-22_13-50-13.md с заголовком "# <запрос>"
+func legacyMeta(name string, body []byte, modTime time.Time) Meta {
+	meta := Meta{Kind: KindRequest, Created: modTime}
+	rest := strings.TrimSuffix(name, ".md")
+	for kind, prefix := range Prefixes {
+		if strings.HasPrefix(rest, prefix) {
+			meta.Kind, rest = kind, strings.TrimPrefix(rest, prefix)
+			break
+		}
+	}
+	parts := strings.Split(rest, "_")
+	if n := len(parts); n >= 3 {
+		if created, err := time.ParseInLocation("2006-01-02_15-04-05", parts[n-2]+"_"+parts[n-1], time.Local); err == nil {
+			meta.Created = created
+			meta.Model = strings.Join(parts[:n-2], "_")
+		}
+	}
+	if title, _, _ := strings.Cut(string(body), "\n"); strings.HasPrefix(title, "# ") {
+		meta.Query = strings.TrimSpace(strings.TrimPrefix(title, "# "))
+	}
+	return meta
+}
+
+// preview начало текста файла одной строкой (до 200 символов)
+func preview(body []byte) string {
+	text := strings.Join(strings.Fields(string(body)), " ")
+	if r := []rune(text); len(r) > 200 {
+		return string(r[:200]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,188 @@
+// Package results сохраняет ответы, объяснения и скрипты в папку результатов.
+//
+// Каждый файл начинается с YAML front-matter со сведениями о запросе (id,
+// запрос, модель, провайдер, промпт, время, теги, номер записи истории), за
+// которым следует markdown. Сведения всех файлов кешируются в индексе рядом с
+// файлами (IndexFile) вместе с полнотекстовым индексом, чтобы список и поиск
+// результатов работали без чтения каждого файла.
+package results
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Виды результатов
+const (
+	KindRequest     = "request"     // ответ на запрос
+	KindExplanation = "explanation" // подробное объяснение команды
+	KindScript      = "script"      // сгенерированный скрипт
+)
+
+// Prefixes префиксы имен файлов по видам результатов
+var Prefixes = map[string]string{
+	KindRequest:     "gpt_request_",
+	KindExplanation: "gpt_explanation_",
+	KindScript:      "gpt_script_",
+}
+
+// Meta сведения о результате из front-matter
+type Meta struct {
+	ID        string    `yaml:"id" json:"id"`
+	Kind      string    `yaml:"kind" json:"kind"`
+	Query     string    `yaml:"query" json:"query"`
+	Model     string    `yaml:"model,omitempty" json:"model,omitempty"`
+	Provider  string    `yaml:"provider,omitempty" json:"provider,omitempty"`
+	PromptID  int       `yaml:"prompt_id,omitempty" json:"prompt_id,omitempty"`
+	Created   time.Time `yaml:"created" json:"created"`
+	Tags      []string  `yaml:"tags,omitempty" json:"tags,omitempty"`
+	HistoryID int       `yaml:"history_id,omitempty" json:"history_id,omitempty"` // связанная запись истории
+}
+
+// Section раздел markdown: заголовок второго уровня и текст
+type Section struct {
+	Heading string
+	Body    string
+}
+
+// Result результат для сохранения
+type Result struct {
+	Meta
+	Sections []Section
+}
+
+// Write сохраняет результат в папку и возвращает путь к файлу. Пустые ID и
+// Created заполняются. Имя файла строится из вида, модели и времени; если
+// такой файл уже есть (два сохранения в одну секунду), добавляется номер.
+func Write(folder string, r Result) (string, error) {
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+	r.Created = r.Created.Truncate(time.Second)
+	if r.ID == "" {
+		r.ID = newID()
+	}
+	if r.Kind == "" {
+		r.Kind = KindRequest
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
+	}
+
+	content, err := Render(r)
+	if err != nil {
+		return "", err
+	}
+	path, err := reserve(folder, fileBase(r.Meta))
+	if err != nil {
+		return "", err
+	}
+	if err := storage.WriteFile(path, content, 0644); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	// Индекс — только кеш: ошибка его записи не мешает сохранению результата
+	_ = indexWritten(folder, filepath.Base(path))
+	return path, nil
+}
+
+// Render собирает содержимое файла: front-matter, заголовок и разделы
+func Render(r Result) ([]byte, error) {
+	front, err := yaml.Marshal(r.Meta)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(front)
+	buf.WriteString("---\n\n")
+	fmt.Fprintf(&buf, "# %s\n", Title(r.Query))
+	for _, s := range r.Sections {
+		fmt.Fprintf(&buf, "\n## %s\n\n%s\n", s.Heading, strings.TrimRight(s.Body, "\n"))
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse отделяет front-matter от markdown. Для файлов без front-matter (старый
+// формат) ok = false, а body — все содержимое.
+func Parse(content []byte) (meta Meta, body []byte, ok bool) {
+	rest, found := bytes.CutPrefix(content, []byte("---\n"))
+	if !found {
+		return meta, content, false
+	}
+	front, body, found := bytes.Cut(rest, []byte("\n---\n"))
+	if !found {
+		return meta, content, false
+	}
+	if err := yaml.Unmarshal(front, &meta); err != nil {
+		return Meta{}, content, false
+	}
+	return meta, bytes.TrimLeft(body, "\n"), true
+}
+
+// Title заголовок результата: запрос, сокращенный до 120 символов (по рунам)
+func Title(s string) string {
+	const maxLen, head = 120, 116
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxLen {
+		return string(r[:head]) + " ..."
+	}
+	return s
+}
+
+// fileBase имя файла без номера и расширения: gpt_<вид>_<модель>_<время>
+func fileBase(m Meta) string {
+	prefix, ok := Prefixes[m.Kind]
+	if !ok {
+		prefix = Prefixes[KindRequest]
+	}
+	model := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, m.Model)
+	if model == "" {
+		model = "unknown"
+	}
+	return prefix + model + "_" + m.Created.Format("2006-01-02_15-04-05")
+}
+
+// reserve создает пустой файл с незанятым именем: base.md, base_2.md, ...
+// O_EXCL не дает двум одновременным сохранениям получить одно имя.
+func reserve(folder, base string) (string, error) {
+	for n := 1; ; n++ {
+		name := base + ".md"
+		if n > 1 {
+			name = fmt.Sprintf("%s_%d.md", base, n)
+		}
+		path := filepath.Join(folder, name)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, storage.FileMode(0644))
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return path, file.Close()
+	}
+}
+
+// newID случайный идентификатор результата
+func newID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
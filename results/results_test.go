@@ -0,0 +1,116 @@
+package results
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAndList(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2025, 10, 22, 13, 50, 13, 0, time.Local)
+	r := Result{
+		Meta:     Meta{Query: "list files\nrecursively", Model: "hf.co/yandex/model:Q4", Provider: "ollama", PromptID: 2, Created: created, Tags: []string{"fs"}, HistoryID: 7},
+		Sections: []Section{{Heading: "Response", Body: "ls -R\n"}},
+	}
+	first, err := Write(dir, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Write(dir, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(first) != "gpt_request_hf.co-yandex-model-Q4_2025-10-22_13-50-13.md" {
+		t.Errorf("unexpected file name %s", first)
+	}
+	if filepath.Base(second) != "gpt_request_hf.co-yandex-model-Q4_2025-10-22_13-50-13_2.md" {
+		t.Errorf("saves in the same second must not collide: %s", second)
+	}
+
+	content, _ := os.ReadFile(first)
+	meta, body, ok := Parse(content)
+	if !ok || meta.Query != r.Query || meta.HistoryID != 7 || meta.ID == "" || !meta.Created.Equal(created) {
+		t.Errorf("front-matter is not parsed back: %+v", meta)
+	}
+	if !strings.HasPrefix(string(body), "# list files recursively\n\n## Response\n\nls -R\n") {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	legacy := "gpt_explanation_GigaChat-2-Max_2025-10-21_09-00-00.md"
+	os.WriteFile(filepath.Join(dir, legacy), []byte("# why ls\n\n## Command\n\nls\n"), 0644)
+	files, err := List(dir)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("expected 3 files, got %+v (%v)", files, err)
+	}
+	if files[0].Name != filepath.Base(second) || files[2].Name != legacy {
+		t.Errorf("files must be sorted newest first: %s, %s", files[0].Name, files[2].Name)
+	}
+	old := files[2]
+	if !old.Legacy || old.Kind != KindExplanation || old.Model != "GigaChat-2-Max" || old.Query != "why ls" || old.Created.Hour() != 9 {
+		t.Errorf("legacy metadata is wrong: %+v", old)
+	}
+	if _, err := os.Stat(filepath.Join(dir, IndexFile)); err != nil {
+		t.Error("index must be written")
+	}
+
+	// Удаленный файл пропадает из индекса
+	os.Remove(first)
+	if files, _ := List(dir); len(files) != 2 {
+		t.Errorf("deleted file must leave the list: %+v", files)
+	}
+	if page, _ := Search(dir, Query{Text: "recursively"}); page.Total != 1 {
+		t.Errorf("deleted file must leave the index: %+v", page.Files)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2025, 10, 1, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 25; i++ {
+		model := "llama3"
+		if i%5 == 0 {
+			model = "qwen"
+		}
+		query := fmt.Sprintf("запрос номер %d", i)
+		if i == 7 {
+			query = "найти большие файлы в /var/log"
+		}
+		Write(dir, Result{
+			Meta:     Meta{Query: query, Model: model, Created: day.Add(time.Duration(i) * 24 * time.Hour)},
+			Sections: []Section{{Heading: "Response", Body: "du -sh *"}},
+		})
+	}
+
+	page, err := Search(dir, Query{Limit: 10, Offset: 20})
+	if err != nil || page.Total != 25 || len(page.Files) != 5 || page.Files[0].Query != "запрос номер 4" {
+		t.Fatalf("unexpected last page %+v (%v)", page, err)
+	}
+	if page, _ := Search(dir, Query{Text: "БОЛЬШ файл"}); page.Total != 1 || page.Files[0].Query != "найти большие файлы в /var/log" {
+		t.Errorf("words must match by prefix in any case: %+v", page)
+	}
+	if page, _ := Search(dir, Query{Text: "'файлы в /var'"}); page.Total != 1 {
+		t.Errorf("phrase must match: %+v", page)
+	}
+	if page, _ := Search(dir, Query{Text: "'в файлы'"}); page.Total != 0 {
+		t.Errorf("phrase must keep word order: %+v", page)
+	}
+	if page, _ := Search(dir, Query{Model: "qwen", From: day.Add(24 * time.Hour), To: day.Add(20 * 24 * time.Hour)}); page.Total != 3 {
+		t.Errorf("expected 3 qwen results between days 1 and 20: %+v", page)
+	}
+	if models, _ := Models(dir); strings.Join(models, ",") != "llama3,qwen" {
+		t.Errorf("unexpected models %v", models)
+	}
+
+	// Файл, измененный в обход Write, переиндексируется при пересканировании
+	os.WriteFile(filepath.Join(dir, page.Files[0].Name), []byte("# новый текст\n"), 0644)
+	if err := Rescan(dir); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := Search(dir, Query{Text: "новый"}); page.Total != 1 {
+		t.Errorf("rescan must reindex changed files: %+v", page)
+	}
+}
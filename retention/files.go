@@ -0,0 +1,113 @@
+package retention
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+)
+
+// PinsFile список закрепленных файлов результатов (по имени в строке)
+const PinsFile = ".lcg_pinned"
+
+// Префиксы файлов в папке результатов
+var (
+	ResultPrefixes      = []string{"gpt_request_", "gpt_script_"}
+	ExplanationPrefixes = []string{"gpt_explanation_"}
+)
+
+// LoadPins читает список закрепленных файлов; отсутствующий список — пустой
+func LoadPins(dir string) (map[string]bool, error) {
+	pins := map[string]bool{}
+	file, err := os.Open(filepath.Join(dir, PinsFile))
+	if os.IsNotExist(err) {
+		return pins, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" && !strings.HasPrefix(name, "#") {
+			pins[name] = true
+		}
+	}
+	return pins, scanner.Err()
+}
+
+// SetPinned закрепляет или открепляет файлы папки результатов.
+// Имена можно указывать путем — учитывается только имя файла.
+func SetPinned(dir string, names []string, pinned bool) error {
+	pins, err := LoadPins(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		name = filepath.Base(name)
+		if pinned {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				return fmt.Errorf("файл %s не найден в %s", name, dir)
+			}
+			pins[name] = true
+		} else {
+			delete(pins, name)
+		}
+	}
+
+	var list []string
+	for name := range pins {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	content := ""
+	if len(list) > 0 {
+		content = strings.Join(list, "\n") + "\n"
+	}
+	return os.WriteFile(filepath.Join(dir, PinsFile), []byte(content), 0644)
+}
+
+// ListFiles возвращает файлы папки с одним из префиксов. Время файла берется
+// из created его front-matter (по индексу результатов): шифрование, перезапись
+// и синхронизация меняют время изменения файла, но не время создания результата.
+// Время изменения используется только для файлов без front-matter.
+func ListFiles(dir string, prefixes []string, pins map[string]bool) ([]Item, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	list, err := results.List(dir)
+	if err != nil {
+		return nil, fmt.Errorf("индекс результатов: %v", err)
+	}
+	created := map[string]time.Time{}
+	for _, file := range list {
+		if !file.Legacy && !file.Created.IsZero() {
+			created[file.Name] = file.Created
+		}
+	}
+	var items []Item
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(name, p) }) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		item := Item{Name: name, Time: info.ModTime(), Size: info.Size(), Pinned: pins[name]}
+		if t, ok := created[name]; ok {
+			item.Time = t
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
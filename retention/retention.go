@@ -0,0 +1,162 @@
+// Package retention ограничивает рост истории и папки результатов: по возрасту,
+// количеству и суммарному размеру. Закрепленные элементы (избранные записи
+// истории, закрепленные файлы) не удаляются и не учитываются в лимитах.
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy ограничения хранения; нулевые поля не ограничивают
+type Policy struct {
+	MaxAge   time.Duration
+	MaxCount int
+	MaxSize  int64 // байт
+}
+
+// Enabled проверяет, задано ли хотя бы одно ограничение
+func (p Policy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxCount > 0 || p.MaxSize > 0
+}
+
+// String краткое описание ограничений для вывода
+func (p Policy) String() string {
+	var parts []string
+	if p.MaxAge > 0 {
+		parts = append(parts, fmt.Sprintf("не старше %s", formatAge(p.MaxAge)))
+	}
+	if p.MaxCount > 0 {
+		parts = append(parts, fmt.Sprintf("не больше %d шт.", p.MaxCount))
+	}
+	if p.MaxSize > 0 {
+		parts = append(parts, fmt.Sprintf("не больше %s", FormatSize(p.MaxSize)))
+	}
+	if len(parts) == 0 {
+		return "без ограничений"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParsePolicy разбирает ограничения из строк настроек; пустая строка или 0 — без ограничения
+func ParsePolicy(age, count, size string) (Policy, error) {
+	var p Policy
+	var err error
+	if p.MaxAge, err = ParseAge(age); err != nil {
+		return p, err
+	}
+	if count = strings.TrimSpace(count); count != "" {
+		if p.MaxCount, err = strconv.Atoi(count); err != nil || p.MaxCount < 0 {
+			return p, fmt.Errorf("неверное количество %q", count)
+		}
+	}
+	if p.MaxSize, err = ParseSize(size); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// ParseAge разбирает возраст: 90d, 2w, 6m (месяц — 30 дней), 1y (365 дней)
+// или длительность Go (36h)
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	day := 24 * time.Hour
+	units := map[byte]time.Duration{'d': day, 'w': 7 * day, 'm': 30 * day, 'y': 365 * day}
+	if unit, ok := units[value[len(value)-1]]; ok {
+		if n, err := strconv.Atoi(value[:len(value)-1]); err == nil && n >= 0 {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("неверный возраст %q: ожидается вида 90d, 2w, 6m, 1y или 36h", value)
+}
+
+// ParseSize разбирает размер: число байт или с суффиксом KB, MB, GB (по 1024)
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("неверный размер %q: ожидается вида 500KB, 10MB, 1GB", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatSize размер в удобном для чтения виде
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+func formatAge(d time.Duration) string {
+	if days := int(d / (24 * time.Hour)); days > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d дн.", days)
+	}
+	return d.String()
+}
+
+// Item элемент, подлежащий ограничению: запись истории или файл
+type Item struct {
+	Name   string
+	Time   time.Time
+	Size   int64
+	Pinned bool
+}
+
+// Select возвращает элементы, которые нужно удалить по политике: старше MaxAge,
+// а также самые старые из тех, что не помещаются в MaxCount и MaxSize.
+// Закрепленные элементы не удаляются и не учитываются в лимитах.
+func Select(items []Item, p Policy, now time.Time) []Item {
+	if !p.Enabled() {
+		return nil
+	}
+	sorted := append([]Item(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time)
+	})
+
+	var remove []Item
+	count, size := 0, int64(0)
+	for _, item := range sorted {
+		if item.Pinned {
+			continue
+		}
+		switch {
+		case p.MaxAge > 0 && now.Sub(item.Time) > p.MaxAge,
+			p.MaxCount > 0 && count >= p.MaxCount,
+			p.MaxSize > 0 && size+item.Size > p.MaxSize:
+			remove = append(remove, item)
+		default:
+			count++
+			size += item.Size
+		}
+	}
+	return remove
+}
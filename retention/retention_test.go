@@ -0,0 +1,117 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+)
+
+func TestParse(t *testing.T) {
+	p, err := ParsePolicy("90d", "100", "1.5MB")
+	if err != nil || p.MaxAge != 90*24*time.Hour || p.MaxCount != 100 || p.MaxSize != 3<<19 {
+		t.Errorf("ParsePolicy: %+v, %v", p, err)
+	}
+	if p, _ := ParsePolicy("", "0", ""); p.Enabled() {
+		t.Errorf("empty policy must be disabled: %+v", p)
+	}
+	for _, bad := range [][3]string{{"soon", "", ""}, {"", "-1", ""}, {"", "", "10XB"}} {
+		if _, err := ParsePolicy(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	now := time.Now()
+	items := []Item{
+		{Name: "new", Time: now, Size: 10},
+		{Name: "pinned-old", Time: now.Add(-100 * 24 * time.Hour), Size: 1000, Pinned: true},
+		{Name: "mid", Time: now.Add(-time.Hour), Size: 10},
+		{Name: "old", Time: now.Add(-48 * time.Hour), Size: 10},
+	}
+	names := func(items []Item) (result []string) {
+		for _, i := range items {
+			result = append(result, i.Name)
+		}
+		return result
+	}
+	if got := names(Select(items, Policy{MaxAge: 24 * time.Hour}, now)); len(got) != 1 || got[0] != "old" {
+		t.Errorf("age: %v", got)
+	}
+	if got := names(Select(items, Policy{MaxCount: 1}, now)); len(got) != 2 || got[0] != "mid" {
+		t.Errorf("count: %v", got)
+	}
+	// Закрепленный файл не учитывается в размере
+	if got := names(Select(items, Policy{MaxSize: 25}, now)); len(got) != 1 || got[0] != "old" {
+		t.Errorf("size: %v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	for _, name := range []string{"gpt_request_m_1.md", "gpt_request_m_2.md", "gpt_explanation_m_1.md", "notes.md"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("content"), 0644)
+		os.Chtimes(path, old, old)
+	}
+	if err := SetPinned(dir, []string{filepath.Join(dir, "gpt_request_m_2.md")}, true); err != nil {
+		t.Fatal(err)
+	}
+	store := history.NewJSON(filepath.Join(dir, "history.json"))
+	store.Add(history.Entry{Command: "old", Timestamp: old})
+	store.Add(history.Entry{Command: "old starred", Timestamp: old, Starred: true})
+	store.Add(history.Entry{Command: "new", Timestamp: time.Now()})
+
+	policy := Policy{MaxAge: 24 * time.Hour}
+	p := Policies{History: policy, Results: policy}
+	if reports, _ := Run(store, dir, p, true); len(reports) != 2 || len(reports[0].Removed) != 1 || len(reports[1].Removed) != 1 {
+		t.Fatalf("dry run: %+v", reports)
+	}
+	if entries, _ := store.List(); len(entries) != 3 {
+		t.Fatal("dry run must not delete")
+	}
+	if _, err := Run(store, dir, p, false); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := store.List(); len(entries) != 2 || entries[0].Command != "old starred" {
+		t.Errorf("history after gc: %+v", entries)
+	}
+	for name, exists := range map[string]bool{"gpt_request_m_1.md": false, "gpt_request_m_2.md": true, "gpt_explanation_m_1.md": true, "notes.md": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != exists {
+			t.Errorf("%s: exists=%v", name, err == nil)
+		}
+	}
+}
+
+func TestListFilesCreated(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	// Старый результат перезаписан (шифрование, синхронизация): время изменения новое
+	rewritten, err := results.Write(dir, results.Result{Meta: results.Meta{Query: "old", Model: "m", Created: now.Add(-10 * 24 * time.Hour)}, Sections: []results.Section{{Heading: "Response", Body: "ls"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(rewritten, now, now)
+	fresh, err := results.Write(dir, results.Result{Meta: results.Meta{Query: "new", Model: "m", Created: now}, Sections: []results.Section{{Heading: "Response", Body: "pwd"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := now.Add(-20 * 24 * time.Hour)
+	os.Chtimes(fresh, old, old)
+
+	items, err := ListFiles(dir, ResultPrefixes, nil)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("ListFiles: %+v, %v", items, err)
+	}
+	if got := Select(items, Policy{MaxAge: 24 * time.Hour}, now); len(got) != 1 || got[0].Name != filepath.Base(rewritten) {
+		t.Errorf("age must come from created, not mtime: %+v", got)
+	}
+	if got := Select(items, Policy{MaxCount: 1}, now); len(got) != 1 || got[0].Name != filepath.Base(rewritten) {
+		t.Errorf("count must keep the newest created: %+v", got)
+	}
+}
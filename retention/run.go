@@ -0,0 +1,142 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+)
+
+// Виды хранимых данных
+const (
+	KindHistory      = "history"
+	KindResults      = "results"
+	KindExplanations = "explanations"
+)
+
+// Policies ограничения для каждого вида данных
+type Policies struct {
+	History      Policy
+	Results      Policy
+	Explanations Policy
+}
+
+// Enabled проверяет, задано ли хотя бы одно ограничение
+func (p Policies) Enabled() bool {
+	return p.History.Enabled() || p.Results.Enabled() || p.Explanations.Enabled()
+}
+
+// FromConfig разбирает ограничения из настроек
+func FromConfig(c config.RetentionConfig) (Policies, error) {
+	var p Policies
+	var err error
+	for _, kind := range []struct {
+		name   string
+		limits config.RetentionLimits
+		policy *Policy
+	}{
+		{"HISTORY", c.History, &p.History},
+		{"RESULTS", c.Results, &p.Results},
+		{"EXPLANATIONS", c.Explanations, &p.Explanations},
+	} {
+		if *kind.policy, err = ParsePolicy(kind.limits.MaxAge, kind.limits.MaxCount, kind.limits.MaxSize); err != nil {
+			return p, fmt.Errorf("LCG_%s_MAX_*: %v", kind.name, err)
+		}
+	}
+	return p, nil
+}
+
+// Report результат очистки одного вида данных
+type Report struct {
+	Kind    string
+	Policy  Policy
+	Removed []Item
+	Freed   int64
+}
+
+// Run применяет ограничения к истории (store может быть nil — история не
+// очищается) и к файлам папки результатов. С dryRun ничего не удаляется,
+// в отчете — то, что было бы удалено.
+func Run(store history.Store, resultDir string, p Policies, dryRun bool) ([]Report, error) {
+	now := time.Now()
+	var reports []Report
+
+	if store != nil && p.History.Enabled() {
+		report, err := pruneHistory(store, p.History, now, dryRun)
+		if err != nil {
+			return reports, fmt.Errorf("история: %v", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if !p.Results.Enabled() && !p.Explanations.Enabled() {
+		return reports, nil
+	}
+	pins, err := LoadPins(resultDir)
+	if err != nil {
+		return reports, err
+	}
+	for _, kind := range []struct {
+		name     string
+		prefixes []string
+		policy   Policy
+	}{
+		{KindResults, ResultPrefixes, p.Results},
+		{KindExplanations, ExplanationPrefixes, p.Explanations},
+	} {
+		if !kind.policy.Enabled() {
+			continue
+		}
+		items, err := ListFiles(resultDir, kind.prefixes, pins)
+		if err != nil {
+			return reports, err
+		}
+		report := Report{Kind: kind.name, Policy: kind.policy}
+		for _, item := range Select(items, kind.policy, now) {
+			if !dryRun {
+				if err := os.Remove(filepath.Join(resultDir, item.Name)); err != nil && !os.IsNotExist(err) {
+					return append(reports, report), err
+				}
+			}
+			report.Removed = append(report.Removed, item)
+			report.Freed += item.Size
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// pruneHistory удаляет записи истории сверх ограничений; избранные записи сохраняются
+func pruneHistory(store history.Store, p Policy, now time.Time, dryRun bool) (Report, error) {
+	report := Report{Kind: KindHistory, Policy: p}
+	entries, err := store.List()
+	if err != nil {
+		return report, err
+	}
+	items := make([]Item, 0, len(entries))
+	ids := map[string]int{}
+	for _, e := range entries {
+		// Размер записи — размер ее JSON-представления
+		data, _ := json.Marshal(e)
+		name := fmt.Sprintf("#%d %s", e.Index, strings.ReplaceAll(strings.TrimSpace(e.Command), "\n", " "))
+		ids[name] = e.Index
+		items = append(items, Item{Name: name, Time: e.Timestamp, Size: int64(len(data)), Pinned: e.Starred})
+	}
+
+	report.Removed = Select(items, p, now)
+	var remove []int
+	for _, item := range report.Removed {
+		remove = append(remove, ids[item.Name])
+		report.Freed += item.Size
+	}
+	if dryRun || len(remove) == 0 {
+		return report, nil
+	}
+	_, err = store.DeleteMany(remove)
+	return report, err
+}
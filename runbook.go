@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/runbook"
+	"github.com/urfave/cli/v2"
+)
+
+// loadRunbook читает ранбук и переводит ошибки в коды выхода
+func loadRunbook(name string) (runbook.Runbook, error) {
+	if err := runbook.ValidateName(name); err != nil {
+		return runbook.Runbook{}, cli.Exit(err.Error(), exitValidation)
+	}
+	rb, err := runbook.Load(config.AppConfig.RunbookFolder, name)
+	if errors.Is(err, runbook.ErrNotFound) {
+		return rb, cli.Exit(fmt.Sprintf("Ранбук %q не найден: создайте его командой lcg runbook new %s", name, name), exitValidation)
+	}
+	if err != nil {
+		return rb, cli.Exit(err.Error(), exitGeneral)
+	}
+	return rb, nil
+}
+
+// listRunbooks выводит ранбуки с числом шагов
+func listRunbooks() error {
+	list, err := runbook.List(config.AppConfig.RunbookFolder)
+	if err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	if len(list) == 0 {
+		fmt.Println("Ранбуков нет. Создайте первый: lcg runbook new <имя>")
+		return nil
+	}
+	for _, rb := range list {
+		line := fmt.Sprintf("%-24s %-40s шагов: %d", rb.Name, rb.Title, len(rb.Steps))
+		if len(rb.Tags) > 0 {
+			line += "  [" + strings.Join(rb.Tags, ", ") + "]"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// showRunbook выводит шаги ранбука
+func showRunbook(name string) error {
+	rb, err := loadRunbook(name)
+	if err != nil {
+		return err
+	}
+	printColored(fmt.Sprintf("📒 %s (%s)\n", rb.Title, rb.Name), colorCyan)
+	if rb.Description != "" {
+		fmt.Println(rb.Description)
+	}
+	if len(rb.Steps) == 0 {
+		fmt.Printf("Шагов нет. Добавьте шаг: lcg runbook add %s --command \"<команда>\"\n", rb.Name)
+		return nil
+	}
+	for i, step := range rb.Steps {
+		printStep(i, len(rb.Steps), step)
+	}
+	return nil
+}
+
+// printStep выводит шаг: номер, описание и команду
+func printStep(i, total int, step runbook.Step) {
+	fmt.Println()
+	title := fmt.Sprintf("Шаг %d/%d", i+1, total)
+	if step.Description != "" {
+		title += ": " + step.Description
+	}
+	printColored(title+"\n", colorYellow)
+	fmt.Printf("  $ %s\n", strings.ReplaceAll(step.Command, "\n", "\n    "))
+}
+
+// newRunbook создает пустой ранбук
+func newRunbook(name, title, description string) error {
+	if err := runbook.ValidateName(name); err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	if _, err := runbook.Load(config.AppConfig.RunbookFolder, name); err == nil {
+		return cli.Exit(fmt.Sprintf("Ранбук %q уже существует", name), exitValidation)
+	}
+	rb := runbook.Runbook{Name: name, Meta: runbook.Meta{Title: title, Description: description}}
+	if err := runbook.Save(config.AppConfig.RunbookFolder, rb); err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	printColored(fmt.Sprintf("✅ Ранбук %s создан\n", name), colorGreen)
+	return nil
+}
+
+// addRunbookStep добавляет шаг в конец ранбука. Команда берется из записи
+// истории (historyID), генерируется по запросу (ask) или задается явно
+// (command); описание по умолчанию — запрос, по которому получена команда.
+func addRunbookStep(name string, historyID int, ask, command, description string, timeout int) error {
+	sources := 0
+	for _, set := range []bool{historyID > 0, ask != "", command != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return cli.Exit("Укажите источник команды: --history <номер>, --ask \"<запрос>\" или --command \"<команда>\"", exitValidation)
+	}
+	rb, err := loadRunbook(name)
+	if err != nil {
+		return err
+	}
+
+	step := runbook.Step{Description: description, Command: command}
+	switch {
+	case historyID > 0:
+		store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+		if err != nil {
+			return cli.Exit(err.Error(), exitGeneral)
+		}
+		e, err := store.Get(historyID)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Запись #%d: %v", historyID, err), exitValidation)
+		}
+		step.Command = e.Response
+		if step.Description == "" {
+			step.Description = e.Command
+		}
+	case ask != "":
+		system, err := gpt.RenderConfiguredPrompt(config.AppConfig.Prompt)
+		if err != nil {
+			return cli.Exit(err.Error(), exitValidation)
+		}
+		gpt3 := initGPT(system, timeout)
+		response, _ := getCommand(gpt3, ask)
+		if response == "" {
+			return cli.Exit("Модель не вернула команду", exitGeneral)
+		}
+		fmt.Printf("Команда: %s\n", response)
+		fmt.Print("Добавить в ранбук? (y/N): ")
+		if !cmdPackage.Confirm() {
+			fmt.Println("❌ Шаг не добавлен")
+			return nil
+		}
+		step.Command = response
+		if step.Description == "" {
+			step.Description = ask
+		}
+	}
+	if strings.TrimSpace(step.Command) == "" {
+		return cli.Exit("Пустая команда", exitValidation)
+	}
+
+	rb.Steps = append(rb.Steps, step)
+	if err := runbook.Save(config.AppConfig.RunbookFolder, rb); err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	printColored(fmt.Sprintf("✅ Шаг %d добавлен в ранбук %s\n", len(rb.Steps), rb.Name), colorGreen)
+	return nil
+}
+
+// deleteRunbook удаляет ранбук после подтверждения
+func deleteRunbook(name string) error {
+	rb, err := loadRunbook(name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Удалить ранбук %s (шагов: %d)? (y/N): ", rb.Name, len(rb.Steps))
+	if !cmdPackage.Confirm() {
+		fmt.Println("❌ Удаление отменено")
+		return nil
+	}
+	if err := runbook.Delete(config.AppConfig.RunbookFolder, name); err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	printColored(fmt.Sprintf("🗑️  Ранбук %s удален\n", name), colorGreen)
+	return nil
+}
+
+// stepResult итог шага при выполнении ранбука
+type stepResult struct {
+	skipped bool
+	code    int
+}
+
+// runRunbook выполняет шаги ранбука по порядку, начиная с from (с 1). Перед
+// каждым шагом спрашивает: выполнить, пропустить, изменить команду или
+// остановиться (по умолчанию); с --yes выполняет все шаги без вопросов.
+// Останавливается на первом шаге с ненулевым кодом выхода.
+func runRunbook(name string, from int) error {
+	// Шаги выполняются через bash, как (e)выполнить в меню действий
+	if !config.AppConfig.AllowExecution {
+		return cli.Exit("⚠️  Выполнение команд отключено. Установите LCG_ALLOW_EXECUTION=1 для включения этой функции.", exitValidation)
+	}
+	rb, err := loadRunbook(name)
+	if err != nil {
+		return err
+	}
+	if len(rb.Steps) == 0 {
+		fmt.Println("В ранбуке нет шагов")
+		return nil
+	}
+	if from < 1 || from > len(rb.Steps) {
+		return cli.Exit(fmt.Sprintf("Неверный номер шага %d: в ранбуке шагов %d", from, len(rb.Steps)), exitValidation)
+	}
+	if !cmdPackage.AssumeYes() && !cmdPackage.IsInteractive() {
+		return cli.Exit("Без терминала шаги нельзя подтвердить: используйте --yes", exitValidation)
+	}
+
+	printColored(fmt.Sprintf("📒 %s\n", rb.Title), colorCyan)
+	var results []stepResult
+	edited, failed, stopped := false, false, false
+	for i := from - 1; i < len(rb.Steps) && !failed && !stopped; i++ {
+		printStep(i, len(rb.Steps), rb.Steps[i])
+		action := "y"
+		for !cmdPackage.AssumeYes() {
+			fmt.Print("(y)выполнить, (s)пропустить, (e)изменить, (q)остановить [q]: ")
+			action = strings.ToLower(cmdPackage.ReadAnswer())
+			if action == "e" {
+				fmt.Print("Новая команда (пусто — без изменений): ")
+				if command := cmdPackage.ReadAnswer(); command != "" && command != rb.Steps[i].Command {
+					rb.Steps[i].Command = command
+					edited = true
+				}
+				fmt.Printf("  $ %s\n", rb.Steps[i].Command)
+				continue
+			}
+			if action != "y" && action != "s" {
+				// Пустой или неизвестный ответ останавливает ранбук
+				action = "q"
+			}
+			break
+		}
+		switch action {
+		case "s":
+			results = append(results, stepResult{skipped: true})
+		case "q":
+			stopped = true
+		default:
+			code := runStepCommand(rb.Steps[i].Command)
+			results = append(results, stepResult{code: code})
+			failed = code != 0
+		}
+	}
+
+	fmt.Println()
+	for i, result := range results {
+		step := from + i
+		switch {
+		case result.skipped:
+			fmt.Printf("⏭  Шаг %d пропущен\n", step)
+		case result.code == 0:
+			fmt.Printf("✅ Шаг %d выполнен\n", step)
+		default:
+			fmt.Printf("❌ Шаг %d завершился с кодом %d\n", step, result.code)
+		}
+	}
+	if edited {
+		fmt.Print("Сохранить измененные команды в ранбук? (y/N): ")
+		if cmdPackage.Confirm() {
+			if err := runbook.Save(config.AppConfig.RunbookFolder, rb); err != nil {
+				return cli.Exit(err.Error(), exitGeneral)
+			}
+			printColored("✅ Ранбук сохранен\n", colorGreen)
+		}
+	}
+	if failed {
+		last := from + len(results) - 1
+		return cli.Exit(fmt.Sprintf("Ранбук остановлен на шаге %d: продолжить можно с --from %d", last, last), exitGeneral)
+	}
+	if stopped {
+		printColored(fmt.Sprintf("Ранбук остановлен: продолжить можно с --from %d\n", from+len(results)), colorYellow)
+		return nil
+	}
+	printColored("✅ Ранбук выполнен\n", colorGreen)
+	return nil
+}
+
+// runStepCommand выполняет команду шага и возвращает код выхода
+func runStepCommand(command string) int {
+	cmd := exec.Command("bash", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Ошибка выполнения: %v\n", err)
+		return 127
+	}
+}
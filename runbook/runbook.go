@@ -0,0 +1,263 @@
+// Package runbook хранит ранбуки — именованные упорядоченные наборы шагов.
+//
+// Ранбук — markdown-файл <имя>.md в папке ранбуков. Он начинается с YAML
+// front-matter (название, описание, теги, время), за которым идут заголовок,
+// описание и шаги: раздел "## Шаг N" с описанием шага и командой в блоке кода
+// bash. Такой файл читается как обычная инструкция и разбирается обратно.
+package runbook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound ранбук с таким именем не найден
+var ErrNotFound = errors.New("ранбук не найден")
+
+// namePattern допустимые имена ранбуков: они же имена файлов и части адресов
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Meta сведения о ранбуке из front-matter
+type Meta struct {
+	Title       string    `yaml:"title" json:"title"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags        []string  `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Created     time.Time `yaml:"created" json:"created"`
+	Updated     time.Time `yaml:"updated" json:"updated"`
+}
+
+// Step шаг ранбука
+type Step struct {
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+// Runbook ранбук
+type Runbook struct {
+	Name string `json:"name"` // имя файла без .md
+	Meta
+	Steps []Step `json:"steps"`
+}
+
+// ValidateName проверяет имя ранбука: строчные латинские буквы, цифры, точка,
+// дефис и подчеркивание
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) || len(name) > 100 {
+		return fmt.Errorf("недопустимое имя ранбука %q: используйте строчные латинские буквы, цифры, '.', '-' и '_'", name)
+	}
+	return nil
+}
+
+// Save записывает ранбук в папку. Пустые Title и Created заполняются,
+// Updated — текущее время.
+func Save(folder string, rb Runbook) error {
+	if err := ValidateName(rb.Name); err != nil {
+		return err
+	}
+	now := time.Now().Truncate(time.Second)
+	if rb.Title == "" {
+		rb.Title = rb.Name
+	}
+	if rb.Created.IsZero() {
+		rb.Created = now
+	}
+	rb.Updated = now
+	content, err := Render(rb)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	return storage.WriteFile(filepath.Join(folder, rb.Name+".md"), content, 0644)
+}
+
+// Load читает ранбук по имени
+func Load(folder, name string) (Runbook, error) {
+	if err := ValidateName(name); err != nil {
+		return Runbook{}, err
+	}
+	content, err := storage.ReadFile(filepath.Join(folder, name+".md"))
+	if os.IsNotExist(err) {
+		return Runbook{}, ErrNotFound
+	}
+	if err != nil {
+		return Runbook{}, err
+	}
+	rb, err := Parse(content)
+	if err != nil {
+		return Runbook{}, fmt.Errorf("%s: %v", name, err)
+	}
+	rb.Name = name
+	return rb, nil
+}
+
+// List возвращает ранбуки папки по имени; нечитаемые файлы пропускаются
+func List(folder string) ([]Runbook, error) {
+	entries, err := os.ReadDir(folder)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Runbook
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".md")
+		if !ok || !entry.Type().IsRegular() || ValidateName(name) != nil {
+			continue
+		}
+		if rb, err := Load(folder, name); err == nil {
+			list = append(list, rb)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Delete удаляет ранбук
+func Delete(folder, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(folder, name+".md"))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Render собирает markdown ранбука: front-matter, заголовок, описание и шаги
+func Render(rb Runbook) ([]byte, error) {
+	front, err := yaml.Marshal(rb.Meta)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(front)
+	buf.WriteString("---\n\n")
+	fmt.Fprintf(&buf, "# %s\n", strings.Join(strings.Fields(rb.Title), " "))
+	if description := strings.TrimSpace(rb.Description); description != "" {
+		fmt.Fprintf(&buf, "\n%s\n", description)
+	}
+	for i, step := range rb.Steps {
+		fmt.Fprintf(&buf, "\n## Шаг %d\n\n", i+1)
+		if description := strings.TrimSpace(step.Description); description != "" {
+			fmt.Fprintf(&buf, "%s\n\n", description)
+		}
+		command := strings.TrimRight(step.Command, "\n")
+		fence := codeFence(command)
+		fmt.Fprintf(&buf, "%sbash\n%s\n%s\n", fence, command, fence)
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse разбирает markdown ранбука. Шаг — раздел второго уровня; команда —
+// последний блок кода раздела, описание — остальной текст.
+func Parse(content []byte) (Runbook, error) {
+	var rb Runbook
+	rest, found := bytes.CutPrefix(content, []byte("---\n"))
+	if !found {
+		return rb, errors.New("нет front-matter")
+	}
+	front, body, found := bytes.Cut(rest, []byte("\n---\n"))
+	if !found {
+		return rb, errors.New("front-matter не закрыт")
+	}
+	if err := yaml.Unmarshal(front, &rb.Meta); err != nil {
+		return rb, fmt.Errorf("front-matter: %v", err)
+	}
+
+	var section []string
+	inStep := false
+	flush := func() {
+		if inStep {
+			rb.Steps = append(rb.Steps, parseStep(section))
+		}
+		section = nil
+	}
+	fence := ""
+	for _, line := range strings.Split(string(body), "\n") {
+		if fence == "" {
+			if strings.HasPrefix(line, "## ") {
+				flush()
+				inStep = true
+				continue
+			}
+			if f := fenceOf(line); f != "" {
+				fence = f
+			}
+		} else if strings.TrimSpace(line) == fence {
+			fence = ""
+		}
+		section = append(section, line)
+	}
+	flush()
+	return rb, nil
+}
+
+// parseStep разбирает строки раздела шага
+func parseStep(lines []string) Step {
+	var step Step
+	open, start, end := -1, -1, -1
+	fence := ""
+	for i, line := range lines {
+		switch {
+		case fence == "":
+			if f := fenceOf(line); f != "" {
+				fence, open = f, i
+			}
+		case strings.TrimSpace(line) == fence:
+			// Берется последний блок кода раздела
+			fence, start, end = "", open, i
+		}
+	}
+	if end < 0 {
+		step.Description = strings.TrimSpace(strings.Join(lines, "\n"))
+		return step
+	}
+	step.Command = strings.Join(lines[start+1:end], "\n")
+	var parts []string
+	for _, part := range [][]string{lines[:start], lines[end+1:]} {
+		if text := strings.TrimSpace(strings.Join(part, "\n")); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	step.Description = strings.Join(parts, "\n\n")
+	return step
+}
+
+// fenceOf ограничитель блока кода в строке открытия (```bash) или пустая строка
+func fenceOf(line string) string {
+	n := len(line) - len(strings.TrimLeft(line, "`"))
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// codeFence ограничитель блока кода длиннее любой последовательности обратных
+// кавычек в команде, чтобы команда с ``` не ломала разметку
+func codeFence(command string) string {
+	longest, run := 0, 0
+	for _, r := range command {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
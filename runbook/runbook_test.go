@@ -0,0 +1,72 @@
+package runbook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenderParse(t *testing.T) {
+	rb := Runbook{
+		Meta: Meta{Title: "Очистка диска", Description: "Освобождает место\nна /var", Tags: []string{"ops"}},
+		Steps: []Step{
+			{Description: "Размер журналов", Command: "du -sh /var/log"},
+			{Command: "journalctl --vacuum-size=100M"},
+			{Description: "Команда с ```", Command: "echo '```'\necho `date`"},
+		},
+	}
+	content, err := Render(rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Title != rb.Title || parsed.Description != rb.Description || !reflect.DeepEqual(parsed.Tags, rb.Tags) {
+		t.Errorf("front-matter is not parsed back: %+v", parsed.Meta)
+	}
+	if !reflect.DeepEqual(parsed.Steps, rb.Steps) {
+		t.Errorf("steps are not parsed back:\n%q\n%q", parsed.Steps, rb.Steps)
+	}
+
+	// Файл, поправленный вручную: текст после блока кода входит в описание
+	manual := "---\ntitle: x\n---\n\n# x\n\n## Проверка\n\nСначала\n\n```\nuptime\n```\n\nпотом смотрим load\n"
+	parsed, err = Parse([]byte(manual))
+	if err != nil || len(parsed.Steps) != 1 || parsed.Steps[0].Command != "uptime" || parsed.Steps[0].Description != "Сначала\n\nпотом смотрим load" {
+		t.Errorf("unexpected manual parse %+v (%v)", parsed.Steps, err)
+	}
+	if _, err := Parse([]byte("# no front-matter\n")); err == nil {
+		t.Error("file without front-matter must be rejected")
+	}
+}
+
+func TestSaveLoadList(t *testing.T) {
+	dir := t.TempDir()
+	if err := Save(dir, Runbook{Name: "../evil"}); err == nil {
+		t.Error("invalid name must be rejected")
+	}
+	if err := Save(dir, Runbook{Name: "deploy", Steps: []Step{{Command: "make"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(dir, Runbook{Name: "backup"}); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+
+	rb, err := Load(dir, "deploy")
+	if err != nil || rb.Title != "deploy" || rb.Created.IsZero() || len(rb.Steps) != 1 || rb.Steps[0].Command != "make" {
+		t.Errorf("unexpected runbook %+v (%v)", rb, err)
+	}
+	list, err := List(dir)
+	if err != nil || len(list) != 2 || list[0].Name != "backup" || list[1].Name != "deploy" {
+		t.Errorf("unexpected list %+v (%v)", list, err)
+	}
+	if err := Delete(dir, "backup"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir, "backup"); err != ErrNotFound {
+		t.Errorf("deleted runbook must not be found: %v", err)
+	}
+}
@@ -2,14 +2,15 @@ package serve
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
 	"github.com/direct-dev-ru/linux-command-gpt/validation"
 )
 
@@ -19,6 +20,7 @@ type SaveResultRequest struct {
 	Command     string `json:"command"`
 	Explanation string `json:"explanation,omitempty"`
 	Model       string `json:"model"`
+	PromptID    int    `json:"prompt_id,omitempty"`
 }
 
 // SaveResultResponse представляет ответ на сохранение результата
@@ -36,6 +38,12 @@ type AddToHistoryRequest struct {
 	Response    string `json:"response"`
 	Explanation string `json:"explanation,omitempty"`
 	System      string `json:"system"`
+
+	// Сведения о запросе для истории
+	PromptID int     `json:"prompt_id,omitempty"`
+	Model    string  `json:"model,omitempty"`
+	Verbose  string  `json:"verbose,omitempty"`
+	Elapsed  float64 `json:"elapsed,omitempty"`
 }
 
 // AddToHistoryResponse представляет ответ на добавление в историю
@@ -86,30 +94,37 @@ func handleSaveResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Генерируем имя файла
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("gpt_request_%s_%s.md", req.Model, timestamp)
-	filePath := path.Join(config.AppConfig.ResultFolder, filename)
-	title := truncateTitle(req.Prompt)
-
-	// Формируем содержимое
-	var content string
+	// Результат связывается с записью истории того же запроса, если она есть
+	meta := results.Meta{
+		Kind:     results.KindRequest,
+		Query:    req.Prompt,
+		Model:    req.Model,
+		Provider: config.AppConfig.ProviderType,
+		PromptID: req.PromptID,
+	}
+	if store, err := historyStore(); err == nil {
+		if entries, err := store.Find(history.Filter{Query: req.Prompt}); err == nil && len(entries) > 0 {
+			meta.HistoryID, meta.Tags = entries[0].Index, entries[0].Tags
+		}
+	}
+	sections := []results.Section{
+		{Heading: "Prompt", Body: req.Prompt},
+		{Heading: "Response", Body: req.Command},
+	}
 	if strings.TrimSpace(req.Explanation) != "" {
-		content = fmt.Sprintf("# %s\n\n## Prompt\n\n%s\n\n## Response\n\n%s\n\n## Explanation\n\n%s\n",
-			title, req.Prompt, req.Command, req.Explanation)
-	} else {
-		content = fmt.Sprintf("# %s\n\n## Prompt\n\n%s\n\n## Response\n\n%s\n",
-			title, req.Prompt, req.Command)
+		sections = append(sections, results.Section{Heading: "Explanation", Body: req.Explanation})
 	}
 
 	// Сохраняем файл
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+	filePath, err := results.Write(config.AppConfig.ResultFolder, results.Result{Meta: meta, Sections: sections})
+	if err != nil {
 		apiJsonResponse(w, SaveResultResponse{
 			Success: false,
 			Error:   "Failed to save file",
 		})
 		return
 	}
+	filename := filepath.Base(filePath)
 
 	// Debug вывод для сохранения результата
 	PrintWebSaveDebugInfo("SAVE_RESULT", req.Prompt, req.Command, req.Explanation, req.Model, filename)
@@ -161,43 +176,40 @@ func handleAddToHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Проверяем, есть ли уже такой запрос в истории
-	entries, err := Read(config.AppConfig.ResultHistory)
+	// Заменяем запись с таким же запросом или добавляем новую
+	store, err := historyStore()
 	if err != nil {
-		// Если файл не существует, создаем пустой массив
-		entries = []HistoryEntry{}
-	}
-
-	// Ищем дубликат
-	duplicateIndex := -1
-	for i, entry := range entries {
-		if strings.EqualFold(strings.TrimSpace(entry.Command), strings.TrimSpace(req.Prompt)) {
-			duplicateIndex = i
-			break
-		}
-	}
-
-	// Создаем новую запись
-	newEntry := HistoryEntry{
-		Index:       len(entries) + 1,
-		Command:     req.Prompt,
-		Response:    req.Response,
-		Explanation: req.Explanation,
-		System:      req.System,
-		Timestamp:   time.Now(),
-	}
-
-	if duplicateIndex == -1 {
-		// Добавляем новую запись
-		entries = append(entries, newEntry)
-	} else {
-		// Перезаписываем существующую
-		newEntry.Index = entries[duplicateIndex].Index
-		entries[duplicateIndex] = newEntry
+		apiJsonResponse(w, AddToHistoryResponse{
+			Success: false,
+			Error:   "Failed to save to history",
+		})
+		return
 	}
-
-	// Сохраняем историю
-	if err := Write(config.AppConfig.ResultHistory, entries); err != nil {
+	model := config.AppConfig.Model
+	if req.Model != "" && req.Model != "Unknown" {
+		model = req.Model
+	}
+	verbosity := ""
+	if req.Verbose == "v" || req.Verbose == "vv" || req.Verbose == "vvv" {
+		verbosity = req.Verbose
+	}
+	host, _ := os.Hostname()
+	_, replaced, err := store.Upsert(HistoryEntry{
+		Schema:        history.SchemaVersion,
+		Command:       req.Prompt,
+		Response:      req.Response,
+		Explanation:   req.Explanation,
+		System:        req.System,
+		Model:         model,
+		Timestamp:     time.Now(),
+		Provider:      config.AppConfig.ProviderType,
+		PromptID:      req.PromptID,
+		PromptVersion: promptVersion(req.PromptID),
+		Verbosity:     verbosity,
+		Elapsed:       req.Elapsed,
+		Host:          host,
+	})
+	if err != nil {
 		apiJsonResponse(w, AddToHistoryResponse{
 			Success: false,
 			Error:   "Failed to save to history",
@@ -206,7 +218,7 @@ func handleAddToHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	message := "Added to history successfully"
-	if duplicateIndex != -1 {
+	if replaced {
 		message = "Updated existing history entry"
 	}
 
@@ -226,17 +238,3 @@ func apiJsonResponse(w http.ResponseWriter, data interface{}) {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
-
-// truncateTitle сокращает строку до 120 символов (по рунам), добавляя " ..." при усечении
-func truncateTitle(s string) string {
-	const maxLen = 120
-	if runeCount := len([]rune(s)); runeCount <= maxLen {
-		return s
-	}
-	const head = 116
-	r := []rune(s)
-	if len(r) <= head {
-		return s
-	}
-	return string(r[:head]) + " ..."
-}
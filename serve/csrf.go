@@ -277,6 +277,23 @@ func (c *CSRFManager) createSignature(data CSRFData) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// pageCSRFToken выдает CSRF-токен для изменяющих запросов со страницы и
+// записывает его в cookie; при ошибке отвечает 500 и возвращает false
+func pageCSRFToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	csrfManager := GetCSRFManager()
+	if csrfManager == nil {
+		http.Error(w, "CSRF manager not initialized", http.StatusInternalServerError)
+		return "", false
+	}
+	csrfToken, err := csrfManager.GenerateToken(getSessionID(r))
+	if err != nil {
+		http.Error(w, "Failed to generate CSRF token", http.StatusInternalServerError)
+		return "", false
+	}
+	setCSRFCookie(w, csrfToken)
+	return csrfToken, true
+}
+
 // getTokenFromCookie извлекает CSRF токен из cookie
 func GetCSRFTokenFromCookie(r *http.Request) string {
 	cookie, err := r.Cookie("csrf_token")
@@ -68,11 +68,6 @@ func handleExecute(w http.ResponseWriter, r *http.Request) {
 	// Определяем системный промпт
 	systemPrompt := ""
 	if req.SystemText != "" {
-		// Валидация длины пользовательского системного промпта
-		if err := validation.ValidateSystemPrompt(req.SystemText); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
 		systemPrompt = req.SystemText
 	} else if req.SystemID > 0 && req.SystemID <= 5 {
 		// Получаем системный промпт по ID
@@ -82,22 +77,23 @@ func handleExecute(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Failed to get system prompt", http.StatusInternalServerError)
 			return
 		}
-		// Валидация длины системного промпта из базы
-		if err := validation.ValidateSystemPrompt(prompt.Content); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
 		systemPrompt = prompt.Content
 	} else {
 		// Используем промпт по умолчанию
-		// Валидация длины системного промпта по умолчанию
-		if err := validation.ValidateSystemPrompt(config.AppConfig.Prompt); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
 		systemPrompt = config.AppConfig.Prompt
 	}
 
+	// Подставляем переменные шаблона и проверяем длину готового промпта
+	systemPrompt, err := gpt.RenderConfiguredPrompt(systemPrompt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.ValidateSystemPrompt(systemPrompt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Устанавливаем таймаут
 	timeout := req.Timeout
 	if timeout <= 0 {
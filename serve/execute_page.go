@@ -156,8 +156,13 @@ func handleExecuteRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Валидация длины системного промпта
-	if err := validation.ValidateSystemPrompt(systemPrompt.Content); err != nil {
+	// Подставляем переменные шаблона и проверяем длину готового промпта
+	systemText, err := gpt.RenderConfiguredPrompt(systemPrompt.Content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.ValidateSystemPrompt(systemText); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -168,13 +173,13 @@ func handleExecuteRequest(w http.ResponseWriter, r *http.Request) {
 		config.AppConfig.Host,
 		config.AppConfig.JwtToken,
 		config.AppConfig.Model,
-		systemPrompt.Content,
+		systemText,
 		0.01,
 		120,
 	)
 
 	// Debug вывод для основного запроса
-	PrintWebDebugInfo("EXECUTE", prompt, systemPrompt.Content, config.AppConfig.Model, 120)
+	PrintWebDebugInfo("EXECUTE", prompt, systemText, config.AppConfig.Model, 120)
 
 	// Выполняем запрос
 	response, elapsed := getCommand(*gpt3, prompt)
@@ -288,6 +293,12 @@ func formatResultSection(result ExecuteResultData) string {
 		useMarkdown = true
 	}
 
+	// Уровень подробности попадает в разметку, поэтому берем только допустимые значения
+	verbose := ""
+	if result.Verbose == "v" || result.Verbose == "vv" || result.Verbose == "vvv" {
+		verbose = result.Verbose
+	}
+
 	commandBlock := ""
 	if useMarkdown {
 		// Рендерим Markdown в HTML
@@ -303,7 +314,7 @@ func formatResultSection(result ExecuteResultData) string {
             <div class="command-result">
                 <h3>✅ Команда:</h3>
                 %s
-                <div class="result-meta">
+                <div class="result-meta" data-elapsed="%.2f" data-verbose="%s">
                     <span>Модель: %s</span>
                     <span>Время: %.2f сек</span>
                 </div>
@@ -316,7 +327,9 @@ func formatResultSection(result ExecuteResultData) string {
                 const resultData = {
                     command: %s,
                     explanation: %s,
-                    model: %s
+                    model: %s,
+                    elapsed: %.2f,
+                    verbose: "%s"
                 };
                 const resultDataField = document.getElementById('resultData');
                 if (resultDataField) {
@@ -324,10 +337,10 @@ func formatResultSection(result ExecuteResultData) string {
                 }
             })();
         </script>`,
-		commandBlock, result.Model, result.Elapsed, explanationSection,
+		commandBlock, result.Elapsed, verbose, result.Model, result.Elapsed, explanationSection,
 		fmt.Sprintf(`"%s"`, strings.ReplaceAll(result.Command, `"`, `\"`)),
 		fmt.Sprintf(`"%s"`, strings.ReplaceAll(result.Explanation, `"`, `\"`)),
-		fmt.Sprintf(`"%s"`, result.Model))
+		fmt.Sprintf(`"%s"`, result.Model), result.Elapsed, verbose)
 }
 
 // formatVerboseButtons форматирует кнопки подробности
@@ -1,16 +1,21 @@
 package serve
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/diffutil"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
 	"github.com/direct-dev-ru/linux-command-gpt/serve/templates"
+	"github.com/direct-dev-ru/linux-command-gpt/validation"
 	"github.com/russross/blackfriday/v2"
 )
 
@@ -20,11 +25,42 @@ type HistoryEntryInfo struct {
 	Command   string
 	Response  string
 	Timestamp string
+	Starred   bool
+	Tags      []string
+	Rating    string
+	Provider  string
+	Model     string
+	PromptID  int
+	Host      string
+	Details   string // провайдер, модель, промпт, время ответа одной строкой
+	Versions  int    // число версий ответа (0 — одна)
+}
+
+// historyPageData данные шаблона списка истории
+type historyPageData struct {
+	Entries  []HistoryEntryInfo
+	BasePath string
+	AppName  string
+	Filtered bool
+	Tag      string
+	Starred  bool
+	Rating   string
+	Provider string
+	Model    string
+	PromptID int
+	Host     string
+
+	ExportFormats []string
+
+	Static  bool       // страница статического сайта (lcg export site): без управления
+	AllTags []string   // теги со страницами на статическом сайте
+	Results []FileInfo // результаты с тегом страницы на статическом сайте
 }
 
 // handleHistoryPage обрабатывает страницу истории запросов
 func handleHistoryPage(w http.ResponseWriter, r *http.Request) {
-	historyEntries, err := readHistoryEntries()
+	filter := historyFilter(r)
+	historyEntries, err := readHistoryEntries(filter)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка чтения истории: %v", err), http.StatusInternalServerError)
 		return
@@ -38,27 +74,58 @@ func handleHistoryPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := struct {
-		Entries  []HistoryEntryInfo
-		BasePath string
-		AppName  string
-	}{
+	data := historyPageData{
 		Entries:  historyEntries,
 		BasePath: getBasePath(),
 		AppName:  config.AppConfig.AppName,
+		Filtered: filter != history.Filter{},
+		Tag:      filter.Tag,
+		Starred:  filter.Starred,
+		Rating:   filter.Rating,
+		Provider: filter.Provider,
+		Model:    filter.Model,
+		PromptID: filter.PromptID,
+		Host:     filter.Host,
+
+		ExportFormats: history.ExportFormats,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	t.Execute(w, data)
 }
 
-// readHistoryEntries читает записи истории
-func readHistoryEntries() ([]HistoryEntryInfo, error) {
-	entries, err := Read(config.AppConfig.ResultHistory)
+// historyFilter отбор по пометкам из запроса: ?tag=<тег>, ?starred=1, ?rating=good|bad
+func historyFilter(r *http.Request) history.Filter {
+	query := r.URL.Query()
+	filter := history.Filter{
+		Tag:      query.Get("tag"),
+		Starred:  query.Get("starred") == "1",
+		Provider: query.Get("provider"),
+		Model:    query.Get("model"),
+		Host:     query.Get("host"),
+	}
+	filter.PromptID, _ = strconv.Atoi(query.Get("prompt_id"))
+	if rating, err := history.ParseRating(query.Get("rating")); err == nil {
+		filter.Rating = rating
+	}
+	return filter
+}
+
+// readHistoryEntries читает записи истории, подходящие под фильтр
+func readHistoryEntries(filter history.Filter) ([]HistoryEntryInfo, error) {
+	store, err := historyStore()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.Find(filter)
 	if err != nil {
 		return nil, err
 	}
+	return historyEntryInfos(entries), nil
+}
 
+// historyEntryInfos записи истории для вывода, новые сначала
+func historyEntryInfos(entries []HistoryEntry) []HistoryEntryInfo {
 	// Сортируем записи по времени в убывающем порядке (новые сначала)
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Timestamp.After(entries[j].Timestamp)
@@ -71,10 +138,19 @@ func readHistoryEntries() ([]HistoryEntryInfo, error) {
 			Command:   entry.Command,
 			Response:  entry.Response,
 			Timestamp: entry.Timestamp.Format("02.01.2006 15:04:05"),
+			Starred:   entry.Starred,
+			Tags:      entry.Tags,
+			Rating:    entry.Rating,
+			Provider:  entry.Provider,
+			Model:     entry.Model,
+			PromptID:  entry.PromptID,
+			Host:      entry.Host,
+			Details:   entry.Details(),
+			Versions:  len(entry.Versions),
 		})
 	}
 
-	return result, nil
+	return result
 }
 
 // handleDeleteHistoryEntry обрабатывает удаление записи истории
@@ -109,6 +185,92 @@ func handleDeleteHistoryEntry(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Запись успешно удалена"))
 }
 
+// AnnotateHistoryRequest изменение пометок записи; отсутствующие поля не меняются
+type AnnotateHistoryRequest struct {
+	Starred *bool     `json:"starred,omitempty"`
+	Tags    *[]string `json:"tags,omitempty"`
+	Rating  *string   `json:"rating,omitempty"` // good, bad или пустая строка — снять оценку
+	Note    *string   `json:"note,omitempty"`
+
+	Preferred *int `json:"preferred,omitempty"` // номер предпочтительной версии ответа, 0 — последняя
+}
+
+// handleAnnotateHistoryEntry обрабатывает изменение избранного, тегов и оценки записи
+func handleAnnotateHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Убираем BasePath из URL перед извлечением индекса
+	basePath := config.AppConfig.Server.BasePath
+	var indexStr string
+	if basePath != "" && basePath != "/" {
+		basePath = strings.TrimSuffix(basePath, "/")
+		indexStr = strings.TrimPrefix(r.URL.Path, basePath+"/history/annotate/")
+	} else {
+		indexStr = strings.TrimPrefix(r.URL.Path, "/history/annotate/")
+	}
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		http.Error(w, "Invalid index", http.StatusBadRequest)
+		return
+	}
+
+	var req AnnotateHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Note != nil {
+		if err := validation.ValidateExplanation(*req.Note); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	store, err := historyStore()
+	if err == nil {
+		err = store.Update(index, func(e *HistoryEntry) error {
+			if req.Starred != nil {
+				e.Starred = *req.Starred
+			}
+			if req.Tags != nil {
+				e.Tags = history.NormalizeTags(*req.Tags)
+			}
+			if req.Rating != nil {
+				if *req.Rating == "" {
+					e.Rating, e.RatingNote = "", ""
+				} else {
+					rating, err := history.ParseRating(*req.Rating)
+					if err != nil {
+						return err
+					}
+					e.Rating = rating
+				}
+			}
+			if req.Note != nil && e.Rating != "" {
+				e.RatingNote = strings.TrimSpace(*req.Note)
+			}
+			if req.Preferred != nil {
+				return e.Prefer(*req.Preferred)
+			}
+			return nil
+		})
+	}
+	if err == history.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка сохранения: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Пометки сохранены"))
+}
+
 // handleClearHistory обрабатывает очистку всей истории
 func handleClearHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
@@ -116,7 +278,10 @@ func handleClearHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := os.WriteFile(config.AppConfig.ResultHistory, []byte("[]"), 0644)
+	store, err := historyStore()
+	if err == nil {
+		err = store.Clear()
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка очистки: %v", err), http.StatusInternalServerError)
 		return
@@ -126,6 +291,40 @@ func handleClearHistory(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("История успешно очищена"))
 }
 
+// handleExportHistory выгружает историю файлом: ?format=json|jsonl|csv|markdown|sh,
+// с теми же фильтрами по пометкам, что и страница истории
+func handleExportHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = history.FormatJSON
+	}
+	var buf bytes.Buffer
+	store, err := historyStore()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка чтения истории: %v", err), http.StatusInternalServerError)
+		return
+	}
+	entries, err := store.Find(historyFilter(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка чтения истории: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := history.Export(&buf, entries, format); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("lcg_history_%s.%s", time.Now().Format("2006-01-02"), history.FileExtension(format))
+	w.Header().Set("Content-Type", history.ContentType(format)+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
 // handleHistoryView обрабатывает просмотр записи истории
 func handleHistoryView(w http.ResponseWriter, r *http.Request) {
 	// Получаем индекс из URL, учитывая BasePath
@@ -143,32 +342,103 @@ func handleHistoryView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Читаем записи истории
-	entries, err := Read(config.AppConfig.ResultHistory)
+	// Ищем запись с нужным номером
+	store, err := historyStore()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Ошибка чтения истории: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Ищем запись с нужным индексом
-	var targetEntry *HistoryEntry
-	for _, entry := range entries {
-		if entry.Index == index {
-			targetEntry = &entry
-			break
-		}
+	targetEntry, err := store.Get(index)
+	if err == history.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка чтения истории: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	if targetEntry == nil {
+	// Версия ответа: из ?version= или предпочтительная/последняя
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+	diffFrom, _ := strconv.Atoi(r.URL.Query().Get("diff"))
+	data, err := newHistoryViewData(targetEntry, version, diffFrom)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
+	csrfToken, ok := pageCSRFToken(w, r)
+	if !ok {
+		return
+	}
+	data.CSRFToken = csrfToken
+
+	// Парсим и выполняем шаблон
+	tmpl := templates.HistoryViewTemplate
+	t, err := template.New("history_view").Parse(tmpl)
+	if err != nil {
+		http.Error(w, "Ошибка шаблона", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	t.Execute(w, data)
+}
+
+// historyViewData данные шаблона просмотра записи истории
+type historyViewData struct {
+	Index           int
+	Timestamp       string
+	Command         string
+	Response        string
+	ExplanationHTML template.HTML
+	BasePath        string
+	Starred         bool
+	Tags            string
+	TagList         []string
+	Rating          string
+	RatingNote      string
+	Meta            HistoryEntry // сведения о запросе: провайдер, модель, промпт, хост
+	Version         int
+	VersionTime     string // время показанной версии ответа, если их несколько
+	Versions        []versionLink
+	Preferred       int
+	DiffFrom        int
+	Diff            []diffLine
+	Static          bool   // страница статического сайта (lcg export site): без управления
+	CSRFToken       string // токен для изменения пометок записи
+}
+
+// newHistoryViewData данные страницы записи с версией ответа version (0 —
+// предпочтительная или последняя) и различиями с версией diffFrom (0 — без них)
+func newHistoryViewData(entry HistoryEntry, version, diffFrom int) (historyViewData, error) {
+	if version == 0 {
+		version = entry.Active()
+	}
+	shown, err := entry.WithVersion(version)
+	if err != nil {
+		return historyViewData{}, err
+	}
+	var versions []versionLink
+	versionTime := ""
+	if len(entry.Versions) > 0 {
+		if t := entry.Versions[version-1].Timestamp; !t.IsZero() {
+			versionTime = t.Format("02.01.2006 15:04:05")
+		}
+		for i, v := range entry.Versions {
+			versions = append(versions, versionLink{
+				N:         i + 1,
+				Timestamp: v.Timestamp.Format("02.01.2006 15:04:05"),
+				Current:   i+1 == version,
+				Preferred: i+1 == entry.Preferred,
+			})
+		}
+	}
 
 	// Формируем объяснение, если оно есть
 	explanationSection := ""
-	if strings.TrimSpace(targetEntry.Explanation) != "" {
+	if strings.TrimSpace(shown.Explanation) != "" {
 		// Конвертируем Markdown в HTML
-		explanationHTML := blackfriday.Run([]byte(targetEntry.Explanation))
+		explanationHTML := blackfriday.Run([]byte(shown.Explanation))
 		explanationSection = fmt.Sprintf(`
 			<div class="history-explanation">
 				<h3>📖 Подробное объяснение:</h3>
@@ -176,31 +446,85 @@ func handleHistoryView(w http.ResponseWriter, r *http.Request) {
 			</div>`, string(explanationHTML))
 	}
 
-	// Создаем данные для шаблона
-	data := struct {
-		Index           int
-		Timestamp       string
-		Command         string
-		Response        string
-		ExplanationHTML template.HTML
-		BasePath        string
-	}{
-		Index:           index,
-		Timestamp:       targetEntry.Timestamp.Format("02.01.2006 15:04:05"),
-		Command:         targetEntry.Command,
-		Response:        targetEntry.Response,
+	return historyViewData{
+		Index:           entry.Index,
+		Timestamp:       shown.Timestamp.Format("02.01.2006 15:04:05"),
+		Command:         shown.Command,
+		Response:        shown.Response,
 		ExplanationHTML: template.HTML(explanationSection),
 		BasePath:        getBasePath(),
-	}
+		Starred:         shown.Starred,
+		Tags:            strings.Join(shown.Tags, " "),
+		TagList:         shown.Tags,
+		Rating:          shown.Rating,
+		RatingNote:      shown.RatingNote,
+		Meta:            shown,
+		Version:         version,
+		VersionTime:     versionTime,
+		Versions:        versions,
+		Preferred:       entry.Preferred,
+		DiffFrom:        diffFrom,
+		Diff:            versionDiff(entry, diffFrom, version),
+	}, nil
+}
 
-	// Парсим и выполняем шаблон
-	tmpl := templates.HistoryViewTemplate
-	t, err := template.New("history_view").Parse(tmpl)
+// versionLink версия ответа записи для переключателя на странице записи
+type versionLink struct {
+	N         int
+	Timestamp string
+	Current   bool
+	Preferred bool
+}
+
+// diffLine строка различий с классом подсветки
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+// versionDiff различия ответа и объяснения версии from с версией to;
+// nil, если from не задана или совпадает с to
+func versionDiff(e HistoryEntry, from, to int) []diffLine {
+	if from == 0 || from == to {
+		return nil
+	}
+	a, err := e.Version(from)
 	if err != nil {
-		http.Error(w, "Ошибка шаблона", http.StatusInternalServerError)
-		return
+		return nil
+	}
+	b, err := e.Version(to)
+	if err != nil {
+		return nil
+	}
+	fromName, toName := fmt.Sprintf("версия %d", from), fmt.Sprintf("версия %d", to)
+	diff := diffutil.Unified(a.Response, b.Response, fromName, toName, 3)
+	if explanation := diffutil.Unified(a.Explanation, b.Explanation, fromName+" (объяснение)", toName+" (объяснение)", 3); explanation != "" {
+		diff += explanation
 	}
+	return diffLines(diff)
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t.Execute(w, data)
+// diffLines строки unified diff с классами подсветки; пустой diff — "Изменений нет"
+func diffLines(diff string) []diffLine {
+	if diff == "" {
+		return []diffLine{{Class: "diff-context", Text: "Изменений нет.\n"}}
+	}
+	var lines []diffLine
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		class := "diff-context"
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			class = "diff-file"
+		case strings.HasPrefix(line, "@@"):
+			class = "diff-hunk"
+		case strings.HasPrefix(line, "+"):
+			class = "diff-add"
+		case strings.HasPrefix(line, "-"):
+			class = "diff-del"
+		}
+		if line != "" {
+			lines = append(lines, diffLine{Class: class, Text: line})
+		}
+	}
+	return lines
 }
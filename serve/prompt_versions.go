@@ -0,0 +1,169 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/serve/templates"
+)
+
+// promptVersionRow версия промпта в списке на странице версий
+type promptVersionRow struct {
+	N         int
+	Timestamp string
+	Author    string
+	Name      string
+	Change    string
+	Note      string
+	Deleted   bool
+	Current   bool // последняя версия существующего промпта
+	Selected  bool // показана на странице
+}
+
+// RollbackPromptRequest откат промпта к версии
+type RollbackPromptRequest struct {
+	Version int `json:"version"`
+}
+
+// promptVersion текущая версия промпта для записи в историю; 0 — свой текст
+func promptVersion(id int) int {
+	if id <= 0 {
+		return 0
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return 0
+	}
+	return gpt.NewPromptManager(homeDir).CurrentVersion(id)
+}
+
+// handlePromptVersions страница версий промпта /prompts/versions/<id>?version=N&diff=M:
+// список версий, содержимое версии N и различия с версией M (по умолчанию с предыдущей)
+func handlePromptVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, makePath("/prompts/versions/")))
+	if err != nil {
+		renderNotFound(w, "Промпт не найден", getBasePath())
+		return
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		http.Error(w, "Ошибка получения домашней директории", http.StatusInternalServerError)
+		return
+	}
+	pm := gpt.NewPromptManager(homeDir)
+	list, err := pm.PromptVersions(id)
+	if err != nil {
+		renderNotFound(w, "Промпт не найден", getBasePath())
+		return
+	}
+
+	current := pm.CurrentVersion(id)
+	shown := len(list)
+	if n, err := strconv.Atoi(r.URL.Query().Get("version")); err == nil && n >= 1 && n <= len(list) {
+		shown = n
+	}
+	diffFrom := shown - 1
+	if value := r.URL.Query().Get("diff"); value != "" {
+		diffFrom, _ = strconv.Atoi(value)
+	}
+	var diff []diffLine
+	if diffFrom >= 1 && diffFrom <= len(list) && diffFrom != shown {
+		diff = diffLines(gpt.DiffPromptVersions(list[diffFrom-1], list[shown-1]))
+	} else {
+		diffFrom = 0
+	}
+
+	var rows []promptVersionRow
+	for i := len(list) - 1; i >= 0; i-- {
+		v := list[i]
+		row := promptVersionRow{
+			N:        v.Version,
+			Author:   v.Author,
+			Name:     v.Name,
+			Change:   gpt.VersionChange(list, i),
+			Note:     v.Note,
+			Deleted:  v.Deleted,
+			Current:  v.Version == current,
+			Selected: v.Version == shown,
+		}
+		if !v.Timestamp.IsZero() {
+			row.Timestamp = v.Timestamp.Format("2006-01-02 15:04:05")
+		}
+		rows = append(rows, row)
+	}
+
+	t, err := template.New("prompt_versions").Parse(templates.PromptVersionsTemplate)
+	if err != nil {
+		http.Error(w, "Ошибка шаблона", http.StatusInternalServerError)
+		return
+	}
+	csrfToken, ok := pageCSRFToken(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	t.Execute(w, struct {
+		ID        int
+		Versions  []promptVersionRow
+		Shown     gpt.PromptVersion
+		Current   int
+		DiffFrom  int
+		Diff      []diffLine
+		BasePath  string
+		AppName   string
+		CSRFToken string
+	}{
+		ID:        id,
+		Versions:  rows,
+		Shown:     list[shown-1],
+		Current:   current,
+		DiffFrom:  diffFrom,
+		Diff:      diff,
+		BasePath:  getBasePath(),
+		AppName:   config.AppConfig.AppName,
+		CSRFToken: csrfToken,
+	})
+}
+
+// handleRollbackPrompt откатывает промпт /prompts/rollback/<id> к версии (POST JSON)
+func handleRollbackPrompt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, makePath("/prompts/rollback/")))
+	if err != nil {
+		http.Error(w, "Неверный ID промпта", http.StatusBadRequest)
+		return
+	}
+	var req RollbackPromptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Ошибка парсинга JSON", http.StatusBadRequest)
+		return
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		http.Error(w, "Ошибка получения домашней директории", http.StatusInternalServerError)
+		return
+	}
+	pm := gpt.NewPromptManager(homeDir)
+	if err := pm.RollbackPrompt(id, req.Version); err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка отката: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Промпт #%d возвращен к версии %d", id, req.Version)))
+}
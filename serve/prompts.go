@@ -83,6 +83,12 @@ func handlePromptsPage(w http.ResponseWriter, r *http.Request) {
 	// Получаем промпты подробности из файла sys_prompts
 	verbosePrompts := getVerbosePromptsFromFile(pm.Prompts, lang)
 
+	// Токен для импорта промптов
+	csrfToken, ok := pageCSRFToken(w, r)
+	if !ok {
+		return
+	}
+
 	data := struct {
 		Prompts               []PromptWithDefault
 		VerbosePrompts        []VerbosePrompt
@@ -92,6 +98,7 @@ func handlePromptsPage(w http.ResponseWriter, r *http.Request) {
 		MaxPromptDescLength   int
 		BasePath              string
 		AppName               string
+		CSRFToken             string
 	}{
 		Prompts:               promptsWithDefault,
 		VerbosePrompts:        verbosePrompts,
@@ -101,6 +108,7 @@ func handlePromptsPage(w http.ResponseWriter, r *http.Request) {
 		MaxPromptDescLength:   config.AppConfig.Validation.MaxPromptDescLength,
 		BasePath:              getBasePath(),
 		AppName:               config.AppConfig.AppName,
+		CSRFToken:             csrfToken,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -0,0 +1,142 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/validation"
+)
+
+// ImportPromptsRequest импорт промптов из файла экспорта
+type ImportPromptsRequest struct {
+	Content  string `json:"content"`  // содержимое файла yaml или json
+	Strategy string `json:"strategy"` // rename, overwrite или skip
+}
+
+// ImportPromptsResponse итог импорта промптов
+type ImportPromptsResponse struct {
+	Added   int         `json:"added"`
+	Updated int         `json:"updated"`
+	Skipped int         `json:"skipped"`
+	IDs     map[int]int `json:"ids"` // ID в файле -> ID после импорта
+}
+
+// handleExportPrompts выгружает промпты файлом: ?format=yaml|json&ids=1,9
+func handleExportPrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = gpt.PromptFormatYAML
+	}
+	var ids []int
+	for _, part := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			http.Error(w, "Неверный ID промпта", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		http.Error(w, "Ошибка получения домашней директории", http.StatusInternalServerError)
+		return
+	}
+	pm := gpt.NewPromptManager(homeDir)
+	prompts, err := pm.SelectPrompts(ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	if err := gpt.ExportPrompts(&buf, prompts, format); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	contentType := "application/yaml"
+	if format == gpt.PromptFormatJSON {
+		contentType = "application/json"
+	}
+	filename := fmt.Sprintf("lcg_prompts_%s.%s", time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
+// handleImportPrompts загружает промпты из файла экспорта (POST JSON)
+func handleImportPrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ImportPromptsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Ошибка парсинга JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = gpt.ConflictRename
+	}
+	incoming, err := gpt.ParsePrompts([]byte(req.Content))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка разбора файла: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		http.Error(w, "Ошибка получения домашней директории", http.StatusInternalServerError)
+		return
+	}
+	pm := gpt.NewPromptManager(homeDir)
+	plan, err := pm.PlanImport(incoming, req.Strategy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Те же ограничения длины, что и для промптов из формы
+	for _, prompt := range append(plan.Add, plan.Update...) {
+		for _, err := range []error{
+			validation.ValidatePromptName(prompt.Name),
+			validation.ValidatePromptDescription(prompt.Description),
+			validation.ValidateSystemPrompt(prompt.Content),
+		} {
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Промпт %q: %v", prompt.Name, err), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if len(plan.Add)+len(plan.Update) > 0 {
+		if err := pm.ApplyImport(plan); err != nil {
+			http.Error(w, fmt.Sprintf("Ошибка импорта: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ImportPromptsResponse{
+		Added:   len(plan.Add),
+		Updated: len(plan.Update),
+		Skipped: len(plan.Skip),
+		IDs:     plan.IDs,
+	})
+}
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
 	"github.com/direct-dev-ru/linux-command-gpt/serve/templates"
 	"github.com/russross/blackfriday/v2"
 )
@@ -41,18 +44,126 @@ func generateAbbreviation(appName string) string {
 type FileInfo struct {
 	Name        string
 	DisplayName string
+	Kind        string // значок вида результата (объяснение, скрипт); пусто для ответа
+	Model       string
+	Tags        []string
+	HistoryID   int // связанная запись истории
 	Size        string
-	ModTime     string
-	Preview     template.HTML
-	Content     string // Полное содержимое для поиска
+	ModTime     string // время создания результата для вывода
+	Created     time.Time
+	Preview     string // начало текста из индекса
 }
 
-// handleResultsPage обрабатывает главную страницу со списком файлов
+// resultKindLabels подписи видов результатов, кроме ответа на запрос
+var resultKindLabels = map[string]string{
+	results.KindExplanation: "📖 Объяснение",
+	results.KindScript:      "📜 Скрипт",
+}
+
+// resultsPageSize число результатов на странице
+const resultsPageSize = 30
+
+// resultsFilter фильтры страницы результатов из параметров запроса
+type resultsFilter struct {
+	Query string // текст поиска (q)
+	Model string
+	From  string // дата ГГГГ-ММ-ДД, включительно
+	To    string // дата ГГГГ-ММ-ДД, включительно
+	Page  int    // номер страницы с 1
+}
+
+// parseResultsFilter читает фильтры из параметров запроса; неверные даты и
+// номер страницы игнорируются
+func parseResultsFilter(r *http.Request) (resultsFilter, results.Query) {
+	params := r.URL.Query()
+	f := resultsFilter{
+		Query: strings.TrimSpace(params.Get("q")),
+		Model: params.Get("model"),
+		From:  params.Get("from"),
+		To:    params.Get("to"),
+		Page:  1,
+	}
+	if page, err := strconv.Atoi(params.Get("page")); err == nil && page > 1 {
+		f.Page = page
+	}
+	q := results.Query{
+		Text:   f.Query,
+		Model:  f.Model,
+		Offset: (f.Page - 1) * resultsPageSize,
+		Limit:  resultsPageSize,
+	}
+	if from, err := time.ParseInLocation("2006-01-02", f.From, time.Local); err == nil {
+		q.From = from
+	} else {
+		f.From = ""
+	}
+	if to, err := time.ParseInLocation("2006-01-02", f.To, time.Local); err == nil {
+		q.To = to.AddDate(0, 0, 1)
+	} else {
+		f.To = ""
+	}
+	return f, q
+}
+
+// pageURL ссылка на страницу результатов с теми же фильтрами
+func (f resultsFilter) pageURL(page int) string {
+	params := url.Values{}
+	for key, value := range map[string]string{"q": f.Query, "model": f.Model, "from": f.From, "to": f.To} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	if len(params) == 0 {
+		return getBasePath() + "/"
+	}
+	return getBasePath() + "/?" + params.Encode()
+}
+
+// resultsPageData данные шаблона списка результатов
+type resultsPageData struct {
+	Files           []FileInfo
+	TotalFiles      int
+	RecentFiles     int
+	Found           int
+	Filter          resultsFilter
+	Filtered        bool
+	Models          []string
+	Page            int
+	Pages           int
+	PrevURL         string
+	NextURL         string
+	ResetURL        string
+	BasePath        string
+	AppName         string
+	AppAbbreviation string
+	Static          bool // страница статического сайта (lcg export site): без управления
+}
+
+// handleResultsPage обрабатывает главную страницу со списком файлов: поиск,
+// фильтры по модели и дате и постраничный вывод идут по индексу результатов
 func handleResultsPage(w http.ResponseWriter, r *http.Request) {
-	files, err := getResultFiles()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Ошибка чтения папки: %v", err), http.StatusInternalServerError)
-		return
+	folder := config.AppConfig.ResultFolder
+	filter, query := parseResultsFilter(r)
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		// Папка появится при первом сохранении
+		folder = ""
+	}
+
+	var page results.Page
+	var all, recent results.Page
+	var models []string
+	if folder != "" {
+		var err error
+		if page, err = results.Search(folder, query); err != nil {
+			http.Error(w, fmt.Sprintf("Ошибка чтения папки: %v", err), http.StatusInternalServerError)
+			return
+		}
+		all, _ = results.Search(folder, results.Query{Limit: 1})
+		recent, _ = results.Search(folder, results.Query{From: time.Now().AddDate(0, 0, -7), Limit: 1})
+		models, _ = results.Models(folder)
 	}
 
 	tmpl := templates.ResultsPageTemplate
@@ -63,96 +174,59 @@ func handleResultsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Подсчитываем статистику
-	recentCount := 0
-	weekAgo := time.Now().AddDate(0, 0, -7)
-	for _, file := range files {
-		// Парсим время из строки для сравнения
-		if modTime, err := time.Parse("02.01.2006 15:04", file.ModTime); err == nil {
-			if modTime.After(weekAgo) {
-				recentCount++
-			}
-		}
-	}
-
-	data := struct {
-		Files           []FileInfo
-		TotalFiles      int
-		RecentFiles     int
-		BasePath        string
-		AppName         string
-		AppAbbreviation string
-	}{
-		Files:           files,
-		TotalFiles:      len(files),
-		RecentFiles:     recentCount,
+	pages := (page.Total + resultsPageSize - 1) / resultsPageSize
+	data := resultsPageData{
+		Files:           resultFiles(page.Files),
+		TotalFiles:      all.Total,
+		RecentFiles:     recent.Total,
+		Found:           page.Total,
+		Filter:          filter,
+		Filtered:        filter.Query != "" || filter.Model != "" || filter.From != "" || filter.To != "",
+		Models:          models,
+		Page:            filter.Page,
+		Pages:           pages,
+		ResetURL:        getBasePath() + "/",
 		BasePath:        getBasePath(),
 		AppName:         config.AppConfig.AppName,
 		AppAbbreviation: generateAbbreviation(config.AppConfig.AppName),
 	}
+	if filter.Page > 1 {
+		data.PrevURL = filter.pageURL(min(filter.Page-1, max(pages, 1)))
+	}
+	if filter.Page < pages {
+		data.NextURL = filter.pageURL(filter.Page + 1)
+	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	t.Execute(w, data)
 }
 
-// getResultFiles возвращает список файлов из папки результатов
-func getResultFiles() ([]FileInfo, error) {
-	entries, err := os.ReadDir(config.AppConfig.ResultFolder)
-	if err != nil {
-		return nil, err
-	}
-
+// resultFiles карточки результатов по сведениям из индекса (файлы не читаются)
+func resultFiles(list []results.File) []FileInfo {
 	var files []FileInfo
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
-			continue
-		}
-
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		// Читаем превью файла (первые 200 символов) как обычный текст
-		preview := ""
-		fullContent := ""
-		if content, err := os.ReadFile(filepath.Join(config.AppConfig.ResultFolder, entry.Name())); err == nil {
-			// Сохраняем полное содержимое для поиска
-			fullContent = string(content)
-
-			// Берем первые 200 символов как превью
-			preview = string(content)
-			// Очищаем от лишних пробелов и переносов
-			preview = strings.ReplaceAll(preview, "\n", " ")
-			preview = strings.ReplaceAll(preview, "\r", "")
-			preview = strings.ReplaceAll(preview, "  ", " ")
-			preview = strings.TrimSpace(preview)
-
-			if len(preview) > 200 {
-				preview = preview[:200] + "..."
-			}
-		}
-
+	for _, file := range list {
 		files = append(files, FileInfo{
-			Name:        entry.Name(),
-			DisplayName: formatFileDisplayName(entry.Name()),
-			Size:        formatFileSize(info.Size()),
-			ModTime:     info.ModTime().Format("02.01.2006 15:04"),
-			Preview:     template.HTML(preview),
-			Content:     fullContent,
+			Name:        file.Name,
+			DisplayName: resultDisplayName(file),
+			Kind:        resultKindLabels[file.Kind],
+			Model:       file.Model,
+			Tags:        file.Tags,
+			HistoryID:   file.HistoryID,
+			Size:        formatFileSize(file.Size),
+			ModTime:     file.Created.Format("02.01.2006 15:04"),
+			Created:     file.Created,
+			Preview:     file.Preview,
 		})
 	}
+	return files
+}
 
-	// Сортируем по времени изменения (новые сверху)
-	for i := 0; i < len(files)-1; i++ {
-		for j := i + 1; j < len(files); j++ {
-			if files[i].ModTime < files[j].ModTime {
-				files[i], files[j] = files[j], files[i]
-			}
-		}
+// resultDisplayName заголовок карточки результата: запрос, а без него — имя файла
+func resultDisplayName(file results.File) string {
+	if title := results.Title(file.Query); title != "" {
+		return title
 	}
-
-	return files, nil
+	return file.Name
 }
 
 // formatFileSize форматирует размер файла в читаемый вид
@@ -169,65 +243,6 @@ func formatFileSize(size int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
-// formatFileDisplayName преобразует имя файла вида
-// gpt_request_GigaChat-2-Max_2025-10-22_13-50-13.md
-// в "Gpt Request GigaChat 2 Max — 2025-10-22 13:50:13"
-func formatFileDisplayName(filename string) string {
-	name := strings.TrimSuffix(filename, ".md")
-	// Разделим на части по '_'
-	parts := strings.Split(name, "_")
-	if len(parts) == 0 {
-		return filename
-	}
-
-	// Первая часть может быть префиксом gpt/request — заменим '_' на пробел и приведем регистр
-	var words []string
-	for _, p := range parts {
-		if p == "" {
-			continue
-		}
-		// Заменяем '-' на пробел в словах модели/текста
-		p = strings.ReplaceAll(p, "-", " ")
-		// Разбиваем по пробелам и капитализуем каждое слово
-		for _, w := range strings.Fields(p) {
-			if w == "" {
-				continue
-			}
-			r := []rune(w)
-			r[0] = unicode.ToUpper(r[0])
-			words = append(words, string(r))
-		}
-	}
-
-	// Попробуем распознать хвост как дату и время
-	// Ищем шаблон YYYY-MM-DD_HH-MM-SS в исходном имени
-	var pretty string
-	// ожидаем последние две части — дата и время
-	if len(parts) >= 3 {
-		datePart := parts[len(parts)-2]
-		timePart := parts[len(parts)-1]
-		// заменить '-' в времени на ':'
-		timePretty := strings.ReplaceAll(timePart, "-", ":")
-		if len(datePart) == 10 && len(timePart) == 8 { // примитивная проверка
-			// Собираем текст до датных частей
-			text := strings.Join(words[:len(words)-2], " ")
-			pretty = strings.TrimSpace(text)
-			if pretty != "" {
-				pretty += " — " + datePart + " " + timePretty
-			} else {
-				pretty = datePart + " " + timePretty
-			}
-			return pretty
-		}
-	}
-
-	if len(words) > 0 {
-		pretty = strings.Join(words, " ")
-		return pretty
-	}
-	return filename
-}
-
 // handleFileView обрабатывает просмотр конкретного файла
 func handleFileView(w http.ResponseWriter, r *http.Request) {
 	// Учитываем BasePath при извлечении имени файла
@@ -251,25 +266,13 @@ func handleFileView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content, err := os.ReadFile(filePath)
+	file, body, err := results.Read(config.AppConfig.ResultFolder, filename)
 	if err != nil {
 		renderNotFound(w, "Файл не найден или был удален", getBasePath())
 		return
 	}
 
-	// Конвертируем Markdown в HTML
-	htmlContent := blackfriday.Run(content)
-
-	// Создаем данные для шаблона
-	data := struct {
-		Filename string
-		Content  template.HTML
-		BasePath string
-	}{
-		Filename: filename,
-		Content:  template.HTML(htmlContent),
-		BasePath: getBasePath(),
-	}
+	data := newFileViewData(filename, file, body)
 
 	// Парсим и выполняем шаблон
 	tmpl := templates.FileViewTemplate
@@ -284,6 +287,30 @@ func handleFileView(w http.ResponseWriter, r *http.Request) {
 	t.Execute(w, data)
 }
 
+// fileViewData данные шаблона просмотра результата
+type fileViewData struct {
+	Filename string
+	Title    string
+	Kind     string
+	Meta     results.File
+	Content  template.HTML
+	BasePath string
+	Static   bool
+}
+
+// newFileViewData данные страницы результата; markdown переводится в HTML,
+// front-matter показывается отдельно
+func newFileViewData(filename string, file results.File, body []byte) fileViewData {
+	return fileViewData{
+		Filename: filename,
+		Title:    resultDisplayName(file),
+		Kind:     resultKindLabels[file.Kind],
+		Meta:     file,
+		Content:  template.HTML(blackfriday.Run(body)),
+		BasePath: getBasePath(),
+	}
+}
+
 // handleDeleteFile обрабатывает удаление файла
 func handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 	// Проверяем метод запроса
@@ -0,0 +1,51 @@
+package serve
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/retention"
+)
+
+// startRetention применяет ограничения хранения при запуске сервера и затем
+// каждые LCG_GC_INTERVAL (0 — только при запуске)
+func startRetention(store history.Store) error {
+	policies, err := retention.FromConfig(config.AppConfig.Retention)
+	if err != nil {
+		return err
+	}
+	if !policies.Enabled() {
+		return nil
+	}
+	interval, err := retention.ParseAge(config.AppConfig.Retention.GCInterval)
+	if err != nil {
+		return fmt.Errorf("LCG_GC_INTERVAL: %v", err)
+	}
+	if config.AppConfig.IsNoHistoryEnabled() {
+		store = nil
+	}
+
+	collect := func() {
+		reports, err := retention.Run(store, config.AppConfig.ResultFolder, policies, false)
+		if err != nil {
+			fmt.Printf("⚠️  Очистка по ограничениям хранения: %v\n", err)
+			return
+		}
+		for _, r := range reports {
+			if len(r.Removed) > 0 {
+				fmt.Printf("🧹 Очистка %s: удалено %d (%s)\n", r.Kind, len(r.Removed), retention.FormatSize(r.Freed))
+			}
+		}
+	}
+	collect()
+	if interval > 0 {
+		go func() {
+			for range time.Tick(interval) {
+				collect()
+			}
+		}()
+	}
+	return nil
+}
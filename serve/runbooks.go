@@ -0,0 +1,284 @@
+package serve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/runbook"
+	"github.com/direct-dev-ru/linux-command-gpt/serve/templates"
+	"github.com/direct-dev-ru/linux-command-gpt/validation"
+)
+
+// runbookHistoryLimit сколько последних записей истории предлагается при сборке ранбука
+const runbookHistoryLimit = 50
+
+// runbooksPageData данные страницы списка ранбуков
+type runbooksPageData struct {
+	Runbooks []runbook.Runbook
+	BasePath string
+	AppName  string
+}
+
+// runbookViewData данные страниц просмотра и редактирования ранбука
+type runbookViewData struct {
+	Runbook   runbook.Runbook
+	IsNew     bool
+	History   []runbookHistoryItem // последние записи истории для шагов
+	CSRFToken string
+	BasePath  string
+	AppName   string
+}
+
+// runbookHistoryItem запись истории, из которой можно взять команду шага
+type runbookHistoryItem struct {
+	Index   int    `json:"index"`
+	Query   string `json:"query"`
+	Command string `json:"command"`
+}
+
+// SaveRunbookRequest сохранение ранбука из редактора
+type SaveRunbookRequest struct {
+	Original    string         `json:"original"` // прежнее имя; пусто — новый ранбук
+	Name        string         `json:"name"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Tags        []string       `json:"tags"`
+	Steps       []runbook.Step `json:"steps"`
+}
+
+// GenerateStepRequest генерация команды шага по запросу
+type GenerateStepRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+// handleRunbooksPage список ранбуков
+func handleRunbooksPage(w http.ResponseWriter, r *http.Request) {
+	list, err := runbook.List(config.AppConfig.RunbookFolder)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка чтения ранбуков: %v", err), http.StatusInternalServerError)
+		return
+	}
+	t, err := template.New("runbooks").Parse(templates.RunbooksPageTemplate)
+	if err != nil {
+		http.Error(w, "Ошибка шаблона", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	t.Execute(w, runbooksPageData{Runbooks: list, BasePath: getBasePath(), AppName: config.AppConfig.AppName})
+}
+
+// handleRunbookView просмотр ранбука /runbooks/view/<имя>
+func handleRunbookView(w http.ResponseWriter, r *http.Request) {
+	rb, ok := requestRunbook(w, r, "/runbooks/view/")
+	if !ok {
+		return
+	}
+	renderRunbookPage(w, r, "runbook_view", templates.RunbookViewTemplate, runbookViewData{Runbook: rb})
+}
+
+// handleRunbookNew редактор нового ранбука
+func handleRunbookNew(w http.ResponseWriter, r *http.Request) {
+	renderRunbookPage(w, r, "runbook_edit", templates.RunbookEditTemplate, runbookViewData{IsNew: true, History: runbookHistory()})
+}
+
+// handleRunbookEdit редактор ранбука /runbooks/edit/<имя>
+func handleRunbookEdit(w http.ResponseWriter, r *http.Request) {
+	rb, ok := requestRunbook(w, r, "/runbooks/edit/")
+	if !ok {
+		return
+	}
+	renderRunbookPage(w, r, "runbook_edit", templates.RunbookEditTemplate, runbookViewData{Runbook: rb, History: runbookHistory()})
+}
+
+// requestRunbook читает ранбук, имя которого указано в адресе после prefix;
+// при ошибке отвечает сам и возвращает false
+func requestRunbook(w http.ResponseWriter, r *http.Request, prefix string) (runbook.Runbook, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return runbook.Runbook{}, false
+	}
+	name := strings.TrimPrefix(r.URL.Path, makePath(prefix))
+	rb, err := runbook.Load(config.AppConfig.RunbookFolder, name)
+	if err != nil {
+		if errors.Is(err, runbook.ErrNotFound) || runbook.ValidateName(name) != nil {
+			renderNotFound(w, "Ранбук не найден", getBasePath())
+		} else {
+			http.Error(w, fmt.Sprintf("Ошибка чтения ранбука: %v", err), http.StatusInternalServerError)
+		}
+		return rb, false
+	}
+	return rb, true
+}
+
+// renderRunbookPage выводит страницу ранбука с CSRF-токеном для изменяющих запросов
+func renderRunbookPage(w http.ResponseWriter, r *http.Request, name, text string, data runbookViewData) {
+	csrfToken, ok := pageCSRFToken(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		http.Error(w, "Ошибка шаблона", http.StatusInternalServerError)
+		return
+	}
+	data.CSRFToken = csrfToken
+	data.BasePath = getBasePath()
+	data.AppName = config.AppConfig.AppName
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	t.Execute(w, data)
+}
+
+// runbookHistory последние записи истории, новые первыми; без истории — пусто
+func runbookHistory() []runbookHistoryItem {
+	store, err := historyStore()
+	if err != nil {
+		return nil
+	}
+	entries, err := store.List()
+	if err != nil {
+		return nil
+	}
+	var items []runbookHistoryItem
+	for i := len(entries) - 1; i >= 0 && len(items) < runbookHistoryLimit; i-- {
+		if e := entries[i]; strings.TrimSpace(e.Response) != "" {
+			items = append(items, runbookHistoryItem{Index: e.Index, Query: e.Command, Command: e.Response})
+		}
+	}
+	return items
+}
+
+// handleSaveRunbook сохраняет ранбук из редактора (POST JSON)
+func handleSaveRunbook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req SaveRunbookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := runbook.ValidateName(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	folder := config.AppConfig.RunbookFolder
+	rb := runbook.Runbook{Name: req.Name}
+	if req.Original != "" {
+		existing, err := runbook.Load(folder, req.Original)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Ранбук %s: %v", req.Original, err), http.StatusNotFound)
+			return
+		}
+		rb.Created = existing.Created
+	}
+	if req.Name != req.Original {
+		if _, err := runbook.Load(folder, req.Name); err == nil {
+			http.Error(w, fmt.Sprintf("Ранбук %s уже существует", req.Name), http.StatusConflict)
+			return
+		}
+	}
+	rb.Title = strings.TrimSpace(req.Title)
+	rb.Description = strings.TrimSpace(req.Description)
+	rb.Tags = history.NormalizeTags(req.Tags)
+	for i, step := range req.Steps {
+		step.Description = strings.TrimSpace(step.Description)
+		if strings.TrimSpace(step.Command) == "" {
+			http.Error(w, fmt.Sprintf("Шаг %d: пустая команда", i+1), http.StatusBadRequest)
+			return
+		}
+		if err := validation.ValidateCommand(step.Command); err != nil {
+			http.Error(w, fmt.Sprintf("Шаг %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+		rb.Steps = append(rb.Steps, step)
+	}
+
+	if err := runbook.Save(folder, rb); err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка сохранения: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if req.Original != "" && req.Original != req.Name {
+		// Переименование: прежний файл больше не нужен
+		if err := runbook.Delete(folder, req.Original); err != nil {
+			http.Error(w, fmt.Sprintf("Ошибка удаления прежнего ранбука: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Ранбук сохранен"))
+}
+
+// handleGenerateRunbookStep генерирует команду шага по запросу системным
+// промптом по умолчанию (POST JSON)
+func handleGenerateRunbookStep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req GenerateStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	if req.Prompt == "" {
+		http.Error(w, "Prompt is required", http.StatusBadRequest)
+		return
+	}
+	if err := validation.ValidateUserMessage(req.Prompt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	system, err := gpt.RenderConfiguredPrompt(config.AppConfig.Prompt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	gpt3 := gpt.NewGpt3(
+		config.AppConfig.ProviderType,
+		config.AppConfig.Host,
+		config.AppConfig.JwtToken,
+		config.AppConfig.Model,
+		system,
+		0.01,
+		120,
+	)
+	PrintWebDebugInfo("RUNBOOK", req.Prompt, system, config.AppConfig.Model, 120)
+	command, _ := getCommand(*gpt3, req.Prompt)
+	if command == "" {
+		http.Error(w, "Модель не вернула команду", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"command": command})
+}
+
+// handleDeleteRunbook удаляет ранбук /runbooks/delete/<имя> (DELETE)
+func handleDeleteRunbook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, makePath("/runbooks/delete/"))
+	err := runbook.Delete(config.AppConfig.RunbookFolder, name)
+	if errors.Is(err, runbook.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Ошибка удаления: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Ранбук удален"))
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
 	"github.com/direct-dev-ru/linux-command-gpt/serve/templates"
 	"github.com/direct-dev-ru/linux-command-gpt/ssl"
 )
@@ -56,10 +57,19 @@ func StartResultServer(host, port string) error {
 			return fmt.Errorf("failed to create results folder: %v", mkErr)
 		}
 	}
-	if _, err := os.Stat(config.AppConfig.ResultHistory); os.IsNotExist(err) {
-		if writeErr := Write(config.AppConfig.ResultHistory, []HistoryEntry{}); writeErr != nil {
-			return fmt.Errorf("failed to create history file: %v", writeErr)
-		}
+	// Индекс результатов строится до запуска сервера, а не при первом запросе
+	if err := results.Rescan(config.AppConfig.ResultFolder); err != nil {
+		fmt.Printf("⚠️  Индекс результатов не обновлен: %v\n", err)
+	}
+	store, err := historyStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Init(); err != nil {
+		return fmt.Errorf("failed to create history file: %v", err)
+	}
+	if err := startRetention(store); err != nil {
+		return fmt.Errorf("invalid retention settings: %v", err)
 	}
 
 	addr := fmt.Sprintf("%s:%s", host, port)
@@ -210,7 +220,9 @@ func registerRoutesExceptHome() {
 	http.HandleFunc(makePath("/history"), AuthMiddleware(handleHistoryPage))
 	http.HandleFunc(makePath("/history/view/"), AuthMiddleware(handleHistoryView))
 	http.HandleFunc(makePath("/history/delete/"), AuthMiddleware(handleDeleteHistoryEntry))
+	http.HandleFunc(makePath("/history/annotate/"), AuthMiddleware(CSRFMiddleware(handleAnnotateHistoryEntry)))
 	http.HandleFunc(makePath("/history/clear"), AuthMiddleware(handleClearHistory))
+	http.HandleFunc(makePath("/history/export"), AuthMiddleware(handleExportHistory))
 
 	// Управление промптами
 	http.HandleFunc(makePath("/prompts"), AuthMiddleware(handlePromptsPage))
@@ -220,7 +232,20 @@ func registerRoutesExceptHome() {
 	http.HandleFunc(makePath("/prompts/delete/"), AuthMiddleware(handleDeletePrompt))
 	http.HandleFunc(makePath("/prompts/restore/"), AuthMiddleware(handleRestorePrompt))
 	http.HandleFunc(makePath("/prompts/restore-verbose/"), AuthMiddleware(handleRestoreVerbosePrompt))
+	http.HandleFunc(makePath("/prompts/versions/"), AuthMiddleware(handlePromptVersions))
+	http.HandleFunc(makePath("/prompts/rollback/"), AuthMiddleware(CSRFMiddleware(handleRollbackPrompt)))
 	http.HandleFunc(makePath("/prompts/save-lang"), AuthMiddleware(handleSaveLang))
+	http.HandleFunc(makePath("/prompts/export"), AuthMiddleware(handleExportPrompts))
+	http.HandleFunc(makePath("/prompts/import"), AuthMiddleware(CSRFMiddleware(handleImportPrompts)))
+
+	// Ранбуки
+	http.HandleFunc(makePath("/runbooks"), AuthMiddleware(handleRunbooksPage))
+	http.HandleFunc(makePath("/runbooks/view/"), AuthMiddleware(handleRunbookView))
+	http.HandleFunc(makePath("/runbooks/new"), AuthMiddleware(handleRunbookNew))
+	http.HandleFunc(makePath("/runbooks/edit/"), AuthMiddleware(handleRunbookEdit))
+	http.HandleFunc(makePath("/runbooks/save"), AuthMiddleware(CSRFMiddleware(handleSaveRunbook)))
+	http.HandleFunc(makePath("/runbooks/generate"), AuthMiddleware(CSRFMiddleware(handleGenerateRunbookStep)))
+	http.HandleFunc(makePath("/runbooks/delete/"), AuthMiddleware(CSRFMiddleware(handleDeleteRunbook)))
 
 	// Веб-страница для выполнения запросов
 	http.HandleFunc(makePath("/run"), AuthMiddleware(CSRFMiddleware(handleExecutePage)))
@@ -267,7 +292,9 @@ func registerRoutes() {
 	http.HandleFunc(makePath("/history"), AuthMiddleware(handleHistoryPage))
 	http.HandleFunc(makePath("/history/view/"), AuthMiddleware(handleHistoryView))
 	http.HandleFunc(makePath("/history/delete/"), AuthMiddleware(handleDeleteHistoryEntry))
+	http.HandleFunc(makePath("/history/annotate/"), AuthMiddleware(CSRFMiddleware(handleAnnotateHistoryEntry)))
 	http.HandleFunc(makePath("/history/clear"), AuthMiddleware(handleClearHistory))
+	http.HandleFunc(makePath("/history/export"), AuthMiddleware(handleExportHistory))
 
 	// Управление промптами
 	http.HandleFunc(makePath("/prompts"), AuthMiddleware(handlePromptsPage))
@@ -277,7 +304,20 @@ func registerRoutes() {
 	http.HandleFunc(makePath("/prompts/delete/"), AuthMiddleware(handleDeletePrompt))
 	http.HandleFunc(makePath("/prompts/restore/"), AuthMiddleware(handleRestorePrompt))
 	http.HandleFunc(makePath("/prompts/restore-verbose/"), AuthMiddleware(handleRestoreVerbosePrompt))
+	http.HandleFunc(makePath("/prompts/versions/"), AuthMiddleware(handlePromptVersions))
+	http.HandleFunc(makePath("/prompts/rollback/"), AuthMiddleware(CSRFMiddleware(handleRollbackPrompt)))
 	http.HandleFunc(makePath("/prompts/save-lang"), AuthMiddleware(handleSaveLang))
+	http.HandleFunc(makePath("/prompts/export"), AuthMiddleware(handleExportPrompts))
+	http.HandleFunc(makePath("/prompts/import"), AuthMiddleware(CSRFMiddleware(handleImportPrompts)))
+
+	// Ранбуки
+	http.HandleFunc(makePath("/runbooks"), AuthMiddleware(handleRunbooksPage))
+	http.HandleFunc(makePath("/runbooks/view/"), AuthMiddleware(handleRunbookView))
+	http.HandleFunc(makePath("/runbooks/new"), AuthMiddleware(handleRunbookNew))
+	http.HandleFunc(makePath("/runbooks/edit/"), AuthMiddleware(handleRunbookEdit))
+	http.HandleFunc(makePath("/runbooks/save"), AuthMiddleware(CSRFMiddleware(handleSaveRunbook)))
+	http.HandleFunc(makePath("/runbooks/generate"), AuthMiddleware(CSRFMiddleware(handleGenerateRunbookStep)))
+	http.HandleFunc(makePath("/runbooks/delete/"), AuthMiddleware(CSRFMiddleware(handleDeleteRunbook)))
 
 	// Веб-страница для выполнения запросов
 	http.HandleFunc(makePath("/run"), AuthMiddleware(CSRFMiddleware(handleExecutePage)))
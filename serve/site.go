@@ -0,0 +1,344 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+	"github.com/direct-dev-ru/linux-command-gpt/serve/templates"
+)
+
+// siteMarker файл, которым lcg отмечает каталог со своей выгрузкой сайта:
+// такой каталог можно очищать при повторной выгрузке
+const siteMarker = ".lcg-site"
+
+// sitePaths файлы и каталоги выгрузки, которые удаляются перед повторной выгрузкой
+var sitePaths = []string{"index.html", "search-index.json", "page", "file", "history", "tags"}
+
+// SiteOptions настройки выгрузки статического сайта
+type SiteOptions struct {
+	Dir         string   // каталог сайта
+	BasePath    string   // путь, под которым сайт будет опубликован ("/kb"); пусто — корень
+	ExcludeTags []string // записи истории и результаты с этими тегами не выгружаются
+	SkipHistory bool     // без истории (--no-history): только результаты
+}
+
+// SiteReport итог выгрузки сайта
+type SiteReport struct {
+	Results int // страниц результатов
+	Entries int // страниц записей истории
+	Tags    int // страниц тегов
+	Skipped int // не выгружено по тегам
+}
+
+// siteDoc документ индекса поиска сайта
+type siteDoc struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Kind  string `json:"kind"`
+	Date  string `json:"date"`
+	Terms string `json:"terms"` // слова страницы через пробел (results.Terms)
+}
+
+// siteWriter собирает страницы сайта по шаблонам веб-интерфейса
+type siteWriter struct {
+	dir      string
+	basePath string
+	tmpl     map[string]*template.Template
+	docs     []siteDoc
+}
+
+// ExportSite выгружает результаты и историю в статический HTML-сайт: список
+// результатов по страницам, страницы результатов, историю, записи истории, теги
+// и индекс для поиска в браузере. Страницы строятся теми же шаблонами, что и в
+// lcg serve, но без управления (удаления, пометок, выполнения). Адреса страниц
+// оканчиваются на "/" и лежат в <адрес>/index.html, поэтому сайт открывается с
+// любого статического хостинга.
+func ExportSite(opts SiteOptions) (SiteReport, error) {
+	var report SiteReport
+	if err := prepareSiteDir(opts.Dir); err != nil {
+		return report, err
+	}
+	w := &siteWriter{dir: opts.Dir, basePath: siteBasePath(opts.BasePath), tmpl: map[string]*template.Template{}}
+	for name, text := range map[string]string{
+		"results":      templates.ResultsPageTemplate,
+		"file_view":    templates.FileViewTemplate,
+		"history":      templates.HistoryPageTemplate,
+		"history_view": templates.HistoryViewTemplate,
+	} {
+		t, err := template.New(name).Parse(text)
+		if err != nil {
+			return report, fmt.Errorf("шаблон %s: %v", name, err)
+		}
+		w.tmpl[name] = t
+	}
+	excluded := func(tags []string) bool {
+		for _, tag := range opts.ExcludeTags {
+			if (history.Entry{Tags: tags}).HasTag(tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// История
+	var entries []HistoryEntry
+	published, hidden := map[int]bool{}, map[int]bool{}
+	if _, err := os.Stat(config.AppConfig.ResultHistory); err == nil && !opts.SkipHistory {
+		store, err := historyStore()
+		if err != nil {
+			return report, err
+		}
+		all, err := store.List()
+		if err != nil {
+			return report, fmt.Errorf("история: %v", err)
+		}
+		for _, e := range all {
+			if excluded(e.Tags) {
+				hidden[e.Index] = true
+				report.Skipped++
+				continue
+			}
+			entries = append(entries, e)
+			published[e.Index] = true
+		}
+	}
+
+	// Результаты
+	var files []results.File
+	if _, err := os.Stat(config.AppConfig.ResultFolder); err == nil {
+		list, err := results.List(config.AppConfig.ResultFolder)
+		if err != nil {
+			return report, fmt.Errorf("результаты: %v", err)
+		}
+		for _, file := range list {
+			if excluded(file.Tags) || hidden[file.HistoryID] {
+				// Результат записи, скрытой тегом позже, тоже не выгружается
+				report.Skipped++
+				continue
+			}
+			if !published[file.HistoryID] {
+				// Ссылка на невыгруженную запись истории вела бы на отсутствующую страницу
+				file.HistoryID = 0
+			}
+			files = append(files, file)
+		}
+	}
+
+	for _, file := range files {
+		if err := w.writeResult(file); err != nil {
+			return report, err
+		}
+		report.Results++
+	}
+	if err := w.writeResultsIndex(files); err != nil {
+		return report, err
+	}
+
+	tags := map[string][]HistoryEntry{}
+	tagFiles := map[string][]results.File{}
+	for _, e := range entries {
+		if err := w.writeEntry(e); err != nil {
+			return report, err
+		}
+		report.Entries++
+		for _, tag := range e.Tags {
+			tags[tag] = append(tags[tag], e)
+		}
+	}
+	for _, file := range files {
+		for _, tag := range file.Tags {
+			tagFiles[tag] = append(tagFiles[tag], file)
+			if _, ok := tags[tag]; !ok {
+				tags[tag] = nil
+			}
+		}
+	}
+	var allTags []string
+	for tag := range tags {
+		if filepath.IsLocal(tag) {
+			allTags = append(allTags, tag)
+		}
+	}
+	sort.Strings(allTags)
+	if err := w.writeHistory(filepath.Join("history", "index.html"), entries, nil, "", allTags); err != nil {
+		return report, err
+	}
+	for _, tag := range allTags {
+		if err := w.writeHistory(filepath.Join("tags", tag, "index.html"), tags[tag], tagFiles[tag], tag, allTags); err != nil {
+			return report, err
+		}
+		report.Tags++
+	}
+
+	data, err := json.Marshal(w.docs)
+	if err != nil {
+		return report, err
+	}
+	if err := w.write("search-index.json", data); err != nil {
+		return report, err
+	}
+	return report, os.WriteFile(filepath.Join(opts.Dir, siteMarker), []byte("lcg export site\n"), 0644)
+}
+
+// prepareSiteDir создает каталог сайта или очищает прошлую выгрузку в нем.
+// Непустой каталог без отметки lcg не трогается.
+func prepareSiteDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return os.MkdirAll(dir, 0755)
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, siteMarker)); err != nil {
+		return fmt.Errorf("каталог %s не пуст и не является выгрузкой lcg (нет файла %s)", dir, siteMarker)
+	}
+	for _, name := range sitePaths {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// siteBasePath приводит путь публикации к виду "/kb" (корень — пустая строка)
+func siteBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// writeResult страница результата file/<имя>/
+func (w *siteWriter) writeResult(file results.File) error {
+	_, body, err := results.Read(config.AppConfig.ResultFolder, file.Name)
+	if err != nil {
+		return fmt.Errorf("результат %s: %v", file.Name, err)
+	}
+	data := newFileViewData(file.Name, file, body)
+	data.BasePath, data.Static = w.basePath, true
+	kind := resultKindLabels[file.Kind]
+	if kind == "" {
+		kind = "📄 Ответ"
+	}
+	w.docs = append(w.docs, siteDoc{
+		URL:   w.basePath + "/file/" + file.Name + "/",
+		Title: data.Title,
+		Kind:  kind,
+		Date:  file.Created.Format("02.01.2006 15:04"),
+		Terms: strings.Join(results.Terms(strings.Join(append([]string{file.Query, file.Model, string(body)}, file.Tags...), " ")), " "),
+	})
+	return w.render("file_view", filepath.Join("file", file.Name, "index.html"), data)
+}
+
+// writeResultsIndex список результатов: первая страница в корне сайта,
+// остальные — page/<N>/
+func (w *siteWriter) writeResultsIndex(files []results.File) error {
+	pages := max((len(files)+resultsPageSize-1)/resultsPageSize, 1)
+	recent := 0
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	for _, file := range files {
+		if file.Created.After(weekAgo) {
+			recent++
+		}
+	}
+	pageURL := func(page int) string {
+		if page == 1 {
+			return w.basePath + "/"
+		}
+		return w.basePath + "/page/" + strconv.Itoa(page) + "/"
+	}
+	for page := 1; page <= pages; page++ {
+		data := resultsPageData{
+			Files:           resultFiles(files[(page-1)*resultsPageSize : min(page*resultsPageSize, len(files))]),
+			TotalFiles:      len(files),
+			RecentFiles:     recent,
+			Found:           len(files),
+			Page:            page,
+			Pages:           pages,
+			BasePath:        w.basePath,
+			AppName:         config.AppConfig.AppName,
+			AppAbbreviation: generateAbbreviation(config.AppConfig.AppName),
+			Static:          true,
+		}
+		if page > 1 {
+			data.PrevURL = pageURL(page - 1)
+		}
+		if page < pages {
+			data.NextURL = pageURL(page + 1)
+		}
+		path := "index.html"
+		if page > 1 {
+			path = filepath.Join("page", strconv.Itoa(page), "index.html")
+		}
+		if err := w.render("results", path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntry страница записи истории history/view/<номер>/ с предпочтительной
+// или последней версией ответа
+func (w *siteWriter) writeEntry(e HistoryEntry) error {
+	data, err := newHistoryViewData(e, 0, 0)
+	if err != nil {
+		return fmt.Errorf("запись #%d: %v", e.Index, err)
+	}
+	data.BasePath, data.Static = w.basePath, true
+	w.docs = append(w.docs, siteDoc{
+		URL:   w.basePath + "/history/view/" + strconv.Itoa(e.Index) + "/",
+		Title: results.Title(data.Command),
+		Kind:  "📝 Запись истории #" + strconv.Itoa(e.Index),
+		Date:  e.Timestamp.Format("02.01.2006 15:04"),
+		Terms: strings.Join(results.Terms(strings.Join(append([]string{data.Command, data.Response, data.Meta.Explanation, data.Meta.Model}, e.Tags...), " ")), " "),
+	})
+	return w.render("history_view", filepath.Join("history", "view", strconv.Itoa(e.Index), "index.html"), data)
+}
+
+// writeHistory список записей истории: всех (tag пуст) или с тегом вместе
+// с результатами с этим тегом
+func (w *siteWriter) writeHistory(path string, entries []HistoryEntry, files []results.File, tag string, allTags []string) error {
+	return w.render("history", path, historyPageData{
+		Entries:  historyEntryInfos(entries),
+		Results:  resultFiles(files),
+		BasePath: w.basePath,
+		AppName:  config.AppConfig.AppName,
+		Filtered: tag != "",
+		Tag:      tag,
+		Static:   true,
+		AllTags:  allTags,
+	})
+}
+
+// render выполняет шаблон и записывает страницу
+func (w *siteWriter) render(name, path string, data any) error {
+	var buf strings.Builder
+	if err := w.tmpl[name].Execute(&buf, data); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return w.write(path, []byte(buf.String()))
+}
+
+// write записывает файл сайта открыто: сайт предназначен для публикации
+func (w *siteWriter) write(path string, data []byte) error {
+	path = filepath.Join(w.dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -89,6 +89,7 @@ var ExecutePageScriptsTemplate = template.Must(template.New("execute_scripts").P
     function saveResult() {
         const resultDataField = document.getElementById('resultData');
         const prompt = document.getElementById('prompt').value;
+        const systemId = document.getElementById('system_id').value;
         const csrfToken = document.querySelector('input[name="csrf_token"]').value;
         
         if (!resultDataField.value || !prompt.trim()) {
@@ -102,7 +103,8 @@ var ExecutePageScriptsTemplate = template.Must(template.New("execute_scripts").P
                 prompt: prompt,
                 command: resultData.command,
                 explanation: resultData.explanation || '',
-                model: resultData.model || 'Unknown'
+                model: resultData.model || 'Unknown',
+                prompt_id: parseInt(systemId, 10) || 0
             };
             
             fetch('{{.BasePath}}/api/save-result', {
@@ -152,7 +154,11 @@ var ExecutePageScriptsTemplate = template.Must(template.New("execute_scripts").P
                 command: resultData.command,
                 response: resultData.command,
                 explanation: resultData.explanation || '',
-                system: systemName
+                system: systemName,
+                prompt_id: parseInt(systemId, 10) || 0,
+                model: resultData.model || '',
+                verbose: resultData.verbose || '',
+                elapsed: resultData.elapsed || 0
             };
             
             fetch('{{.BasePath}}/api/add-to-history', {
@@ -267,6 +273,7 @@ var ExecutePageScriptsTemplate = template.Must(template.New("execute_scripts").P
         const commandElement = document.querySelector('.command-code, .command-md');
         const explanationElement = document.querySelector('.explanation-content');
         const modelElement = document.querySelector('.result-meta span:first-child');
+        const metaElement = document.querySelector('.result-meta');
         
         if (commandElement) {
             const command = commandElement.textContent.trim();
@@ -276,7 +283,9 @@ var ExecutePageScriptsTemplate = template.Must(template.New("execute_scripts").P
             const resultData = {
                 command: command,
                 explanation: explanation,
-                model: model
+                model: model,
+                elapsed: metaElement ? parseFloat(metaElement.dataset.elapsed) || 0 : 0,
+                verbose: metaElement ? metaElement.dataset.verbose || '' : ''
             };
             
             resultDataField.value = JSON.stringify(resultData);
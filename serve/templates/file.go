@@ -7,7 +7,7 @@ const FileViewTemplate = `
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>{{.Filename}} - LCG Results</title>
+    <title>{{.Title}} - LCG Results</title>
     <style>
         body {
             font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
@@ -54,6 +54,29 @@ const FileViewTemplate = `
             padding: 30px;
             line-height: 1.6;
         }
+        .result-meta {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 8px 16px;
+            color: #666;
+            font-size: 0.9em;
+            padding-bottom: 15px;
+            border-bottom: 1px solid #e0e0e0;
+        }
+        .result-meta a {
+            color: #2d5016;
+        }
+        .result-tag {
+            padding: 0 8px;
+            border-radius: 10px;
+            background: #e8f5e9;
+            color: #2d5016;
+        }
+        .result-file {
+            font-family: 'Monaco', 'Menlo', monospace;
+            font-size: 0.85em;
+            color: #999;
+        }
         .content h1 {
             color: #2d5016;
             border-bottom: 2px solid #2d5016;
@@ -125,10 +148,18 @@ const FileViewTemplate = `
 <body>
     <div class="container">
         <div class="header">
-            <h1>📄 {{.Filename}}</h1>
+            <h1>📄 {{if .Kind}}{{.Kind}}: {{end}}{{.Title}}</h1>
             <a href="{{.BasePath}}/" class="back-btn">← Назад к списку</a>
         </div>
         <div class="content">
+            <div class="result-meta">
+                <span>📅 {{.Meta.Created.Format "02.01.2006 15:04:05"}}</span>
+                {{if .Meta.Model}}<span>🧠 {{if .Meta.Provider}}{{.Meta.Provider}} / {{end}}{{.Meta.Model}}</span>{{end}}
+                {{if .Meta.PromptID}}<span>⚙️ промпт #{{.Meta.PromptID}}</span>{{end}}
+                {{if .Meta.HistoryID}}<span><a href="{{.BasePath}}/history/view/{{.Meta.HistoryID}}{{if .Static}}/{{end}}">📝 запись истории #{{.Meta.HistoryID}}</a></span>{{end}}
+                {{range .Meta.Tags}}{{if $.Static}}<a class="result-tag" href="{{$.BasePath}}/tags/{{.}}/">#{{.}}</a>{{else}}<span class="result-tag">#{{.}}</span>{{end}}{{end}}
+                <span class="result-file">{{.Filename}}</span>
+            </div>
             {{.Content}}
         </div>
     </div>
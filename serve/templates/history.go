@@ -132,6 +132,68 @@ const HistoryPageTemplate = `
             color: rgb(171, 27, 24); /* ярче при ховере */
             transform: translateY(-1px);
         }
+        .history-marks {
+            display: flex;
+            gap: 6px;
+            flex-wrap: wrap;
+            margin-bottom: 8px;
+        }
+        .mark-star {
+            color: #f1c40f;
+            font-size: 1.1em;
+        }
+        .mark-tag {
+            background: #e8f5e9;
+            color: #2d5016;
+            padding: 2px 8px;
+            border-radius: 10px;
+            font-size: 0.85em;
+            text-decoration: none;
+        }
+        .mark-tag:hover {
+            background: #c8e6c9;
+        }
+        .history-meta {
+            display: flex;
+            gap: 6px;
+            flex-wrap: wrap;
+            margin-bottom: 8px;
+            font-size: 0.8em;
+        }
+        .meta-link {
+            color: #666;
+            border: 1px solid #ddd;
+            padding: 1px 8px;
+            border-radius: 10px;
+            text-decoration: none;
+        }
+        .meta-link:hover {
+            border-color: #a8e6cf;
+            color: #2d5016;
+        }
+        .filter-bar {
+            display: flex;
+            gap: 8px;
+            flex-wrap: wrap;
+            align-items: center;
+            margin-bottom: 10px;
+        }
+        .filter-link {
+            padding: 6px 12px;
+            border: 1px solid #a8e6cf;
+            border-radius: 16px;
+            color: #2d5016;
+            text-decoration: none;
+            font-size: 0.9em;
+        }
+        .filter-link.active {
+            background: #2d5016;
+            color: white;
+        }
+        .export-label {
+            color: #666;
+            font-size: 0.9em;
+        }
         .empty-state {
             text-align: center;
             padding: 60px 20px;
@@ -167,11 +229,42 @@ const HistoryPageTemplate = `
         <div class="content">
             <div class="nav-buttons">
                 <a href="{{.BasePath}}/" class="nav-btn">🏠 Главная</a>
+                {{if not .Static}}
                 <a href="{{.BasePath}}/run" class="nav-btn">🚀 Выполнение</a>
                 <a href="{{.BasePath}}/prompts" class="nav-btn">⚙️ Промпты</a>
                 <button class="nav-btn clear-btn" onclick="clearHistory()">🗑️ Очистить всю историю</button>
+                {{end}}
             </div>
             
+            {{if .Static}}
+            <!-- Страницы тегов -->
+            <div class="filter-bar">
+                <a href="{{.BasePath}}/history/" class="filter-link{{if not .Tag}} active{{end}}">Все</a>
+                {{range .AllTags}}<a href="{{$.BasePath}}/tags/{{.}}/" class="filter-link{{if eq . $.Tag}} active{{end}}">#{{.}}</a>
+                {{end}}
+            </div>
+            {{else}}
+            <!-- Фильтры по пометкам -->
+            <div class="filter-bar">
+                <a href="{{.BasePath}}/history" class="filter-link{{if not .Filtered}} active{{end}}">Все</a>
+                <a href="{{.BasePath}}/history?starred=1" class="filter-link{{if .Starred}} active{{end}}">★ Избранное</a>
+                <a href="{{.BasePath}}/history?rating=good" class="filter-link{{if eq .Rating "good"}} active{{end}}">👍 Хорошие</a>
+                <a href="{{.BasePath}}/history?rating=bad" class="filter-link{{if eq .Rating "bad"}} active{{end}}">👎 Плохие</a>
+                {{if .Tag}}<span class="filter-link active">#{{.Tag}}</span>{{end}}
+                {{if .Provider}}<span class="filter-link active">{{.Provider}}</span>{{end}}
+                {{if .Model}}<span class="filter-link active">{{.Model}}</span>{{end}}
+                {{if .PromptID}}<span class="filter-link active">промпт #{{.PromptID}}</span>{{end}}
+                {{if .Host}}<span class="filter-link active">🖥 {{.Host}}</span>{{end}}
+            </div>
+
+            <!-- Экспорт с учетом текущего фильтра -->
+            <div class="filter-bar">
+                <span class="export-label">⬇️ Экспорт:</span>
+                {{range .ExportFormats}}<a href="{{$.BasePath}}/history/export?format={{.}}{{if $.Tag}}&tag={{$.Tag}}{{end}}{{if $.Starred}}&starred=1{{end}}{{if $.Rating}}&rating={{$.Rating}}{{end}}{{if $.Provider}}&provider={{$.Provider}}{{end}}{{if $.Model}}&model={{$.Model}}{{end}}{{if $.PromptID}}&prompt_id={{$.PromptID}}{{end}}{{if $.Host}}&host={{$.Host}}{{end}}" class="filter-link">{{.}}</a>
+                {{end}}
+            </div>
+            {{end}}
+
             <!-- Поиск -->
             <div class="search-container" style="margin: 20px 0;">
                 <input type="text" id="searchInput" placeholder="🔍 Поиск по командам, ответам и объяснениям..." 
@@ -179,6 +272,22 @@ const HistoryPageTemplate = `
                 <div id="searchResults" style="margin-top: 10px; color: #666; font-size: 14px;"></div>
             </div>
 
+            {{range .Results}}
+            <div class="history-item" onclick="window.location.href = '{{$.BasePath}}/file/{{.Name}}/'">
+                <div class="history-header">
+                    <div>
+                        <span class="history-index">{{if .Kind}}{{.Kind}}{{else}}📄 Ответ{{end}}</span>
+                        <span class="history-timestamp">{{.ModTime}}</span>
+                    </div>
+                </div>
+                <div class="history-marks">
+                    {{range .Tags}}<a class="mark-tag" href="{{$.BasePath}}/tags/{{.}}/" onclick="event.stopPropagation()">#{{.}}</a>{{end}}
+                </div>
+                <div class="history-command">{{.DisplayName}}</div>
+                {{if .Preview}}<div class="history-response">{{.Preview}}</div>{{end}}
+            </div>
+            {{end}}
+
             {{if .Entries}}
             {{range .Entries}}
             <div class="history-item" onclick="viewHistoryEntry({{.Index}})">
@@ -186,14 +295,35 @@ const HistoryPageTemplate = `
                     <div>
                         <span class="history-index">#{{.Index}}</span>
                         <span class="history-timestamp">{{.Timestamp}}</span>
+                        {{if .Versions}}<span class="history-timestamp" title="Версий ответа">📚 {{.Versions}}</span>{{end}}
                     </div>
-                    <button class="delete-btn" onclick="event.stopPropagation(); deleteHistoryEntry({{.Index}})">✖</button>
+                    {{if not $.Static}}<button class="delete-btn" onclick="event.stopPropagation(); deleteHistoryEntry({{.Index}})">✖</button>{{end}}
+                </div>
+                {{if or .Starred .Rating .Tags}}
+                <div class="history-marks">
+                    {{if .Starred}}<span class="mark-star" title="Избранное">★</span>{{end}}
+                    {{if eq .Rating "good"}}<span title="Хороший ответ">👍</span>{{end}}
+                    {{if eq .Rating "bad"}}<span title="Плохой ответ">👎</span>{{end}}
+                    {{range .Tags}}<a class="mark-tag" href="{{if $.Static}}{{$.BasePath}}/tags/{{.}}/{{else}}{{$.BasePath}}/history?tag={{.}}{{end}}" onclick="event.stopPropagation()">#{{.}}</a>{{end}}
                 </div>
+                {{end}}
+                {{if .Details}}
+                {{if $.Static}}
+                <div class="history-meta" title="{{.Details}}">{{.Details}}</div>
+                {{else}}
+                <div class="history-meta" title="{{.Details}}">
+                    {{if .Provider}}<a class="meta-link" href="{{$.BasePath}}/history?provider={{.Provider}}" onclick="event.stopPropagation()">{{.Provider}}</a>{{end}}
+                    {{if .Model}}<a class="meta-link" href="{{$.BasePath}}/history?model={{.Model}}" onclick="event.stopPropagation()">{{.Model}}</a>{{end}}
+                    {{if .PromptID}}<a class="meta-link" href="{{$.BasePath}}/history?prompt_id={{.PromptID}}" onclick="event.stopPropagation()">промпт #{{.PromptID}}</a>{{end}}
+                    {{if .Host}}<a class="meta-link" href="{{$.BasePath}}/history?host={{.Host}}" onclick="event.stopPropagation()">🖥 {{.Host}}</a>{{end}}
+                </div>
+                {{end}}
+                {{end}}
                 <div class="history-command">{{.Command}}</div>
                 <div class="history-response">{{.Response}}</div>
             </div>
             {{end}}
-            {{else}}
+            {{else if not .Results}}
             <div class="empty-state">
                 <h3>📝 История пуста</h3>
                 <p>Здесь будут отображаться запросы после использования команды lcg</p>
@@ -204,9 +334,9 @@ const HistoryPageTemplate = `
     
     <script>
         function viewHistoryEntry(index) {
-            window.location.href = '{{.BasePath}}/history/view/' + index;
+            window.location.href = '{{.BasePath}}/history/view/' + index{{if .Static}} + '/'{{end}};
         }
-        
+        {{if not .Static}}
         function deleteHistoryEntry(index) {
             if (confirm('Вы уверены, что хотите удалить запись #' + index + '?')) {
                 fetch('{{.BasePath}}/history/delete/' + index, {
@@ -244,6 +374,7 @@ const HistoryPageTemplate = `
                 });
             }
         }
+        {{end}}
         
         // Поиск по истории
         function performSearch() {
@@ -69,6 +69,35 @@ const HistoryViewTemplate = `
             font-weight: 600;
             color: #2d5016;
         }
+        .versions {
+            display: flex;
+            gap: 8px;
+            flex-wrap: wrap;
+            align-items: center;
+            margin-bottom: 20px;
+        }
+        .version-link {
+            padding: 4px 12px;
+            border: 1px solid #a8e6cf;
+            border-radius: 14px;
+            color: #2d5016;
+            text-decoration: none;
+        }
+        .version-link.active {
+            background: #2d5016;
+            color: white;
+        }
+        .history-diff pre {
+            background: #f8f9fa;
+            padding: 15px;
+            border-radius: 6px;
+            overflow-x: auto;
+            font-size: 0.9em;
+        }
+        .diff-add { color: #2e7d32; }
+        .diff-del { color: #c62828; }
+        .diff-hunk { color: #1565c0; }
+        .diff-file { font-weight: bold; }
         .history-command {
             background: #f8f9fa;
             padding: 15px;
@@ -202,6 +231,42 @@ const HistoryViewTemplate = `
         .delete-btn:hover {
             background: #c0392b;
         }
+        .marks {
+            background: #f8f9fa;
+            border: 1px solid #e9ecef;
+            border-radius: 8px;
+            padding: 15px;
+            margin-bottom: 20px;
+            display: flex;
+            flex-direction: column;
+            gap: 10px;
+        }
+        .marks-row {
+            display: flex;
+            gap: 8px;
+            flex-wrap: wrap;
+            align-items: center;
+        }
+        .marks input[type="text"] {
+            flex: 1;
+            min-width: 200px;
+            padding: 8px;
+            border: 1px solid #ddd;
+            border-radius: 6px;
+        }
+        .mark-btn {
+            background: white;
+            border: 1px solid #a8e6cf;
+            color: #2d5016;
+            padding: 8px 14px;
+            border-radius: 6px;
+            cursor: pointer;
+            text-decoration: none;
+        }
+        .mark-btn.active {
+            background: #2d5016;
+            color: white;
+        }
         
         /* Мобильная адаптация */
         @media (max-width: 768px) {
@@ -224,18 +289,105 @@ const HistoryViewTemplate = `
     <div class="container">
         <div class="header">
             <h1>📝 Запись #{{.Index}}</h1>
-            <a href="{{.BasePath}}/history" class="back-btn">← Назад к истории</a>
+            <a href="{{.BasePath}}/history{{if .Static}}/{{end}}" class="back-btn">← Назад к истории</a>
         </div>
         <div class="content">
             <div class="history-meta">
                 <div class="history-meta-item">
                     <span class="history-meta-label">📅 Время:</span> {{.Timestamp}}
                 </div>
+                {{if .VersionTime}}
+                <div class="history-meta-item">
+                    <span class="history-meta-label">🕘 Версия {{.Version}}:</span> {{.VersionTime}}
+                </div>
+                {{end}}
                 <div class="history-meta-item">
                     <span class="history-meta-label">🔢 Индекс:</span> #{{.Index}}
                 </div>
+                {{with .Meta}}
+                {{if or .Provider .Model}}
+                <div class="history-meta-item">
+                    <span class="history-meta-label">🤖 Модель:</span> {{if .Provider}}{{.Provider}} / {{end}}{{.Model}}
+                </div>
+                {{end}}
+                {{if .PromptID}}
+                <div class="history-meta-item">
+                    <span class="history-meta-label">⚙️ Промпт:</span> {{if $.Static}}#{{.PromptID}}{{if .PromptVersion}} v{{.PromptVersion}}{{end}}{{else}}<a class="version-link" href="{{$.BasePath}}/prompts/versions/{{.PromptID}}{{if .PromptVersion}}?version={{.PromptVersion}}{{end}}">#{{.PromptID}}{{if .PromptVersion}} v{{.PromptVersion}}{{end}}</a>{{end}}
+                </div>
+                {{end}}
+                {{if .Verbosity}}
+                <div class="history-meta-item">
+                    <span class="history-meta-label">📖 Объяснение:</span> {{.Verbosity}}
+                </div>
+                {{end}}
+                {{if .Elapsed}}
+                <div class="history-meta-item">
+                    <span class="history-meta-label">⏱ Ответ:</span> {{printf "%.2f" .Elapsed}} сек
+                </div>
+                {{end}}
+                {{if .Host}}
+                <div class="history-meta-item">
+                    <span class="history-meta-label">🖥 Хост:</span> {{.Host}}{{if .Cwd}}:{{.Cwd}}{{end}}
+                </div>
+                {{end}}
+                {{end}}
             </div>
             
+            {{if .Static}}
+            {{if or .Starred .Rating .TagList}}
+            <div class="marks">
+                <div class="marks-row">
+                    {{if .Starred}}<span class="mark-btn active">★ В избранном</span>{{end}}
+                    {{if eq .Rating "good"}}<span class="mark-btn active">👍 Хороший ответ</span>{{end}}
+                    {{if eq .Rating "bad"}}<span class="mark-btn active">👎 Плохой ответ</span>{{end}}
+                    {{if .RatingNote}}<span>{{.RatingNote}}</span>{{end}}
+                    {{range .TagList}}<a class="mark-btn" href="{{$.BasePath}}/tags/{{.}}/">#{{.}}</a>{{end}}
+                </div>
+            </div>
+            {{end}}
+            {{else}}
+            <div class="marks">
+                <div class="marks-row">
+                    <button class="mark-btn{{if .Starred}} active{{end}}" onclick="annotate({starred: {{if .Starred}}false{{else}}true{{end}}})">★ {{if .Starred}}В избранном{{else}}В избранное{{end}}</button>
+                    <button class="mark-btn{{if eq .Rating "good"}} active{{end}}" onclick="rate('good')">👍 Хороший ответ</button>
+                    <button class="mark-btn{{if eq .Rating "bad"}} active{{end}}" onclick="rate('bad')">👎 Плохой ответ</button>
+                    {{if .Rating}}<button class="mark-btn" onclick="annotate({rating: '', note: ''})">Снять оценку</button>{{end}}
+                </div>
+                <div class="marks-row">
+                    <input type="text" id="ratingNote" placeholder="Комментарий к оценке" value="{{.RatingNote}}">
+                </div>
+                <div class="marks-row">
+                    <input type="text" id="tagsInput" placeholder="Теги через пробел или запятую" value="{{.Tags}}">
+                    <button class="mark-btn" onclick="saveTags()">🏷 Сохранить теги</button>
+                </div>
+            </div>
+            {{end}}
+
+            {{if and .Versions (not .Static)}}
+            <div class="versions">
+                <span class="history-meta-label">📚 Версии ответа:</span>
+                {{range .Versions}}<a class="version-link{{if .Current}} active{{end}}" href="{{$.BasePath}}/history/view/{{$.Index}}?version={{.N}}" title="{{.Timestamp}}">{{.N}}{{if .Preferred}} ★{{end}}</a>
+                {{end}}
+                {{if eq .Preferred .Version}}
+                <button class="mark-btn" onclick="annotate({preferred: 0})">Снять отметку предпочтительной</button>
+                {{else}}
+                <button class="mark-btn" onclick="annotate({preferred: {{.Version}}})">★ Сделать предпочтительной</button>
+                {{end}}
+                <select onchange="if (this.value) location.href = '{{.BasePath}}/history/view/{{.Index}}?version={{.Version}}&diff=' + this.value">
+                    <option value="">🔀 Сравнить с версией…</option>
+                    {{range .Versions}}{{if not .Current}}<option value="{{.N}}"{{if eq .N $.DiffFrom}} selected{{end}}>{{.N}}</option>{{end}}
+                    {{end}}
+                </select>
+            </div>
+            {{end}}
+
+            {{if .Diff}}
+            <div class="history-diff">
+                <h3>🔀 Изменения: версия {{.DiffFrom}} → версия {{.Version}}</h3>
+                <pre>{{range .Diff}}<span class="{{.Class}}">{{.Text}}</span>{{end}}</pre>
+            </div>
+            {{end}}
+
             <div class="history-command">
                 <h3>💬 Запрос пользователя:</h3>
                 <div class="history-command-text">{{.Command}}</div>
@@ -249,13 +401,42 @@ const HistoryViewTemplate = `
             {{.ExplanationHTML}}
             
             <div class="actions">
-                <a href="{{.BasePath}}/history" class="action-btn">📝 К истории</a>
-                <button class="action-btn delete-btn" onclick="deleteHistoryEntry({{.Index}})">🗑️ Удалить запись</button>
+                <a href="{{.BasePath}}/history{{if .Static}}/{{end}}" class="action-btn">📝 К истории</a>
+                {{if not .Static}}<button class="action-btn delete-btn" onclick="deleteHistoryEntry({{.Index}})">🗑️ Удалить запись</button>{{end}}
             </div>
         </div>
     </div>
     
+    {{if not .Static}}
     <script>
+        function annotate(changes) {
+            fetch('{{.BasePath}}/history/annotate/{{.Index}}', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': '{{.CSRFToken}}' },
+                body: JSON.stringify(changes)
+            })
+            .then(response => {
+                if (response.ok) {
+                    location.reload();
+                } else {
+                    response.text().then(text => alert('Ошибка сохранения: ' + text));
+                }
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                alert('Ошибка сохранения');
+            });
+        }
+
+        function rate(rating) {
+            annotate({rating: rating, note: document.getElementById('ratingNote').value});
+        }
+
+        function saveTags() {
+            const tags = document.getElementById('tagsInput').value.split(/[\s,]+/).filter(t => t !== '');
+            annotate({tags: tags});
+        }
+
         function deleteHistoryEntry(index) {
             if (confirm('Вы уверены, что хотите удалить запись #' + index + '?')) {
                 fetch('{{.BasePath}}/history/delete/' + index, {
@@ -275,5 +456,6 @@ const HistoryViewTemplate = `
             }
         }
     </script>
+    {{end}}
 </body>
 </html>`
@@ -0,0 +1,238 @@
+package templates
+
+// PromptVersionsTemplate шаблон страницы версий системного промпта
+const PromptVersionsTemplate = `
+<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Версии промпта #{{.ID}} - LCG Results</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background: linear-gradient(135deg, #56ab2f 0%, #a8e6cf 100%);
+            min-height: 100vh;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 0 auto;
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 20px 40px rgba(0,0,0,0.1);
+            overflow: hidden;
+        }
+        .header {
+            background: linear-gradient(135deg, #2d5016 0%, #4a7c59 100%);
+            color: white;
+            padding: 30px;
+            text-align: center;
+        }
+        .header h1 {
+            margin: 0;
+            font-size: 2.5em;
+            font-weight: 300;
+        }
+        .content {
+            padding: 30px;
+        }
+        .nav-buttons {
+            display: flex;
+            gap: 10px;
+            margin-bottom: 20px;
+            flex-wrap: wrap;
+        }
+        .nav-btn {
+            background: #3498db;
+            color: white;
+            border: none;
+            padding: 12px 24px;
+            border-radius: 6px;
+            cursor: pointer;
+            font-size: 1em;
+            text-decoration: none;
+            transition: background 0.3s ease;
+            display: inline-block;
+            text-align: center;
+        }
+        .nav-btn:hover {
+            background: #2980b9;
+        }
+        .versions-table {
+            width: 100%;
+            border-collapse: collapse;
+            margin-bottom: 20px;
+        }
+        .versions-table th, .versions-table td {
+            text-align: left;
+            padding: 8px 10px;
+            border-bottom: 1px solid #e0efe0;
+            font-size: 0.9em;
+        }
+        .versions-table th {
+            color: #2d5016;
+        }
+        .versions-table tr.selected {
+            background: #f0f8f0;
+        }
+        .version-link {
+            padding: 4px 12px;
+            border: 1px solid #a8e6cf;
+            border-radius: 14px;
+            color: #2d5016;
+            text-decoration: none;
+        }
+        .version-link.active {
+            background: #2d5016;
+            color: white;
+        }
+        .current-badge {
+            background: #28a745;
+            color: white;
+            padding: 2px 6px;
+            border-radius: 3px;
+            font-size: 0.8em;
+        }
+        .deleted {
+            color: #c62828;
+        }
+        .note {
+            color: #666;
+        }
+        .action-btn {
+            background: #4a7c59;
+            color: white;
+            border: none;
+            padding: 6px 12px;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 0.8em;
+            transition: background 0.3s ease;
+        }
+        .action-btn:hover {
+            background: #2d5016;
+        }
+        .prompt-version {
+            background: #f0f8f0;
+            border: 1px solid #a8e6cf;
+            border-radius: 8px;
+            padding: 20px;
+            margin-bottom: 20px;
+        }
+        .prompt-version h3, .history-diff h3 {
+            margin: 0 0 10px 0;
+            color: #2d5016;
+        }
+        .prompt-description {
+            color: #666;
+            margin-bottom: 10px;
+        }
+        .prompt-content {
+            background: #f8f9fa;
+            padding: 15px;
+            border-radius: 4px;
+            font-family: 'Monaco', 'Menlo', monospace;
+            font-size: 0.9em;
+            color: #2d5016;
+            border-left: 3px solid #2d5016;
+            white-space: pre-wrap;
+        }
+        .diff-select {
+            margin-bottom: 20px;
+        }
+        .history-diff pre {
+            background: #f8f9fa;
+            padding: 15px;
+            border-radius: 6px;
+            overflow-x: auto;
+            font-size: 0.9em;
+        }
+        .diff-add { color: #2e7d32; }
+        .diff-del { color: #c62828; }
+        .diff-hunk { color: #1565c0; }
+        .diff-file { font-weight: bold; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>🕘 Версии промпта #{{.ID}}</h1>
+        </div>
+        <div class="content">
+            <div class="nav-buttons">
+                <a href="{{.BasePath}}/" class="nav-btn">🏠 Главная</a>
+                <a href="{{.BasePath}}/prompts" class="nav-btn">⚙️ Промпты</a>
+                <a href="{{.BasePath}}/history" class="nav-btn">📝 История</a>
+            </div>
+
+            <table class="versions-table">
+                <tr><th>Версия</th><th>Время</th><th>Автор</th><th>Название</th><th>Изменения</th><th></th></tr>
+                {{range .Versions}}
+                <tr{{if .Selected}} class="selected"{{end}}>
+                    <td><a class="version-link{{if .Selected}} active{{end}}" href="{{$.BasePath}}/prompts/versions/{{$.ID}}?version={{.N}}">v{{.N}}</a>{{if .Current}} <span class="current-badge">текущая</span>{{end}}</td>
+                    <td>{{.Timestamp}}</td>
+                    <td>{{.Author}}</td>
+                    <td>{{.Name}}</td>
+                    <td{{if .Deleted}} class="deleted"{{end}}>{{.Change}}{{if .Note}} <span class="note">({{.Note}})</span>{{end}}</td>
+                    <td>{{if and (not .Current) (not .Deleted)}}<button class="action-btn" onclick="rollbackPrompt({{.N}})">↩️ Откатить</button>{{end}}</td>
+                </tr>
+                {{end}}
+            </table>
+
+            <div class="prompt-version">
+                <h3>Версия {{.Shown.Version}}{{if .Shown.Deleted}} — промпт удален{{end}}</h3>
+                {{if not .Shown.Deleted}}
+                <div><strong>{{.Shown.Name}}</strong></div>
+                <div class="prompt-description">{{.Shown.Description}}</div>
+                <div class="prompt-content">{{.Shown.Content}}</div>
+                {{end}}
+            </div>
+
+            {{if gt (len .Versions) 1}}
+            <div class="diff-select">
+                <select onchange="if (this.value) location.href = '{{.BasePath}}/prompts/versions/{{.ID}}?version={{.Shown.Version}}&diff=' + this.value">
+                    <option value="">🔀 Сравнить с версией…</option>
+                    {{range .Versions}}{{if not .Selected}}<option value="{{.N}}"{{if eq .N $.DiffFrom}} selected{{end}}>v{{.N}}</option>{{end}}
+                    {{end}}
+                </select>
+            </div>
+            {{end}}
+
+            {{if .DiffFrom}}
+            <div class="history-diff">
+                <h3>🔀 Изменения: версия {{.DiffFrom}} → версия {{.Shown.Version}}</h3>
+                <pre>{{range .Diff}}<span class="{{.Class}}">{{.Text}}</span>{{end}}</pre>
+            </div>
+            {{end}}
+        </div>
+    </div>
+
+    <script>
+        function rollbackPrompt(version) {
+            if (!confirm('Откатить промпт #{{.ID}} к версии ' + version + '?')) {
+                return;
+            }
+            fetch('{{.BasePath}}/prompts/rollback/{{.ID}}', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': '{{.CSRFToken}}',
+                },
+                body: JSON.stringify({version: version})
+            })
+            .then(response => response.text().then(text => {
+                if (!response.ok) {
+                    throw new Error(text);
+                }
+                location.href = '{{.BasePath}}/prompts/versions/{{.ID}}';
+            }))
+            .catch(error => {
+                alert(error.message);
+            });
+        }
+    </script>
+</body>
+</html>
+`
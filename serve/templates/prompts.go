@@ -124,6 +124,7 @@ const PromptsPageTemplate = `
             border-radius: 4px;
             cursor: pointer;
             font-size: 0.8em;
+            text-decoration: none;
             transition: background 0.3s ease;
         }
         .action-btn:hover {
@@ -243,6 +244,7 @@ const PromptsPageTemplate = `
                 <a href="{{.BasePath}}/run" class="nav-btn">🚀 Выполнение</a>
                 <a href="{{.BasePath}}/history" class="nav-btn">📝 История</a>
                 <button class="nav-btn add-btn" onclick="showAddForm()">➕ Добавить промпт</button>
+                <button class="nav-btn" onclick="showTransferForm()">📦 Импорт / экспорт</button>
                 <div class="lang-switcher">
                     <button class="lang-btn {{if eq .Lang "ru"}}active{{end}}" onclick="switchLang('ru')">🇷🇺 RU</button>
                     <button class="lang-btn {{if eq .Lang "en"}}active{{end}}" onclick="switchLang('en')">🇺🇸 EN</button>
@@ -262,6 +264,7 @@ const PromptsPageTemplate = `
                 <div class="prompt-item">
                     <div class="prompt-actions">
                         <button class="action-btn" onclick="editPrompt({{.ID}}, '{{.Name}}', '{{.Description}}', '{{.Content}}')">✏️</button>
+                        <a class="action-btn" href="{{$.BasePath}}/prompts/versions/{{.ID}}" title="Версии промпта">🕘</a>
                         <button class="action-btn restore-btn" onclick="restorePrompt({{.ID}})" title="Восстановить к значению по умолчанию">🔄</button>
                         <button class="action-btn delete-btn" onclick="deletePrompt({{.ID}})">🗑️</button>
                     </div>
@@ -340,7 +343,90 @@ const PromptsPageTemplate = `
         </div>
     </div>
     
+    <!-- Форма импорта и экспорта -->
+    <div id="transferForm" style="display: none; position: fixed; top: 0; left: 0; width: 100%; height: 100%; background: rgba(0,0,0,0.5); z-index: 1000;">
+        <div style="position: absolute; top: 50%; left: 50%; transform: translate(-50%, -50%); background: white; padding: 30px; border-radius: 12px; max-width: 600px; width: 90%;">
+            <h3>📤 Экспорт</h3>
+            <div style="margin-bottom: 15px;">
+                <label style="display: block; margin-bottom: 5px; font-weight: 600;">ID промптов через запятую (пусто — все):</label>
+                <input type="text" id="exportIds" placeholder="1, 9" style="width: 100%; padding: 8px; border: 1px solid #ddd; border-radius: 4px;">
+            </div>
+            <div style="margin-bottom: 25px; text-align: right;">
+                <button type="button" onclick="exportPrompts('yaml')" style="background: #3498db; color: white; border: none; padding: 8px 16px; border-radius: 4px; margin-right: 10px; cursor: pointer;">Скачать YAML</button>
+                <button type="button" onclick="exportPrompts('json')" style="background: #3498db; color: white; border: none; padding: 8px 16px; border-radius: 4px; cursor: pointer;">Скачать JSON</button>
+            </div>
+            <h3>📥 Импорт</h3>
+            <div style="margin-bottom: 15px;">
+                <label style="display: block; margin-bottom: 5px; font-weight: 600;">Файл (yaml или json):</label>
+                <input type="file" id="importFile" accept=".yaml,.yml,.json" style="width: 100%;">
+            </div>
+            <div style="margin-bottom: 20px;">
+                <label style="display: block; margin-bottom: 5px; font-weight: 600;">Если промпт с таким названием уже есть:</label>
+                <select id="importStrategy" style="width: 100%; padding: 8px; border: 1px solid #ddd; border-radius: 4px;">
+                    <option value="rename">добавить под новым названием</option>
+                    <option value="overwrite">заменить имеющийся</option>
+                    <option value="skip">пропустить</option>
+                </select>
+            </div>
+            <div style="text-align: right;">
+                <button type="button" onclick="hideTransferForm()" style="background: #6c757d; color: white; border: none; padding: 8px 16px; border-radius: 4px; margin-right: 10px; cursor: pointer;">Закрыть</button>
+                <button type="button" onclick="importPrompts()" style="background: #2d5016; color: white; border: none; padding: 8px 16px; border-radius: 4px; cursor: pointer;">Импортировать</button>
+            </div>
+        </div>
+    </div>
+    
     <script>
+        function showTransferForm() {
+            document.getElementById('transferForm').style.display = 'block';
+        }
+        
+        function hideTransferForm() {
+            document.getElementById('transferForm').style.display = 'none';
+        }
+        
+        function exportPrompts(format) {
+            const ids = document.getElementById('exportIds').value.replace(/\s+/g, '');
+            let url = '{{.BasePath}}/prompts/export?format=' + format;
+            if (ids) {
+                url += '&ids=' + encodeURIComponent(ids);
+            }
+            window.location.href = url;
+        }
+        
+        function importPrompts() {
+            const file = document.getElementById('importFile').files[0];
+            if (!file) {
+                alert('Выберите файл для импорта');
+                return;
+            }
+            file.text()
+            .then(content => fetch('{{.BasePath}}/prompts/import', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-CSRF-Token': '{{.CSRFToken}}',
+                },
+                body: JSON.stringify({
+                    content: content,
+                    strategy: document.getElementById('importStrategy').value
+                })
+            }))
+            .then(response => {
+                if (!response.ok) {
+                    return response.text().then(text => { throw new Error(text); });
+                }
+                return response.json();
+            })
+            .then(data => {
+                alert('Добавлено: ' + data.added + ', обновлено: ' + data.updated + ', пропущено: ' + data.skipped);
+                location.reload();
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                alert('Ошибка импорта: ' + error.message);
+            });
+        }
+        
         function showAddForm() {
             document.getElementById('formTitle').textContent = 'Добавить промпт';
             document.getElementById('promptFormData').reset();
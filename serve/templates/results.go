@@ -129,6 +129,27 @@ const ResultsPageTemplate = `
             font-size: 0.9em;
             margin-bottom: 10px;
         }
+        .file-kind {
+            font-size: 0.8em;
+            font-weight: 500;
+            color: #4a7c59;
+        }
+        .file-tag {
+            display: inline-block;
+            margin: 4px 4px 0 0;
+            padding: 1px 8px;
+            border-radius: 10px;
+            background: #e8f5e9;
+            color: #2d5016;
+            font-size: 0.85em;
+        }
+        .history-link {
+            color: #2d5016;
+            text-decoration: none;
+        }
+        .history-link:hover {
+            text-decoration: underline;
+        }
         .file-preview {
             background: #f0f8f0;
             padding: 10px;
@@ -140,6 +161,57 @@ const ResultsPageTemplate = `
             overflow: hidden;
             border-left: 3px solid #2d5016;
         }
+        .search-container {
+            margin-bottom: 20px;
+        }
+        .search-container input[type="text"] {
+            width: 100%;
+            box-sizing: border-box;
+            padding: 12px;
+            border: 1px solid #ddd;
+            border-radius: 6px;
+            font-size: 16px;
+        }
+        .search-filters {
+            display: flex;
+            gap: 10px;
+            margin-top: 10px;
+            align-items: center;
+            flex-wrap: wrap;
+            color: #666;
+        }
+        .search-filters select, .search-filters input {
+            padding: 8px;
+            border: 1px solid #ddd;
+            border-radius: 6px;
+            font-size: 14px;
+        }
+        .search-filters .nav-btn {
+            padding: 8px 20px;
+        }
+        .reset-link {
+            color: #666;
+            text-decoration: none;
+        }
+        .reset-link:hover {
+            color: #2d5016;
+        }
+        .search-results {
+            margin-top: 10px;
+            color: #27ae60;
+            font-size: 14px;
+        }
+        .search-results.nothing {
+            color: #e74c3c;
+        }
+        .pagination {
+            display: flex;
+            justify-content: center;
+            align-items: center;
+            gap: 20px;
+            margin-top: 30px;
+            color: #666;
+        }
         .empty-state {
             text-align: center;
             padding: 60px 20px;
@@ -185,7 +257,8 @@ const ResultsPageTemplate = `
             .stats { grid-template-columns: 1fr 1fr; }
             .nav-buttons { flex-direction: column; gap: 8px; }
             .nav-btn, .nav-button { text-align: center; padding: 12px 16px; font-size: 14px; }
-            .search-container input { font-size: 16px; width: 96% !important; }
+            .search-container input[type="text"] { font-size: 16px; }
+            .search-filters { flex-direction: column; align-items: stretch; }
         }
         @media (max-width: 480px) {
             .header h1 { font-size: 1.6em; }
@@ -202,18 +275,41 @@ const ResultsPageTemplate = `
         </div>
         <div class="content">
             <div class="nav-buttons">
+                {{if .Static}}
+                <a href="{{.BasePath}}/history/" class="nav-btn">📝 История</a>
+                {{else}}
                 <button class="nav-btn" onclick="location.reload()">🔄 Обновить</button>
                 <a href="{{.BasePath}}/run" class="nav-btn">🚀 Выполнение</a>
                 <a href="{{.BasePath}}/history" class="nav-btn">📝 История</a>
                 <a href="{{.BasePath}}/prompts" class="nav-btn">⚙️ Промпты</a>
+                <a href="{{.BasePath}}/runbooks" class="nav-btn">📒 Ранбуки</a>
+                {{end}}
             </div>
             
-            <!-- Поиск -->
+            {{if .Static}}
+            <!-- Поиск по индексу сайта в браузере -->
             <div class="search-container">
-                <input type="text" id="searchInput" placeholder="🔍 Поиск по содержимому файлов..." 
-                       style="width: 100%; padding: 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 16px;">
-                <div id="searchResults" style="margin-top: 10px; color: #666; font-size: 14px;"></div>
+                <input type="text" id="searchInput" placeholder="🔍 Поиск по результатам и истории...">
+                <div id="searchResults" class="search-results"></div>
+                <div id="searchList" class="files-grid" style="margin-top: 20px;"></div>
             </div>
+            {{else}}
+            <!-- Поиск и фильтры -->
+            <form class="search-container" method="get" action="{{.BasePath}}/">
+                <input type="text" name="q" value="{{.Filter.Query}}" placeholder="🔍 Поиск по содержимому файлов (слова или 'фраза')...">
+                <div class="search-filters">
+                    <select name="model" title="Модель">
+                        <option value="">🧠 Все модели</option>
+                        {{range .Models}}<option value="{{.}}"{{if eq . $.Filter.Model}} selected{{end}}>{{.}}</option>{{end}}
+                    </select>
+                    <label>с <input type="date" name="from" value="{{.Filter.From}}"></label>
+                    <label>по <input type="date" name="to" value="{{.Filter.To}}"></label>
+                    <button type="submit" class="nav-btn">Найти</button>
+                    {{if .Filtered}}<a href="{{.ResetURL}}" class="reset-link">✖ Сбросить</a>{{end}}
+                </div>
+                {{if .Filtered}}<div class="search-results{{if not .Found}} nothing{{end}}">{{if .Found}}🔍 Найдено: {{.Found}} из {{.TotalFiles}} файлов{{else}}🔍 Ничего не найдено{{end}}</div>{{end}}
+            </form>
+            {{end}}
             
             <div class="stats">
                 <div class="stat-card">
@@ -227,22 +323,42 @@ const ResultsPageTemplate = `
             </div>
 
             {{if .Files}}
-            <div class="files-grid">
+            <div class="files-grid" id="filesGrid">
                 {{range .Files}}
-                <div class="file-card" data-content="{{.Content}}">
+                <div class="file-card">
+                    {{if not $.Static}}
                     <div class="file-actions">
                         <button class="delete-btn" onclick="deleteFile('{{.Name}}')" title="Удалить файл">✖</button>
                     </div>
-                    <div class="file-card-content" onclick="window.location.href='{{$.BasePath}}/file/{{.Name}}'">
-                        <div class="file-name">{{.DisplayName}}</div>
+                    {{end}}
+                    <div class="file-card-content" onclick="window.location.href='{{$.BasePath}}/file/{{.Name}}{{if $.Static}}/{{end}}'">
+                        <div class="file-name">{{if .Kind}}<span class="file-kind">{{.Kind}}</span> {{end}}{{.DisplayName}}</div>
                         <div class="file-info">
-                            📅 {{.ModTime}} | 📏 {{.Size}}
+                            📅 {{.ModTime}}{{if .Model}} | 🧠 {{.Model}}{{end}} | 📏 {{.Size}}{{if .HistoryID}} | <a class="history-link" href="{{$.BasePath}}/history/view/{{.HistoryID}}{{if $.Static}}/{{end}}" onclick="event.stopPropagation()">📝 #{{.HistoryID}}</a>{{end}}
+                            {{range .Tags}}{{if $.Static}}<a class="file-tag" href="{{$.BasePath}}/tags/{{.}}/" onclick="event.stopPropagation()">#{{.}}</a>{{else}}<span class="file-tag">#{{.}}</span>{{end}}{{end}}
                         </div>
                         <div class="file-preview">{{.Preview}}</div>
                     </div>
                 </div>
                 {{end}}
             </div>
+            {{if gt .Pages 1}}
+            <div class="pagination" id="pagination">
+                {{if .PrevURL}}<a href="{{.PrevURL}}" class="nav-btn">← Назад</a>{{end}}
+                <span>Страница {{.Page}} из {{.Pages}}</span>
+                {{if .NextURL}}<a href="{{.NextURL}}" class="nav-btn">Вперед →</a>{{end}}
+            </div>
+            {{end}}
+            {{else if .Filtered}}
+            <div class="empty-state">
+                <h3>🔍 Ничего не найдено</h3>
+                <p>Измените запрос или <a href="{{.ResetURL}}">сбросьте фильтры</a></p>
+            </div>
+            {{else if .PrevURL}}
+            <div class="empty-state">
+                <h3>📄 На этой странице нет результатов</h3>
+                <p><a href="{{.PrevURL}}">К последней странице</a></p>
+            </div>
             {{else}}
             <div class="empty-state">
                 <h3>📁 Папка пуста</h3>
@@ -252,6 +368,61 @@ const ResultsPageTemplate = `
         </div>
     </div>
     
+    {{if .Static}}
+    <script>
+        // Индекс поиска загружается при первом вводе: [{url, title, kind, date, terms}]
+        let searchIndex = null;
+
+        function loadSearchIndex() {
+            if (!searchIndex) {
+                searchIndex = fetch('{{.BasePath}}/search-index.json').then(response => response.json());
+            }
+            return searchIndex;
+        }
+
+        function performSearch() {
+            const words = document.getElementById('searchInput').value.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(w => w !== '');
+            const searchResults = document.getElementById('searchResults');
+            const searchList = document.getElementById('searchList');
+            const grid = document.getElementById('filesGrid');
+            const pagination = document.getElementById('pagination');
+            if (words.length === 0) {
+                searchResults.textContent = '';
+                searchList.replaceChildren();
+                if (grid) grid.style.display = '';
+                if (pagination) pagination.style.display = '';
+                return;
+            }
+            loadSearchIndex().then(index => {
+                // Каждое слово запроса должно быть началом одного из слов страницы
+                const found = index.filter(doc => words.every(w => (' ' + doc.terms).includes(' ' + w)));
+                searchList.replaceChildren(...found.slice(0, 100).map(doc => {
+                    const card = document.createElement('a');
+                    card.className = 'file-card';
+                    card.href = doc.url;
+                    card.style.textDecoration = 'none';
+                    const name = document.createElement('div');
+                    name.className = 'file-name';
+                    name.textContent = doc.title;
+                    const info = document.createElement('div');
+                    info.className = 'file-info';
+                    info.textContent = doc.kind + ' | 📅 ' + doc.date;
+                    card.append(name, info);
+                    return card;
+                }));
+                if (grid) grid.style.display = 'none';
+                if (pagination) pagination.style.display = 'none';
+                searchResults.className = 'search-results' + (found.length ? '' : ' nothing');
+                searchResults.textContent = found.length ? '🔍 Найдено: ' + found.length + (found.length > 100 ? ' (показаны первые 100)' : '') : '🔍 Ничего не найдено';
+            }).catch(error => {
+                console.error('Error:', error);
+                searchResults.textContent = 'Ошибка загрузки индекса поиска';
+            });
+        }
+
+        document.getElementById('searchInput').addEventListener('input', performSearch);
+    </script>
+    {{else}}
     <script>
         function deleteFile(filename) {
             if (confirm('Вы уверены, что хотите удалить файл "' + filename + '"?\\n\\nЭто действие нельзя отменить.')) {
@@ -271,74 +442,7 @@ const ResultsPageTemplate = `
                 });
             }
         }
-        
-        // Поиск по содержимому файлов
-        function performSearch() {
-            const searchTerm = document.getElementById('searchInput').value.trim();
-            const searchResults = document.getElementById('searchResults');
-            const fileCards = document.querySelectorAll('.file-card');
-            
-            if (searchTerm === '') {
-                // Показать все файлы
-                fileCards.forEach(card => {
-                    card.style.display = 'block';
-                });
-                searchResults.textContent = '';
-                return;
-            }
-            
-            let visibleCount = 0;
-            let totalCount = fileCards.length;
-            
-            fileCards.forEach(card => {
-                const fileName = card.querySelector('.file-name').textContent.toLowerCase();
-                const fullContent = card.getAttribute('data-content').toLowerCase();
-                
-                // Проверяем поиск по полному содержимому файла
-                const fileContent = fileName + ' ' + fullContent;
-                
-                let matches = false;
-                
-                // Проверяем, есть ли фраза в кавычках
-                if (searchTerm.startsWith("'") && searchTerm.endsWith("'")) {
-                    // Поиск точной фразы
-                    const phrase = searchTerm.slice(1, -1).toLowerCase();
-                    matches = fileContent.includes(phrase);
-                } else {
-                    // Поиск по отдельным словам
-                    const words = searchTerm.toLowerCase().split(/\s+/);
-                    matches = words.every(word => fileContent.includes(word));
-                }
-                
-                if (matches) {
-                    card.style.display = 'block';
-                    visibleCount++;
-                } else {
-                    card.style.display = 'none';
-                }
-            });
-            
-            // Обновляем информацию о результатах
-            if (visibleCount === 0) {
-                searchResults.textContent = '🔍 Ничего не найдено';
-                searchResults.style.color = '#e74c3c';
-            } else if (visibleCount === totalCount) {
-                searchResults.textContent = '';
-            } else {
-                searchResults.textContent = '🔍 Найдено: ' + visibleCount + ' из ' + totalCount + ' файлов';
-                searchResults.style.color = '#27ae60';
-            }
-        }
-        
-        // Обработчик ввода в поле поиска
-        document.getElementById('searchInput').addEventListener('input', performSearch);
-        
-        // Обработчик Enter в поле поиска
-        document.getElementById('searchInput').addEventListener('keypress', function(e) {
-            if (e.key === 'Enter') {
-                performSearch();
-            }
-        });
     </script>
+    {{end}}
 </body>
 </html>`
@@ -0,0 +1,526 @@
+package templates
+
+// runbookStyles общие стили страниц ранбуков
+const runbookStyles = `
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background: linear-gradient(135deg, #56ab2f 0%, #a8e6cf 100%);
+            min-height: 100vh;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 0 auto;
+            background: white;
+            border-radius: 12px;
+            box-shadow: 0 20px 40px rgba(0,0,0,0.1);
+            overflow: hidden;
+        }
+        .header {
+            background: linear-gradient(135deg, #2d5016 0%, #4a7c59 100%);
+            color: white;
+            padding: 30px;
+            text-align: center;
+        }
+        .header h1 {
+            margin: 0;
+            font-size: 2.5em;
+            font-weight: 300;
+        }
+        .content {
+            padding: 30px;
+        }
+        .nav-buttons {
+            display: flex;
+            gap: 10px;
+            margin-bottom: 20px;
+            flex-wrap: wrap;
+        }
+        .nav-btn {
+            background: #3498db;
+            color: white;
+            border: none;
+            padding: 12px 24px;
+            border-radius: 6px;
+            cursor: pointer;
+            font-size: 1em;
+            text-decoration: none;
+            transition: background 0.3s ease;
+            display: inline-block;
+            text-align: center;
+        }
+        .nav-btn:hover {
+            background: #2980b9;
+        }
+        .nav-btn.save-btn {
+            background: #27ae60;
+        }
+        .nav-btn.save-btn:hover {
+            background: #219a52;
+        }
+        .nav-btn.delete-btn {
+            background: #e74c3c;
+        }
+        .nav-btn.delete-btn:hover {
+            background: #c0392b;
+        }
+        .runbook-item {
+            background: #f0f8f0;
+            border: 1px solid #a8e6cf;
+            border-radius: 8px;
+            padding: 20px;
+            margin-bottom: 15px;
+            cursor: pointer;
+            transition: all 0.3s ease;
+        }
+        .runbook-item:hover {
+            border-color: #2d5016;
+            transform: translateY(-2px);
+            box-shadow: 0 8px 25px rgba(45,80,22,0.2);
+        }
+        .runbook-title {
+            font-weight: 600;
+            color: #333;
+            font-size: 1.15em;
+        }
+        .runbook-meta {
+            color: #666;
+            font-size: 0.9em;
+            margin-top: 6px;
+        }
+        .mark-tag {
+            background: #e8f5e9;
+            color: #2d5016;
+            padding: 2px 8px;
+            border-radius: 10px;
+            font-size: 0.85em;
+        }
+        .step {
+            border: 1px solid #a8e6cf;
+            border-radius: 8px;
+            padding: 15px 20px;
+            margin-bottom: 12px;
+            background: #fafdfa;
+        }
+        .step-header {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            gap: 10px;
+            margin-bottom: 8px;
+        }
+        .step-index {
+            background: #2d5016;
+            color: white;
+            padding: 4px 8px;
+            border-radius: 4px;
+            font-weight: bold;
+        }
+        .steps-view {
+            counter-reset: step;
+        }
+        .steps-view .step-index::before {
+            counter-increment: step;
+            content: "Шаг " counter(step);
+        }
+        .step-description {
+            color: #333;
+            margin-bottom: 8px;
+            white-space: pre-wrap;
+        }
+        .step-command {
+            background: #f8f9fa;
+            padding: 10px;
+            border-radius: 4px;
+            font-family: 'Monaco', 'Menlo', monospace;
+            font-size: 0.9em;
+            color: #2d5016;
+            border-left: 3px solid #2d5016;
+            white-space: pre-wrap;
+            word-break: break-all;
+            margin: 0;
+        }
+        .small-btn {
+            background: transparent;
+            border: 1px solid #ddd;
+            border-radius: 4px;
+            padding: 2px 8px;
+            cursor: pointer;
+            color: #555;
+        }
+        .small-btn:hover {
+            border-color: #2d5016;
+            color: #2d5016;
+        }
+        .hint {
+            color: #666;
+            font-size: 0.9em;
+            margin: 10px 0 20px;
+        }
+        .hint code {
+            background: #f0f8f0;
+            padding: 2px 6px;
+            border-radius: 4px;
+        }
+        .empty-state {
+            text-align: center;
+            padding: 60px 20px;
+            color: #666;
+        }
+        label {
+            display: block;
+            font-weight: 600;
+            color: #2d5016;
+            margin: 12px 0 4px;
+        }
+        input[type=text], textarea, select {
+            width: 100%;
+            box-sizing: border-box;
+            padding: 10px;
+            border: 1px solid #ddd;
+            border-radius: 6px;
+            font-size: 15px;
+            font-family: inherit;
+        }
+        textarea.command {
+            font-family: 'Monaco', 'Menlo', monospace;
+            font-size: 0.9em;
+        }
+        .add-step {
+            border: 1px dashed #a8e6cf;
+            border-radius: 8px;
+            padding: 15px 20px;
+            margin: 20px 0;
+        }
+        .add-row {
+            display: flex;
+            gap: 10px;
+            align-items: center;
+        }
+        .add-row > *:first-child {
+            flex: 1;
+        }
+        .status {
+            margin-top: 10px;
+            font-size: 0.95em;
+        }
+
+        /* Мобильная адаптация */
+        @media (max-width: 768px) {
+            body { padding: 10px; }
+            .container { margin: 0; border-radius: 8px; box-shadow: 0 10px 20px rgba(0,0,0,0.1); }
+            .header { padding: 20px; }
+            .header h1 { font-size: 2em; }
+            .content { padding: 20px; }
+            .nav-buttons { flex-direction: column; gap: 8px; }
+            .nav-btn { text-align: center; padding: 12px 16px; font-size: 14px; }
+            .add-row { flex-direction: column; align-items: stretch; }
+        }
+`
+
+// RunbooksPageTemplate шаблон страницы списка ранбуков
+const RunbooksPageTemplate = `
+<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Ранбуки - LCG Results</title>
+    <style>` + runbookStyles + `</style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>📒 Ранбуки</h1>
+            <p>Наборы команд {{.AppName}} для пошагового выполнения</p>
+        </div>
+        <div class="content">
+            <div class="nav-buttons">
+                <a href="{{.BasePath}}/" class="nav-btn">🏠 Главная</a>
+                <a href="{{.BasePath}}/history" class="nav-btn">📝 История</a>
+                <a href="{{.BasePath}}/runbooks/new" class="nav-btn save-btn">➕ Новый ранбук</a>
+            </div>
+            <div class="hint">Выполнение по шагам с подтверждением: <code>lcg runbook run &lt;имя&gt;</code></div>
+
+            {{if .Runbooks}}
+            {{range .Runbooks}}
+            <div class="runbook-item" onclick="window.location.href='{{$.BasePath}}/runbooks/view/{{.Name}}'">
+                <div class="runbook-title">{{.Title}}</div>
+                <div class="runbook-meta">
+                    {{.Name}} · шагов: {{len .Steps}} · изменен {{.Updated.Format "02.01.2006 15:04"}}
+                    {{range .Tags}}<span class="mark-tag">#{{.}}</span> {{end}}
+                </div>
+                {{if .Description}}<div class="runbook-meta">{{.Description}}</div>{{end}}
+            </div>
+            {{end}}
+            {{else}}
+            <div class="empty-state">
+                <h3>📒 Ранбуков пока нет</h3>
+                <p>Соберите первый из команд истории или новых запросов: кнопка «Новый ранбук» или <code>lcg runbook new &lt;имя&gt;</code></p>
+            </div>
+            {{end}}
+        </div>
+    </div>
+</body>
+</html>
+`
+
+// RunbookViewTemplate шаблон страницы просмотра ранбука
+const RunbookViewTemplate = `
+<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Runbook.Title}} - Ранбуки</title>
+    <style>` + runbookStyles + `</style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>📒 {{.Runbook.Title}}</h1>
+            <p>{{.Runbook.Name}} · шагов: {{len .Runbook.Steps}}</p>
+        </div>
+        <div class="content">
+            <div class="nav-buttons">
+                <a href="{{.BasePath}}/runbooks" class="nav-btn">⬅️ Ранбуки</a>
+                <a href="{{.BasePath}}/runbooks/edit/{{.Runbook.Name}}" class="nav-btn">✏️ Изменить</a>
+                <button class="nav-btn delete-btn" onclick="deleteRunbook()">🗑️ Удалить</button>
+            </div>
+            {{if .Runbook.Description}}<div class="step-description">{{.Runbook.Description}}</div>{{end}}
+            {{if .Runbook.Tags}}<div class="hint">{{range .Runbook.Tags}}<span class="mark-tag">#{{.}}</span> {{end}}</div>{{end}}
+            <div class="hint">Выполнить: <code>lcg runbook run {{.Runbook.Name}}</code></div>
+
+            <div class="steps-view">
+            {{range $i, $step := .Runbook.Steps}}
+            <div class="step">
+                <div class="step-header">
+                    <span class="step-index"></span>
+                    <button class="small-btn" onclick="copyCommand(this)">📋 Копировать</button>
+                </div>
+                {{if $step.Description}}<div class="step-description">{{$step.Description}}</div>{{end}}
+                <pre class="step-command">{{$step.Command}}</pre>
+            </div>
+            {{else}}
+            <div class="empty-state">
+                <h3>Шагов нет</h3>
+                <p>Добавьте шаги в редакторе</p>
+            </div>
+            {{end}}
+            </div>
+        </div>
+    </div>
+
+    <script>
+        function copyCommand(button) {
+            const command = button.closest('.step').querySelector('.step-command').textContent;
+            navigator.clipboard.writeText(command).then(() => {
+                button.textContent = '✅ Скопировано';
+                setTimeout(() => { button.textContent = '📋 Копировать'; }, 1500);
+            });
+        }
+
+        function deleteRunbook() {
+            if (!confirm('Удалить ранбук {{.Runbook.Name}}?')) {
+                return;
+            }
+            fetch('{{.BasePath}}/runbooks/delete/{{.Runbook.Name}}', {
+                method: 'DELETE',
+                headers: { 'X-CSRF-Token': '{{.CSRFToken}}' }
+            })
+            .then(response => {
+                if (response.ok) {
+                    window.location.href = '{{.BasePath}}/runbooks';
+                } else {
+                    response.text().then(text => alert('Ошибка при удалении: ' + text));
+                }
+            })
+            .catch(error => {
+                console.error('Error:', error);
+                alert('Ошибка при удалении ранбука');
+            });
+        }
+    </script>
+</body>
+</html>
+`
+
+// RunbookEditTemplate шаблон редактора ранбука: шаги из истории, новые
+// генерации или команды, введенные вручную
+const RunbookEditTemplate = `
+<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{if .IsNew}}Новый ранбук{{else}}{{.Runbook.Title}}{{end}} - Ранбуки</title>
+    <style>` + runbookStyles + `</style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>✏️ {{if .IsNew}}Новый ранбук{{else}}{{.Runbook.Title}}{{end}}</h1>
+            <p>Шаги выполняются по порядку командой lcg runbook run</p>
+        </div>
+        <div class="content">
+            <div class="nav-buttons">
+                <a href="{{.BasePath}}/runbooks{{if not .IsNew}}/view/{{.Runbook.Name}}{{end}}" class="nav-btn">⬅️ Назад</a>
+                <button class="nav-btn save-btn" onclick="saveRunbook()">💾 Сохранить</button>
+            </div>
+
+            <label for="name">Имя (файл и адрес)</label>
+            <input type="text" id="name" value="{{.Runbook.Name}}" placeholder="deploy-app">
+            <label for="title">Название</label>
+            <input type="text" id="title" value="{{.Runbook.Title}}" placeholder="Выкатка приложения">
+            <label for="description">Описание</label>
+            <textarea id="description" rows="3">{{.Runbook.Description}}</textarea>
+            <label for="tags">Теги (через запятую)</label>
+            <input type="text" id="tags" value="{{range $i, $t := .Runbook.Tags}}{{if $i}}, {{end}}{{$t}}{{end}}">
+
+            <h3>Шаги</h3>
+            <div id="steps"></div>
+
+            <div class="add-step">
+                <label>Добавить шаг</label>
+                {{if .History}}
+                <div class="add-row">
+                    <select id="historySelect">
+                        <option value="">— команда из истории —</option>
+                        {{range $i, $h := .History}}<option value="{{$i}}">#{{$h.Index}} {{$h.Query}} → {{$h.Command}}</option>
+                        {{end}}
+                    </select>
+                    <button class="small-btn" onclick="addFromHistory()">➕ Из истории</button>
+                </div>
+                {{end}}
+                <div class="add-row" style="margin-top: 10px;">
+                    <input type="text" id="askInput" placeholder="Запрос к модели, например: перезапустить nginx">
+                    <button class="small-btn" id="askBtn" onclick="addGenerated()">🤖 Сгенерировать</button>
+                </div>
+                <div class="add-row" style="margin-top: 10px;">
+                    <span></span>
+                    <button class="small-btn" onclick="addStep('', '')">➕ Пустой шаг</button>
+                </div>
+            </div>
+            <div id="status" class="status"></div>
+        </div>
+    </div>
+
+    <script>
+        const original = {{.Runbook.Name}};
+        const historyItems = {{.History}} || [];
+        let steps = {{.Runbook.Steps}} || [];
+
+        function escapeHtml(text) {
+            const div = document.createElement('div');
+            div.textContent = text;
+            return div.innerHTML;
+        }
+
+        function renderSteps() {
+            const container = document.getElementById('steps');
+            if (steps.length === 0) {
+                container.innerHTML = '<div class="hint">Шагов пока нет</div>';
+                return;
+            }
+            container.innerHTML = steps.map((step, i) =>
+                '<div class="step">' +
+                    '<div class="step-header">' +
+                        '<span class="step-index">Шаг ' + (i + 1) + '</span>' +
+                        '<span>' +
+                            '<button class="small-btn" onclick="moveStep(' + i + ', -1)"' + (i === 0 ? ' disabled' : '') + '>↑</button> ' +
+                            '<button class="small-btn" onclick="moveStep(' + i + ', 1)"' + (i === steps.length - 1 ? ' disabled' : '') + '>↓</button> ' +
+                            '<button class="small-btn" onclick="removeStep(' + i + ')">✖</button>' +
+                        '</span>' +
+                    '</div>' +
+                    '<input type="text" placeholder="Описание шага" value="' + escapeHtml(step.description || '').replace(/"/g, '&quot;') + '" oninput="steps[' + i + '].description = this.value">' +
+                    '<textarea class="command" rows="2" style="margin-top: 6px;" placeholder="Команда" oninput="steps[' + i + '].command = this.value">' + escapeHtml(step.command || '') + '</textarea>' +
+                '</div>'
+            ).join('');
+        }
+
+        function addStep(description, command) {
+            steps.push({ description: description, command: command });
+            renderSteps();
+        }
+
+        function moveStep(i, delta) {
+            const j = i + delta;
+            [steps[i], steps[j]] = [steps[j], steps[i]];
+            renderSteps();
+        }
+
+        function removeStep(i) {
+            steps.splice(i, 1);
+            renderSteps();
+        }
+
+        function addFromHistory() {
+            const select = document.getElementById('historySelect');
+            if (select.value === '') {
+                return;
+            }
+            const item = historyItems[Number(select.value)];
+            addStep(item.query, item.command);
+            select.value = '';
+        }
+
+        function setStatus(text, color) {
+            const status = document.getElementById('status');
+            status.textContent = text;
+            status.style.color = color;
+        }
+
+        function addGenerated() {
+            const input = document.getElementById('askInput');
+            const prompt = input.value.trim();
+            if (prompt === '') {
+                return;
+            }
+            const button = document.getElementById('askBtn');
+            button.disabled = true;
+            setStatus('⏳ Генерирую команду...', '#666');
+            fetch('{{.BasePath}}/runbooks/generate', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': '{{.CSRFToken}}' },
+                body: JSON.stringify({ prompt: prompt })
+            })
+            .then(response => response.ok ? response.json() : response.text().then(text => { throw new Error(text); }))
+            .then(data => {
+                addStep(prompt, data.command);
+                input.value = '';
+                setStatus('✅ Шаг добавлен: проверьте команду перед сохранением', '#27ae60');
+            })
+            .catch(error => setStatus('❌ ' + error.message, '#e74c3c'))
+            .finally(() => { button.disabled = false; });
+        }
+
+        function saveRunbook() {
+            const body = {
+                original: original,
+                name: document.getElementById('name').value.trim(),
+                title: document.getElementById('title').value,
+                description: document.getElementById('description').value,
+                tags: document.getElementById('tags').value.split(',').map(t => t.trim()).filter(t => t !== ''),
+                steps: steps
+            };
+            fetch('{{.BasePath}}/runbooks/save', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': '{{.CSRFToken}}' },
+                body: JSON.stringify(body)
+            })
+            .then(response => {
+                if (response.ok) {
+                    window.location.href = '{{.BasePath}}/runbooks/view/' + encodeURIComponent(body.name);
+                } else {
+                    response.text().then(text => setStatus('❌ ' + text, '#e74c3c'));
+                }
+            })
+            .catch(error => setStatus('❌ ' + error.message, '#e74c3c'));
+        }
+
+        renderSteps();
+    </script>
+</body>
+</html>
+`
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/direct-dev-ru/linux-command-gpt/history"
+	"github.com/direct-dev-ru/linux-command-gpt/serve"
+	"github.com/urfave/cli/v2"
+)
+
+// executeExportSite выгружает результаты и историю в статический сайт в dir.
+// basePath — путь публикации сайта, excludeTags — теги записей, которые не
+// выгружаются (по умолчанию LCG_SYNC_EXCLUDE_TAGS: такие записи не покидают
+// машину ни при синхронизации, ни при публикации).
+func executeExportSite(dir, basePath, excludeTags string, skipHistory bool) error {
+	opts := serve.SiteOptions{
+		Dir:         dir,
+		BasePath:    basePath,
+		ExcludeTags: history.ParseTags(excludeTags),
+		SkipHistory: skipHistory,
+	}
+
+	report, err := serve.ExportSite(opts)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка выгрузки сайта: %v", err), exitGeneral)
+	}
+	fmt.Printf("Результаты: %d, записи истории: %d, теги: %d\n", report.Results, report.Entries, report.Tags)
+	if report.Skipped > 0 {
+		fmt.Printf("Не выгружено по тегам %v: %d\n", opts.ExcludeTags, report.Skipped)
+	}
+	printColored(fmt.Sprintf("✅ Сайт выгружен в %s\n", dir), colorGreen)
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/results"
+	"github.com/direct-dev-ru/linux-command-gpt/storage"
+	"github.com/urfave/cli/v2"
+)
+
+// storageErr ошибка получения ключа шифрования при запуске; команды, кроме
+// lcg storage keygen, с ней не выполняются
+var storageErr error
+
+// configureStorage получает ключ шифрования из настроек (при LCG_ENCRYPTION_ASK
+// запрашивает парольную фразу) и включает шифрование данных на диске
+func configureStorage() error {
+	enc := &config.AppConfig.Encryption
+	if enc.AskPassphrase && enc.KeyFile == "" && enc.Key == "" && enc.Passphrase == "" {
+		passphrase, err := cmdPackage.ReadSecret("🔐 Парольная фраза шифрования: ")
+		if err != nil {
+			return err
+		}
+		enc.Passphrase = passphrase
+	}
+	key, err := storage.LoadKey(*enc)
+	if err != nil {
+		return err
+	}
+	storage.Use(key)
+	return nil
+}
+
+// storageFiles файлы, которые шифруются: результаты и ранбуки (*.md), sys_prompts и версии
+// промптов. Индекс результатов шифрует свои записи сам и после перезаписи строится заново.
+func storageFiles() ([]string, error) {
+	var files []string
+	for _, folder := range []string{config.AppConfig.ResultFolder, config.AppConfig.RunbookFolder} {
+		entries, err := os.ReadDir(folder)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".md") {
+				files = append(files, filepath.Join(folder, entry.Name()))
+			}
+		}
+	}
+	for _, name := range []string{"sys_prompts", "sys_prompts_versions"} {
+		prompts := filepath.Join(config.AppConfig.PromptFolder, name)
+		if _, err := os.Stat(prompts); err == nil {
+			files = append(files, prompts)
+		}
+	}
+	return files, nil
+}
+
+// rewriteStorage переписывает историю, результаты, ранбуки и sys_prompts текущими ключами:
+// читает любым ключом чтения, записывает ключом записи (или открыто)
+func rewriteStorage() (entries, files int, err error) {
+	if _, statErr := os.Stat(config.AppConfig.ResultHistory); statErr == nil {
+		store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+		if err != nil {
+			return 0, 0, err
+		}
+		list, err := store.List()
+		if err != nil {
+			return 0, 0, fmt.Errorf("история: %v", err)
+		}
+		if err := store.Rewrite(); err != nil {
+			return 0, 0, fmt.Errorf("история: %v", err)
+		}
+		entries = len(list)
+	}
+
+	paths, err := storageFiles()
+	if err != nil {
+		return entries, 0, err
+	}
+	for _, path := range paths {
+		data, err := storage.ReadFile(path)
+		if err != nil {
+			return entries, files, err
+		}
+		if err := storage.WriteFile(path, data, 0644); err != nil {
+			return entries, files, err
+		}
+		files++
+	}
+	if files > 0 {
+		if err := results.Rescan(config.AppConfig.ResultFolder); err != nil {
+			return entries, files, fmt.Errorf("индекс результатов: %v", err)
+		}
+	}
+	return entries, files, nil
+}
+
+// requireKey возвращает текущий ключ шифрования или ошибку, если он не задан
+func requireKey() (*storage.Key, error) {
+	key, err := storage.LoadKey(config.AppConfig.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("ключ шифрования не задан (LCG_ENCRYPTION_KEY_FILE, LCG_ENCRYPTION_KEY, LCG_ENCRYPTION_PASSPHRASE или LCG_ENCRYPTION_ASK)")
+	}
+	return key, nil
+}
+
+// storageStatus выводит состояние шифрования данных
+func storageStatus() error {
+	source := storage.Source(config.AppConfig.Encryption)
+	if source == "" {
+		printColored("🔓 Шифрование выключено\n", colorYellow)
+	} else {
+		printColored(fmt.Sprintf("🔐 Шифрование включено, ключ: %s\n", source), colorGreen)
+	}
+
+	if state, err := fileState(config.AppConfig.ResultHistory); err == nil {
+		fmt.Printf("История (%s): %s\n", config.AppConfig.ResultHistory, state)
+	}
+	paths, err := storageFiles()
+	if err != nil {
+		return cli.Exit(err.Error(), exitGeneral)
+	}
+	encrypted := 0
+	for _, path := range paths {
+		if ok, err := storage.IsEncryptedFile(path); err == nil && ok {
+			encrypted++
+		}
+	}
+	fmt.Printf("Файлы результатов и sys_prompts: зашифровано %d из %d\n", encrypted, len(paths))
+	if source != "" && encrypted < len(paths) {
+		printColored("Открытые файлы будут зашифрованы при следующей записи; зашифровать сразу: lcg storage encrypt\n", colorYellow)
+	}
+	return nil
+}
+
+// fileState состояние файла истории для вывода
+func fileState(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "нет файла", nil
+	}
+	if config.AppConfig.HistoryBackend == "bolt" {
+		// В базе шифруются отдельные записи, по заголовку файла это не видно
+		return "база bbolt", nil
+	}
+	ok, err := storage.IsEncryptedFile(path)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return "зашифрована", nil
+	}
+	return "открыта", nil
+}
+
+// encryptStorage шифрует существующие данные текущим ключом
+func encryptStorage() error {
+	key, err := requireKey()
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	storage.Use(key)
+	entries, files, err := rewriteStorage()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка шифрования: %v", err), exitGeneral)
+	}
+	printColored(fmt.Sprintf("🔐 Зашифровано: записей истории %d, файлов %d\n", entries, files), colorGreen)
+	return nil
+}
+
+// decryptStorage расшифровывает данные; после этого ключ можно убрать из настроек
+func decryptStorage() error {
+	key, err := requireKey()
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+	storage.Use(nil, key)
+	entries, files, err := rewriteStorage()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка расшифровки: %v", err), exitGeneral)
+	}
+	printColored(fmt.Sprintf("🔓 Расшифровано: записей истории %d, файлов %d\n", entries, files), colorGreen)
+	fmt.Println("Уберите ключ из настроек, иначе данные снова будут шифроваться при записи")
+	return nil
+}
+
+// rekeyStorage перешифровывает данные новым ключом. Без newKeyFile и
+// askPassphrase новый ключ генерируется и заменяет текущий файл ключа.
+func rekeyStorage(newKeyFile string, askPassphrase bool) error {
+	oldKey, err := requireKey()
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+
+	var newKey *storage.Key
+	var generated string // сгенерированный файл, который заменит текущий файл ключа
+	switch {
+	case newKeyFile != "":
+		newKey, err = storage.LoadKey(config.EncryptionConfig{KeyFile: newKeyFile})
+	case askPassphrase:
+		var passphrase string
+		if passphrase, err = cmdPackage.ReadSecret("🔐 Новая парольная фраза: "); err == nil {
+			var repeat string
+			if repeat, err = cmdPackage.ReadSecret("🔐 Повторите парольную фразу: "); err == nil && repeat != passphrase {
+				err = fmt.Errorf("парольные фразы не совпадают")
+			}
+		}
+		if err == nil {
+			newKey, err = storage.NewKey([]byte(passphrase))
+		}
+	case config.AppConfig.Encryption.KeyFile != "":
+		generated = config.AppConfig.Encryption.KeyFile + ".new"
+		// Файл .new остается от прерванной смены ключа: часть данных уже
+		// зашифрована им, поэтому продолжаем с тем же ключом
+		if _, statErr := os.Stat(generated); statErr == nil {
+			printColored(fmt.Sprintf("Продолжается прерванная смена ключа: новый ключ из %s\n", generated), colorYellow)
+		} else {
+			err = storage.WriteKeyFile(generated)
+		}
+		if err == nil {
+			newKey, err = storage.LoadKey(config.EncryptionConfig{KeyFile: generated})
+		}
+	default:
+		err = fmt.Errorf("укажите --new-key-file или --new-passphrase")
+	}
+	if err != nil {
+		return cli.Exit(err.Error(), exitValidation)
+	}
+
+	// Читаем старым и новым ключом: после прерванной смены ключа часть данных
+	// уже может быть зашифрована новым
+	storage.Use(newKey, oldKey)
+	entries, files, err := rewriteStorage()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка смены ключа: %v", err), exitGeneral)
+	}
+	if generated != "" {
+		backup := config.AppConfig.Encryption.KeyFile + ".old"
+		if err := copyKeyFile(config.AppConfig.Encryption.KeyFile, backup); err != nil {
+			return cli.Exit(fmt.Sprintf("Данные перешифрованы ключом из %s, но сохранить копию старого ключа не удалось: %v", generated, err), exitGeneral)
+		}
+		fmt.Printf("Старый ключ сохранен в %s\n", backup)
+		if err := os.Rename(generated, config.AppConfig.Encryption.KeyFile); err != nil {
+			return cli.Exit(fmt.Sprintf("Данные перешифрованы ключом из %s, но заменить файл ключа не удалось: %v", generated, err), exitGeneral)
+		}
+	}
+	printColored(fmt.Sprintf("🔑 Ключ заменен: перешифровано записей истории %d, файлов %d\n", entries, files), colorGreen)
+	switch {
+	case newKeyFile != "":
+		fmt.Printf("Укажите новый ключ в настройках: LCG_ENCRYPTION_KEY_FILE=%s\n", newKeyFile)
+	case askPassphrase:
+		fmt.Println("Укажите новую парольную фразу в настройках (LCG_ENCRYPTION_PASSPHRASE или LCG_ENCRYPTION_ASK)")
+	}
+	return nil
+}
+
+// copyKeyFile копирует файл ключа с правами 0600
+func copyKeyFile(from, to string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(to, data, 0600)
+}
+
+// keygenStorage создает файл со случайным ключом
+func keygenStorage(path string) error {
+	if path == "" {
+		path = config.AppConfig.Encryption.KeyFile
+	}
+	if path == "" {
+		return cli.Exit("Укажите файл ключа: lcg storage keygen <file>", exitValidation)
+	}
+	if err := storage.WriteKeyFile(path); err != nil {
+		return cli.Exit(fmt.Sprintf("Не удалось создать файл ключа: %v", err), exitValidation)
+	}
+	printColored(fmt.Sprintf("🔑 Ключ записан в %s (права 0600)\n", path), colorGreen)
+	fmt.Printf("Включите шифрование: export LCG_ENCRYPTION_KEY_FILE=%s && lcg storage encrypt\n", path)
+	return nil
+}
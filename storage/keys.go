@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+)
+
+// Source описание источника ключа для вывода (без самого ключа)
+func Source(c config.EncryptionConfig) string {
+	switch {
+	case c.KeyFile != "":
+		return "файл " + c.KeyFile
+	case c.Key != "":
+		return "LCG_ENCRYPTION_KEY"
+	case c.Passphrase != "":
+		return "парольная фраза"
+	}
+	return ""
+}
+
+// LoadKey получает ключ из настроек: файл ключа, затем ключ из окружения,
+// затем парольная фраза. Если ничего не задано, возвращает nil.
+func LoadKey(c config.EncryptionConfig) (*Key, error) {
+	switch {
+	case c.KeyFile != "":
+		data, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать файл ключа: %v", err)
+		}
+		key, err := NewKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c.KeyFile, err)
+		}
+		return key, nil
+	case c.Key != "":
+		return NewKey([]byte(c.Key))
+	case c.Passphrase != "":
+		return NewKey([]byte(c.Passphrase))
+	}
+	return nil, nil
+}
+
+// WriteKeyFile создает файл с новым случайным ключом и правами 0600;
+// существующий файл не перезаписывается
+func WriteKeyFile(path string) error {
+	key, err := GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(key + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
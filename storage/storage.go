@@ -0,0 +1,375 @@
+// Package storage шифрует данные lcg на диске: историю, файлы результатов и
+// sys_prompts.
+//
+// Зашифрованные данные начинаются с заголовка LCGENC1, за которым следуют соль,
+// nonce и шифртекст AES-256-GCM. Ключ получается из секрета (файл ключа,
+// переменная окружения или парольная фраза) через scrypt с солью из заголовка.
+// Данные без заголовка читаются как есть, поэтому открытые файлы продолжают
+// работать и шифруются при следующей записи.
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Magic заголовок зашифрованных данных
+const Magic = "LCGENC1"
+
+// linePrefix начало зашифрованной строки журнала (base64 после префикса)
+const linePrefix = Magic + ":"
+
+const (
+	saltSize = 16
+	keySize  = 32
+	// Параметры scrypt: ~50 мс на ключ, результат кешируется по соли
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// indexSalt соль ключа для индексов: значения должны совпадать между запусками
+var indexSalt = []byte("lcg-storage-index")
+
+var (
+	// ErrNoKey данные зашифрованы, а ключ не задан
+	ErrNoKey = errors.New("данные зашифрованы, задайте ключ (LCG_ENCRYPTION_KEY_FILE, LCG_ENCRYPTION_KEY или LCG_ENCRYPTION_PASSPHRASE)")
+	// ErrWrongKey ключ не подходит или данные повреждены
+	ErrWrongKey = errors.New("не удалось расшифровать данные: неверный ключ или данные повреждены")
+)
+
+// Key ключ шифрования, полученный из секрета
+type Key struct {
+	secret []byte
+
+	mu      sync.Mutex
+	derived map[string][]byte // соль -> ключ AES
+	salt    []byte            // соль для новых записей
+	index   []byte            // ключ HMAC для индексов
+}
+
+// NewKey создает ключ из секрета; пробелы по краям секрета не учитываются
+func NewKey(secret []byte) (*Key, error) {
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return nil, errors.New("пустой ключ шифрования")
+	}
+	return &Key{secret: secret, derived: map[string][]byte{}}, nil
+}
+
+// derive возвращает ключ AES для соли
+func (k *Key) derive(salt []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.derived[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key(k.secret, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	k.derived[string(salt)] = key
+	return key, nil
+}
+
+// writeSalt соль для новых записей; одна на процесс, чтобы не выводить ключ заново
+func (k *Key) writeSalt() ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.salt == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		k.salt = salt
+	}
+	return k.salt, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal шифрует данные: заголовок, соль, nonce и шифртекст.
+// Заголовок и соль проверяются вместе с данными.
+func (k *Key) Seal(plain []byte) ([]byte, error) {
+	salt, err := k.writeSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := k.derive(salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(Magic)+saltSize+gcm.NonceSize()+len(plain)+gcm.Overhead())
+	out = append(append(out, Magic...), salt...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := out
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, header), nil
+}
+
+// Open расшифровывает данные, зашифрованные Seal
+func (k *Key) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) || len(data) < len(Magic)+saltSize {
+		return nil, ErrWrongKey
+	}
+	header := data[:len(Magic)+saltSize]
+	key, err := k.derive(header[len(Magic):])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(header):]
+	if len(rest) < gcm.NonceSize() {
+		return nil, ErrWrongKey
+	}
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+// indexKey ключ HMAC для значений индексов
+func (k *Key) indexKey() ([]byte, error) {
+	k.mu.Lock()
+	index := k.index
+	k.mu.Unlock()
+	if index != nil {
+		return index, nil
+	}
+	index, err := scrypt.Key(k.secret, indexSalt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.index = index
+	k.mu.Unlock()
+	return index, nil
+}
+
+// Fingerprint короткий отпечаток ключа; по нему видно, каким ключом построены индексы
+func (k *Key) Fingerprint() string {
+	index, err := k.indexKey()
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, index)
+	mac.Write([]byte("fingerprint"))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Ключи текущего процесса: новые данные шифруются writeKey, а читаются любым
+// из readKeys (при смене ключа — старым и новым)
+var (
+	stateMu  sync.RWMutex
+	writeKey *Key
+	readKeys []*Key
+)
+
+// Use задает ключ для записи (nil — данные пишутся открытыми) и дополнительные
+// ключи для чтения
+func Use(write *Key, read ...*Key) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	writeKey = write
+	readKeys = nil
+	if write != nil {
+		readKeys = append(readKeys, write)
+	}
+	for _, k := range read {
+		if k != nil {
+			readKeys = append(readKeys, k)
+		}
+	}
+}
+
+// Enabled сообщает, шифруются ли записываемые данные
+func Enabled() bool {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+	return writeKey != nil
+}
+
+// Fingerprint отпечаток ключа записи; пусто — шифрование выключено
+func Fingerprint() string {
+	stateMu.RLock()
+	k := writeKey
+	stateMu.RUnlock()
+	if k == nil {
+		return ""
+	}
+	return k.Fingerprint()
+}
+
+// IsEncrypted проверяет наличие заголовка зашифрованных данных
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+// Encode шифрует данные ключом записи; без ключа возвращает их как есть
+func Encode(data []byte) ([]byte, error) {
+	stateMu.RLock()
+	k := writeKey
+	stateMu.RUnlock()
+	if k == nil {
+		return data, nil
+	}
+	return k.Seal(data)
+}
+
+// Decode расшифровывает данные любым из ключей чтения; открытые данные
+// возвращаются как есть
+func Decode(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	stateMu.RLock()
+	keys := readKeys
+	stateMu.RUnlock()
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	for _, k := range keys {
+		if plain, err := k.Open(data); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, ErrWrongKey
+}
+
+// EncodeLine шифрует строку журнала: результат — одна строка без переводов строк
+func EncodeLine(line []byte) ([]byte, error) {
+	if !Enabled() {
+		return line, nil
+	}
+	sealed, err := Encode(line)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(linePrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, linePrefix)
+	base64.StdEncoding.Encode(out[len(linePrefix):], sealed)
+	return out, nil
+}
+
+// DecodeLine расшифровывает строку, записанную EncodeLine
+func DecodeLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, []byte(linePrefix)) {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(line[len(linePrefix):]))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return Decode(sealed)
+}
+
+// IndexValue значение для ключа индекса: при шифровании — HMAC значения,
+// чтобы индекс не раскрывал текст запросов
+func IndexValue(value string) string {
+	stateMu.RLock()
+	k := writeKey
+	stateMu.RUnlock()
+	if k == nil {
+		return value
+	}
+	index, err := k.indexKey()
+	if err != nil {
+		return value
+	}
+	mac := hmac.New(sha256.New, index)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// FileMode права на файл: зашифрованные данные доступны только владельцу
+func FileMode(perm os.FileMode) os.FileMode {
+	if Enabled() {
+		return 0600
+	}
+	return perm
+}
+
+// ReadFile читает файл и расшифровывает его при необходимости
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
+
+// WriteFile записывает файл, шифруя его при включенном шифровании.
+// Зашифрованный файл создается с правами 0600.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	out, err := Encode(data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, FileMode(perm)); err != nil {
+		return err
+	}
+	// os.WriteFile не меняет права существующего файла
+	return Protect(path)
+}
+
+// Protect при включенном шифровании оставляет доступ к файлу только владельцу
+func Protect(path string) error {
+	if !Enabled() {
+		return nil
+	}
+	return os.Chmod(path, 0600)
+}
+
+// IsEncryptedFile проверяет, зашифрован ли файл
+func IsEncryptedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	head := make([]byte, len(Magic))
+	n, _ := file.Read(head)
+	return IsEncrypted(head[:n]), nil
+}
+
+// GenerateKey создает случайный ключ (32 байта в hex) для файла ключа
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
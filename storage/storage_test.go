@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, _ := NewKey([]byte("secret\n"))
+	other, _ := NewKey([]byte("other"))
+	plain := []byte("ssh root@db-01.internal")
+
+	sealed, err := key.Seal(plain)
+	if err != nil || !IsEncrypted(sealed) || bytes.Contains(sealed, plain) {
+		t.Fatalf("Seal: %q, %v", sealed, err)
+	}
+	if got, err := key.Open(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("Open: %q, %v", got, err)
+	}
+	if _, err := other.Open(sealed); err != ErrWrongKey {
+		t.Errorf("wrong key must fail: %v", err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := key.Open(sealed); err != ErrWrongKey {
+		t.Errorf("tampered data must fail: %v", err)
+	}
+	if _, err := NewKey([]byte("  ")); err == nil {
+		t.Error("empty key must fail")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	defer Use(nil)
+	oldKey, _ := NewKey([]byte("old"))
+	newKey, _ := NewKey([]byte("new"))
+	path := filepath.Join(t.TempDir(), "result.md")
+
+	// Открытый файл читается как есть
+	os.WriteFile(path, []byte("plain"), 0644)
+	Use(oldKey)
+	if data, err := ReadFile(path); err != nil || string(data) != "plain" {
+		t.Errorf("plain read: %q, %v", data, err)
+	}
+
+	if err := WriteFile(path, []byte("hidden"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	if info, _ := os.Stat(path); !IsEncrypted(raw) || info.Mode().Perm() != 0600 {
+		t.Errorf("file must be encrypted with 0600: %q, %v", raw, info.Mode())
+	}
+
+	// Смена ключа: читаем старым, пишем новым
+	Use(newKey, oldKey)
+	data, err := ReadFile(path)
+	if err != nil || string(data) != "hidden" {
+		t.Fatalf("read with old key: %q, %v", data, err)
+	}
+	WriteFile(path, data, 0644)
+	Use(oldKey)
+	if _, err := ReadFile(path); err == nil {
+		t.Error("old key must not read rekeyed file")
+	}
+	Use(nil)
+	if _, err := ReadFile(path); err == nil {
+		t.Error("encrypted file without key must fail")
+	}
+
+	Use(newKey)
+	line, _ := EncodeLine([]byte(`{"op":"put"}`))
+	if bytes.ContainsAny(line, "\n{") {
+		t.Errorf("encoded line: %q", line)
+	}
+	if got, err := DecodeLine(line); err != nil || string(got) != `{"op":"put"}` {
+		t.Errorf("DecodeLine: %q, %v", got, err)
+	}
+	if IndexValue("ls") == "ls" || IndexValue("ls") != IndexValue("ls") {
+		t.Error("index value must be a stable HMAC")
+	}
+}
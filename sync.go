@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+
+	cmdPackage "github.com/direct-dev-ru/linux-command-gpt/cmd"
+	"github.com/direct-dev-ru/linux-command-gpt/config"
+	"github.com/direct-dev-ru/linux-command-gpt/gitsync"
+	"github.com/direct-dev-ru/linux-command-gpt/gpt"
+	"github.com/urfave/cli/v2"
+)
+
+// syncKindNames названия видов данных для вывода
+var syncKindNames = map[string]string{
+	"history": "История",
+	"results": "Результаты",
+	"prompts": "Промпты",
+}
+
+// executeSync синхронизирует историю, результаты и промпты через git-репозиторий
+// и выводит отчет; со skipHistory (--no-history, LCG_NO_HISTORY) история не
+// синхронизируется
+func executeSync(remote string, dryRun, skipHistory bool) error {
+	opts := gitsync.Options{Config: config.AppConfig.Sync, DryRun: dryRun}
+	if remote != "" {
+		opts.Config.Remote = remote
+	}
+	if opts.Config.Remote == "" {
+		return cli.Exit("Репозиторий синхронизации не задан: укажите LCG_SYNC_REMOTE или --remote", exitValidation)
+	}
+	if !skipHistory {
+		store, err := cmdPackage.OpenHistory(config.AppConfig.ResultHistory)
+		if err != nil {
+			return cli.Exit(err.Error(), exitGeneral)
+		}
+		opts.Store = store
+	}
+	if opts.Config.Results {
+		opts.ResultFolder = config.AppConfig.ResultFolder
+	}
+	if opts.Config.Prompts {
+		if currentUser, err := user.Current(); err == nil {
+			opts.Prompts = gpt.NewPromptManager(currentUser.HomeDir)
+		}
+	}
+
+	if dryRun {
+		printColored("🔍 Пробный запуск: данные и репозиторий не меняются\n", colorYellow)
+	}
+	report, err := gitsync.Run(opts)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка синхронизации: %v", err), exitGeneral)
+	}
+
+	total := 0
+	for _, kind := range report.Kinds {
+		total += kind.Total()
+		line := fmt.Sprintf("%s: ⬇️  %d  ⬆️  %d  🔀 %d", syncKindNames[kind.Name], kind.Pulled, kind.Pushed, kind.Merged)
+		if deleted := kind.DeletedLocal + kind.DeletedRemote; deleted > 0 {
+			line += fmt.Sprintf("  🗑️  %d", deleted)
+		}
+		if kind.Excluded > 0 {
+			line += fmt.Sprintf("  (не отправлено по тегам %s: %d)", opts.Config.ExcludeTags, kind.Excluded)
+		}
+		fmt.Println(line)
+	}
+	switch {
+	case total == 0:
+		printColored("✅ Данные уже синхронизированы\n", colorGreen)
+	case !dryRun:
+		printColored(fmt.Sprintf("✅ Синхронизировано с %s\n", opts.Config.Remote), colorGreen)
+	}
+	return nil
+}